@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/lib/pq"
+	"github.com/sunil206b/smart_booking/internal/config"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/driver"
+	"github.com/sunil206b/smart_booking/internal/importer"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/repository/dbrepo"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// runImport imports the reservations in a CSV file from the command line, like an upload in the admin tool:
+//
+//	web import [-dry-run] [-errors failed.csv] [-currency USD] [-property 1] reservations.csv
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "Check the file without importing anything")
+	errorsFile := flags.String("errors", "", "File the rows which fail are written to")
+	baseCurrency := flags.String("currency", currency.DefaultBase, "Base currency the amounts in the file are in")
+	propertyID := flags.Int("property", 0, "ID of the property whose rooms the file books, 0 for every property")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New("usage: web import [-dry-run] [-errors file] [-currency code] [-property id] reservations.csv")
+	}
+	if _, err := currency.NewConverter(*baseCurrency); err != nil {
+		return err
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	pgURL, err := pq.ParseURL(os.Getenv("ELEPHANTSQL_URL"))
+	if err != nil {
+		return errors.New(fmt.Sprintf("failed to parse Elephant SQL URL %v\n", err))
+	}
+	db, err := driver.ConnectPQSQL(pgURL)
+	if err != nil {
+		return errors.New(fmt.Sprintf("failed to connect Elephant SQL %v\n", err))
+	}
+	defer db.SQL.Close()
+	repo := dbrepo.NewPostgreRepo(db.SQL, &config.AppConfig{})
+
+	var errs bytes.Buffer
+	result, err := importer.Import(file, repo, *baseCurrency, *propertyID, *dryRun, &errs)
+	if err != nil {
+		return err
+	}
+	run := models.ImportRun{
+		FileName: filepath.Base(flags.Arg(0)),
+		DryRun:   *dryRun,
+		Rows:     result.Rows,
+		Imported: result.Imported,
+		Failed:   result.Failed,
+	}
+	if result.Failed > 0 {
+		run.Errors = errs.Bytes()
+	}
+	if err = repo.CreateImportRun(&run); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Printf("Dry run of %d rows: %d can be imported, %d failed\n", result.Rows, result.Imported, result.Failed)
+	} else {
+		fmt.Printf("%d of %d reservations imported, %d failed\n", result.Imported, result.Rows, result.Failed)
+	}
+	if result.Failed == 0 {
+		return nil
+	}
+	if *errorsFile == "" {
+		_, err = os.Stdout.Write(errs.Bytes())
+		return err
+	}
+	if err = ioutil.WriteFile(*errorsFile, errs.Bytes(), 0644); err != nil {
+		return err
+	}
+	fmt.Printf("The rows which failed were written to %s\n", *errorsFile)
+	return nil
+}
@@ -9,14 +9,20 @@ import (
 	"github.com/lib/pq"
 	"github.com/subosito/gotenv"
 	"github.com/sunil206b/smart_booking/internal/config"
+	"github.com/sunil206b/smart_booking/internal/currency"
 	"github.com/sunil206b/smart_booking/internal/driver"
 	"github.com/sunil206b/smart_booking/internal/handlers"
 	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/payments"
+	"github.com/sunil206b/smart_booking/internal/property"
 	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/waitlist"
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -32,6 +38,13 @@ func init() {
 }
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := runImport(os.Args[2:]); err != nil {
+			log.Fatalf("Failed to import with error %v\n", err)
+		}
+		return
+	}
+
 	db, err := run()
 	if err != nil {
 		log.Fatalf("Failed to run with error %v\n", err)
@@ -42,6 +55,12 @@ func main() {
 	log.Println("Starting mail channel listener....")
 	listenForMail()
 
+	log.Println("Starting sweepers....")
+	startSweeper("expired holds", holdSweepInterval, handlers.Handler.DeleteExpiredHolds)
+	startSweeper("waitlist offers", waitlistSweepInterval, handlers.Handler.ExpireWaitlistOffers)
+	startSweeper("old stays", retentionSweepInterval, handlers.Handler.AnonymiseOldStays)
+	startSweeper("housekeeping tasks", housekeepingSweepInterval, handlers.Handler.GenerateHousekeepingTasks)
+
 	srv := &http.Server{
 		Handler:      routes(&appConfig),
 		Addr:         ":80",
@@ -59,11 +78,19 @@ func run() (*driver.DB, error) {
 	gob.Register(models.Room{})
 	gob.Register(models.RoomRestriction{})
 	gob.Register(models.Restriction{})
-	gob.Register(map[string]int{})
+	gob.Register(models.WaitlistEntry{})
 
 	// Read flags
 	inProduction := flag.Bool("production", true, "Application is in production")
 	useCache := flag.Bool("cache", true, "Use Template Cache")
+	baseCurrency := flag.String("currency", currency.DefaultBase, "Base currency prices and reservations are kept in")
+	paymentProvider := flag.String("payments", "none", "Payment gateway taking deposits (fake, none)")
+	hotelName := flag.String("hotel", "Fort Smythe", "Name of the hotel printed on invoices")
+	siteURL := flag.String("url", "http://localhost", "Address of the site used for links in emails")
+	holdTTL := flag.Duration("hold", 15*time.Minute, "How long a room is held for a guest filling in the reservation form")
+	waitlistOfferTTL := flag.Duration("waitlist-offer", waitlist.DefaultOfferTTL, "How long booking links sent to waitlisted guests work for")
+	retentionMonths := flag.Int("retention-months", 0, "Months after check-out the guest details of stays are anonymised (0 keeps them)")
+	depositPolicy := flag.String("deposit", "percent:30", "Deposit taken when booking (first-night, percent:N, none)")
 	//dbHost := flag.String("dbhost", "localhost", "Database host")
 	//dbName := flag.String("dbname", "", "Database name")
 	//dbUser := flag.String("dbuser", "", "Database user")
@@ -83,6 +110,31 @@ func run() (*driver.DB, error) {
 	//Change this to true when in the production
 	appConfig.InProduction = *inProduction
 	appConfig.UseCache = *useCache
+	appConfig.HotelName = *hotelName
+	appConfig.SiteURL = strings.TrimSuffix(*siteURL, "/")
+	appConfig.HoldTTL = *holdTTL
+	appConfig.WaitlistOfferTTL = *waitlistOfferTTL
+	if *retentionMonths < 0 {
+		return nil, errors.New("retention-months cannot be negative")
+	}
+	appConfig.RetentionMonths = *retentionMonths
+
+	converter, err := currency.NewConverter(*baseCurrency)
+	if err != nil {
+		return nil, err
+	}
+	appConfig.Currency = converter
+	appConfig.Properties = property.NewDirectory()
+
+	appConfig.Deposit, err = payments.ParseDepositPolicy(*depositPolicy)
+	if err != nil {
+		return nil, err
+	}
+	appConfig.Payments, err = newPaymentProvider(*paymentProvider, os.Getenv("PAYMENT_WEBHOOK_SECRET"),
+		appConfig.InProduction)
+	if err != nil {
+		return nil, err
+	}
 
 	infoLog = log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
 	appConfig.InfoLog = infoLog
@@ -111,6 +163,11 @@ func run() (*driver.DB, error) {
 		return nil, errors.New(fmt.Sprintf("failed to connect Elephant SQL %v\n", err))
 	}
 
+	err = i18n.LoadCatalogs("./translations")
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error while loading translations: %v\n", err))
+	}
+
 	tc, err := render.CreateTemplateCache()
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("error while creating template cache: %v\n", err))
@@ -121,6 +178,33 @@ func run() (*driver.DB, error) {
 	rhHandler := handlers.NewRouteHandler(&appConfig, db)
 	handlers.NewHandler(rhHandler)
 
+	err = rhHandler.LoadExchangeRates()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error while loading exchange rates: %v\n", err))
+	}
+	err = rhHandler.LoadProperties()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error while loading properties: %v\n", err))
+	}
+
 	helpers.NewHelpers(&appConfig)
 	return db, nil
 }
+
+// newPaymentProvider creates the payment gateway with the given name, signing its webhooks with secret. The fake
+// gateway marks deposits as paid without taking any money, so it cannot be used in production.
+func newPaymentProvider(name, secret string, inProduction bool) (payments.PaymentProvider, error) {
+	switch name {
+	case "fake":
+		if inProduction {
+			return nil, errors.New("the fake payment gateway cannot be used in production")
+		}
+		if secret == "" {
+			return nil, errors.New("PAYMENT_WEBHOOK_SECRET must be set to sign the webhooks of the payment gateway")
+		}
+		return payments.NewFakeProvider(secret), nil
+	case "none", "":
+		return nil, nil
+	}
+	return nil, errors.New(fmt.Sprintf("unknown payment gateway %q", name))
+}
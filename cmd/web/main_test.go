@@ -8,3 +8,29 @@ func TestRun(t *testing.T) {
 		t.Error("Failed run()")
 	}
 }
+
+func TestNewPaymentProvider(t *testing.T) {
+	var tests = []struct {
+		name         string
+		provider     string
+		secret       string
+		inProduction bool
+		expErr       bool
+		expGateway   bool
+	}{
+		{"none", "none", "", true, false, false},
+		{"fake", "fake", "secret", false, false, true},
+		{"fake in production", "fake", "secret", true, true, false},
+		{"fake without secret", "fake", "", false, true, false},
+		{"unknown", "paypal", "secret", false, true, false},
+	}
+	for _, e := range tests {
+		gateway, err := newPaymentProvider(e.provider, e.secret, e.inProduction)
+		if (err != nil) != e.expErr {
+			t.Errorf("for %s, expected error %v but got %v", e.name, e.expErr, err)
+		}
+		if (gateway != nil) != e.expGateway {
+			t.Errorf("for %s, expected a gateway %v but got %v", e.name, e.expGateway, gateway)
+		}
+	}
+}
@@ -3,12 +3,16 @@ package main
 import (
 	"github.com/justinas/nosurf"
 	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/property"
 	"net/http"
+	"strings"
 )
 
-// NoSurf adds CSRF protection to all POST requests
+// NoSurf adds CSRF protection to all POST requests except the payment gateway webhooks, which are signed instead
 func NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
+	csrfHandler.ExemptPath("/payments/webhook")
 
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
@@ -19,18 +23,103 @@ func NoSurf(next http.Handler) http.Handler {
 	return csrfHandler
 }
 
-// SessionLoad loads and saves the session on every request
+// streamedPaths are downloads sent as they are written. The session buffers the whole response to save itself, so
+// it is only read for these paths, and changes made to it are not saved.
+var streamedPaths = []string{"/admin/reservations-export"}
+
+// SessionLoad loads and saves the session on every request, only loading it for streamed downloads
 func SessionLoad(next http.Handler) http.Handler {
-	return session.LoadAndSave(next)
+	loadAndSave := session.LoadAndSave(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for _, p := range streamedPaths {
+			if r.URL.Path == p {
+				readSession(next, w, r)
+				return
+			}
+		}
+		loadAndSave.ServeHTTP(w, r)
+	})
+}
+
+// readSession loads the session of the request without saving it afterwards
+func readSession(next http.Handler, w http.ResponseWriter, r *http.Request) {
+	token := ""
+	if cookie, err := r.Cookie(session.Cookie.Name); err == nil {
+		token = cookie.Value
+	}
+	ctx, err := session.Load(r.Context(), token)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	next.ServeHTTP(w, r.WithContext(ctx))
 }
 
 func Auth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if !helpers.IsAuthenticated(r) {
-			session.Put(r.Context(), "error", "Login first")
+			session.Put(r.Context(), "error", i18n.T(i18n.FromContext(r.Context()), "login.required"))
 			http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 			return
 		}
 		next.ServeHTTP(w, r)
 	})
 }
+
+// Locale picks the locale of the request from the URL prefix, the language cookie or the Accept-Language header,
+// in that order. A locale prefix such as /es is stripped before routing and remembered in the language cookie.
+func Locale(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locale, path := i18n.SplitPath(r.URL.Path)
+		if locale != "" {
+			http.SetCookie(w, i18n.Cookie(locale, appConfig.InProduction))
+			r = stripPrefix(r, "/"+locale, path)
+		} else if c, err := r.Cookie(i18n.CookieName); err == nil && i18n.Supported(c.Value) {
+			locale = c.Value
+		} else {
+			locale = i18n.Negotiate(r.Header.Get("Accept-Language"))
+		}
+		next.ServeHTTP(w, r.WithContext(i18n.WithLocale(r.Context(), locale)))
+	})
+}
+
+// Property picks the property of the request from the host name, the /p/{slug} URL prefix or the property cookie, in
+// that order. A property prefix is stripped before routing and remembered in the cookie. Requests without a property
+// search and show every property.
+func Property(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p, ok := appConfig.Properties.ByHost(r.Host)
+		if slug, path := property.SplitPath(r.URL.Path); slug != "" {
+			if !ok {
+				p, ok = appConfig.Properties.BySlug(slug)
+				if !ok {
+					http.NotFound(w, r)
+					return
+				}
+				http.SetCookie(w, property.Cookie(slug, appConfig.InProduction))
+			}
+			r = stripPrefix(r, "/p/"+slug, path)
+		} else if c, err := r.Cookie(property.CookieName); err == nil && !ok {
+			p, ok = appConfig.Properties.BySlug(c.Value)
+		}
+		if ok {
+			r = r.WithContext(property.WithProperty(r.Context(), p))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// stripPrefix returns a copy of the request with a prefix removed from its URL, leaving path
+func stripPrefix(r *http.Request, prefix, path string) *http.Request {
+	r2 := new(http.Request)
+	*r2 = *r
+	u := *r.URL
+	u.Path = path
+	u.RawPath = ""
+	r2.URL = &u
+	r2.RequestURI = strings.TrimPrefix(r.RequestURI, prefix)
+	if r2.RequestURI == "" || r2.RequestURI[0] != '/' {
+		r2.RequestURI = "/" + r2.RequestURI
+	}
+	return r2
+}
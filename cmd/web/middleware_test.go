@@ -2,7 +2,12 @@ package main
 
 import (
 	"fmt"
+	"github.com/alexedwards/scs/v2"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/property"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 )
 
@@ -29,3 +34,115 @@ func TestSessionLoad(t *testing.T) {
 		t.Error(fmt.Sprintf("type is not http.Handler, but is %T\n", v))
 	}
 }
+
+func TestSessionLoad_Streamed(t *testing.T) {
+	session = scs.New()
+	for _, e := range []struct {
+		path        string
+		expStreamed bool
+	}{
+		{"/admin/reservations-export", true},
+		{"/admin/reservations-all", false},
+	} {
+		rr := httptest.NewRecorder()
+		streamed := false
+		h := SessionLoad(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			session.Put(r.Context(), "user_id", 1)
+			w.Write([]byte("id,name\n"))
+			streamed = rr.Body.Len() > 0
+		}))
+		h.ServeHTTP(rr, httptest.NewRequest("GET", e.path, nil))
+
+		if streamed != e.expStreamed {
+			t.Errorf("for %s, expected the response to be streamed to be %v", e.path, e.expStreamed)
+		}
+		if saved := rr.Header().Get("Set-Cookie") != ""; saved == e.expStreamed {
+			t.Errorf("for %s, expected the session to be saved to be %v", e.path, !e.expStreamed)
+		}
+	}
+}
+
+func TestLocale(t *testing.T) {
+	if err := i18n.LoadCatalogs("../../translations"); err != nil {
+		t.Fatal(err)
+	}
+	var tests = []struct {
+		name      string
+		path      string
+		cookie    string
+		header    string
+		expLocale string
+		expPath   string
+	}{
+		{"prefix", "/es/search-availability", "fr", "fr", "es", "/search-availability"},
+		{"cookie", "/search-availability", "fr", "es", "fr", "/search-availability"},
+		{"header", "/about", "", "es-ES,es;q=0.9", "es", "/about"},
+		{"default", "/about", "xx", "", i18n.DefaultLocale, "/about"},
+	}
+	for _, e := range tests {
+		var gotLocale, gotPath string
+		h := Locale(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			gotLocale = i18n.FromContext(r.Context())
+			gotPath = r.URL.Path
+		}))
+		req := httptest.NewRequest("GET", e.path, nil)
+		if e.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: i18n.CookieName, Value: e.cookie})
+		}
+		req.Header.Set("Accept-Language", e.header)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		if gotLocale != e.expLocale || gotPath != e.expPath {
+			t.Errorf("for %s, expected %s %s but got %s %s", e.name, e.expLocale, e.expPath, gotLocale, gotPath)
+		}
+	}
+}
+
+func TestProperty(t *testing.T) {
+	appConfig.Properties = property.NewDirectory()
+	appConfig.Properties.Set([]models.Property{
+		{ID: 1, Slug: "fort-smythe", Name: "Fort Smythe"},
+		{ID: 2, Slug: "harbour-view", Name: "Harbour View", Hostname: "harbourview.com"},
+	})
+	var tests = []struct {
+		name      string
+		host      string
+		path      string
+		cookie    string
+		expStatus int
+		expID     int
+		expPath   string
+		expCookie bool
+	}{
+		{"host", "harbourview.com:8080", "/search-availability", "fort-smythe", http.StatusOK, 2, "/search-availability", false},
+		{"prefix", "example.com", "/p/fort-smythe/search-availability", "", http.StatusOK, 1, "/search-availability", true},
+		{"prefix root", "example.com", "/p/harbour-view", "", http.StatusOK, 2, "/", true},
+		{"unknown prefix", "example.com", "/p/nowhere/about", "", http.StatusNotFound, 0, "", false},
+		{"cookie", "example.com", "/about", "harbour-view", http.StatusOK, 2, "/about", false},
+		{"none", "example.com", "/about", "nowhere", http.StatusOK, 0, "/about", false},
+	}
+	for _, e := range tests {
+		gotID, gotPath := 0, ""
+		h := Property(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if p, ok := property.FromContext(r.Context()); ok {
+				gotID = p.ID
+			}
+			gotPath = r.URL.Path
+		}))
+		req := httptest.NewRequest("GET", e.path, nil)
+		req.Host = e.host
+		if e.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: property.CookieName, Value: e.cookie})
+		}
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus || gotID != e.expID || gotPath != e.expPath {
+			t.Errorf("for %s, expected %d %d %s but got %d %d %s", e.name, e.expStatus, e.expID, e.expPath, rr.Code,
+				gotID, gotPath)
+		}
+		if set := rr.Header().Get("Set-Cookie") != ""; set != e.expCookie {
+			t.Errorf("for %s, expected the property cookie to be set %v but got %v", e.name, e.expCookie, set)
+		}
+	}
+}
@@ -15,12 +15,17 @@ func routes(app *config.AppConfig) http.Handler {
 
 	router := chi.NewRouter()
 	router.Use(middleware.Recoverer)
+	router.Use(Locale)
+	router.Use(Property)
 	router.Use(NoSurf)
 	router.Use(SessionLoad)
 
 	router.Get("/", handlers.Handler.Home)
 	router.Get("/about", handlers.Handler.About)
 	router.Get("/contact", handlers.Handler.Contact)
+	router.Get("/set-language/{locale}", handlers.Handler.SetLanguage)
+	router.Get("/set-currency/{code}", handlers.Handler.SetCurrency)
+	router.Get("/set-property/{slug}", handlers.Handler.SetProperty)
 
 	router.Get("/generals-quarters", handlers.Handler.Generals)
 	router.Get("/majors-suite", handlers.Handler.Majors)
@@ -28,12 +33,22 @@ func routes(app *config.AppConfig) http.Handler {
 	router.Get("/search-availability", handlers.Handler.Availability)
 	router.Post("/search-availability", handlers.Handler.PostAvailability)
 	router.Post("/search-availability-json", handlers.Handler.AvailabilityJSON)
+	router.Get("/availability-calendar-json", handlers.Handler.AvailabilityCalendarJSON)
 	router.Get("/choose-room/{id}", handlers.Handler.ChooseRoom)
 	router.Get("/book-room", handlers.Handler.BookRoom)
+	router.Get("/waitlist", handlers.Handler.Waitlist)
+	router.Post("/waitlist", handlers.Handler.PostWaitlist)
+	router.Get("/waitlist/book/{token}", handlers.Handler.WaitlistBook)
 
 	router.Get("/make-reservations", handlers.Handler.Reservation)
 	router.Post("/make-reservations", handlers.Handler.PostReservation)
+	router.Get("/make-reservations/cancel", handlers.Handler.ReleaseHold)
 	router.Get("/reservation-summary", handlers.Handler.ReservationSummary)
+	router.Get("/reservation-payment", handlers.Handler.ReservationPayment)
+	router.Get("/reservation-payment/return", handlers.Handler.ReservationPaymentReturn)
+	router.Post("/payments/webhook", handlers.Handler.PaymentWebhook)
+	router.Get("/payments/fake/checkout/{reference}", handlers.Handler.FakeCheckout)
+	router.Post("/payments/fake/checkout/{reference}", handlers.Handler.PostFakeCheckout)
 
 	router.Get("/user/login", handlers.Handler.Login)
 	router.Post("/user/login", handlers.Handler.PostLogin)
@@ -44,16 +59,75 @@ func routes(app *config.AppConfig) http.Handler {
 
 	router.Route("/admin", func(r chi.Router) {
 		r.Use(Auth)
+		r.Use(handlers.Handler.StaffProperty)
 		r.Get("/dashboard", handlers.Handler.AdminDashBoard)
+		r.Post("/property", handlers.Handler.AdminSetProperty)
 		r.Get("/reservations-new", handlers.Handler.AdminNewReservations)
 		r.Get("/reservations-all", handlers.Handler.AdminAllReservations)
+		r.Get("/reservations-today", handlers.Handler.AdminFrontDesk)
+		r.Get("/reservations-export", handlers.Handler.AdminExportReservations)
+		r.Get("/reservations-import", handlers.Handler.AdminImportReservations)
+		r.Post("/reservations-import", handlers.Handler.AdminPostImportReservations)
+		r.Get("/imports/{id}/errors", handlers.Handler.AdminImportErrors)
 		r.Get("/reservations-calender", handlers.Handler.AdminReservationsCalender)
 		r.Post("/reservations-calender", handlers.Handler.AdminPostReservationsCalender)
+		r.Post("/reservations/{id}/move", handlers.Handler.AdminMoveReservation)
+		r.Post("/blocks", handlers.Handler.AdminPostBlocks)
 		r.Get("/process-reservation/{src}/{id}/do", handlers.Handler.AdminProcessReservation)
 		r.Get("/delete-reservation/{src}/{id}/do", handlers.Handler.AdminDeleteReservation)
 
+		r.Get("/housekeeping", handlers.Handler.AdminHousekeeping)
+		r.Post("/housekeeping/generate", handlers.Handler.AdminGenerateHousekeepingTasks)
+		r.Post("/housekeeping/rooms/{id}", handlers.Handler.AdminPostRoomHousekeeping)
+		r.Post("/housekeeping/tasks/{id}", handlers.Handler.AdminPostHousekeepingTask)
+
+		r.Get("/guests", handlers.Handler.AdminGuests)
+		r.Get("/guests/{id}", handlers.Handler.AdminShowGuest)
+		r.Post("/guests/{id}", handlers.Handler.AdminPostGuest)
+		r.Post("/guests/{id}/merge", handlers.Handler.AdminMergeGuest)
+		r.Get("/privacy", handlers.Handler.AdminPrivacy)
+		r.Get("/privacy/export", handlers.Handler.AdminExportGuestData)
+		r.Post("/privacy/erase", handlers.Handler.AdminEraseGuestData)
+
 		r.Get("/reservations/{src}/{id}/show", handlers.Handler.AdminShowReservation)
 		r.Post("/reservations/{src}/{id}", handlers.Handler.AdminPostShowReservation)
+		r.Post("/reservations/{src}/{id}/folio", handlers.Handler.AdminPostFolioItem)
+		r.Get("/reservations/{src}/{id}/folio/{item}/delete", handlers.Handler.AdminDeleteFolioItem)
+		r.Post("/reservations/{src}/{id}/invoices", handlers.Handler.AdminCreateInvoice)
+		r.Post("/reservations/{src}/{id}/check-in", handlers.Handler.AdminCheckIn)
+		r.Post("/reservations/{src}/{id}/check-out", handlers.Handler.AdminCheckOut)
+		r.Get("/invoices/{id}/pdf", handlers.Handler.AdminInvoicePDF)
+		r.Post("/invoices/{id}/email", handlers.Handler.AdminEmailInvoice)
+
+		r.Get("/room-rules", handlers.Handler.AdminRoomRules)
+		r.Post("/room-rules", handlers.Handler.AdminPostRoomRule)
+		r.Get("/delete-room-rule/{id}/do", handlers.Handler.AdminDeleteRoomRule)
+
+		r.Get("/tax-rules", handlers.Handler.AdminTaxRules)
+		r.Post("/tax-rules", handlers.Handler.AdminPostTaxRule)
+		r.Get("/delete-tax-rule/{id}/do", handlers.Handler.AdminDeleteTaxRule)
+
+		r.Get("/promo-codes", handlers.Handler.AdminPromoCodes)
+		r.Post("/promo-codes", handlers.Handler.AdminPostPromoCode)
+		r.Get("/delete-promo-code/{id}/do", handlers.Handler.AdminDeletePromoCode)
+		r.Get("/extras", handlers.Handler.AdminExtras)
+		r.Post("/extras", handlers.Handler.AdminPostExtra)
+		r.Get("/extras/{id}/{state}", handlers.Handler.AdminToggleExtra)
+
+		r.Get("/properties", handlers.Handler.AdminProperties)
+		r.Post("/properties", handlers.Handler.AdminPostProperty)
+		r.Get("/properties/{id}", handlers.Handler.AdminShowProperty)
+		r.Post("/properties/{id}", handlers.Handler.AdminPostProperty)
+		r.Post("/rooms/{id}/property", handlers.Handler.AdminPostRoomProperty)
+		r.Post("/staff/{id}/property", handlers.Handler.AdminPostStaffProperty)
+
+		r.Get("/exchange-rates", handlers.Handler.AdminExchangeRates)
+		r.Post("/exchange-rates", handlers.Handler.AdminPostExchangeRate)
+		r.Post("/exchange-rates/import", handlers.Handler.AdminImportExchangeRates)
+		r.Get("/delete-exchange-rate/{code}/do", handlers.Handler.AdminDeleteExchangeRate)
+
+		r.Post("/payments/{id}/capture", handlers.Handler.AdminCapturePayment)
+		r.Post("/payments/{id}/refund", handlers.Handler.AdminRefundPayment)
 	})
 	return router
 }
@@ -2,6 +2,8 @@ package main
 
 import (
 	"fmt"
+	"github.com/sunil206b/smart_booking/internal/handlers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"github.com/sunil206b/smart_booking/internal/models"
 	mail "github.com/xhit/go-simple-mail/v2"
 	"io/ioutil"
@@ -37,7 +39,7 @@ func sendMsg(m *models.MailData) {
 	if m.Template == "" {
 		email.SetBody(mail.TextHTML, m.Content)
 	} else {
-		data, err := ioutil.ReadFile(fmt.Sprintf("./email-templates/%s", m.Template))
+		data, err := readMailTemplate(m.Template, m.Locale)
 		if err != nil {
 			appConfig.ErrorLog.Println(err)
 		}
@@ -45,11 +47,41 @@ func sendMsg(m *models.MailData) {
 		msgToSend := strings.Replace(mailTemplate, "[%body%]", m.Content, 1)
 		email.SetBody(mail.TextHTML, msgToSend)
 	}
+	for _, a := range m.Attachments {
+		email.Attach(&mail.File{Name: a.Name, MimeType: a.ContentType, Data: a.Data})
+	}
 
+	sent := models.SentEmail{Recipient: m.To, Subject: m.Subject, Content: m.Content, Template: m.Template,
+		Status: models.EmailStatusSent, CreatedAt: time.Now()}
 	err = email.Send(client)
 	if err != nil {
 		errorLog.Println(err)
+		sent.Status = models.EmailStatusFailed
 	} else {
 		log.Println("Email Sent")
 	}
+	recordSentEmail(&sent)
+}
+
+// recordSentEmail keeps a copy of an email so it is included in the data exported for or erased with its recipient
+func recordSentEmail(sent *models.SentEmail) {
+	if handlers.Handler == nil {
+		return
+	}
+	err := handlers.Handler.DB.CreateSentEmail(sent)
+	if err != nil {
+		errorLog.Println(err)
+	}
+}
+
+// readMailTemplate reads the email template translated into the locale from ./email-templates/<locale>,
+// falling back to the untranslated template in ./email-templates
+func readMailTemplate(name, locale string) ([]byte, error) {
+	if locale != "" && locale != i18n.DefaultLocale {
+		data, err := ioutil.ReadFile(fmt.Sprintf("./email-templates/%s/%s", locale, name))
+		if err == nil {
+			return data, nil
+		}
+	}
+	return ioutil.ReadFile(fmt.Sprintf("./email-templates/%s", name))
 }
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	// holdSweepInterval is how often expired room holds are deleted
+	holdSweepInterval = time.Minute
+	// waitlistSweepInterval is how often expired waitlist booking links are looked for, passing their room on to the
+	// next guest waiting
+	waitlistSweepInterval = time.Minute
+	// retentionSweepInterval is how often the guest details of stays older than the retention period are anonymised
+	retentionSweepInterval = 24 * time.Hour
+	// housekeepingSweepInterval is how often the housekeeping tasks of the day are made for new departures and
+	// stayovers
+	housekeepingSweepInterval = time.Hour
+)
+
+// startSweeper runs a clean up job in the background at every interval, logging its failures
+func startSweeper(name string, interval time.Duration, sweep func(now time.Time) error) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			err := sweep(now)
+			if err != nil {
+				errorLog.Printf("failed to sweep %s: %v\n", name, err)
+			}
+		}
+	}()
+}
@@ -124,4 +124,425 @@ insert into restrictions(restriction_name, created_at, updated_at) values ('Bloc
 insert into room_restrictions(start_date, end_date, created_at, updated_at, room_id, restriction_id)
  values ($1, $2, $3,$4, $5, $6);
 
-delete from room_restrictions where id = $1;
\ No newline at end of file
+delete from room_restrictions where id = $1;
+
+create table room_rules(
+    id serial primary key,
+    room_id INT not null,
+    start_date DATE not null,
+    end_date DATE not null,
+    min_stay INT not null default 0,
+    max_stay INT not null default 0,
+    closed_to_arrival BOOLEAN not null default false,
+    closed_to_departure BOOLEAN not null default false,
+    min_advance_days INT not null default 0,
+    max_advance_days INT not null default 0,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    foreign key(room_id) references rooms(id) on delete cascade
+);
+
+create INDEX idx_room_rules_room_id ON room_rules(room_id);
+create INDEX idx_room_rules_dates ON room_rules(start_date, end_date);
+
+select id, room_id, start_date, end_date, min_stay, max_stay, closed_to_arrival, closed_to_departure,
+min_advance_days, max_advance_days from room_rules where start_date <= $2 and end_date >= $1;
+
+-- nightly price of the room in cents
+ALTER TABLE rooms ADD COLUMN price integer not null default 0;
+
+select r.id, r.room_name, r.price, d.night,
+exists(select 1 from room_restrictions rr where rr.room_id = r.id and rr.start_date <= d.night and rr.end_date > d.night)
+from rooms r
+cross join (select generate_series('2021-07-01'::date, '2021-09-29'::date - 1, interval '1 day')::date as night) d
+order by r.room_name, d.night;
+
+-- units of the currency bought by one unit of the base currency of the property
+create table exchange_rates(
+    id serial primary key,
+    currency_code VARCHAR(3) not null unique,
+    rate NUMERIC(18, 8) not null check (rate > 0),
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+insert into exchange_rates(currency_code, rate, created_at, updated_at) values('EUR', 0.9, now(), now())
+on conflict (currency_code) do update set rate = excluded.rate, updated_at = excluded.updated_at;
+
+-- total price of the stay in cents of the base currency
+ALTER TABLE reservations ADD COLUMN total_amount integer not null default 0;
+
+-- amounts in cents of the currency, as taken through the payment gateway
+create table payments(
+    id serial primary key,
+    reservation_id integer not null,
+    provider VARCHAR(50) not null,
+    reference VARCHAR(255) not null default '',
+    amount integer not null check (amount > 0),
+    currency VARCHAR(3) not null,
+    status VARCHAR(20) not null default 'pending',
+    captured integer not null default 0,
+    refunded integer not null default 0,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    foreign key(reservation_id) references reservations(id) on delete cascade
+);
+
+create INDEX idx_payments_reservation_id ON payments(reservation_id);
+create UNIQUE INDEX idx_payments_reference ON payments(provider, reference) where reference <> '';
+
+-- charges added to the folio of a reservation by staff, in cents of the base currency
+create table folio_items(
+    id serial primary key,
+    reservation_id integer not null,
+    kind VARCHAR(20) not null,
+    description VARCHAR(255) not null,
+    quantity integer not null default 1 check (quantity > 0),
+    unit_amount integer not null,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    foreign key(reservation_id) references reservations(id) on delete cascade
+);
+
+create INDEX idx_folio_items_reservation_id ON folio_items(reservation_id);
+
+-- the last invoice number issued, updated in the transaction creating an invoice so numbers have no gaps
+create table invoice_numbers(
+    id integer primary key check (id = 1),
+    last_number integer not null
+);
+
+insert into invoice_numbers(id, last_number) values(1, 0) on conflict do nothing;
+
+-- invoices are never changed once issued, the PDF is kept as it was sent to the guest
+create table invoices(
+    id serial primary key,
+    reservation_id integer not null,
+    number VARCHAR(20) not null unique,
+    total integer not null,
+    currency VARCHAR(3) not null,
+    pdf bytea not null,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    foreign key(reservation_id) references reservations(id) on delete cascade
+);
+
+create INDEX idx_invoices_reservation_id ON invoices(reservation_id);
+
+-- taxes and fees charged on stays. Percentage rules hold basis points (750 is 7.5%), the others cents of the base
+-- currency. Rules without a room apply to every room, and only to the nights between start_date and end_date.
+create table tax_rules(
+    id serial primary key,
+    name VARCHAR(255) not null,
+    kind VARCHAR(20) not null check (kind in ('percent', 'per_night', 'per_person_night', 'per_stay')),
+    amount integer not null check (amount >= 0),
+    room_id integer,
+    start_date DATE not null,
+    end_date DATE not null check (end_date >= start_date),
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    foreign key(room_id) references rooms(id) on delete cascade
+);
+
+create INDEX idx_tax_rules_dates ON tax_rules(start_date, end_date);
+
+alter table reservations add column guests integer not null default 1;
+alter table reservations add column room_amount integer not null default 0;
+update reservations set room_amount = total_amount;
+
+-- the taxes and fees of a reservation as they were charged when it was made, so later changes to the rules do not
+-- change what the guest was quoted
+create table reservation_taxes(
+    id serial primary key,
+    reservation_id integer not null,
+    tax_rule_id integer,
+    name VARCHAR(255) not null,
+    kind VARCHAR(20) not null,
+    amount integer not null,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    foreign key(reservation_id) references reservations(id) on delete cascade,
+    foreign key(tax_rule_id) references tax_rules(id) on delete set null
+);
+
+create INDEX idx_reservation_taxes_reservation_id ON reservation_taxes(reservation_id);
+
+-- discount codes for campaigns. Percentage codes hold basis points (1000 is 10%), fixed ones cents of the base
+-- currency. valid_from and valid_to are when guests can book with the code, stay_start and stay_end the first and
+-- last night of the stays it is good for, if set. A max_uses of 0 means the code can be used any number of times.
+create table promo_codes(
+    id serial primary key,
+    code VARCHAR(40) not null unique,
+    description VARCHAR(255) not null default '',
+    kind VARCHAR(20) not null check (kind in ('percent', 'fixed')),
+    amount integer not null check (amount > 0),
+    valid_from DATE not null,
+    valid_to DATE not null check (valid_to >= valid_from),
+    stay_start DATE,
+    stay_end DATE,
+    min_nights integer not null default 0,
+    max_uses integer not null default 0,
+    uses integer not null default 0 check (max_uses = 0 or uses <= max_uses),
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+-- the rooms a promo code is limited to, codes without rooms are good for every room
+create table promo_code_rooms(
+    promo_code_id integer not null,
+    room_id integer not null,
+    primary key(promo_code_id, room_id),
+    foreign key(promo_code_id) references promo_codes(id) on delete cascade,
+    foreign key(room_id) references rooms(id) on delete cascade
+);
+
+alter table reservations add column promo_code_id integer references promo_codes(id) on delete set null;
+alter table reservations add column discount integer not null default 0;
+
+create INDEX idx_reservations_promo_code_id ON reservations(promo_code_id);
+
+-- extras guests can book with a room, priced in cents of the base currency per stay, per night or per guest. The
+-- inventory is how many can be booked for the same night, 0 for no limit.
+create table extras(
+    id serial primary key,
+    name VARCHAR(255) not null,
+    description VARCHAR(255) not null default '',
+    unit VARCHAR(20) not null check (unit in ('per_stay', 'per_night', 'per_guest')),
+    price integer not null check (price >= 0),
+    inventory integer not null default 0 check (inventory >= 0),
+    active boolean not null default true,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP
+);
+
+-- the extras of a reservation as they were booked. An extra counts against its inventory on every night of the stay.
+create table reservation_extras(
+    id serial primary key,
+    reservation_id integer not null,
+    extra_id integer,
+    name VARCHAR(255) not null,
+    unit VARCHAR(20) not null,
+    quantity integer not null check (quantity > 0),
+    unit_price integer not null,
+    amount integer not null,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    foreign key(reservation_id) references reservations(id) on delete cascade,
+    foreign key(extra_id) references extras(id) on delete set null
+);
+
+create INDEX idx_reservation_extras_reservation_id ON reservation_extras(reservation_id);
+create INDEX idx_reservation_extras_extra_id ON reservation_extras(extra_id);
+
+-- guests waiting for a room to free up for their dates, served in the order they joined. room_id is the room asked
+-- for, or null for any room. When a room frees up the first guest it fits is sent a booking link holding the token,
+-- which stops working at expires_at.
+create table waitlist_entries(
+    id serial primary key,
+    first_name VARCHAR(255) not null,
+    last_name VARCHAR(255) not null,
+    email VARCHAR(255) not null,
+    phone VARCHAR(255) not null default '',
+    locale VARCHAR(10) not null default 'en',
+    room_id integer,
+    start_date DATE not null,
+    end_date DATE not null,
+    status VARCHAR(20) not null default 'waiting' check (status in ('waiting', 'offered', 'accepted', 'expired')),
+    offered_room_id integer,
+    token VARCHAR(64) unique,
+    offered_at TIMESTAMP,
+    expires_at TIMESTAMP,
+    created_at TIMESTAMP,
+    updated_at TIMESTAMP,
+    check (end_date > start_date),
+    foreign key(room_id) references rooms(id) on delete cascade,
+    foreign key(offered_room_id) references rooms(id) on delete set null
+);
+
+create INDEX idx_waitlist_entries_status_dates ON waitlist_entries(status, start_date, end_date);
+
+-- a room held for a guest while they fill in the reservation form. Holds expire at expires_at, after which they no
+-- longer make the room unavailable and are swept away, and become reservation restrictions when the guest books.
+insert into restrictions(restriction_name, created_at, updated_at) values ('Hold', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+ALTER TABLE room_restrictions ADD COLUMN expires_at TIMESTAMP;
+create INDEX idx_room_restrictions_expires_at ON room_restrictions(expires_at) where expires_at is not null;
+
+-- reservations moved to another room or other dates from the admin calendar, and who moved them
+create table reservation_moves(
+    id serial primary key,
+    reservation_id integer not null,
+    user_id integer,
+    from_room_id integer not null,
+    to_room_id integer not null,
+    from_start DATE not null,
+    from_end DATE not null,
+    to_start DATE not null,
+    to_end DATE not null,
+    created_at TIMESTAMP,
+    foreign key(reservation_id) references reservations(id) on delete cascade,
+    foreign key(user_id) references users(id) on delete set null,
+    foreign key(from_room_id) references rooms(id),
+    foreign key(to_room_id) references rooms(id)
+);
+
+create INDEX idx_reservation_moves_reservation_id ON reservation_moves(reservation_id);
+
+-- why a room was blocked, for ranged owner and maintenance blocks
+alter table room_restrictions add column reason varchar(255) not null default '';
+alter table room_restrictions add column notes text not null default '';
+
+-- reservations cancelled from the admin tool, kept for reporting after the reservation is deleted
+create table cancellations(
+    id serial primary key,
+    reservation_id integer not null,
+    room_id integer references rooms(id),
+    check_in DATE not null,
+    check_out DATE not null,
+    booked_at TIMESTAMP,
+    cancelled_at TIMESTAMP not null,
+    total_amount integer not null default 0
+);
+
+create INDEX idx_cancellations_cancelled_at ON cancellations(cancelled_at);
+create INDEX idx_reservations_check_in ON reservations(check_in);
+
+-- files of reservations imported, or checked in a dry run, with the rows which failed as a CSV file
+create table import_runs(
+    id serial primary key,
+    user_id integer references users(id) on delete set null,
+    file_name VARCHAR(255) not null default '',
+    dry_run boolean not null default false,
+    row_count integer not null default 0,
+    imported integer not null default 0,
+    failed integer not null default 0,
+    errors bytea,
+    created_at TIMESTAMP not null
+);
+
+-- words of the guest name and email searched in the admin reservation lists, with the parts of the email as words too
+alter table reservations add column search tsvector generated always as (
+    to_tsvector('simple', first_name || ' ' || last_name || ' ' || email || ' ' || replace(email, '@', ' '))
+) stored;
+
+create INDEX idx_reservations_search ON reservations USING gin(search);
+create INDEX idx_reservations_created_at ON reservations(created_at);
+
+-- guests reservations are linked to by email address, with the existing reservations linked to a guest for each email
+-- from their latest reservation
+create table guests(
+    id serial primary key,
+    first_name VARCHAR(255) not null,
+    last_name VARCHAR(255) not null,
+    email VARCHAR(255) not null,
+    phone VARCHAR(20) not null default '',
+    notes text not null default '',
+    tags text[] not null default '{}',
+    preferences text not null default '',
+    created_at TIMESTAMP not null,
+    updated_at TIMESTAMP not null
+);
+
+create UNIQUE INDEX idx_guests_email ON guests(lower(email));
+
+alter table reservations add column guest_id integer references guests(id);
+
+insert into guests(first_name, last_name, email, phone, created_at, updated_at)
+select distinct on (lower(email)) first_name, last_name, email, phone, coalesce(created_at, now()), now()
+from reservations order by lower(email), created_at desc nulls last;
+
+update reservations rs set guest_id = g.id from guests g where lower(rs.email) = lower(g.email);
+
+create INDEX idx_reservations_guest_id ON reservations(guest_id);
+
+-- emails sent by the site, kept so they can be included in and erased by data subject requests
+create table sent_emails(
+    id serial primary key,
+    recipient VARCHAR(255) not null,
+    subject VARCHAR(255) not null default '',
+    content text not null default '',
+    template VARCHAR(255) not null default '',
+    status VARCHAR(20) not null default 'sent',
+    created_at TIMESTAMP not null
+);
+
+create INDEX idx_sent_emails_recipient ON sent_emails(lower(recipient));
+create INDEX idx_sent_emails_created_at ON sent_emails(created_at);
+
+-- when the guest details of a reservation were erased, keeping its dates and amounts for occupancy and accounts
+alter table reservations add column anonymised_at TIMESTAMP;
+create INDEX idx_reservations_check_out ON reservations(check_out) where anonymised_at is null;
+create INDEX idx_waitlist_entries_email ON waitlist_entries(lower(email));
+
+-- when the guest actually arrived and left, with the key handed over and the ID checked at the front desk
+alter table reservations add column checked_in_at TIMESTAMP;
+alter table reservations add column checked_out_at TIMESTAMP;
+alter table reservations add column key_number VARCHAR(20) not null default '';
+alter table reservations add column id_verification text not null default '';
+create INDEX idx_reservations_in_house ON reservations(room_id) where checked_in_at is not null and checked_out_at is null;
+
+-- housekeeping status of each room. A room out of order is blocked by the room restriction out_of_order_block_id
+alter table rooms add column housekeeping_status VARCHAR(20) not null default 'clean'
+    check (housekeeping_status in ('dirty', 'cleaning', 'clean', 'inspected', 'out_of_order'));
+alter table rooms add column housekeeping_updated_at TIMESTAMP;
+alter table rooms add column out_of_order_block_id integer references room_restrictions(id) on delete set null;
+
+-- rooms to clean each day, made for departures and for guests staying over
+create table housekeeping_tasks(
+    id serial primary key,
+    room_id integer not null,
+    reservation_id integer,
+    kind VARCHAR(20) not null check (kind in ('departure', 'stayover')),
+    task_date DATE not null,
+    status VARCHAR(20) not null default 'open' check (status in ('open', 'in_progress', 'done')),
+    assigned_to integer,
+    notes text not null default '',
+    completed_at TIMESTAMP,
+    created_at TIMESTAMP not null,
+    updated_at TIMESTAMP not null,
+    foreign key(room_id) references rooms(id) on delete cascade,
+    foreign key(reservation_id) references reservations(id) on delete set null,
+    foreign key(assigned_to) references users(id) on delete set null
+);
+
+create UNIQUE INDEX idx_housekeeping_tasks_room_day ON housekeeping_tasks(room_id, task_date, kind);
+create INDEX idx_housekeeping_tasks_task_date ON housekeeping_tasks(task_date);
+
+-- hotels run from the site. Each property owns its rooms and the staff working at it, and sends its emails and
+-- takes deposits its own way. Users without a property work at all of them.
+create table properties(
+    id serial primary key,
+    slug VARCHAR(50) not null unique,
+    name VARCHAR(255) not null,
+    hostname VARCHAR(255) not null default '',
+    email_from VARCHAR(255) not null default '',
+    email_from_name VARCHAR(255) not null default '',
+    logo_url VARCHAR(500) not null default '',
+    brand_color VARCHAR(7) not null default '',
+    deposit_policy VARCHAR(50) not null default '',
+    created_at TIMESTAMP not null,
+    updated_at TIMESTAMP not null
+);
+
+create UNIQUE INDEX idx_properties_hostname ON properties(lower(hostname)) where hostname <> '';
+
+insert into properties(slug, name, created_at, updated_at) values('fort-smythe', 'Fort Smythe', now(), now());
+
+-- rooms added without a property belong to the first one
+alter table rooms add column property_id integer not null default 1 references properties(id);
+alter table users add column property_id integer references properties(id) on delete set null;
+create INDEX idx_rooms_property_id ON rooms(property_id);
+
+-- guests waiting for any room are only offered the rooms of the property they joined the waitlist at
+alter table waitlist_entries add column property_id integer references properties(id) on delete cascade;
+
+-- the email addresses of guests merged into another guest, so their next reservations are linked to the guest kept
+create table guest_emails(
+    guest_id integer not null references guests(id) on delete cascade,
+    email VARCHAR(255) not null,
+    created_at TIMESTAMP not null
+);
+
+create UNIQUE INDEX idx_guest_emails_email ON guest_emails(lower(email));
+
+-- the checkout of the payment gateway a pending payment sends the guest back to
+ALTER TABLE payments ADD COLUMN checkout_url VARCHAR(2048) not null default '';
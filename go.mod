@@ -7,8 +7,8 @@ require (
 	github.com/asaskevich/govalidator v0.0.0-20210307081110-f21760c49a8d
 	github.com/go-chi/chi/v5 v5.0.3
 	github.com/justinas/nosurf v1.1.1
-	github.com/lib/pq v1.10.2 // indirect
-	github.com/subosito/gotenv v1.2.0 // indirect
-	github.com/xhit/go-simple-mail/v2 v2.10.0 // indirect
-	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97 // indirect
+	github.com/lib/pq v1.10.2
+	github.com/subosito/gotenv v1.2.0
+	github.com/xhit/go-simple-mail/v2 v2.10.0
+	golang.org/x/crypto v0.0.0-20210711020723-a769d52b0f97
 )
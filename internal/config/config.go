@@ -2,9 +2,13 @@ package config
 
 import (
 	"github.com/alexedwards/scs/v2"
+	"github.com/sunil206b/smart_booking/internal/currency"
 	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/payments"
+	"github.com/sunil206b/smart_booking/internal/property"
 	"html/template"
 	"log"
+	"time"
 )
 
 // AppConfig holds the application config
@@ -16,4 +20,18 @@ type AppConfig struct {
 	InProduction  bool
 	Session       *scs.SessionManager
 	MailChan      chan *models.MailData
+	Currency      *currency.Converter
+	Payments      payments.PaymentProvider
+	Deposit       payments.DepositPolicy
+	HotelName     string
+	// Properties are the hotels run from the site
+	Properties *property.Directory
+	// SiteURL is the address of the site, used for links in emails
+	SiteURL string
+	// HoldTTL is how long a room is held for a guest filling in the reservation form
+	HoldTTL time.Duration
+	// WaitlistOfferTTL is how long the booking link sent to a waitlisted guest works for
+	WaitlistOfferTTL time.Duration
+	// RetentionMonths is how many months after check-out the guest details of a stay are anonymised, 0 keeping them
+	RetentionMonths int
 }
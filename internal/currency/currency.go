@@ -0,0 +1,272 @@
+package currency
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBase is the base currency used when none is configured
+const DefaultBase = "USD"
+
+// Currency describes how amounts of a currency are written
+type Currency struct {
+	Code     string
+	Symbol   string
+	Decimals int
+}
+
+var currencies = map[string]Currency{
+	"AUD": {"AUD", "A$", 2},
+	"CAD": {"CAD", "C$", 2},
+	"CHF": {"CHF", "CHF", 2},
+	"EUR": {"EUR", "€", 2},
+	"GBP": {"GBP", "£", 2},
+	"INR": {"INR", "₹", 2},
+	"JPY": {"JPY", "¥", 0},
+	"MXN": {"MXN", "MX$", 2},
+	"USD": {"USD", "$", 2},
+}
+
+// Lookup returns the currency with the ISO 4217 code
+func Lookup(code string) (Currency, bool) {
+	c, ok := currencies[strings.ToUpper(code)]
+	return c, ok
+}
+
+// Known returns the codes of all supported currencies in alphabetical order
+func Known() []string {
+	var codes []string
+	for code := range currencies {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Rate is the number of units of a currency one unit of the base currency buys
+type Rate struct {
+	Code string
+	Rate float64
+}
+
+// Converter converts amounts held in the base currency into other currencies using an offline table of
+// exchange rates. It is safe for concurrent use.
+type Converter struct {
+	base  Currency
+	mu    sync.RWMutex
+	rates map[string]float64
+}
+
+// NewConverter creates a converter for amounts in the base currency, with no exchange rates
+func NewConverter(base string) (*Converter, error) {
+	c, ok := Lookup(base)
+	if !ok {
+		return nil, fmt.Errorf("unsupported base currency %q", base)
+	}
+	return &Converter{base: c, rates: map[string]float64{}}, nil
+}
+
+// Base returns the code of the base currency
+func (c *Converter) Base() string {
+	return c.base.Code
+}
+
+// SetRates replaces the exchange rates of the converter
+func (c *Converter) SetRates(rates []Rate) {
+	m := make(map[string]float64, len(rates))
+	for _, r := range rates {
+		m[r.Code] = r.Rate
+	}
+	c.mu.Lock()
+	c.rates = m
+	c.mu.Unlock()
+}
+
+// Supported returns true if amounts can be converted into the currency
+func (c *Converter) Supported(code string) bool {
+	if code == c.base.Code {
+		return true
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	_, ok := c.rates[code]
+	return ok
+}
+
+// Codes returns the base currency followed by the currencies with an exchange rate, in alphabetical order
+func (c *Converter) Codes() []string {
+	c.mu.RLock()
+	var codes []string
+	for code := range c.rates {
+		if code != c.base.Code {
+			codes = append(codes, code)
+		}
+	}
+	c.mu.RUnlock()
+	sort.Strings(codes)
+	return append([]string{c.base.Code}, codes...)
+}
+
+// Convert converts an amount in minor units of the base currency, such as cents, into minor units of the currency
+func (c *Converter) Convert(amount int, to string) (int, error) {
+	if to == c.base.Code {
+		return amount, nil
+	}
+	target, ok := Lookup(to)
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %q", to)
+	}
+	c.mu.RLock()
+	rate, ok := c.rates[target.Code]
+	c.mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("no exchange rate for %s", target.Code)
+	}
+	major := float64(amount) / math.Pow10(c.base.Decimals) * rate
+	return int(math.Round(major * math.Pow10(target.Decimals))), nil
+}
+
+// Format converts an amount in minor units of the base currency into the currency and formats it for the locale,
+// formatting it in the base currency if there is no exchange rate for the currency
+func (c *Converter) Format(amount int, code, locale string) string {
+	converted, err := c.Convert(amount, code)
+	if err != nil {
+		return Format(amount, c.base.Code, locale)
+	}
+	return Format(converted, code, locale)
+}
+
+// Format formats an amount in minor units of the currency with the symbol and separators of the locale
+func Format(amount int, code, locale string) string {
+	c, ok := Lookup(code)
+	if !ok {
+		c = Currency{Code: code, Symbol: code, Decimals: 2}
+	}
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	scale := int(math.Pow10(c.Decimals))
+	number := group(strconv.Itoa(amount/scale), i18n.T(locale, "number.group_separator"))
+	if c.Decimals > 0 {
+		number += i18n.T(locale, "number.decimal_separator") + fmt.Sprintf("%0*d", c.Decimals, amount%scale)
+	}
+	return sign + i18n.T(locale, "currency.format", c.Symbol, number)
+}
+
+// Decimal writes an amount in minor units of the currency as a plain decimal number in major units, such as "-12.50",
+// the way ParseAmount reads it
+func Decimal(amount int, code string) string {
+	c, ok := Lookup(code)
+	if !ok {
+		c = Currency{Code: code, Decimals: 2}
+	}
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	scale := int(math.Pow10(c.Decimals))
+	number := strconv.Itoa(amount / scale)
+	if c.Decimals > 0 {
+		number += "." + fmt.Sprintf("%0*d", c.Decimals, amount%scale)
+	}
+	return sign + number
+}
+
+// ParseAmount parses an amount written in major units of the currency with a decimal point, such as "-12.50",
+// into minor units
+func ParseAmount(s, code string) (int, error) {
+	c, ok := Lookup(code)
+	if !ok {
+		return 0, fmt.Errorf("unsupported currency %s", code)
+	}
+	s = strings.TrimSpace(s)
+	sign := 1
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+		s = s[1:]
+	}
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if whole == "" || len(fraction) > c.Decimals || (c.Decimals > 0 && strings.Contains(s, ".") && fraction == "") {
+		return 0, fmt.Errorf("invalid amount %q for %s", s, c.Code)
+	}
+	fraction += strings.Repeat("0", c.Decimals-len(fraction))
+	amount, err := strconv.Atoi(whole + fraction)
+	if err != nil || strings.ContainsAny(whole+fraction, "+-") {
+		return 0, fmt.Errorf("invalid amount %q for %s", s, c.Code)
+	}
+	return sign * amount, nil
+}
+
+// group inserts the separator between every three digits of the whole number
+func group(digits, separator string) string {
+	var b strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteString(separator)
+		}
+		b.WriteRune(d)
+	}
+	return b.String()
+}
+
+// ParseRates reads exchange rates from CSV with a currency code and a rate on each line. A header line
+// starting with "currency" is skipped. The error names the first line which is not valid.
+func ParseRates(r io.Reader, base string) ([]Rate, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	var rates []Rate
+	seen := make(map[string]bool)
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		if line == 1 && strings.EqualFold(strings.TrimSpace(record[0]), "currency") {
+			continue
+		}
+		if len(record) != 2 {
+			return nil, fmt.Errorf("line %d: expected a currency code and a rate", line)
+		}
+
+		c, ok := Lookup(strings.TrimSpace(record[0]))
+		if !ok {
+			return nil, fmt.Errorf("line %d: unsupported currency %q", line, record[0])
+		}
+		if c.Code == base {
+			return nil, fmt.Errorf("line %d: %s is the base currency", line, c.Code)
+		}
+		if seen[c.Code] {
+			return nil, fmt.Errorf("line %d: duplicate rate for %s", line, c.Code)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if err != nil || rate <= 0 || math.IsInf(rate, 0) {
+			return nil, fmt.Errorf("line %d: invalid rate %q", line, record[1])
+		}
+		seen[c.Code] = true
+		rates = append(rates, Rate{Code: c.Code, Rate: rate})
+	}
+	if len(rates) == 0 {
+		return nil, errors.New("no exchange rates found")
+	}
+	return rates, nil
+}
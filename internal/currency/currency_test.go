@@ -0,0 +1,184 @@
+package currency
+
+import (
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	if err := i18n.LoadCatalogs("../../translations"); err != nil {
+		panic(err)
+	}
+	os.Exit(m.Run())
+}
+
+func TestNewConverter(t *testing.T) {
+	if _, err := NewConverter("XYZ"); err == nil {
+		t.Error("expected an error for an unsupported base currency")
+	}
+	c, err := NewConverter("usd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Base() != "USD" {
+		t.Errorf("expected USD but got %s", c.Base())
+	}
+}
+
+func TestConverter_Convert(t *testing.T) {
+	c, _ := NewConverter("USD")
+	c.SetRates([]Rate{{"EUR", 0.9}, {"JPY", 110.5}})
+
+	var tests = []struct {
+		amount int
+		to     string
+		exp    int
+		expErr bool
+	}{
+		{10000, "USD", 10000, false},
+		{10000, "EUR", 9000, false},
+		{12345, "JPY", 13641, false},
+		{10000, "GBP", 0, true},
+		{10000, "XYZ", 0, true},
+	}
+	for _, e := range tests {
+		got, err := c.Convert(e.amount, e.to)
+		if e.expErr && err == nil {
+			t.Errorf("converting to %s: expected an error", e.to)
+		}
+		if !e.expErr && got != e.exp {
+			t.Errorf("converting %d to %s: expected %d but got %d", e.amount, e.to, e.exp, got)
+		}
+	}
+}
+
+func TestConverter_Codes(t *testing.T) {
+	c, _ := NewConverter("EUR")
+	c.SetRates([]Rate{{"USD", 1.1}, {"GBP", 0.85}})
+
+	if got := strings.Join(c.Codes(), ","); got != "EUR,GBP,USD" {
+		t.Errorf("expected the base currency first, but got %s", got)
+	}
+	if !c.Supported("GBP") || c.Supported("JPY") {
+		t.Error("only the base currency and currencies with a rate should be supported")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	var tests = []struct {
+		amount int
+		code   string
+		locale string
+		exp    string
+	}{
+		{123456789, "USD", "en", "$1,234,567.89"},
+		{5, "USD", "en", "$0.05"},
+		{-150000, "GBP", "en", "-£1,500.00"},
+		{123456, "EUR", "es", "1.234,56 €"},
+		{1500, "JPY", "en", "¥1,500"},
+		{100, "XYZ", "en", "XYZ1.00"},
+	}
+	for _, e := range tests {
+		if got := Format(e.amount, e.code, e.locale); got != e.exp {
+			t.Errorf("Format(%d, %s, %s): expected %q but got %q", e.amount, e.code, e.locale, e.exp, got)
+		}
+	}
+}
+
+func TestParseRates(t *testing.T) {
+	rates, err := ParseRates(strings.NewReader("currency,rate\nEUR, 0.9\ngbp,0.8\n"), "USD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rates) != 2 || rates[1].Code != "GBP" || rates[1].Rate != 0.8 {
+		t.Errorf("unexpected rates %v", rates)
+	}
+
+	var invalid = []struct {
+		name string
+		csv  string
+	}{
+		{"empty", ""},
+		{"header only", "currency,rate\n"},
+		{"unknown currency", "XYZ,1.2\n"},
+		{"base currency", "USD,1\n"},
+		{"negative rate", "EUR,-1\n"},
+		{"not a number", "EUR,abc\n"},
+		{"duplicate", "EUR,0.9\nEUR,0.8\n"},
+		{"missing rate", "EUR\n"},
+	}
+	for _, e := range invalid {
+		if _, err := ParseRates(strings.NewReader(e.csv), "USD"); err == nil {
+			t.Errorf("for %s, expected an error", e.name)
+		}
+	}
+}
+
+func TestConverter_Format(t *testing.T) {
+	c, _ := NewConverter("USD")
+	c.SetRates([]Rate{{"EUR", 0.5}})
+
+	if got := c.Format(10000, "EUR", "en"); got != "€50.00" {
+		t.Errorf("expected €50.00 but got %q", got)
+	}
+	if got := c.Format(10000, "GBP", "en"); got != "$100.00" {
+		t.Errorf("expected the base currency without a rate, but got %q", got)
+	}
+}
+
+func TestParseAmount(t *testing.T) {
+	var tests = []struct {
+		amount string
+		code   string
+		exp    int
+		expErr bool
+	}{
+		{"12.50", "USD", 1250, false},
+		{"12.5", "USD", 1250, false},
+		{"12", "USD", 1200, false},
+		{"-3.05", "EUR", -305, false},
+		{"1500", "JPY", 1500, false},
+		{"15.5", "JPY", 0, true},
+		{"1.234", "USD", 0, true},
+		{"12.", "USD", 0, true},
+		{".50", "USD", 0, true},
+		{"1,000.00", "USD", 0, true},
+		{"--1", "USD", 0, true},
+		{"abc", "USD", 0, true},
+		{"1", "XYZ", 0, true},
+	}
+	for _, e := range tests {
+		got, err := ParseAmount(e.amount, e.code)
+		if e.expErr != (err != nil) {
+			t.Errorf("ParseAmount(%q, %s): expected error %v but got %v", e.amount, e.code, e.expErr, err)
+		}
+		if got != e.exp {
+			t.Errorf("ParseAmount(%q, %s): expected %d but got %d", e.amount, e.code, e.exp, got)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	var tests = []struct {
+		amount int
+		code   string
+		exp    string
+	}{
+		{1250, "USD", "12.50"},
+		{-305, "EUR", "-3.05"},
+		{5, "USD", "0.05"},
+		{123456789, "USD", "1234567.89"},
+		{1500, "JPY", "1500"},
+	}
+	for _, e := range tests {
+		got := Decimal(e.amount, e.code)
+		if got != e.exp {
+			t.Errorf("Decimal(%d, %s): expected %q but got %q", e.amount, e.code, e.exp, got)
+		}
+		if back, err := ParseAmount(got, e.code); err != nil || back != e.amount {
+			t.Errorf("Decimal(%d, %s): expected ParseAmount to read it back, got %d, %v", e.amount, e.code, back, err)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// formulaPrefixes are the characters which make a spreadsheet read a cell of a CSV file as a formula
+const formulaPrefixes = "=+-@\t\r"
+
+// EscapeFormula prefixes text starting like a formula with a quote, so a spreadsheet opening the CSV file shows it as
+// text rather than running it
+func EscapeFormula(s string) string {
+	if s != "" && strings.IndexByte(formulaPrefixes, s[0]) >= 0 {
+		return "'" + s
+	}
+	return s
+}
+
+// UnescapeFormula removes the quote EscapeFormula added to text, so files written with it can be read back
+func UnescapeFormula(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.IndexByte(formulaPrefixes, s[1]) >= 0 {
+		return s[1:]
+	}
+	return s
+}
+
+// csvWriter writes a table as a CSV file, with dates as yyyy-mm-dd and times as yyyy-mm-dd hh:mm
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (cw *csvWriter) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, c := range cells {
+		switch v := c.(type) {
+		case string:
+			record[i] = EscapeFormula(v)
+		case int:
+			record[i] = strconv.Itoa(v)
+		case Number:
+			record[i] = string(v)
+		case Date:
+			if !time.Time(v).IsZero() {
+				record[i] = time.Time(v).Format("2006-01-02")
+			}
+		case time.Time:
+			if !v.IsZero() {
+				record[i] = v.Format("2006-01-02 15:04")
+			}
+		default:
+			return fmt.Errorf("cannot export a cell of type %T", c)
+		}
+	}
+	if err := cw.w.Write(record); err != nil {
+		return err
+	}
+	// send each row on rather than buffering the file
+	cw.w.Flush()
+	return cw.w.Error()
+}
+
+func (cw *csvWriter) Close() error {
+	cw.w.Flush()
+	return cw.w.Error()
+}
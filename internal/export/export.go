@@ -0,0 +1,69 @@
+// Package export writes tables of data as CSV files or Excel workbooks one row at a time, so large tables can be
+// streamed to the browser as they are read from the database
+package export
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format is a file format tables can be exported as
+type Format string
+
+// the formats tables can be exported as
+const (
+	FormatCSV  Format = "csv"
+	FormatXLSX Format = "xlsx"
+)
+
+// Number is a decimal number, such as an amount of money, written as a number rather than as text
+type Number string
+
+// Date is a date without a time of day
+type Date time.Time
+
+// Writer writes the rows of a table. Cells can be strings, ints, Numbers, Dates or times. Close must be called after
+// the last row to finish the file.
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	Close() error
+}
+
+// ContentType returns the media type of files in the format
+func (f Format) ContentType() string {
+	if f == FormatXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// Valid returns true for the formats tables can be exported as
+func (f Format) Valid() bool {
+	return f == FormatCSV || f == FormatXLSX
+}
+
+// NewWriter returns a writer of the table with the given header in the format, naming the sheet of a workbook
+func NewWriter(w io.Writer, f Format, sheet string, header ...string) (Writer, error) {
+	var ew Writer
+	switch f {
+	case FormatCSV:
+		ew = newCSVWriter(w)
+	case FormatXLSX:
+		xw, err := newXLSXWriter(w, sheet)
+		if err != nil {
+			return nil, err
+		}
+		ew = xw
+	default:
+		return nil, fmt.Errorf("unknown export format %q", f)
+	}
+	cells := make([]interface{}, len(header))
+	for i, h := range header {
+		cells[i] = h
+	}
+	if err := ew.WriteRow(cells...); err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
@@ -0,0 +1,134 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	checkIn = Date(time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC))
+	created = time.Date(2050, time.June, 1, 12, 0, 0, 0, time.UTC)
+)
+
+func TestNewWriter_CSV(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, "Reservations", "ID", "Name", "Check-in", "Total", "Created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRow(1, "Smith, John", checkIn, Number("150.00"), created); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRow(2, "Jane", Date{}, Number("0.00"), time.Time{}); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	exp := "ID,Name,Check-in,Total,Created\n1,\"Smith, John\",2050-07-10,150.00,2050-06-01 12:00\n2,Jane,,0.00,\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+}
+
+func TestNewWriter_CSVFormulas(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, "Reservations", "Name", "Phone", "Total")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRow("=HYPERLINK(\"http://evil\")", "+1 555", Number("-5.00")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRow("@SUM(A1)", "\t-1", Number("5.00")); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	exp := "Name,Phone,Total\n\"'=HYPERLINK(\"\"http://evil\"\")\",'+1 555,-5.00\n'@SUM(A1),'\t-1,5.00\n"
+	if buf.String() != exp {
+		t.Errorf("expected %q, got %q", exp, buf.String())
+	}
+	if s := UnescapeFormula(EscapeFormula("=1+1")); s != "=1+1" {
+		t.Errorf("expected the escaped formula to be read back, got %q", s)
+	}
+}
+
+func TestNewWriter_XLSX(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatXLSX, "Reservations: July", "ID", "Name", "Check-in", "Total", "Created")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = w.WriteRow(1, "Smith & <Sons>", checkIn, Number("150.00"), created); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := make(map[string]string)
+	for _, f := range z.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		b, _ := ioutil.ReadAll(r)
+		r.Close()
+		parts[f.Name] = string(b)
+	}
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels",
+		"xl/styles.xml", "xl/worksheets/sheet1.xml"} {
+		if _, ok := parts[name]; !ok {
+			t.Errorf("expected the part %s in the workbook", name)
+		}
+	}
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, exp := range []string{
+		`<c r="E1" t="inlineStr"><is><t xml:space="preserve">Created</t></is></c>`,
+		`<c r="A2"><v>1</v></c>`,
+		`Smith &amp; &lt;Sons&gt;`,
+		`<c r="C2" s="1"><v>54979</v></c>`,
+		`<c r="D2"><v>150.00</v></c>`,
+		`<c r="E2" s="2"><v>54940.5</v></c>`,
+	} {
+		if !strings.Contains(sheet, exp) {
+			t.Errorf("expected %s in the sheet", exp)
+		}
+	}
+	if !strings.Contains(parts["xl/workbook.xml"], `name="Reservations July"`) {
+		t.Errorf("expected the sheet name without characters Excel does not allow")
+	}
+}
+
+func TestWriteRow_Invalid(t *testing.T) {
+	for _, f := range []Format{FormatCSV, FormatXLSX} {
+		w, err := NewWriter(ioutil.Discard, f, "Sheet")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = w.WriteRow(1.5); err == nil {
+			t.Errorf("for %s, expected an error writing a float", f)
+		}
+	}
+	if _, err := NewWriter(ioutil.Discard, Format("pdf"), "Sheet"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
+
+func TestColumnName(t *testing.T) {
+	for i, exp := range map[int]string{0: "A", 25: "Z", 26: "AA", 51: "AZ", 52: "BA", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(i); got != exp {
+			t.Errorf("for %d, expected %s but got %s", i, exp, got)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the parts of a workbook other than its sheet, which never change
+const (
+	xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`
+
+	xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`
+
+	xlsxWorkbookRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`
+
+	// cell style 1 is a date and 2 a date and time
+	xlsxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<numFmts count="2"><numFmt numFmtId="164" formatCode="yyyy-mm-dd"/><numFmt numFmtId="165" formatCode="yyyy-mm-dd hh:mm"/></numFmts>
+<fonts count="1"><font><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="3">
+<xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>
+<xf numFmtId="164" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+<xf numFmtId="165" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>
+</cellXfs>
+</styleSheet>`
+
+	xlsxSheetStart = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`
+
+	xlsxSheetEnd = `</sheetData></worksheet>`
+)
+
+// excelEpoch is day zero of the dates in a workbook
+var excelEpoch = time.Date(1899, time.December, 30, 0, 0, 0, 0, time.UTC)
+
+// xlsxWriter writes a table as the only sheet of an Excel workbook. The sheet is the first part of the zip file so
+// rows can be written as they come, with the parts naming the sheet added when the writer is closed.
+type xlsxWriter struct {
+	z     *zip.Writer
+	sheet *bufio.Writer
+	name  string
+	row   int
+}
+
+func newXLSXWriter(w io.Writer, name string) (*xlsxWriter, error) {
+	z := zip.NewWriter(w)
+	part, err := z.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	xw := &xlsxWriter{z: z, sheet: bufio.NewWriter(part), name: name}
+	_, err = xw.sheet.WriteString(xlsxSheetStart)
+	return xw, err
+}
+
+func (xw *xlsxWriter) WriteRow(cells ...interface{}) error {
+	xw.row++
+	fmt.Fprintf(xw.sheet, `<row r="%d">`, xw.row)
+	for i, c := range cells {
+		ref := columnName(i) + strconv.Itoa(xw.row)
+		switch v := c.(type) {
+		case string:
+			fmt.Fprintf(xw.sheet, `<c r="%s" t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, ref, escape(v))
+		case int:
+			fmt.Fprintf(xw.sheet, `<c r="%s"><v>%d</v></c>`, ref, v)
+		case Number:
+			if _, err := strconv.ParseFloat(string(v), 64); err != nil {
+				return fmt.Errorf("cannot export %q as a number", v)
+			}
+			fmt.Fprintf(xw.sheet, `<c r="%s"><v>%s</v></c>`, ref, v)
+		case Date:
+			if !time.Time(v).IsZero() {
+				fmt.Fprintf(xw.sheet, `<c r="%s" s="1"><v>%s</v></c>`, ref, serial(time.Time(v)))
+			}
+		case time.Time:
+			if !v.IsZero() {
+				fmt.Fprintf(xw.sheet, `<c r="%s" s="2"><v>%s</v></c>`, ref, serial(v))
+			}
+		default:
+			return fmt.Errorf("cannot export a cell of type %T", c)
+		}
+	}
+	_, err := xw.sheet.WriteString(`</row>`)
+	return err
+}
+
+func (xw *xlsxWriter) Close() error {
+	if _, err := xw.sheet.WriteString(xlsxSheetEnd); err != nil {
+		return err
+	}
+	if err := xw.sheet.Flush(); err != nil {
+		return err
+	}
+	workbook := `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="` + escape(sheetName(xw.name)) + `" sheetId="1" r:id="rId1"/></sheets></workbook>`
+	parts := []struct{ name, content string }{
+		{"[Content_Types].xml", xlsxContentTypes},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", workbook},
+		{"xl/_rels/workbook.xml.rels", xlsxWorkbookRels},
+		{"xl/styles.xml", xlsxStyles},
+	}
+	for _, p := range parts {
+		part, err := xw.z.Create(p.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(part, p.content); err != nil {
+			return err
+		}
+	}
+	return xw.z.Close()
+}
+
+// columnName returns the name of the column with the index, counting from 0, such as A, Z or AA
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// serial returns the time as the number of days since the epoch of a workbook
+func serial(t time.Time) string {
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+	days := t.Sub(excelEpoch).Hours() / 24
+	return strconv.FormatFloat(days, 'f', -1, 64)
+}
+
+// sheetName returns a name Excel accepts for a sheet, at most 31 characters without []:*?/\
+func sheetName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`[]:*?/\`, r) {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" {
+		name = "Sheet1"
+	}
+	if len([]rune(name)) > 31 {
+		name = string([]rune(name)[:31])
+	}
+	return name
+}
+
+// escape escapes text for XML, replacing characters XML cannot hold
+func escape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
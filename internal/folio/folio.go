@@ -0,0 +1,154 @@
+package folio
+
+import (
+	"fmt"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/rules"
+	"time"
+)
+
+// dateLayout is how dates are written in the descriptions of folio lines
+const dateLayout = "2006-01-02"
+
+// Kind is the kind of a folio line
+type Kind string
+
+const (
+	KindRoom       Kind = "room"
+	KindDiscount   Kind = "discount"
+	KindExtra      Kind = "extra"
+	KindTax        Kind = "tax"
+	KindAdjustment Kind = "adjustment"
+	KindPayment    Kind = "payment"
+	KindRefund     Kind = "refund"
+)
+
+// ItemKinds are the kinds of line staff can add to a folio
+var ItemKinds = []Kind{KindExtra, KindTax, KindAdjustment}
+
+// ValidItemKind reports whether staff can add a line of the kind
+func ValidItemKind(kind string) bool {
+	for _, k := range ItemKinds {
+		if string(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Line is a charge or a credit on a folio. Amount is Quantity times UnitAmount, and is negative for credits.
+type Line struct {
+	Date        time.Time
+	Kind        Kind
+	Description string
+	Quantity    int
+	UnitAmount  int
+	Amount      int
+	// ItemID is the id of the folio item the line comes from, or 0 for lines derived from the reservation
+	ItemID int
+}
+
+// Folio is the account of a reservation
+type Folio struct {
+	Lines []Line
+	// Charges is the sum of the room, extras, taxes and adjustments
+	Charges int
+	// Paid is the amount captured less the amount refunded
+	Paid int
+	// Balance is what the guest still owes
+	Balance int
+}
+
+// Build puts together the folio of a reservation from its room charge, discount, taxes and extras, the items added by staff, and the
+// amounts captured and refunded by the payment gateway. Authorizations which have not been captured are not money
+// received and are left out.
+func Build(res models.Reservation, items []models.FolioItem, resPayments []models.Payment) Folio {
+	var f Folio
+	roomAmount := res.RoomAmount
+	if roomAmount == 0 && len(res.Taxes) == 0 {
+		// reservations made before taxes were itemised only have a total
+		roomAmount = res.TotalAmount
+	}
+	if roomAmount != 0 {
+		description := fmt.Sprintf("%s, %d night(s) from %s to %s", res.Room.RoomName,
+			rules.Nights(res.CheckInDate, res.CheckOutDate), res.CheckInDate.Format(dateLayout), res.CheckOutDate.Format(dateLayout))
+		f.add(Line{
+			Date:        res.CreatedAt,
+			Kind:        KindRoom,
+			Description: description,
+			Quantity:    1,
+			UnitAmount:  roomAmount,
+		})
+	}
+	if res.Discount > 0 {
+		f.add(Line{
+			Date:        res.CreatedAt,
+			Kind:        KindDiscount,
+			Description: fmt.Sprintf("Promo code %s", res.PromoCode),
+			Quantity:    1,
+			UnitAmount:  -res.Discount,
+		})
+	}
+	for _, tax := range res.Taxes {
+		f.add(Line{
+			Date:        res.CreatedAt,
+			Kind:        KindTax,
+			Description: tax.Name,
+			Quantity:    1,
+			UnitAmount:  tax.Amount,
+		})
+	}
+	for _, extra := range res.Extras {
+		// the amount of an extra is its price times the quantity times the nights or guests it is charged for
+		f.add(Line{
+			Date:        res.CreatedAt,
+			Kind:        KindExtra,
+			Description: extra.Name,
+			Quantity:    extra.Quantity,
+			UnitAmount:  extra.Amount / extra.Quantity,
+		})
+	}
+	for _, item := range items {
+		f.add(Line{
+			Date:        item.CreatedAt,
+			Kind:        Kind(item.Kind),
+			Description: item.Description,
+			Quantity:    item.Quantity,
+			UnitAmount:  item.UnitAmount,
+			ItemID:      item.ID,
+		})
+	}
+	for _, p := range resPayments {
+		if p.Captured > 0 {
+			f.add(Line{
+				Date:        p.CreatedAt,
+				Kind:        KindPayment,
+				Description: fmt.Sprintf("Payment %s", p.Reference),
+				Quantity:    1,
+				UnitAmount:  -p.Captured,
+			})
+		}
+		if p.Refunded > 0 {
+			f.add(Line{
+				Date:        p.UpdatedAt,
+				Kind:        KindRefund,
+				Description: fmt.Sprintf("Refund of payment %s", p.Reference),
+				Quantity:    1,
+				UnitAmount:  p.Refunded,
+			})
+		}
+	}
+	f.Balance = f.Charges - f.Paid
+	return f
+}
+
+func (f *Folio) add(line Line) {
+	line.Amount = line.Quantity * line.UnitAmount
+	switch line.Kind {
+	case KindPayment, KindRefund:
+		f.Paid -= line.Amount
+	default:
+		f.Charges += line.Amount
+	}
+	f.Lines = append(f.Lines, line)
+}
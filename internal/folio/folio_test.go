@@ -0,0 +1,109 @@
+package folio
+
+import (
+	"github.com/sunil206b/smart_booking/internal/models"
+	"testing"
+	"time"
+)
+
+func TestBuild(t *testing.T) {
+	res := models.Reservation{
+		ID:           1,
+		CheckInDate:  time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		CheckOutDate: time.Date(2026, 7, 4, 0, 0, 0, 0, time.UTC),
+		TotalAmount:  30000,
+		Room:         models.Room{RoomName: "Major's Suite"},
+	}
+	items := []models.FolioItem{
+		{ID: 1, Kind: "extra", Description: "Breakfast", Quantity: 2, UnitAmount: 1500},
+		{ID: 2, Kind: "tax", Description: "City tax", Quantity: 3, UnitAmount: 200},
+		{ID: 3, Kind: "adjustment", Description: "Goodwill discount", Quantity: 1, UnitAmount: -1000},
+	}
+	resPayments := []models.Payment{
+		{Reference: "fake_1", Amount: 9000, Status: "refunded", Captured: 9000, Refunded: 4000},
+		{Reference: "fake_2", Amount: 9000, Status: "authorized"},
+		{Reference: "fake_3", Amount: 5000, Status: "captured", Captured: 5000},
+	}
+
+	f := Build(res, items, resPayments)
+	if len(f.Lines) != 7 {
+		t.Fatalf("expected 7 lines but got %d: %+v", len(f.Lines), f.Lines)
+	}
+	if f.Lines[0].Kind != KindRoom || f.Lines[0].Description != "Major's Suite, 3 night(s) from 2026-07-01 to 2026-07-04" {
+		t.Errorf("unexpected room line %+v", f.Lines[0])
+	}
+	if f.Lines[1].Amount != 3000 || f.Lines[1].ItemID != 1 {
+		t.Errorf("unexpected extra line %+v", f.Lines[1])
+	}
+	if f.Charges != 30000+3000+600-1000 {
+		t.Errorf("expected charges of 32600 but got %d", f.Charges)
+	}
+	if f.Paid != 9000-4000+5000 {
+		t.Errorf("expected 10000 paid but got %d", f.Paid)
+	}
+	if f.Balance != 22600 {
+		t.Errorf("expected a balance of 22600 but got %d", f.Balance)
+	}
+}
+
+func TestBuild_DiscountAndTaxes(t *testing.T) {
+	res := models.Reservation{
+		CheckInDate:  time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		CheckOutDate: time.Date(2026, 7, 3, 0, 0, 0, 0, time.UTC),
+		RoomAmount:   20000,
+		PromoCode:    "SUMMER10",
+		Discount:     2000,
+		TotalAmount:  20300,
+		Taxes: []models.ReservationTax{
+			{Name: "VAT 10%", Kind: "percent", Amount: 2000},
+			{Name: "City tax", Kind: "per_person_night", Amount: 300},
+		},
+	}
+	f := Build(res, nil, nil)
+	if len(f.Lines) != 4 {
+		t.Fatalf("expected a room line, a discount and 2 tax lines but got %+v", f.Lines)
+	}
+	if f.Lines[0].Amount != 20000 {
+		t.Errorf("expected the room line to hold the room charge but got %d", f.Lines[0].Amount)
+	}
+	if f.Lines[1].Kind != KindDiscount || f.Lines[1].Description != "Promo code SUMMER10" || f.Lines[1].Amount != -2000 {
+		t.Errorf("unexpected discount line %+v", f.Lines[1])
+	}
+	if f.Lines[2].Kind != KindTax || f.Lines[2].Description != "VAT 10%" || f.Lines[3].Amount != 300 {
+		t.Errorf("unexpected tax lines %+v", f.Lines[2:])
+	}
+	if f.Charges != res.TotalAmount || f.Balance != res.TotalAmount {
+		t.Errorf("expected charges and balance of %d but got %d and %d", res.TotalAmount, f.Charges, f.Balance)
+	}
+}
+
+func TestBuild_Extras(t *testing.T) {
+	res := models.Reservation{
+		CheckInDate:  time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		CheckOutDate: time.Date(2026, 7, 3, 0, 0, 0, 0, time.UTC),
+		RoomAmount:   20000,
+		TotalAmount:  26000,
+		Extras: []models.ReservationExtra{
+			{Name: "Parking", Unit: "per_night", Quantity: 2, UnitPrice: 1500, Amount: 6000},
+		},
+	}
+	f := Build(res, nil, nil)
+	if len(f.Lines) != 2 {
+		t.Fatalf("expected a room line and an extra line but got %+v", f.Lines)
+	}
+	if l := f.Lines[1]; l.Kind != KindExtra || l.Description != "Parking" || l.Quantity != 2 || l.UnitAmount != 3000 ||
+		l.Amount != 6000 {
+		t.Errorf("unexpected extra line %+v", l)
+	}
+	if f.Charges != res.TotalAmount {
+		t.Errorf("expected charges of %d but got %d", res.TotalAmount, f.Charges)
+	}
+}
+
+func TestValidItemKind(t *testing.T) {
+	for kind, exp := range map[string]bool{"extra": true, "tax": true, "adjustment": true, "room": false, "payment": false, "": false} {
+		if got := ValidItemKind(kind); got != exp {
+			t.Errorf("for %q, expected %v but got %v", kind, exp, got)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package forms
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const defaultBindDateLayout = "2006-01-02"
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// Bind copies the values of the form into the struct pointed to by dst. Each exported field tagged with
+// `form:"name"` receives the value called name; fields without the tag are skipped. Strings, bools, ints,
+// uints, floats, time.Time (parsed with the `layout:"..."` tag, or 2006-01-02) and slices of those are supported.
+// Values that cannot be converted add an error to the form; an error is returned only if dst is not a
+// pointer to a struct or holds a field of an unsupported type.
+func (f *Form) Bind(dst interface{}) error {
+	v := reflect.ValueOf(dst)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return errors.New("forms: Bind requires a pointer to a struct")
+	}
+	v = v.Elem()
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := sf.Tag.Get("form")
+		if name == "" || name == "-" || sf.PkgPath != "" {
+			continue
+		}
+		layout := sf.Tag.Get("layout")
+		if layout == "" {
+			layout = defaultBindDateLayout
+		}
+
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Slice {
+			values := f.Values[name]
+			slice := reflect.MakeSlice(fv.Type(), 0, len(values))
+			for _, raw := range values {
+				ev := reflect.New(fv.Type().Elem()).Elem()
+				ok, err := setValue(ev, strings.TrimSpace(raw), layout)
+				if err != nil {
+					return fmt.Errorf("forms: field %s: %v", sf.Name, err)
+				}
+				if !ok {
+					f.AddError(name, "forms.invalid_value")
+					continue
+				}
+				slice = reflect.Append(slice, ev)
+			}
+			fv.Set(slice)
+			continue
+		}
+
+		raw := strings.TrimSpace(f.Get(name))
+		if raw == "" {
+			continue
+		}
+		ok, err := setValue(fv, raw, layout)
+		if err != nil {
+			return fmt.Errorf("forms: field %s: %v", sf.Name, err)
+		}
+		if !ok {
+			f.AddError(name, "forms.invalid_value")
+		}
+	}
+	return nil
+}
+
+// Bind copies the values into the struct pointed to by dst as Form.Bind does, returning an error
+// naming the first value which could not be converted
+func Bind(data url.Values, dst interface{}) error {
+	f := New(data)
+	if err := f.Bind(dst); err != nil {
+		return err
+	}
+	for field, messages := range f.Errors {
+		return fmt.Errorf("%s: %s", field, messages[0])
+	}
+	return nil
+}
+
+// setValue converts raw into the type of v and sets it, returning false if raw cannot be converted
+func setValue(v reflect.Value, raw, layout string) (bool, error) {
+	if v.Type() == timeType {
+		t, err := time.Parse(layout, raw)
+		if err != nil {
+			return false, nil
+		}
+		v.Set(reflect.ValueOf(t))
+		return true, nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(raw)
+	case reflect.Bool:
+		if raw == "on" {
+			v.SetBool(true)
+			return true, nil
+		}
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return false, nil
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return false, nil
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return false, nil
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return false, nil
+		}
+		v.SetFloat(n)
+	default:
+		return false, fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return true, nil
+}
@@ -1,26 +1,43 @@
 package forms
 
 import (
-	"fmt"
 	"github.com/asaskevich/govalidator"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 )
 
-// Form creates a custom form struct, embeds a url.Values object
+// Form creates a custom form struct, embeds a url.Values object.
+// Error messages are translation keys, translated into Locale when they are added.
 type Form struct {
 	url.Values
 	Errors formErrors
+	Locale string
 }
 
-// New initializes a form struct
+// New initializes a form struct in the default locale
 func New(data url.Values) *Form {
+	return NewLocalized(data, i18n.DefaultLocale)
+}
+
+// NewLocalized initializes a form struct whose error messages are translated into the locale
+func NewLocalized(data url.Values, locale string) *Form {
 	return &Form{
-		data,
-		formErrors{},
+		Values: data,
+		Errors: formErrors{},
+		Locale: locale,
 	}
 }
 
+// AddError translates the message key into the locale of the form and adds it to the field
+func (f *Form) AddError(field, key string, args ...interface{}) {
+	f.Errors.Add(field, i18n.T(f.Locale, key, args...))
+}
+
 // Has checks if form field is in post and not empty
 func (f *Form) Has(field string) bool {
 	s := f.Get(field)
@@ -35,7 +52,7 @@ func (f *Form) Required(fields ...string) {
 	for _, field := range fields {
 		value := f.Get(field)
 		if strings.TrimSpace(value) == "" {
-			f.Errors.Add(field, "This field is required")
+			f.AddError(field, "forms.required")
 		}
 	}
 }
@@ -49,7 +66,7 @@ func (f *Form) Valid() bool {
 func (f *Form) MinLength(field string, length int) bool {
 	x := f.Get(field)
 	if len(x) < length {
-		f.Errors.Add(field, fmt.Sprintf("This field must be at least %d characters long", length))
+		f.AddError(field, "forms.min_length", length)
 		return false
 	}
 	return true
@@ -58,6 +75,175 @@ func (f *Form) MinLength(field string, length int) bool {
 //IsEmail checks for valid email address
 func (f *Form) IsEmail(field string) {
 	if !govalidator.IsEmail(f.Get(field)) {
-		f.Errors.Add(field, "Invalid email address")
+		f.AddError(field, "forms.invalid_email")
+	}
+}
+
+//IsDate checks that the field holds a date in the given layout
+func (f *Form) IsDate(field, layout string) bool {
+	if _, err := time.Parse(layout, f.Get(field)); err != nil {
+		f.AddError(field, "forms.invalid_date")
+		return false
+	}
+	return true
+}
+
+//NotInPast checks that the date in the field is not before today
+func (f *Form) NotInPast(field, layout string) bool {
+	t, err := time.Parse(layout, f.Get(field))
+	if err != nil {
+		return false
+	}
+	y, m, d := time.Now().Date()
+	if t.Before(time.Date(y, m, d, 0, 0, 0, 0, time.UTC)) {
+		f.AddError(field, "forms.date_in_past")
+		return false
+	}
+	return true
+}
+
+//DateRange checks that the date in the end field is at least one day after the date in the start field
+func (f *Form) DateRange(startField, endField, layout string) bool {
+	start, err := time.Parse(layout, f.Get(startField))
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(layout, f.Get(endField))
+	if err != nil {
+		return false
+	}
+	if !end.After(start) {
+		f.AddError(endField, "forms.date_order")
+		return false
+	}
+	return true
+}
+
+//MaxStay checks that there are no more than the given number of nights between the start and end fields
+func (f *Form) MaxStay(startField, endField, layout string, nights int) bool {
+	start, err := time.Parse(layout, f.Get(startField))
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(layout, f.Get(endField))
+	if err != nil {
+		return false
+	}
+	if end.Sub(start) > time.Duration(nights)*24*time.Hour {
+		f.AddError(endField, "forms.max_stay", nights)
+		return false
+	}
+	return true
+}
+
+//MaxLength checks for string maximum length
+func (f *Form) MaxLength(field string, length int) bool {
+	x := f.Get(field)
+	if utf8.RuneCountInString(x) > length {
+		f.AddError(field, "forms.max_length", length)
+		return false
+	}
+	return true
+}
+
+//IsNumber checks that the field holds a number
+func (f *Form) IsNumber(field string) bool {
+	if _, err := strconv.ParseFloat(strings.TrimSpace(f.Get(field)), 64); err != nil {
+		f.AddError(field, "forms.number")
+		return false
+	}
+	return true
+}
+
+//IsInt checks that the field holds a whole number
+func (f *Form) IsInt(field string) bool {
+	if _, err := strconv.Atoi(strings.TrimSpace(f.Get(field))); err != nil {
+		f.AddError(field, "forms.whole_number")
+		return false
+	}
+	return true
+}
+
+//IntRange checks that the field holds a whole number between min and max, both inclusive
+func (f *Form) IntRange(field string, min, max int) bool {
+	n, err := strconv.Atoi(strings.TrimSpace(f.Get(field)))
+	if err != nil || n < min || n > max {
+		f.AddError(field, "forms.int_range", min, max)
+		return false
+	}
+	return true
+}
+
+//Matches checks that the field matches the regular expression, adding the given message key if it does not
+func (f *Form) Matches(field string, re *regexp.Regexp, message string) bool {
+	if !re.MatchString(f.Get(field)) {
+		f.AddError(field, message)
+		return false
+	}
+	return true
+}
+
+//IsPhone checks for a valid phone number and replaces the field with the number in E.164 format.
+//Numbers without a country code are assumed to belong to DefaultCallingCode.
+func (f *Form) IsPhone(field string) bool {
+	phone, ok := NormalizePhone(f.Get(field))
+	if !ok {
+		f.AddError(field, "forms.invalid_phone")
+		return false
+	}
+	f.Set(field, phone)
+	return true
+}
+
+//ParseDate returns the date held by the field in the given layout, adding an error if it is not a valid date
+func (f *Form) ParseDate(field, layout string) (time.Time, bool) {
+	t, err := time.Parse(layout, strings.TrimSpace(f.Get(field)))
+	if err != nil {
+		f.AddError(field, "forms.invalid_date")
+		return time.Time{}, false
+	}
+	return t, true
+}
+
+//Equal checks that the field holds the same value as the other field, such as a password confirmation
+func (f *Form) Equal(field, other string) bool {
+	if f.Get(field) != f.Get(other) {
+		f.AddError(field, "forms.not_equal")
+		return false
+	}
+	return true
+}
+
+//RequiredIf makes the field required when the other field holds the given value,
+//or when the other field has any value if value is empty
+func (f *Form) RequiredIf(field, other, value string) {
+	if value == "" && !f.Has(other) {
+		return
+	}
+	if value != "" && f.Get(other) != value {
+		return
+	}
+	f.Required(field)
+}
+
+//ValidatorFunc is a custom validation rule, returning an error describing why the value is not valid
+type ValidatorFunc func(value string) error
+
+//Validate runs the custom validators against the field, stopping at the first failure
+func (f *Form) Validate(field string, validators ...ValidatorFunc) bool {
+	for _, v := range validators {
+		if err := v(f.Get(field)); err != nil {
+			f.Errors.Add(field, err.Error())
+			return false
+		}
+	}
+	return true
+}
+
+//Check adds the message key to the field if ok is false
+func (f *Form) Check(ok bool, field, message string) bool {
+	if !ok {
+		f.AddError(field, message)
 	}
+	return ok
 }
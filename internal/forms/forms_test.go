@@ -1,9 +1,13 @@
 package forms
 
 import (
+	"errors"
 	"net/http/httptest"
 	"net/url"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestForm_Valid(t *testing.T) {
@@ -98,3 +102,289 @@ func TestForm_IsEmail(t *testing.T) {
 		t.Error("form does not have valid email, where it should")
 	}
 }
+
+const testDateLayout = "01/02/2006"
+
+func TestForm_IsDate(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("start", "2021-13-45")
+	form := New(postData)
+	if form.IsDate("start", testDateLayout) {
+		t.Error("shows valid date where it should not")
+	}
+	if form.Errors.Get("start") == "" {
+		t.Error("should have an error, but did not get one")
+	}
+
+	postData = url.Values{}
+	postData.Add("start", "07/13/2021")
+	form = New(postData)
+	if !form.IsDate("start", testDateLayout) {
+		t.Error("shows invalid date where it should not")
+	}
+}
+
+func TestForm_NotInPast(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("start", time.Now().AddDate(0, 0, -1).Format(testDateLayout))
+	form := New(postData)
+	if form.NotInPast("start", testDateLayout) {
+		t.Error("date in the past shows valid")
+	}
+
+	postData = url.Values{}
+	postData.Add("start", time.Now().Format(testDateLayout))
+	form = New(postData)
+	if !form.NotInPast("start", testDateLayout) {
+		t.Error("today shows as in the past")
+	}
+}
+
+func TestForm_DateRange(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("start", "07/13/2021")
+	postData.Add("end", "07/13/2021")
+	form := New(postData)
+	if form.DateRange("start", "end", testDateLayout) {
+		t.Error("zero night stay shows valid")
+	}
+
+	postData.Set("end", "07/10/2021")
+	form = New(postData)
+	if form.DateRange("start", "end", testDateLayout) {
+		t.Error("end before start shows valid")
+	}
+	if form.Errors.Get("end") == "" {
+		t.Error("should have an error, but did not get one")
+	}
+
+	postData.Set("end", "07/14/2021")
+	form = New(postData)
+	if !form.DateRange("start", "end", testDateLayout) {
+		t.Error("one night stay shows invalid")
+	}
+}
+
+func TestForm_MaxStay(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("start", "07/01/2021")
+	postData.Add("end", "07/31/2021")
+	form := New(postData)
+	if form.MaxStay("start", "end", testDateLayout, 14) {
+		t.Error("30 night stay shows valid with a maximum of 14")
+	}
+
+	form = New(postData)
+	if !form.MaxStay("start", "end", testDateLayout, 30) {
+		t.Error("30 night stay shows invalid with a maximum of 30")
+	}
+}
+
+func TestForm_MaxLength(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("a", "abcdef")
+	form := New(postData)
+	if form.MaxLength("a", 5) {
+		t.Error("field shows max length where it should not")
+	}
+	if form.Errors.Get("a") == "" {
+		t.Error("should have an error, but did not get one")
+	}
+
+	form = New(postData)
+	if !form.MaxLength("a", 6) {
+		t.Error("field should be within max length, but shows not")
+	}
+}
+
+func TestForm_Numbers(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("int", "12")
+	postData.Add("float", "12.5")
+	postData.Add("text", "twelve")
+	form := New(postData)
+
+	if !form.IsInt("int") || !form.IsNumber("float") || !form.IsNumber("int") {
+		t.Error("valid numbers show as invalid")
+	}
+	if form.IsInt("float") {
+		t.Error("decimal shows as a whole number")
+	}
+	if form.IsNumber("text") {
+		t.Error("text shows as a number")
+	}
+	if !form.IntRange("int", 1, 12) {
+		t.Error("number at the top of the range shows as out of range")
+	}
+	if form.IntRange("int", 1, 11) {
+		t.Error("number out of range shows as in range")
+	}
+}
+
+func TestForm_Matches(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("code", "SUMMER21")
+	form := New(postData)
+	if !form.Matches("code", regexp.MustCompile(`^[A-Z0-9]+$`), "Invalid code") {
+		t.Error("value should match, but does not")
+	}
+	if form.Matches("code", regexp.MustCompile(`^[a-z]+$`), "Invalid code") {
+		t.Error("value should not match, but does")
+	}
+	if form.Errors.Get("code") != "Invalid code" {
+		t.Error("expected the given message as the error")
+	}
+}
+
+func TestForm_IsPhone(t *testing.T) {
+	tests := []struct {
+		phone string
+		exp   string
+		valid bool
+	}{
+		{"767-432-4312", "+17674324312", true},
+		{"(767) 432 4312", "+17674324312", true},
+		{"+44 20 7946 0958", "+442079460958", true},
+		{"0044 20 7946 0958", "+442079460958", true},
+		{"767-432-431x", "", false},
+		{"12345", "", false},
+		{"+1234567890123456", "", false},
+	}
+
+	for _, e := range tests {
+		postData := url.Values{}
+		postData.Add("phone", e.phone)
+		form := New(postData)
+		valid := form.IsPhone("phone")
+		if valid != e.valid {
+			t.Errorf("for %s, expected valid to be %t but got %t", e.phone, e.valid, valid)
+		}
+		if valid && form.Get("phone") != e.exp {
+			t.Errorf("for %s, expected %s but got %s", e.phone, e.exp, form.Get("phone"))
+		}
+	}
+}
+
+func TestForm_ParseDate(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("date", "07/13/2021")
+	form := New(postData)
+	d, ok := form.ParseDate("date", testDateLayout)
+	if !ok || d.Day() != 13 || d.Month() != time.July {
+		t.Error("failed to parse valid date")
+	}
+
+	postData.Set("date", "13/07/2021")
+	form = New(postData)
+	if _, ok = form.ParseDate("date", testDateLayout); ok {
+		t.Error("parsed an invalid date")
+	}
+}
+
+func TestForm_Equal(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("password", "secret")
+	postData.Add("password_confirm", "secret")
+	form := New(postData)
+	if !form.Equal("password_confirm", "password") {
+		t.Error("equal fields show as different")
+	}
+
+	postData.Set("password_confirm", "other")
+	form = New(postData)
+	if form.Equal("password_confirm", "password") {
+		t.Error("different fields show as equal")
+	}
+}
+
+func TestForm_RequiredIf(t *testing.T) {
+	postData := url.Values{}
+	postData.Add("kind", "company")
+	form := New(postData)
+	form.RequiredIf("company_name", "kind", "company")
+	if form.Valid() {
+		t.Error("conditional field should be required, but is not")
+	}
+
+	postData.Set("kind", "person")
+	form = New(postData)
+	form.RequiredIf("company_name", "kind", "company")
+	if !form.Valid() {
+		t.Error("conditional field should not be required, but is")
+	}
+}
+
+func TestForm_Validate(t *testing.T) {
+	noSpaces := func(value string) error {
+		if strings.Contains(value, " ") {
+			return errors.New("Spaces are not allowed")
+		}
+		return nil
+	}
+
+	postData := url.Values{}
+	postData.Add("code", "has space")
+	form := New(postData)
+	if form.Validate("code", noSpaces) {
+		t.Error("custom validator should have failed")
+	}
+	if form.Errors.Get("code") != "Spaces are not allowed" {
+		t.Error("expected the custom validator message as the error")
+	}
+
+	form = New(postData)
+	if form.Check(false, "code", "Custom check failed"); form.Valid() {
+		t.Error("failed check should make the form invalid")
+	}
+}
+
+func TestForm_Bind(t *testing.T) {
+	type request struct {
+		Name     string    `form:"name"`
+		Guests   int       `form:"guests"`
+		Price    float64   `form:"price"`
+		Agree    bool      `form:"agree"`
+		Arrival  time.Time `form:"arrival" layout:"01/02/2006"`
+		Extras   []int     `form:"extras"`
+		Ignored  string
+		internal string `form:"internal"`
+	}
+
+	postData := url.Values{}
+	postData.Add("name", " John ")
+	postData.Add("guests", "2")
+	postData.Add("price", "99.5")
+	postData.Add("agree", "on")
+	postData.Add("arrival", "07/13/2021")
+	postData.Add("extras", "1")
+	postData.Add("extras", "3")
+	postData.Add("Ignored", "x")
+	postData.Add("internal", "x")
+
+	var req request
+	form := New(postData)
+	if err := form.Bind(&req); err != nil {
+		t.Fatal(err)
+	}
+	if !form.Valid() {
+		t.Error("form shows invalid after binding valid values")
+	}
+	if req.Name != "John" || req.Guests != 2 || req.Price != 99.5 || !req.Agree {
+		t.Errorf("values not bound as expected: %+v", req)
+	}
+	if req.Arrival.Day() != 13 || len(req.Extras) != 2 || req.Extras[1] != 3 {
+		t.Errorf("values not bound as expected: %+v", req)
+	}
+	if req.Ignored != "" || req.internal != "" {
+		t.Error("bound a field which should have been skipped")
+	}
+
+	postData.Set("guests", "two")
+	if err := Bind(postData, &req); err == nil {
+		t.Error("expected an error binding a value which is not a number")
+	}
+
+	if err := form.Bind(req); err == nil {
+		t.Error("expected an error binding into a value which is not a pointer")
+	}
+}
@@ -0,0 +1,47 @@
+package forms
+
+import "strings"
+
+// DefaultCallingCode is the country calling code given to phone numbers entered without one
+var DefaultCallingCode = "1"
+
+const (
+	minPhoneDigits = 8
+	maxPhoneDigits = 15
+)
+
+// NormalizePhone converts a phone number into E.164 format, +<country code><number>,
+// dropping spaces, dots, dashes and parentheses. It returns false if the number is not valid.
+func NormalizePhone(phone string) (string, bool) {
+	phone = strings.TrimSpace(phone)
+	international := false
+	switch {
+	case strings.HasPrefix(phone, "+"):
+		international = true
+		phone = phone[1:]
+	case strings.HasPrefix(phone, "00"):
+		international = true
+		phone = phone[2:]
+	}
+
+	var digits strings.Builder
+	for _, c := range phone {
+		switch {
+		case c >= '0' && c <= '9':
+			digits.WriteRune(c)
+		case c == ' ' || c == '-' || c == '.' || c == '(' || c == ')':
+		default:
+			return "", false
+		}
+	}
+
+	number := digits.String()
+	if !international {
+		number = strings.TrimPrefix(number, DefaultCallingCode)
+		number = DefaultCallingCode + strings.TrimLeft(number, "0")
+	}
+	if len(number) < minPhoneDigits || len(number) > maxPhoneDigits || number[0] == '0' {
+		return "", false
+	}
+	return "+" + number, true
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// blockReasons are offered when blocking rooms, though any reason can be given
+var blockReasons = []string{"Renovation", "Maintenance", "Owner stay", "Closed"}
+
+// AdminPostBlocks blocks the rooms chosen from one date up to another, for renovations and other long closures
+func (rh *RouteHandler) AdminPostBlocks(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("start_date", "end_date", "reason")
+	form.MaxLength("reason", 255)
+	start := parseFormDate(form, "start_date", htmlDateLayout)
+	end := parseFormDate(form, "end_date", htmlDateLayout)
+	if !start.IsZero() && !end.After(start) {
+		form.Errors.Add("end_date", "End date must be after the start date")
+	}
+
+	var blocks []models.RoomRestriction
+	for _, v := range r.PostForm["room_id"] {
+		roomID, err := strconv.Atoi(v)
+		if err != nil {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		blocks = append(blocks, models.RoomRestriction{
+			StartDate: start,
+			EndDate:   end,
+			RoomID:    roomID,
+			Reason:    strings.TrimSpace(form.Get("reason")),
+			Notes:     strings.TrimSpace(form.Get("notes")),
+		})
+	}
+	if len(blocks) == 0 {
+		form.Errors.Add("room_id", "Choose the rooms to block")
+	}
+	var roomIDs []int
+	for _, b := range blocks {
+		roomIDs = append(roomIDs, b.RoomID)
+	}
+	ok, err := rh.managesRooms(r, roomIDs...)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !ok {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	calendar := "/admin/reservations-calender"
+	if !start.IsZero() {
+		calendar = fmt.Sprintf("/admin/reservations-calender?y=%d&m=%d", start.Year(), start.Month())
+	}
+	if !form.Valid() {
+		for _, field := range []string{"room_id", "start_date", "end_date", "reason"} {
+			if msg := form.Errors.Get(field); msg != "" {
+				rh.App.Session.Put(r.Context(), "error", msg)
+				break
+			}
+		}
+		http.Redirect(w, r, calendar, http.StatusSeeOther)
+		return
+	}
+
+	err = rh.DB.CreateBlocks(blocks)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		rh.App.Session.Put(r.Context(), "error", "Some of the rooms have reservations between those dates")
+		http.Redirect(w, r, calendar, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%d room(s) blocked", len(blocks)))
+	http.Redirect(w, r, calendar, http.StatusSeeOther)
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const maxRatesFileSize = 1 << 20
+
+// LoadExchangeRates loads the exchange rate table into the currency converter of the application
+func (rh *RouteHandler) LoadExchangeRates() error {
+	rates, err := rh.DB.AllExchangeRates()
+	if err != nil {
+		return err
+	}
+	converted := make([]currency.Rate, 0, len(rates))
+	for _, rate := range rates {
+		converted = append(converted, currency.Rate{Code: rate.CurrencyCode, Rate: rate.Rate})
+	}
+	rh.App.Currency.SetRates(converted)
+	return nil
+}
+
+// AdminExchangeRates shows the exchange rate table in the admin tool
+func (rh *RouteHandler) AdminExchangeRates(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	rh.renderExchangeRates(w, r, forms.New(nil))
+}
+
+// AdminPostExchangeRate adds or updates the exchange rate of a single currency
+func (rh *RouteHandler) AdminPostExchangeRate(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("currency_code", "rate")
+	code := strings.ToUpper(form.Get("currency_code"))
+	if _, ok := currency.Lookup(code); form.Has("currency_code") && !ok {
+		form.Errors.Add("currency_code", "Unsupported currency")
+	}
+	if code == rh.App.Currency.Base() {
+		form.Errors.Add("currency_code", "The base currency does not need an exchange rate")
+	}
+	rate, err := strconv.ParseFloat(form.Get("rate"), 64)
+	if form.Has("rate") && (err != nil || rate <= 0) {
+		form.Errors.Add("rate", "The rate must be a number greater than zero")
+	}
+	if !form.Valid() {
+		rh.renderExchangeRates(w, r, form)
+		return
+	}
+
+	rh.saveExchangeRates(w, r, []models.ExchangeRate{{CurrencyCode: code, Rate: rate}}, "Exchange rate saved")
+}
+
+// AdminImportExchangeRates replaces the exchange rates of the currencies in an uploaded CSV file
+func (rh *RouteHandler) AdminImportExchangeRates(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRatesFileSize)
+	form := forms.New(nil)
+	if err := r.ParseMultipartForm(maxRatesFileSize); err != nil {
+		form.Errors.Add("rates_file", "The file must be a CSV file smaller than 1MB")
+		rh.renderExchangeRates(w, r, form)
+		return
+	}
+	file, _, err := r.FormFile("rates_file")
+	if err != nil {
+		form.Errors.Add("rates_file", "Choose a CSV file to import")
+		rh.renderExchangeRates(w, r, form)
+		return
+	}
+	defer file.Close()
+
+	parsed, err := currency.ParseRates(file, rh.App.Currency.Base())
+	if err != nil {
+		form.Errors.Add("rates_file", err.Error())
+		rh.renderExchangeRates(w, r, form)
+		return
+	}
+	rates := make([]models.ExchangeRate, 0, len(parsed))
+	for _, rate := range parsed {
+		rates = append(rates, models.ExchangeRate{CurrencyCode: rate.Code, Rate: rate.Rate})
+	}
+	rh.saveExchangeRates(w, r, rates, strconv.Itoa(len(rates))+" exchange rates imported")
+}
+
+// AdminDeleteExchangeRate deletes the exchange rate of a currency, so prices are no longer shown in it
+func (rh *RouteHandler) AdminDeleteExchangeRate(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	err := rh.DB.DeleteExchangeRate(strings.ToUpper(chi.URLParam(r, "code")))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if err = rh.LoadExchangeRates(); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Exchange rate deleted")
+	http.Redirect(w, r, "/admin/exchange-rates", http.StatusSeeOther)
+}
+
+func (rh *RouteHandler) saveExchangeRates(w http.ResponseWriter, r *http.Request, rates []models.ExchangeRate, flash string) {
+	err := rh.DB.SaveExchangeRates(rates)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if err = rh.LoadExchangeRates(); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, "/admin/exchange-rates", http.StatusSeeOther)
+}
+
+func (rh *RouteHandler) renderExchangeRates(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	rates, err := rh.DB.AllExchangeRates()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	var codes []string
+	for _, code := range currency.Known() {
+		if code != rh.App.Currency.Base() {
+			codes = append(codes, code)
+		}
+	}
+	data := make(map[string]interface{})
+	data["rates"] = rates
+	data["currencies"] = codes
+	render.Template(w, r, "admin-exchange-rates.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/export"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"net/http"
+	"time"
+)
+
+// reservationExportHeader names the columns of a reservations export
+var reservationExportHeader = []string{"ID", "First Name", "Last Name", "Email", "Phone", "Room", "Check-in",
+	"Check-out", "Nights", "Guests", "Room Amount", "Discount", "Total", "Currency", "Promo Code", "Status", "Booked"}
+
+// AdminExportReservations sends the reservations matching the filter as a CSV file or Excel workbook, writing each
+// reservation as it is read from the database
+func (rh *RouteHandler) AdminExportReservations(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	format := export.Format(form.Get("format"))
+	if !form.Has("format") {
+		format = export.FormatCSV
+	}
+	filter := parseReservationFilter(form)
+	filter.PropertyID = helpers.AdminPropertyID(r)
+	if !format.Valid() || !form.Valid() {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="reservations-%s.%s"`,
+		time.Now().Format("20060102"), format))
+	ew, err := export.NewWriter(w, format, "Reservations", reservationExportHeader...)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	base := rh.App.Currency.Base()
+	err = rh.DB.EachReservation(filter, func(res models.Reservation) error {
+		status := models.ReservationStatusNew
+		if res.Processed == 1 {
+			status = models.ReservationStatusProcessed
+		}
+		nights := int(res.CheckOutDate.Sub(res.CheckInDate).Hours() / 24)
+		return ew.WriteRow(res.ID, res.FirstName, res.LastName, res.Email, res.Phone, res.Room.RoomName,
+			export.Date(res.CheckInDate), export.Date(res.CheckOutDate), nights, res.Guests,
+			export.Number(currency.Decimal(res.RoomAmount, base)), export.Number(currency.Decimal(res.Discount, base)),
+			export.Number(currency.Decimal(res.TotalAmount, base)), base, res.PromoCode, status, res.CreatedAt)
+	})
+	if err == nil {
+		err = ew.Close()
+	}
+	if err != nil {
+		// the response has started, so the export is left unfinished for the browser to report
+		rh.App.ErrorLog.Println("failed to export reservations", err)
+	}
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/pricing"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// maxExtraQuantity is the most of one extra a guest can book with a reservation
+const maxExtraQuantity = 10
+
+// extraChoice is an extra offered on the reservation form, with how many are left for the stay and how many the
+// guest has chosen
+type extraChoice struct {
+	models.Extra
+	Left     int
+	Quantity int
+}
+
+// Field returns the name of the form field holding the quantity of the extra
+func (c extraChoice) Field() string {
+	return fmt.Sprintf("extra_%d", c.ID)
+}
+
+// AdminExtras shows the catalogue of extras in the admin tool
+func (rh *RouteHandler) AdminExtras(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	rh.renderExtras(w, r, forms.New(nil))
+}
+
+// AdminPostExtra adds an extra to the catalogue
+func (rh *RouteHandler) AdminPostExtra(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "unit", "price")
+	form.MaxLength("name", 255)
+	form.MaxLength("description", 255)
+
+	extra := models.Extra{
+		Name:        form.Get("name"),
+		Description: form.Get("description"),
+		Unit:        form.Get("unit"),
+		Active:      true,
+	}
+	if form.Has("unit") && !pricing.ValidExtraUnit(extra.Unit) {
+		form.Errors.Add("unit", "Choose how the extra is priced")
+	}
+	if form.Has("price") {
+		extra.Price, err = currency.ParseAmount(form.Get("price"), rh.App.Currency.Base())
+		if err != nil || extra.Price < 0 {
+			form.Errors.Add("price", "Enter an amount such as 12.50")
+		}
+	}
+	extra.Inventory = parseFormCount(form, "inventory", "This field must be a number of items")
+
+	if !form.Valid() {
+		rh.renderExtras(w, r, form)
+		return
+	}
+
+	err = rh.DB.CreateExtra(&extra)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Extra "+extra.Name+" saved")
+	http.Redirect(w, r, "/admin/extras", http.StatusSeeOther)
+}
+
+// AdminToggleExtra offers an extra to guests again or withdraws it
+func (rh *RouteHandler) AdminToggleExtra(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	active := chi.URLParam(r, "state") == "on"
+	err := rh.DB.SetExtraActive(id, active)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if active {
+		rh.App.Session.Put(r.Context(), "flash", "Extra offered to guests")
+	} else {
+		rh.App.Session.Put(r.Context(), "flash", "Extra withdrawn")
+	}
+	http.Redirect(w, r, "/admin/extras", http.StatusSeeOther)
+}
+
+func (rh *RouteHandler) renderExtras(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	extras, err := rh.DB.AllExtras()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["extras"] = extras
+	data["units"] = pricing.ExtraUnits
+	render.Template(w, r, "admin-extras.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// extraChoices returns the active extras with how many of each are left for every night of the reservation and how
+// many the reservation already has
+func (rh *RouteHandler) extraChoices(res models.Reservation) ([]extraChoice, error) {
+	extras, err := rh.DB.AllExtras()
+	if err != nil {
+		return nil, err
+	}
+	booked, err := rh.DB.ExtrasBookedByDate(res.CheckInDate, res.CheckOutDate)
+	if err != nil {
+		return nil, err
+	}
+	chosen := make(map[int]int)
+	for _, e := range res.Extras {
+		chosen[e.ExtraID] = e.Quantity
+	}
+
+	var choices []extraChoice
+	for _, e := range extras {
+		if !e.Active {
+			continue
+		}
+		left := maxExtraQuantity
+		if e.Inventory > 0 && e.Inventory-booked[e.ID] < left {
+			left = e.Inventory - booked[e.ID]
+			if left < 0 {
+				left = 0
+			}
+		}
+		choices = append(choices, extraChoice{Extra: e, Left: left, Quantity: chosen[e.ID]})
+	}
+	return choices, nil
+}
+
+// chooseExtras reads the quantity of each extra from the reservation form, adding an error to the form if more are
+// asked for than are left, and adds the extras chosen to the quote
+func chooseExtras(form *forms.Form, choices []extraChoice, quote *pricing.Quote) {
+	for i := range choices {
+		c := &choices[i]
+		c.Quantity = 0
+		if !form.Has(c.Field()) {
+			continue
+		}
+		if c.Left == 0 {
+			if form.Get(c.Field()) != "0" {
+				form.AddError(c.Field(), "extras.sold_out")
+			}
+			continue
+		}
+		if !form.IntRange(c.Field(), 0, c.Left) {
+			continue
+		}
+		c.Quantity, _ = strconv.Atoi(strings.TrimSpace(form.Get(c.Field())))
+		quote.AddExtra(c.Extra, c.Quantity)
+	}
+}
@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/folio"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/invoice"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminPostFolioItem adds a charge to the folio of a reservation
+func (rh *RouteHandler) AdminPostFolioItem(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	src := chi.URLParam(r, "src")
+	res, err := rh.reservationFromURL(r)
+	if err != nil {
+		staffError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("kind", "description", "quantity", "unit_amount")
+	form.MaxLength("description", 255)
+	if form.Has("kind") && !folio.ValidItemKind(form.Get("kind")) {
+		form.Errors.Add("kind", "Choose an extra, a tax or an adjustment")
+	}
+	item := models.FolioItem{
+		ReservationID: res.ID,
+		Kind:          form.Get("kind"),
+		Description:   form.Get("description"),
+	}
+	item.Quantity, err = strconv.Atoi(form.Get("quantity"))
+	if form.Has("quantity") && (err != nil || item.Quantity < 1) {
+		form.Errors.Add("quantity", "The quantity must be a whole number greater than zero")
+	}
+	item.UnitAmount, err = currency.ParseAmount(form.Get("unit_amount"), rh.App.Currency.Base())
+	if form.Has("unit_amount") && err != nil {
+		form.Errors.Add("unit_amount", "Enter an amount such as 12.50")
+	}
+	if item.UnitAmount < 0 && item.Kind != string(folio.KindAdjustment) {
+		form.Errors.Add("unit_amount", "Only adjustments can be negative")
+	}
+
+	if !form.Valid() {
+		stringMap := make(map[string]string)
+		stringMap["src"] = src
+		stringMap["month"] = r.Form.Get("month")
+		stringMap["year"] = r.Form.Get("year")
+		rh.renderAdminReservation(w, r, res, stringMap, form)
+		return
+	}
+
+	err = rh.DB.CreateFolioItem(&item)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Folio item added")
+	http.Redirect(w, r, adminReservationURL(src, res.ID), http.StatusSeeOther)
+}
+
+// AdminDeleteFolioItem deletes a charge from the folio of a reservation
+func (rh *RouteHandler) AdminDeleteFolioItem(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	itemID, _ := strconv.Atoi(chi.URLParam(r, "item"))
+	if _, err = rh.managedReservation(r, id); err != nil {
+		staffError(w, err)
+		return
+	}
+	err = rh.DB.DeleteFolioItem(id, itemID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Folio item deleted")
+	http.Redirect(w, r, adminReservationURL(chi.URLParam(r, "src"), id), http.StatusSeeOther)
+}
+
+// AdminCreateInvoice issues a numbered invoice for the folio of a reservation as it is now
+func (rh *RouteHandler) AdminCreateInvoice(w http.ResponseWriter, r *http.Request) {
+	res, err := rh.reservationFromURL(r)
+	if err != nil {
+		staffError(w, err)
+		return
+	}
+	f, err := rh.reservationFolio(res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if len(f.Lines) == 0 {
+		rh.App.Session.Put(r.Context(), "error", "There is nothing to invoice")
+		http.Redirect(w, r, adminReservationURL(chi.URLParam(r, "src"), res.ID), http.StatusSeeOther)
+		return
+	}
+
+	inv := models.Invoice{
+		ReservationID: res.ID,
+		Total:         f.Charges,
+		Currency:      rh.App.Currency.Base(),
+	}
+	err = rh.DB.CreateInvoice(&inv, func(number string) ([]byte, error) {
+		return rh.invoiceDocument(number, time.Now(), res, f).PDF()
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Invoice "+inv.Number+" created")
+	http.Redirect(w, r, adminReservationURL(chi.URLParam(r, "src"), res.ID), http.StatusSeeOther)
+}
+
+// AdminInvoicePDF downloads the PDF of an invoice
+func (rh *RouteHandler) AdminInvoicePDF(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	inv, err := rh.DB.GetInvoiceByID(id)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if _, err = rh.managedReservation(r, inv.ReservationID); err != nil {
+		staffError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pdf")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, inv.Number))
+	w.Header().Set("Content-Length", strconv.Itoa(len(inv.PDF)))
+	w.Write(inv.PDF)
+}
+
+// AdminEmailInvoice emails the PDF of an invoice to the guest
+func (rh *RouteHandler) AdminEmailInvoice(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	inv, err := rh.DB.GetInvoiceByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	res, err := rh.managedReservation(r, inv.ReservationID)
+	if err != nil {
+		staffError(w, err)
+		return
+	}
+
+	htmlMsg := fmt.Sprintf(`
+		<strong>Invoice %s</strong><br>
+		Dear %s %s, please find attached the invoice for your stay from %s to %s.
+`, inv.Number, res.FirstName, res.LastName, i18n.FormatDate(i18n.DefaultLocale, res.CheckInDate),
+		i18n.FormatDate(i18n.DefaultLocale, res.CheckOutDate))
+	rh.App.MailChan <- &models.MailData{
+		To:       res.Email,
+		From:     rh.mailSender(res.Room.PropertyID),
+		Subject:  "Invoice " + inv.Number,
+		Content:  htmlMsg,
+		Template: "basic.html",
+		Attachments: []models.MailAttachment{
+			{Name: inv.Number + ".pdf", ContentType: "application/pdf", Data: inv.PDF},
+		},
+	}
+
+	rh.App.Session.Put(r.Context(), "flash", "Invoice "+inv.Number+" sent to "+res.Email)
+	http.Redirect(w, r, adminReservationURL(r.Form.Get("src"), res.ID), http.StatusSeeOther)
+}
+
+// reservationFromURL returns the reservation whose id is in the URL, or errOtherProperty if the signed-in user does
+// not work at its property
+func (rh *RouteHandler) reservationFromURL(r *http.Request) (models.Reservation, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	return rh.managedReservation(r, id)
+}
+
+// reservationFolio builds the folio of a reservation
+func (rh *RouteHandler) reservationFolio(res models.Reservation) (folio.Folio, error) {
+	items, err := rh.DB.FolioItemsForReservation(res.ID)
+	if err != nil {
+		return folio.Folio{}, err
+	}
+	resPayments, err := rh.DB.PaymentsForReservation(res.ID)
+	if err != nil {
+		return folio.Folio{}, err
+	}
+	return folio.Build(res, items, resPayments), nil
+}
+
+// invoiceDocument lays out the folio of a reservation as an invoice, with amounts in the base currency
+func (rh *RouteHandler) invoiceDocument(number string, date time.Time, res models.Reservation, f folio.Folio) invoice.Invoice {
+	base := rh.App.Currency.Base()
+	money := func(amount int) string {
+		return currency.Format(amount, base, i18n.DefaultLocale)
+	}
+	inv := invoice.Invoice{
+		Number: number,
+		Date:   date,
+		Issuer: []string{rh.hotelName(res.Room.PropertyID)},
+		Reference: fmt.Sprintf("Reservation %d, %s from %s to %s", res.ID, res.Room.RoomName,
+			i18n.FormatDate(i18n.DefaultLocale, res.CheckInDate), i18n.FormatDate(i18n.DefaultLocale, res.CheckOutDate)),
+		Totals: []invoice.Total{
+			{Label: "Total charges", Amount: money(f.Charges)},
+			{Label: "Paid", Amount: money(f.Paid)},
+			{Label: "Balance due", Amount: money(f.Balance)},
+		},
+	}
+	for _, l := range []string{res.FirstName + " " + res.LastName, res.Email, res.Phone} {
+		if l != "" {
+			inv.BillTo = append(inv.BillTo, l)
+		}
+	}
+	for _, l := range f.Lines {
+		inv.Lines = append(inv.Lines, invoice.Line{
+			Description: l.Description,
+			Quantity:    l.Quantity,
+			UnitAmount:  money(l.UnitAmount),
+			Amount:      money(l.Amount),
+		})
+	}
+	return inv
+}
@@ -0,0 +1,278 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/folio"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// htmlDateTimeLayout is the layout of the value of datetime-local inputs
+	htmlDateTimeLayout = "2006-01-02T15:04"
+	// checkInHour is the hour guests can check in from on their arrival day, and checkOutHour the hour they check
+	// out by on their departure day
+	checkInHour  = 15
+	checkOutHour = 11
+	// maxIDVerificationLen is the longest note of the ID checked at check-in
+	maxIDVerificationLen = 500
+)
+
+// AdminFrontDesk shows the arrivals, departures and guests in house on a day, today unless the query has a date
+func (rh *RouteHandler) AdminFrontDesk(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	now := time.Now().UTC()
+	day := models.Today(now)
+	if date := parseFormDate(form, "date", htmlDateLayout); !date.IsZero() {
+		day = date
+	}
+	reservations, err := rh.DB.FrontDeskReservations(day, helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	var arrivals, departures, inHouse []frontDeskRow
+	for _, res := range reservations {
+		row := frontDeskRow{Reservation: res, Early: earlyCheckIn(res), Late: lateCheckOut(res, now)}
+		switch {
+		case sameDay(res.CheckInDate, day):
+			arrivals = append(arrivals, row)
+		case sameDay(res.CheckOutDate, day) || (res.InHouse() && res.CheckOutDate.Before(day)):
+			departures = append(departures, row)
+		case res.InHouse():
+			inHouse = append(inHouse, row)
+		}
+	}
+	data := make(map[string]interface{})
+	data["day"] = day
+	data["prev"] = day.AddDate(0, 0, -1).Format(htmlDateLayout)
+	data["next"] = day.AddDate(0, 0, 1).Format(htmlDateLayout)
+	data["arrivals"] = arrivals
+	data["departures"] = departures
+	data["in_house"] = inHouse
+	render.Template(w, r, "admin-reservations-today.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// frontDeskRow is a reservation on the front desk board, marked if its guest arrived early or is leaving late
+type frontDeskRow struct {
+	models.Reservation
+	Early bool
+	Late  bool
+}
+
+// AdminCheckIn checks in the guest of a reservation, recording when they arrived, the ID they showed and the key they
+// were given, moving them to another room if one is assigned and charging a fee for arriving early
+func (rh *RouteHandler) AdminCheckIn(w http.ResponseWriter, r *http.Request) {
+	res, form, stringMap, ok := rh.frontDeskForm(w, r)
+	if !ok {
+		return
+	}
+	if !res.CheckedInAt.IsZero() {
+		rh.App.Session.Put(r.Context(), "error", "The guest has already checked in")
+		http.Redirect(w, r, adminReservationURL(stringMap["src"], res.ID), http.StatusSeeOther)
+		return
+	}
+	form.Required("id_verification")
+	form.MaxLength("id_verification", maxIDVerificationLen)
+	form.MaxLength("key_number", 20)
+	action := models.FrontDeskAction{
+		ReservationID:  res.ID,
+		At:             parseFormTime(form, "checked_in_at", time.Now().UTC()),
+		KeyNumber:      form.Get("key_number"),
+		IDVerification: form.Get("id_verification"),
+	}
+	roomID := res.RoomID
+	if form.Has("room_id") {
+		var err error
+		roomID, err = strconv.Atoi(form.Get("room_id"))
+		if err != nil || roomID < 1 {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+	}
+	action.Fee = rh.frontDeskFee(form, res.ID, "Early check-in")
+	if !form.Valid() {
+		rh.renderAdminReservation(w, r, res, stringMap, form)
+		return
+	}
+
+	if roomID != res.RoomID {
+		ok, err := rh.managesRooms(r, roomID)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		if !ok {
+			helpers.ClientError(w, http.StatusForbidden)
+			return
+		}
+		action.Move = &models.ReservationMove{
+			ReservationID: res.ID,
+			UserID:        rh.App.Session.GetInt(r.Context(), "user_id"),
+			ToRoomID:      roomID,
+			ToStart:       res.CheckInDate,
+			ToEnd:         res.CheckOutDate,
+		}
+	}
+
+	err := rh.DB.CheckInReservation(&action)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		form.Errors.Add("room_id", "The room is not available for the stay")
+		rh.renderAdminReservation(w, r, res, stringMap, form)
+		return
+	}
+	if errors.Is(err, repository.ErrAlreadyCheckedIn) {
+		rh.App.Session.Put(r.Context(), "error", "The guest has already checked in")
+		http.Redirect(w, r, adminReservationURL(stringMap["src"], res.ID), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if move := action.Move; move != nil {
+		err = rh.offerFreedRoom(move.FromRoomID, move.FromStart, move.FromEnd)
+		if err != nil {
+			rh.App.ErrorLog.Println("failed to offer the room a reservation moved from to the waitlist", err)
+		}
+	}
+	rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%s %s checked in", res.FirstName, res.LastName))
+	http.Redirect(w, r, adminReservationURL(stringMap["src"], res.ID), http.StatusSeeOther)
+}
+
+// AdminCheckOut checks out the guest of a reservation, recording when they left and charging a fee for leaving late
+func (rh *RouteHandler) AdminCheckOut(w http.ResponseWriter, r *http.Request) {
+	res, form, stringMap, ok := rh.frontDeskForm(w, r)
+	if !ok {
+		return
+	}
+	if !res.InHouse() {
+		rh.App.Session.Put(r.Context(), "error", "The guest is not checked in")
+		http.Redirect(w, r, adminReservationURL(stringMap["src"], res.ID), http.StatusSeeOther)
+		return
+	}
+	action := models.FrontDeskAction{
+		ReservationID: res.ID,
+		At:            parseFormTime(form, "checked_out_at", time.Now().UTC()),
+	}
+	if action.At.Before(res.CheckedInAt) {
+		form.Errors.Add("checked_out_at", "The guest cannot check out before they checked in")
+	}
+	action.Fee = rh.frontDeskFee(form, res.ID, "Late check-out")
+	if !form.Valid() {
+		rh.renderAdminReservation(w, r, res, stringMap, form)
+		return
+	}
+
+	err := rh.DB.CheckOutReservation(&action)
+	if errors.Is(err, repository.ErrNotInHouse) {
+		rh.App.Session.Put(r.Context(), "error", "The guest is not checked in")
+		http.Redirect(w, r, adminReservationURL(stringMap["src"], res.ID), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%s %s checked out", res.FirstName, res.LastName))
+	http.Redirect(w, r, adminReservationURL(stringMap["src"], res.ID), http.StatusSeeOther)
+}
+
+// frontDeskForm reads the reservation in the URL and the posted check-in or check-out form
+func (rh *RouteHandler) frontDeskForm(w http.ResponseWriter, r *http.Request) (models.Reservation, *forms.Form,
+	map[string]string, bool) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return models.Reservation{}, nil, nil, false
+	}
+	res, err := rh.reservationFromURL(r)
+	if err != nil {
+		staffError(w, err)
+		return res, nil, nil, false
+	}
+	stringMap := make(map[string]string)
+	stringMap["src"] = chi.URLParam(r, "src")
+	stringMap["month"] = r.Form.Get("month")
+	stringMap["year"] = r.Form.Get("year")
+	return res, forms.New(r.PostForm), stringMap, true
+}
+
+// frontDeskFee reads the optional fee of a check-in or check-out, described as description unless the form has a
+// description
+func (rh *RouteHandler) frontDeskFee(form *forms.Form, reservationID int, description string) models.FolioItem {
+	fee := models.FolioItem{
+		ReservationID: reservationID,
+		Kind:          string(folio.KindAdjustment),
+		Description:   description,
+		Quantity:      1,
+	}
+	if form.Has("fee_description") {
+		form.MaxLength("fee_description", 255)
+		fee.Description = form.Get("fee_description")
+	}
+	if form.Has("fee") {
+		var err error
+		fee.UnitAmount, err = currency.ParseAmount(form.Get("fee"), rh.App.Currency.Base())
+		if err != nil {
+			form.Errors.Add("fee", "Enter an amount such as 12.50")
+		}
+	}
+	return fee
+}
+
+// parseFormTime parses the optional date and time of a datetime-local input in UTC, which the front desk keeps its times
+// in, returning def if the field is empty and adding an error to the form if it is not valid
+func parseFormTime(form *forms.Form, field string, def time.Time) time.Time {
+	if !form.Has(field) {
+		return def
+	}
+	t, err := time.ParseInLocation(htmlDateTimeLayout, form.Get(field), time.UTC)
+	if err != nil {
+		form.Errors.Add(field, "Invalid date and time")
+		return def
+	}
+	return t
+}
+
+// sameDay returns true if the times are on the same date
+func sameDay(a, b time.Time) bool {
+	return a.Year() == b.Year() && a.YearDay() == b.YearDay()
+}
+
+// earlyCheckIn returns true if the guest of the reservation checked in before the check-in hour of their arrival day
+func earlyCheckIn(res models.Reservation) bool {
+	if res.CheckedInAt.IsZero() {
+		return false
+	}
+	from := time.Date(res.CheckInDate.Year(), res.CheckInDate.Month(), res.CheckInDate.Day(), checkInHour, 0, 0, 0,
+		res.CheckedInAt.Location())
+	return res.CheckedInAt.Before(from)
+}
+
+// lateCheckOut returns true if the guest of the reservation checked out after the check-out hour of their departure
+// day, or is still in house after it
+func lateCheckOut(res models.Reservation, now time.Time) bool {
+	left := res.CheckedOutAt
+	if left.IsZero() {
+		if !res.InHouse() {
+			return false
+		}
+		left = now
+	}
+	by := time.Date(res.CheckOutDate.Year(), res.CheckOutDate.Month(), res.CheckOutDate.Day(), checkOutHour, 0, 0, 0,
+		left.Location())
+	return left.After(by)
+}
@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/property"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	maxGuestsListed = 100
+	maxGuestTags    = 20
+	maxGuestTagLen  = 40
+)
+
+// guestTags are the tags offered on the guest page, which other tags can be added to
+var guestTags = []string{models.GuestTagVIP, models.GuestTagDoNotRent, models.GuestTagReturning,
+	models.GuestTagAccessible}
+
+// AdminGuests lists the guests whose name, email or phone number matches the search
+func (rh *RouteHandler) AdminGuests(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	guests, err := rh.DB.SearchGuests(strings.TrimSpace(form.Get("q")), helpers.AdminPropertyID(r), maxGuestsListed)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["guests"] = guests
+	data["limit"] = maxGuestsListed
+	render.Template(w, r, "admin-guests.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// AdminShowGuest shows the profile of a guest with every past and future stay
+func (rh *RouteHandler) AdminShowGuest(w http.ResponseWriter, r *http.Request) {
+	guest, ok := rh.guestFromURL(w, r)
+	if !ok {
+		return
+	}
+	rh.renderGuest(w, r, guest, forms.New(nil))
+}
+
+// AdminPostGuest updates the name, phone number, notes, tags and preferences of a guest
+func (rh *RouteHandler) AdminPostGuest(w http.ResponseWriter, r *http.Request) {
+	guest, ok := rh.guestFromURL(w, r)
+	if !ok {
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("first_name", "last_name")
+	form.MaxLength("first_name", 255)
+	form.MaxLength("last_name", 255)
+	form.MaxLength("phone", 20)
+	guest.FirstName = form.Get("first_name")
+	guest.LastName = form.Get("last_name")
+	guest.Phone = form.Get("phone")
+	guest.Notes = strings.TrimSpace(form.Get("notes"))
+	guest.Preferences = strings.TrimSpace(form.Get("preferences"))
+	guest.Tags = parseGuestTags(append(form.Values["tag"], strings.Split(form.Get("other_tags"), ",")...))
+	if len(guest.Tags) > maxGuestTags {
+		form.Errors.Add("other_tags", fmt.Sprintf("A guest can have up to %d tags", maxGuestTags))
+	}
+	if !form.Valid() {
+		rh.renderGuest(w, r, guest, form)
+		return
+	}
+
+	err = rh.DB.UpdateGuest(&guest)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Guest saved")
+	http.Redirect(w, r, fmt.Sprintf("/admin/guests/%d", guest.ID), http.StatusSeeOther)
+}
+
+// AdminMergeGuest merges a duplicate guest into the guest of the page, moving their stays to it
+func (rh *RouteHandler) AdminMergeGuest(w http.ResponseWriter, r *http.Request) {
+	guest, ok := rh.guestFromURL(w, r)
+	if !ok {
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	guestURL := fmt.Sprintf("/admin/guests/%d", guest.ID)
+
+	mergeID, err := strconv.Atoi(r.PostForm.Get("merge_id"))
+	if err != nil || mergeID == guest.ID {
+		rh.App.Session.Put(r.Context(), "error", "Choose another guest to merge")
+		http.Redirect(w, r, guestURL, http.StatusSeeOther)
+		return
+	}
+	merged, err := rh.DB.GetGuestByID(mergeID)
+	if errors.Is(err, repository.ErrGuestNotFound) {
+		rh.App.Session.Put(r.Context(), "error", "The guest to merge no longer exists")
+		http.Redirect(w, r, guestURL, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	ok, err = rh.stayedAtProperty(r, merged.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !ok {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = rh.DB.MergeGuests(guest.ID, merged.ID)
+	if errors.Is(err, repository.ErrGuestNotFound) {
+		rh.App.Session.Put(r.Context(), "error", "The guest to merge no longer exists")
+		http.Redirect(w, r, guestURL, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%s %s (%s) merged into this guest", merged.FirstName,
+		merged.LastName, merged.Email))
+	http.Redirect(w, r, guestURL, http.StatusSeeOther)
+}
+
+// guestFromURL returns the guest with the id in the URL, responding with an error if there is none or they never
+// stayed at the property the signed-in user works at
+func (rh *RouteHandler) guestFromURL(w http.ResponseWriter, r *http.Request) (models.Guest, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return models.Guest{}, false
+	}
+	guest, err := rh.DB.GetGuestByID(id)
+	if errors.Is(err, repository.ErrGuestNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return guest, false
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return guest, false
+	}
+	ok, err := rh.stayedAtProperty(r, guest.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return guest, false
+	}
+	if !ok {
+		helpers.ClientError(w, http.StatusForbidden)
+		return guest, false
+	}
+	return guest, true
+}
+
+// stayedAtProperty reports whether the guest has a reservation at the property the signed-in user works at, which is
+// always the case for staff of every property
+func (rh *RouteHandler) stayedAtProperty(r *http.Request, guestID int) (bool, error) {
+	if unrestricted(r) {
+		return true, nil
+	}
+	stays, err := rh.DB.StaysForGuest(guestID, property.StaffFromContext(r.Context()))
+	return len(stays) > 0, err
+}
+
+func (rh *RouteHandler) renderGuest(w http.ResponseWriter, r *http.Request, guest models.Guest, form *forms.Form) {
+	stays, err := rh.DB.StaysForGuest(guest.ID, helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	duplicates, err := rh.DB.PossibleDuplicateGuests(guest, helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	// stays are upcoming until the guest checks out
+	today := time.Now().Truncate(24 * time.Hour)
+	var upcoming, past []models.Reservation
+	for _, stay := range stays {
+		if stay.CheckOutDate.After(today) {
+			upcoming = append(upcoming, stay)
+		} else {
+			past = append(past, stay)
+		}
+	}
+	var otherTags []string
+	for _, tag := range guest.Tags {
+		if !isGuestTag(tag) {
+			otherTags = append(otherTags, tag)
+		}
+	}
+
+	data := make(map[string]interface{})
+	data["guest"] = guest
+	data["upcoming"] = upcoming
+	data["past"] = past
+	data["duplicates"] = duplicates
+	data["tags"] = guestTags
+	data["other_tags"] = strings.Join(otherTags, ", ")
+	render.Template(w, r, "admin-guest-show.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// parseGuestTags returns the tags with spaces trimmed, dropping empty and repeated tags, and using the case of the
+// tags offered on the guest page
+func parseGuestTags(values []string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+	for _, v := range values {
+		tag := strings.Join(strings.Fields(v), " ")
+		if runes := []rune(tag); len(runes) > maxGuestTagLen {
+			tag = string(runes[:maxGuestTagLen])
+		}
+		for _, t := range guestTags {
+			if strings.EqualFold(t, tag) {
+				tag = t
+			}
+		}
+		if tag == "" || seen[strings.ToLower(tag)] {
+			continue
+		}
+		seen[strings.ToLower(tag)] = true
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// isGuestTag returns true if the tag is one of the tags offered on the guest page
+func isGuestTag(tag string) bool {
+	for _, t := range guestTags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
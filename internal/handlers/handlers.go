@@ -7,21 +7,31 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/sunil206b/smart_booking/internal/config"
 	"github.com/sunil206b/smart_booking/internal/driver"
+	"github.com/sunil206b/smart_booking/internal/folio"
 	"github.com/sunil206b/smart_booking/internal/forms"
 	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/pricing"
+	"github.com/sunil206b/smart_booking/internal/property"
+	"github.com/sunil206b/smart_booking/internal/payments"
 	"github.com/sunil206b/smart_booking/internal/render"
 	"github.com/sunil206b/smart_booking/internal/repository"
 	"github.com/sunil206b/smart_booking/internal/repository/dbrepo"
+	"github.com/sunil206b/smart_booking/internal/rules"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 )
 
 const (
-	apiDateLayout         = "01/02/2006"
 	restrictionDateLayout = "01/2/2006"
+	maxStayNights         = 30
+	defaultCalendarDays   = 90
+	maxCalendarDays       = 366
+	maxGuests             = 8
 )
 
 var Handler *RouteHandler
@@ -32,11 +42,12 @@ type RouteHandler struct {
 }
 
 type jsonResponse struct {
-	OK        bool   `json:"ok,omitempty"`
-	Message   string `json:"message,omitempty"`
-	RoomID    string `json:"room_id"`
-	StartDate string `json:"start_date"`
-	EndDate   string `json:"end_date"`
+	OK        bool              `json:"ok,omitempty"`
+	Message   string            `json:"message,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+	RoomID    string            `json:"room_id"`
+	StartDate string            `json:"start_date"`
+	EndDate   string            `json:"end_date"`
 }
 
 func NewRouteHandler(a *config.AppConfig, db *driver.DB) *RouteHandler {
@@ -46,6 +57,14 @@ func NewRouteHandler(a *config.AppConfig, db *driver.DB) *RouteHandler {
 	}
 }
 
+// NewTestRouteHandler creates a route handler backed by the testing repository
+func NewTestRouteHandler(a *config.AppConfig) *RouteHandler {
+	return &RouteHandler{
+		App: a,
+		DB:  dbrepo.NewTestingRepo(a),
+	}
+}
+
 func NewHandler(r *RouteHandler) {
 	Handler = r
 }
@@ -70,41 +89,97 @@ func (rh *RouteHandler) Majors(w http.ResponseWriter, r *http.Request) {
 	render.Template(w, r, "majors.page.tmpl", &models.TemplateData{})
 }
 
+// SetLanguage remembers the chosen locale in the language cookie and sends the visitor back to the page they came from
+func (rh *RouteHandler) SetLanguage(w http.ResponseWriter, r *http.Request) {
+	locale := chi.URLParam(r, "locale")
+	if !i18n.Supported(locale) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	http.SetCookie(w, i18n.Cookie(locale, rh.App.InProduction))
+	redirectBack(w, r)
+}
+
+// SetCurrency remembers the currency chosen by the visitor for displaying prices and sends them back to the page
+// they came from
+func (rh *RouteHandler) SetCurrency(w http.ResponseWriter, r *http.Request) {
+	code := strings.ToUpper(chi.URLParam(r, "code"))
+	if !rh.App.Currency.Supported(code) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "currency", code)
+	redirectBack(w, r)
+}
+
+// redirectBack redirects to the page of this site given by the Referer header, without its locale and property
+// prefixes, or to the home page
+func redirectBack(w http.ResponseWriter, r *http.Request) {
+	back := "/"
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == r.Host && ref.Path != "" {
+		_, back = i18n.SplitPath(ref.Path)
+		_, back = property.SplitPath(back)
+		if ref.RawQuery != "" {
+			back += "?" + ref.RawQuery
+		}
+	}
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
+
 // Availability renders the rooms available page
 func (rh *RouteHandler) Availability(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{})
+	render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{
+		Form: newForm(r, nil),
+	})
 }
 
 // PostAvailability receives form data from the request and send available rooms data
 func (rh *RouteHandler) PostAvailability(w http.ResponseWriter, r *http.Request) {
-	start := r.Form.Get("start_date")
-	end := r.Form.Get("end_date")
-	startDate, err := time.Parse(apiDateLayout, start)
+	err := r.ParseForm()
 	if err != nil {
-		rh.App.ErrorLog.Println("failed to convert start date", err)
 		helpers.ServerError(w, err)
 		return
 	}
-	endDate, err := time.Parse(apiDateLayout, end)
-	if err != nil {
-		rh.App.ErrorLog.Println("failed to convert end date", err)
-		helpers.ServerError(w, err)
+
+	form := newForm(r, r.PostForm)
+	validateStayDates(form, "start_date", "end_date")
+	if !form.Valid() {
+		render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
 		return
 	}
-	rooms, err := rh.DB.SearchAllAvailableRooms(startDate, endDate)
+
+	// a new search gives up the room held for the guest
+	err = rh.releaseHold(r)
+	if err != nil {
+		rh.App.ErrorLog.Println("failed to release hold", err)
+	}
+
+	layout := i18n.DateLayout(form.Locale)
+	startDate, _ := time.Parse(layout, form.Get("start_date"))
+	endDate, _ := time.Parse(layout, form.Get("end_date"))
+	rooms, excluded, err := rh.DB.SearchAllAvailableRooms(startDate, endDate, helpers.PropertyID(r))
 	if err != nil {
 		rh.App.ErrorLog.Println("failed to get rooms", err)
 		helpers.ServerError(w, err)
 		return
 	}
 	if len(rooms) == 0 {
-		rh.App.Session.Put(r.Context(), "error", fmt.Sprintf("No Rooms Available within the range of %s-%s", start, end))
-		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		msg := i18n.T(form.Locale, "search.no_rooms", startDate, endDate)
+		if reasons := exclusionReasons(form.Locale, excluded); len(reasons) > 0 {
+			msg = fmt.Sprintf("%s: %s", msg, strings.Join(reasons, "; "))
+		}
+		rh.App.Session.Put(r.Context(), "error", msg)
+		// offer to let the guest know if a room frees up for the dates
+		rh.App.Session.Put(r.Context(), "waitlist", models.WaitlistEntry{StartDate: startDate, EndDate: endDate})
+		http.Redirect(w, r, "/waitlist", http.StatusSeeOther)
 		return
 	}
 
 	data := make(map[string]interface{})
 	data["rooms"] = rooms
+	data["excluded"] = excluded
 
 	res := models.Reservation{
 		CheckInDate:  startDate,
@@ -119,28 +194,45 @@ func (rh *RouteHandler) PostAvailability(w http.ResponseWriter, r *http.Request)
 
 // AvailabilityJSON receives form data from the request and send JSON response
 func (rh *RouteHandler) AvailabilityJSON(w http.ResponseWriter, r *http.Request) {
-	start := r.Form.Get("check_in_date")
-	end := r.Form.Get("check_out_date")
-
-	startDate, err := time.Parse(apiDateLayout, start)
+	err := r.ParseForm()
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 
-	endDate, err := time.Parse(apiDateLayout, end)
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
+	form := newForm(r, r.Form)
+	validateStayDates(form, "check_in_date", "check_out_date")
+	form.Required("room_id")
+	roomID, err := strconv.Atoi(form.Get("room_id"))
+	if form.Has("room_id") && err != nil {
+		form.AddError("room_id", "search.invalid_room")
 	}
 
-	roomID, err := strconv.Atoi(r.Form.Get("room_id"))
-	if err != nil {
-		helpers.ServerError(w, err)
+	start := form.Get("check_in_date")
+	end := form.Get("check_out_date")
+	if !form.Valid() {
+		resp := jsonResponse{
+			Message:   i18n.T(form.Locale, "search.invalid"),
+			Errors:    make(map[string]string),
+			StartDate: start,
+			EndDate:   end,
+			RoomID:    form.Get("room_id"),
+		}
+		for _, field := range []string{"room_id", "check_out_date", "check_in_date"} {
+			if msg := form.Errors.Get(field); msg != "" {
+				resp.Message = msg
+				resp.Errors[field] = msg
+			}
+		}
+		writeJSON(w, http.StatusBadRequest, resp)
 		return
 	}
 
-	available, err := rh.DB.SearchAvailabilityByDatesByRoom(startDate, endDate, roomID)
+	layout := i18n.DateLayout(form.Locale)
+	startDate, _ := time.Parse(layout, start)
+	endDate, _ := time.Parse(layout, end)
+
+	available, reason, err := rh.DB.SearchAvailabilityByDatesByRoom(startDate, endDate, roomID)
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
@@ -148,7 +240,7 @@ func (rh *RouteHandler) AvailabilityJSON(w http.ResponseWriter, r *http.Request)
 
 	resp := jsonResponse{
 		OK:        available,
-		Message:   "",
+		Message:   i18n.TMessage(form.Locale, reason),
 		StartDate: start,
 		EndDate:   end,
 		RoomID:    strconv.Itoa(roomID),
@@ -163,6 +255,153 @@ func (rh *RouteHandler) AvailabilityJSON(w http.ResponseWriter, r *http.Request)
 	w.Write(out)
 }
 
+type calendarNightJSON struct {
+	Date              string `json:"date"`
+	Available         bool   `json:"available"`
+	Price             int    `json:"price"`
+	DisplayPrice      string `json:"display_price"`
+	MinStay           int    `json:"min_stay,omitempty"`
+	MaxStay           int    `json:"max_stay,omitempty"`
+	ClosedToArrival   bool   `json:"closed_to_arrival"`
+	ClosedToDeparture bool   `json:"closed_to_departure"`
+}
+
+type roomCalendarJSON struct {
+	RoomID   int                 `json:"room_id"`
+	RoomName string              `json:"room_name"`
+	Nights   []calendarNightJSON `json:"nights"`
+}
+
+type calendarResponse struct {
+	OK        bool               `json:"ok"`
+	Message   string             `json:"message,omitempty"`
+	Currency  string             `json:"currency,omitempty"`
+	StartDate string             `json:"start_date"`
+	EndDate   string             `json:"end_date"`
+	Rooms     []roomCalendarJSON `json:"rooms"`
+}
+
+// AvailabilityCalendarJSON sends the per night availability, price and rule flags of every room, or of the
+// room given by room_id, for the number of days given by days starting at start. Prices are in minor units of the
+// base currency, with display prices in the currency chosen by the visitor.
+func (rh *RouteHandler) AvailabilityCalendarJSON(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
+	layout := i18n.DateLayout(locale)
+	today := time.Now()
+	startDate := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if start := r.URL.Query().Get("start"); start != "" {
+		t, err := time.Parse(layout, start)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, calendarResponse{Message: i18n.T(locale, "calendar.invalid_start")})
+			return
+		}
+		startDate = t
+	}
+
+	days := defaultCalendarDays
+	if d := r.URL.Query().Get("days"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < 1 || n > maxCalendarDays {
+			writeJSON(w, http.StatusBadRequest, calendarResponse{Message: i18n.T(locale, "calendar.invalid_days", maxCalendarDays)})
+			return
+		}
+		days = n
+	}
+	endDate := startDate.AddDate(0, 0, days)
+
+	roomID := 0
+	if id := r.URL.Query().Get("room_id"); id != "" {
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, calendarResponse{Message: i18n.T(locale, "search.invalid_room")})
+			return
+		}
+		roomID = n
+	}
+
+	calendars, err := rh.DB.AvailabilityCalendar(startDate, endDate, roomID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	code := helpers.Currency(r)
+	resp := calendarResponse{
+		OK:        true,
+		Currency:  code,
+		StartDate: startDate.Format(layout),
+		EndDate:   endDate.Format(layout),
+		Rooms:     []roomCalendarJSON{},
+	}
+	for _, c := range calendars {
+		room := roomCalendarJSON{
+			RoomID:   c.Room.ID,
+			RoomName: c.Room.RoomName,
+		}
+		for _, n := range c.Nights {
+			room.Nights = append(room.Nights, calendarNightJSON{
+				Date:              n.Date.Format(layout),
+				Available:         !n.Booked,
+				Price:             n.Price,
+				DisplayPrice:      rh.App.Currency.Format(n.Price, code, locale),
+				MinStay:           n.MinStay,
+				MaxStay:           n.MaxStay,
+				ClosedToArrival:   rules.ClosedToArrival(n, today),
+				ClosedToDeparture: n.ClosedToDeparture,
+			})
+		}
+		resp.Rooms = append(resp.Rooms, room)
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// writeJSON writes the value as an indented JSON response with the given status
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "     ")
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(out)
+}
+
+// validateStayDates checks that the check-in and check-out fields of the form hold a stay of at least one night,
+// no longer than the maximum stay, which does not start in the past. Dates are read in the layout of the form's locale.
+func validateStayDates(form *forms.Form, startField, endField string) {
+	layout := i18n.DateLayout(form.Locale)
+	form.Required(startField, endField)
+	if form.Has(startField) && form.IsDate(startField, layout) {
+		form.NotInPast(startField, layout)
+	}
+	if form.Has(endField) && form.IsDate(endField, layout) {
+		if form.DateRange(startField, endField, layout) {
+			form.MaxStay(startField, endField, layout, maxStayNights)
+		}
+	}
+}
+
+// exclusionReasons returns the distinct reasons rooms were excluded from a search, translated into the locale
+func exclusionReasons(locale string, excluded []models.ExcludedRoom) []string {
+	var reasons []string
+	seen := make(map[string]bool)
+	for _, ex := range excluded {
+		reason := i18n.TMessage(locale, ex.Reason)
+		if seen[reason] {
+			continue
+		}
+		seen[reason] = true
+		reasons = append(reasons, reason)
+	}
+	return reasons
+}
+
+// newForm creates a form whose error messages are translated into the locale of the request
+func newForm(r *http.Request, data url.Values) *forms.Form {
+	return forms.NewLocalized(data, i18n.FromContext(r.Context()))
+}
+
 // Reservation renders the contact page
 func (rh *RouteHandler) Reservation(w http.ResponseWriter, r *http.Request) {
 	res, ok := rh.App.Session.Get(r.Context(), "reservation").(models.Reservation)
@@ -177,19 +416,40 @@ func (rh *RouteHandler) Reservation(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	res.Room.RoomName = room.RoomName
+	res.Room.Price = room.Price
+	res.Room.PropertyID = room.PropertyID
+	res.Room.PropertyName = room.PropertyName
+	if res.Guests < 1 {
+		res.Guests = 1
+	}
 
-	rh.App.Session.Put(r.Context(), "reservation", res)
+	quote, err := rh.quoteStay(res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	extras, err := rh.extraChoices(res)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
-	checkinDate := res.CheckInDate.Format(apiDateLayout)
-	checkoutDate := res.CheckOutDate.Format(apiDateLayout)
+	rh.App.Session.Put(r.Context(), "reservation", res)
 
+	locale := i18n.FromContext(r.Context())
 	stringMap := make(map[string]string)
-	stringMap["check_in_date"] = checkinDate
-	stringMap["check_out_date"] = checkoutDate
+	stringMap["check_in_date"] = i18n.FormatDate(locale, res.CheckInDate)
+	stringMap["check_out_date"] = i18n.FormatDate(locale, res.CheckOutDate)
+	if hold, ok := rh.App.Session.Get(r.Context(), "hold").(models.RoomRestriction); ok {
+		stringMap["hold_until"] = hold.ExpiresAt.Format("15:04")
+	}
 	data := make(map[string]interface{})
 	data["reservation"] = res
+	data["quote"] = quote
+	data["extras"] = extras
+	data["max_guests"] = maxGuests
 	render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
-		Form:      forms.New(nil),
+		Form:      newForm(r, nil),
 		Data:      data,
 		StringMap: stringMap,
 	})
@@ -200,6 +460,7 @@ func (rh *RouteHandler) PostReservation(w http.ResponseWriter, r *http.Request)
 	reservation, ok := rh.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
 		helpers.ServerError(w, errors.New("can't get reservation from session"))
+		return
 	}
 	err := r.ParseForm()
 	if err != nil {
@@ -207,72 +468,159 @@ func (rh *RouteHandler) PostReservation(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	reservation.FirstName = r.Form.Get("first_name")
-	reservation.LastName = r.Form.Get("last_name")
-	reservation.Email = r.Form.Get("email")
-	reservation.Phone = r.Form.Get("phone")
-
-	form := forms.New(r.PostForm)
+	form := newForm(r, r.PostForm)
+	validateGuestDetails(form)
 
-	form.Required("first_name", "last_name", "email")
-	form.MinLength("first_name", 3)
-	form.IsEmail("email")
+	var guest guestDetails
+	if err = form.Bind(&guest); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	guest.apply(&reservation)
+	if form.Has("guests") && form.IntRange("guests", 1, maxGuests) {
+		reservation.Guests, _ = strconv.Atoi(form.Get("guests"))
+	}
+	err = rh.applyPromoCode(form, &reservation)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 
-	if !form.Valid() {
+	quote, err := rh.quoteStay(reservation)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	extras, err := rh.extraChoices(reservation)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	chooseExtras(form, extras, &quote)
+	reservation.Guests = quote.Guests
+	reservation.RoomAmount = quote.RoomCharge
+	reservation.Discount = quote.Discount
+	reservation.TotalAmount = quote.Total
+	reservation.Taxes = quote.Taxes()
+	reservation.Extras = quote.Extras
+
+	renderForm := func() {
+		stringMap := make(map[string]string)
+		stringMap["check_in_date"] = i18n.FormatDate(form.Locale, reservation.CheckInDate)
+		stringMap["check_out_date"] = i18n.FormatDate(form.Locale, reservation.CheckOutDate)
+		if hold, ok := rh.App.Session.Get(r.Context(), "hold").(models.RoomRestriction); ok {
+			stringMap["hold_until"] = hold.ExpiresAt.Format("15:04")
+		}
 		data := make(map[string]interface{})
 		data["reservation"] = reservation
+		data["quote"] = quote
+		data["extras"] = extras
+		data["max_guests"] = maxGuests
 
 		render.Template(w, r, "make-reservation.page.tmpl", &models.TemplateData{
-			Form: form,
-			Data: data,
+			Form:      form,
+			Data:      data,
+			StringMap: stringMap,
 		})
+	}
+
+	if !form.Valid() {
+		renderForm()
+		return
+	}
+
+	// make sure the room is still the guest's, holding it again in case the hold expired while they were filling in
+	// the form
+	err = rh.holdRoom(r, reservation)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		rh.roomTaken(w, r)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
 		return
 	}
 
+	reservation.CreatedAt = time.Now()
+	reservation.UpdatedAt = reservation.CreatedAt
 	err = rh.DB.CreateReservation(&reservation)
+	if errors.Is(err, repository.ErrPromoCodeUsedUp) {
+		// the last use of the code went to another guest since it was checked
+		form.AddError("promo_code", "promo.used_up")
+		renderForm()
+		return
+	}
+	if errors.Is(err, repository.ErrExtraSoldOut) {
+		// another guest booked the last of an extra since the form was shown
+		extras, err = rh.extraChoices(reservation)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		form = newForm(r, r.PostForm)
+		chooseExtras(form, extras, &pricing.Quote{})
+		if form.Valid() {
+			form.AddError("extras", "extras.sold_out")
+		}
+		renderForm()
+		return
+	}
 	if err != nil {
 		rh.App.ErrorLog.Println("failed to create reservation ", err)
 		helpers.ServerError(w, err)
 		return
 	}
 
-	restriction := models.RoomRestriction{
-		StartDate:     reservation.CheckInDate,
-		EndDate:       reservation.CheckOutDate,
-		RoomID:        reservation.RoomID,
-		ReservationID: reservation.ID,
-		RestrictionID: 1,
-		CreatedAt:     time.Now(),
-		UpdatedAt:     time.Now(),
+	hold, _ := rh.App.Session.Pop(r.Context(), "hold").(models.RoomRestriction)
+	err = rh.DB.ConvertHold(hold.ID, reservation.ID)
+	if errors.Is(err, repository.ErrHoldExpired) {
+		restriction := models.RoomRestriction{
+			StartDate:     reservation.CheckInDate,
+			EndDate:       reservation.CheckOutDate,
+			RoomID:        reservation.RoomID,
+			ReservationID: reservation.ID,
+			RestrictionID: models.RestrictionReservation,
+			CreatedAt:     time.Now(),
+			UpdatedAt:     time.Now(),
+		}
+		err = rh.DB.CreateRoomRestriction(&restriction)
 	}
-	err = rh.DB.CreateRoomRestriction(&restriction)
 	if err != nil {
 		rh.App.ErrorLog.Println("failed to create room restriction ", err)
 		helpers.ServerError(w, err)
 		return
 	}
+	err = rh.acceptWaitlistOffer(r, reservation)
+	if err != nil {
+		rh.App.ErrorLog.Println("failed to accept the waitlist offer of a reservation", err)
+	}
 
 	htmlMsg := fmt.Sprintf(`
-		<strong>Reservation Confirmation</strong><br>
-		Dear %s, %s: <br>
-		This is confirm your reservation from %s to %s.
-`, reservation.FirstName, reservation.LastName, reservation.CheckInDate.Format(apiDateLayout), reservation.CheckInDate.Format(apiDateLayout))
+		<strong>%s</strong><br>
+		%s<br>
+		%s
+`, i18n.T(form.Locale, "email.confirmation.subject"),
+		i18n.T(form.Locale, "email.confirmation.body", reservation.FirstName, reservation.LastName, reservation.CheckInDate, reservation.CheckOutDate),
+		rh.priceBreakdown(form.Locale, reservation))
 	msg := &models.MailData{
 		To:       reservation.Email,
-		From:     "me@here.com",
-		Subject:  "Reservation Confirmation",
+		From:     rh.mailSender(reservation.Room.PropertyID),
+		Subject:  i18n.T(form.Locale, "email.confirmation.subject"),
 		Content:  htmlMsg,
 		Template: "basic.html",
+		Locale:   form.Locale,
 	}
 	rh.App.MailChan <- msg
 
 	htmlMsg = fmt.Sprintf(`
 		<strong>Reservation Confirmation</strong><br>
-		A reservation has been made for %s from %s to %s.
-`, reservation.Room.RoomName, reservation.CheckInDate.Format(apiDateLayout), reservation.CheckInDate.Format(apiDateLayout))
+		A reservation has been made for %s from %s to %s.<br>
+		%s
+`, reservation.Room.RoomName, i18n.FormatDate(i18n.DefaultLocale, reservation.CheckInDate), i18n.FormatDate(i18n.DefaultLocale, reservation.CheckOutDate),
+		rh.priceBreakdown(i18n.DefaultLocale, reservation))
 	msg = &models.MailData{
-		To:       "me@here.com",
-		From:     "me@here.com",
+		To:       rh.mailInbox(reservation.Room.PropertyID),
+		From:     rh.mailSender(reservation.Room.PropertyID),
 		Subject:  "Reservation Confirmation",
 		Content:  htmlMsg,
 		Template: "basic.html",
@@ -280,7 +628,37 @@ func (rh *RouteHandler) PostReservation(w http.ResponseWriter, r *http.Request)
 	rh.App.MailChan <- msg
 
 	rh.App.Session.Put(r.Context(), "reservation", reservation)
-	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+	http.Redirect(w, r, "/reservation-payment", http.StatusSeeOther)
+}
+
+// guestDetails holds the guest fields posted by the reservation forms
+type guestDetails struct {
+	FirstName string `form:"first_name"`
+	LastName  string `form:"last_name"`
+	Email     string `form:"email"`
+	Phone     string `form:"phone"`
+}
+
+// apply copies the guest details into the reservation
+func (g guestDetails) apply(res *models.Reservation) {
+	res.FirstName = g.FirstName
+	res.LastName = g.LastName
+	res.Email = g.Email
+	res.Phone = g.Phone
+}
+
+// validateGuestDetails checks the guest fields of a reservation form against the limits of the reservations table,
+// normalising the phone number to E.164
+func validateGuestDetails(form *forms.Form) {
+	form.Required("first_name", "last_name", "email")
+	form.MinLength("first_name", 3)
+	form.MaxLength("first_name", 255)
+	form.MaxLength("last_name", 255)
+	form.MaxLength("email", 255)
+	form.IsEmail("email")
+	if form.Has("phone") {
+		form.IsPhone("phone")
+	}
 }
 
 // Contact renders the contact page
@@ -293,24 +671,30 @@ func (rh *RouteHandler) ReservationSummary(w http.ResponseWriter, r *http.Reques
 	reservation, ok := rh.App.Session.Get(r.Context(), "reservation").(models.Reservation)
 	if !ok {
 		rh.App.ErrorLog.Println("Cannot get item from session")
-		rh.App.Session.Put(r.Context(), "error", "There are no reservations made at this point")
+		rh.App.Session.Put(r.Context(), "error", i18n.T(i18n.FromContext(r.Context()), "summary.no_reservation"))
 		http.Redirect(w, r, "/", http.StatusTemporaryRedirect)
 		return
 	}
 
-	checkinDate := reservation.CheckInDate.Format(apiDateLayout)
-	checkoutDate := reservation.CheckOutDate.Format(apiDateLayout)
-
-	stringMap := make(map[string]string)
-	stringMap["check_in_date"] = checkinDate
-	stringMap["check_out_date"] = checkoutDate
+	resPayments, err := rh.DB.PaymentsForReservation(reservation.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	paid := 0
+	for _, p := range resPayments {
+		if p.Status == string(payments.StatusAuthorized) || p.Status == string(payments.StatusCaptured) {
+			paid += p.Amount
+		}
+	}
 
 	rh.App.Session.Remove(r.Context(), "reservation")
 	data := make(map[string]interface{})
 	data["reservation"] = reservation
+	data["paid"] = paid
+	data["balance"] = reservation.TotalAmount - paid
 	render.Template(w, r, "reservation-summary.page.tmpl", &models.TemplateData{
-		Data:      data,
-		StringMap: stringMap,
+		Data: data,
 	})
 }
 
@@ -327,6 +711,15 @@ func (rh *RouteHandler) ChooseRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	res.RoomID = roomID
+	err = rh.holdRoom(r, res)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		rh.roomTaken(w, r)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 	rh.App.Session.Put(r.Context(), "reservation", res)
 
 	http.Redirect(w, r, "/make-reservations", http.StatusSeeOther)
@@ -336,21 +729,24 @@ func (rh *RouteHandler) ChooseRoom(w http.ResponseWriter, r *http.Request) {
 func (rh *RouteHandler) BookRoom(w http.ResponseWriter, r *http.Request) {
 	ID, err := strconv.Atoi(r.URL.Query().Get("id"))
 	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
-	start := r.URL.Query().Get("start")
-	startDate, err := time.Parse(apiDateLayout, start)
-	if err != nil {
-		helpers.ServerError(w, err)
+		helpers.ClientError(w, http.StatusBadRequest)
 		return
 	}
-	end := r.URL.Query().Get("end")
-	endDate, err := time.Parse(apiDateLayout, end)
-	if err != nil {
-		helpers.ServerError(w, err)
+
+	form := newForm(r, url.Values{
+		"start_date": {r.URL.Query().Get("start")},
+		"end_date":   {r.URL.Query().Get("end")},
+	})
+	validateStayDates(form, "start_date", "end_date")
+	if !form.Valid() {
+		render.Template(w, r, "search-availability.page.tmpl", &models.TemplateData{
+			Form: form,
+		})
 		return
 	}
+	layout := i18n.DateLayout(form.Locale)
+	startDate, _ := time.Parse(layout, form.Get("start_date"))
+	endDate, _ := time.Parse(layout, form.Get("end_date"))
 
 	room, err := rh.DB.GetRoomByID(ID)
 	if err != nil {
@@ -358,12 +754,23 @@ func (rh *RouteHandler) BookRoom(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	res := new(models.Reservation)
+	var res models.Reservation
 	res.RoomID = ID
 	res.CheckInDate = startDate
 	res.CheckOutDate = endDate
 	res.Room.RoomName = room.RoomName
+	res.Room.PropertyID = room.PropertyID
+	res.Room.PropertyName = room.PropertyName
 
+	err = rh.holdRoom(r, res)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		rh.roomTaken(w, r)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
 	rh.App.Session.Put(r.Context(), "reservation", res)
 	http.Redirect(w, r, "/make-reservations", http.StatusSeeOther)
 }
@@ -371,7 +778,7 @@ func (rh *RouteHandler) BookRoom(w http.ResponseWriter, r *http.Request) {
 // Login displays the login page
 func (rh *RouteHandler) Login(w http.ResponseWriter, r *http.Request) {
 	render.Template(w, r, "login.page.tmpl", &models.TemplateData{
-		Form: forms.New(nil),
+		Form: newForm(r, nil),
 	})
 }
 
@@ -385,7 +792,7 @@ func (rh *RouteHandler) PostLogin(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	form := forms.New(r.PostForm)
+	form := newForm(r, r.PostForm)
 	form.Required("email", "password")
 	form.IsEmail("email")
 	if !form.Valid() {
@@ -398,13 +805,21 @@ func (rh *RouteHandler) PostLogin(w http.ResponseWriter, r *http.Request) {
 	password := r.Form.Get("password")
 	id, _, err := rh.DB.Authenticate(email, password)
 	if err != nil {
-		rh.App.Session.Put(r.Context(), "error", "Invalid email or password")
+		rh.App.Session.Put(r.Context(), "error", i18n.T(form.Locale, "login.invalid"))
 		http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 		return
 	}
 
+	user, err := rh.DB.GetUserByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
 	rh.App.Session.Put(r.Context(), "user_id", id)
-	rh.App.Session.Put(r.Context(), "flash", "Logged in successfully")
+	// users working at one property start with the admin tool scoped to it
+	rh.App.Session.Put(r.Context(), "property_id", user.PropertyID)
+	rh.App.Session.Put(r.Context(), "flash", i18n.T(form.Locale, "login.success"))
 	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
 
@@ -416,39 +831,6 @@ func (rh *RouteHandler) Logout(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/user/login", http.StatusSeeOther)
 }
 
-// AdminDashBoard displays reservation details in the admin tool
-func (rh *RouteHandler) AdminDashBoard(w http.ResponseWriter, r *http.Request) {
-	render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{})
-}
-
-// AdminNewReservations shows all new reservations in the admin tool
-func (rh *RouteHandler) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
-	rs, err := rh.DB.AllNewReservations()
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
-	data := make(map[string]interface{})
-	data["reservations"] = rs
-	render.Template(w, r, "admin-new-reservations.page.tmpl", &models.TemplateData{
-		Data: data,
-	})
-}
-
-// AdminAllReservations shows all reservations in the admin tool
-func (rh *RouteHandler) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
-	rs, err := rh.DB.AllReservations()
-	if err != nil {
-		helpers.ServerError(w, err)
-		return
-	}
-	data := make(map[string]interface{})
-	data["reservations"] = rs
-	render.Template(w, r, "admin-all-reservations.page.tmpl", &models.TemplateData{
-		Data: data,
-	})
-}
-
 // AdminShowReservation shows single reservation in the admin page
 func (rh *RouteHandler) AdminShowReservation(w http.ResponseWriter, r *http.Request) {
 	exploded := strings.Split(r.RequestURI, "/")
@@ -467,20 +849,78 @@ func (rh *RouteHandler) AdminShowReservation(w http.ResponseWriter, r *http.Requ
 	stringMap["month"] = month
 	stringMap["year"] = year
 
-	res, err := rh.DB.GetReservationByID(id)
+	res, err := rh.managedReservation(r, id)
+	if err != nil {
+		staffError(w, err)
+		return
+	}
+	rh.renderAdminReservation(w, r, res, stringMap, forms.New(nil))
+}
+
+// renderAdminReservation shows a reservation in the admin tool with its payments, folio, invoices and moves
+func (rh *RouteHandler) renderAdminReservation(w http.ResponseWriter, r *http.Request, res models.Reservation,
+	stringMap map[string]string, form *forms.Form) {
+	resPayments, err := rh.DB.PaymentsForReservation(res.ID)
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
+	items, err := rh.DB.FolioItemsForReservation(res.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	invoices, err := rh.DB.InvoicesForReservation(res.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	moves, err := rh.DB.MovesForReservation(res.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rooms, err := rh.DB.AllRooms(helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	var guest models.Guest
+	if res.GuestID != 0 {
+		guest, err = rh.DB.GetGuestByID(res.GuestID)
+		if err != nil && !errors.Is(err, repository.ErrGuestNotFound) {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
 	data := make(map[string]interface{})
 	data["reservation"] = res
+	data["guest"] = guest
+	data["payments"] = resPayments
+	data["folio"] = folio.Build(res, items, resPayments)
+	data["item_kinds"] = folio.ItemKinds
+	data["invoices"] = invoices
+	data["moves"] = moves
+	data["rooms"] = rooms
+	data["early_check_in"] = earlyCheckIn(res)
+	data["late_check_out"] = lateCheckOut(res, time.Now().UTC())
+	data["now"] = time.Now().UTC().Format(htmlDateTimeLayout)
 	render.Template(w, r, "admin-reservation-show.page.tmpl", &models.TemplateData{
 		StringMap: stringMap,
 		Data:      data,
-		Form:      forms.New(nil),
+		Form:      form,
 	})
 }
 
+// adminReservationURL returns the admin page of a reservation opened from the list, calendar or front desk board
+// named by src
+func adminReservationURL(src string, id int) string {
+	if src != "new" && src != "cal" && src != "today" {
+		src = "all"
+	}
+	return fmt.Sprintf("/admin/reservations/%s/%d/show", src, id)
+}
+
 func (rh *RouteHandler) AdminPostShowReservation(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
@@ -495,15 +935,29 @@ func (rh *RouteHandler) AdminPostShowReservation(w http.ResponseWriter, r *http.
 	}
 	src := exploded[3]
 
-	res, err := rh.DB.GetReservationByID(id)
+	res, err := rh.managedReservation(r, id)
 	if err != nil {
+		staffError(w, err)
+		return
+	}
+	form := newForm(r, r.PostForm)
+	validateGuestDetails(form)
+
+	var guest guestDetails
+	if err = form.Bind(&guest); err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
-	res.FirstName = r.Form.Get("first_name")
-	res.LastName = r.Form.Get("last_name")
-	res.Email = r.Form.Get("email")
-	res.Phone = r.Form.Get("phone")
+	guest.apply(&res)
+
+	if !form.Valid() {
+		stringMap := make(map[string]string)
+		stringMap["src"] = src
+		stringMap["month"] = r.Form.Get("month")
+		stringMap["year"] = r.Form.Get("year")
+		rh.renderAdminReservation(w, r, res, stringMap, form)
+		return
+	}
 
 	err = rh.DB.UpdateReservation(&res)
 	if err != nil {
@@ -559,12 +1013,17 @@ func (rh *RouteHandler) AdminReservationsCalender(w http.ResponseWriter, r *http
 	intMap := make(map[string]int)
 	intMap["days_in_month"] = lastOfMonth.Day()
 
-	rooms, err := rh.DB.AllRooms()
+	rooms, err := rh.DB.AllRooms(helpers.AdminPropertyID(r))
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
 	data["rooms"] = rooms
+	data["block_reasons"] = blockReasons
+
+	// the stay of each reservation shown, so it can be dragged to another room or other dates
+	stays := make(map[int]models.RoomRestriction)
+	data["stays"] = stays
 
 	for _, room := range rooms {
 		reservationMap := make(map[string]int)
@@ -580,21 +1039,38 @@ func (rh *RouteHandler) AdminReservationsCalender(w http.ResponseWriter, r *http
 			helpers.ServerError(w, err)
 			return
 		}
+		// every day of a block, with the block map holding its first day in the month so it is removed only once
+		blockDays := make(map[string]models.RoomRestriction)
 		for _, res := range restrictions {
 			if res.ReservationID > 0 {
+				stays[res.ReservationID] = res
 				for d := res.StartDate; d.After(res.EndDate) == false; d = d.AddDate(0, 0, 1) {
 					reservationMap[d.Format("01/2/2006")] = res.ReservationID
 				}
 			} else {
-				blockMap[res.StartDate.Format("01/2/2006")] = res.ID
+				first := res.StartDate
+				if first.Before(firstOfMonth) {
+					first = firstOfMonth
+				}
+				blockMap[first.Format("01/2/2006")] = res.ID
+				for d := first; d.Equal(first) || (d.Before(res.EndDate) && !d.After(lastOfMonth)); d = d.AddDate(0, 0, 1) {
+					blockDays[d.Format("01/2/2006")] = res
+				}
 			}
 		}
 
 		data[fmt.Sprintf("reservation_map_%d", room.ID)] = reservationMap
 		data[fmt.Sprintf("block_map_%d", room.ID)] = blockMap
+		data[fmt.Sprintf("block_days_%d", room.ID)] = blockDays
+	}
 
-		rh.App.Session.Put(r.Context(), fmt.Sprintf("block_map_%d", room.ID), blockMap)
+	// the calendar is saved only if nobody changed it since this version was shown
+	version, err := rh.DB.CalendarVersion(firstOfMonth, lastOfMonth, helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
 	}
+	stringMap["version"] = version
 
 	render.Template(w, r, "admin-reservations-calender.page.tmpl", &models.TemplateData{
 		StringMap: stringMap,
@@ -607,6 +1083,10 @@ func (rh *RouteHandler) AdminReservationsCalender(w http.ResponseWriter, r *http
 func (rh *RouteHandler) AdminProcessReservation(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 	src := chi.URLParam(r, "src")
+	if _, err := rh.managedReservation(r, id); err != nil {
+		staffError(w, err)
+		return
+	}
 	err := rh.DB.UpdateProcessedReservation(id, 1)
 	if err != nil {
 		helpers.ServerError(w, err)
@@ -628,11 +1108,20 @@ func (rh *RouteHandler) AdminProcessReservation(w http.ResponseWriter, r *http.R
 func (rh *RouteHandler) AdminDeleteReservation(w http.ResponseWriter, r *http.Request) {
 	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
 	src := chi.URLParam(r, "src")
-	err := rh.DB.DeleteReservation(id)
+	res, err := rh.managedReservation(r, id)
+	if err != nil {
+		staffError(w, err)
+		return
+	}
+	err = rh.DB.DeleteReservation(id)
 	if err != nil {
 		helpers.ServerError(w, err)
 		return
 	}
+	err = rh.offerFreedRoom(res.RoomID, res.CheckInDate, res.CheckOutDate)
+	if err != nil {
+		rh.App.ErrorLog.Println("failed to offer the room of a deleted reservation to the waitlist", err)
+	}
 	year := r.URL.Query().Get("y")
 	month := r.URL.Query().Get("m")
 	rh.App.Session.Put(r.Context(), "flash", "Reservation Deleted")
@@ -643,8 +1132,9 @@ func (rh *RouteHandler) AdminDeleteReservation(w http.ResponseWriter, r *http.Re
 	}
 }
 
-//AdminPostReservationsCalender handles post of reservation calender
-func (rh RouteHandler) AdminPostReservationsCalender(w http.ResponseWriter, r *http.Request) {
+//AdminPostReservationsCalender handles post of reservation calender. The form lists the blocks shown, so the blocks
+//unchecked are removed and the days checked are blocked, unless the month changed since the calendar was shown.
+func (rh *RouteHandler) AdminPostReservationsCalender(w http.ResponseWriter, r *http.Request) {
 	err := r.ParseForm()
 	if err != nil {
 		helpers.ServerError(w, err)
@@ -653,52 +1143,93 @@ func (rh RouteHandler) AdminPostReservationsCalender(w http.ResponseWriter, r *h
 
 	year, _ := strconv.Atoi(r.Form.Get("y"))
 	month, _ := strconv.Atoi(r.Form.Get("m"))
-
-	rooms, err := rh.DB.AllRooms()
-	if err != nil {
-		helpers.ServerError(w, err)
+	calendar := fmt.Sprintf("/admin/reservations-calender?y=%d&m=%d", year, month)
+	if month < 1 || month > 12 {
+		helpers.ClientError(w, http.StatusBadRequest)
 		return
 	}
+	firstOfMonth := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.UTC)
+	lastOfMonth := firstOfMonth.AddDate(0, 1, -1)
 
-	form := forms.New(r.PostForm)
-	for _, room := range rooms {
-		// Get the block map from the session. Loop through entire map, if we have an entry in the map
-		// that does not exist in our posted data, and if the restriction id > 0, then it is a block we need to
-		// remove
-		curMap := rh.App.Session.Get(r.Context(), fmt.Sprintf("block_map_%d", room.ID)).(map[string]int)
-		for name, value := range curMap {
-			// ok will be false if the value is not in the map
-			if val, ok := curMap[name]; ok {
-				// only pay attention to values > 0, and that are not in the form post
-				// the rest are just placeholders for days without blocks
-				if val > 0 {
-					if !form.Has(fmt.Sprintf("remove_block_%d_%s", room.ID, name)) {
-						//delete the restriction by id
-						err = rh.DB.DeleteBlockByID(value)
-						if err != nil {
-							helpers.ServerError(w, err)
-							return
-						}
-					}
-				}
+	// blocks shown on the calendar which are no longer checked are removed
+	kept := make(map[string]bool)
+	var add []models.RoomRestriction
+	for name, values := range r.PostForm {
+		if strings.HasPrefix(name, "remove_block_") {
+			for _, v := range values {
+				kept[v] = true
 			}
 		}
-	}
-
-	// now handle new blocks
-	for name, _ := range r.PostForm {
-		if strings.HasPrefix(name, "add_block") {
+		if strings.HasPrefix(name, "add_block_") {
 			exploded := strings.Split(name, "_")
-			roomID, _ := strconv.Atoi(exploded[2])
-			t, _ := time.Parse("01/2/2006", exploded[3])
-			// insert a new block
-			err = rh.DB.CreateBlockForRoom(roomID, t)
+			if len(exploded) != 4 {
+				helpers.ClientError(w, http.StatusBadRequest)
+				return
+			}
+			roomID, err := strconv.Atoi(exploded[2])
 			if err != nil {
-				helpers.ServerError(w, err)
+				helpers.ClientError(w, http.StatusBadRequest)
 				return
 			}
+			t, err := time.Parse("01/2/2006", exploded[3])
+			if err != nil {
+				helpers.ClientError(w, http.StatusBadRequest)
+				return
+			}
+			add = append(add, models.RoomRestriction{StartDate: t, EndDate: t.AddDate(0, 0, 1), RoomID: roomID})
+		}
+	}
+	var roomIDs []int
+	for _, b := range add {
+		roomIDs = append(roomIDs, b.RoomID)
+	}
+	ok, err := rh.managesRooms(r, roomIDs...)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !ok {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	var remove []int
+	for _, v := range r.PostForm["shown_block"] {
+		if kept[v] {
+			continue
+		}
+		id, err := strconv.Atoi(v)
+		if err != nil {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+		remove = append(remove, id)
+	}
+
+	// the calendar of the property shown is saved, leaving the blocks of rooms at other properties
+	removed, err := rh.DB.SaveCalendarBlocks(firstOfMonth, lastOfMonth, r.PostForm.Get("version"), add, remove,
+		helpers.AdminPropertyID(r))
+	if errors.Is(err, repository.ErrCalendarChanged) {
+		rh.App.Session.Put(r.Context(), "error",
+			"Someone else changed the calendar while you were editing it. Your changes were not saved, please make them again.")
+		http.Redirect(w, r, calendar, http.StatusSeeOther)
+		return
+	}
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		rh.App.Session.Put(r.Context(), "error",
+			"A day you blocked has a reservation or a guest booking it. Your changes were not saved, please make them again.")
+		http.Redirect(w, r, calendar, http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	for _, block := range removed {
+		err = rh.offerFreedRoom(block.RoomID, block.StartDate, block.EndDate)
+		if err != nil {
+			rh.App.ErrorLog.Println("failed to offer an unblocked room to the waitlist", err)
 		}
 	}
 	rh.App.Session.Put(r.Context(), "flash", "Changes saved")
-	http.Redirect(w, r, fmt.Sprintf("/admin/reservations-calender?y=%d&m=%d", year, month), http.StatusSeeOther)
+	http.Redirect(w, r, calendar, http.StatusSeeOther)
 }
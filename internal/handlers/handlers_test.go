@@ -1,10 +1,26 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/export"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/payments"
+	"github.com/sunil206b/smart_booking/internal/property"
+	"log"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 type postData struct {
@@ -25,22 +41,26 @@ var theTests = []struct {
 	{"majors", "/majors-suite", "GET", []postData{}, http.StatusOK},
 	{"contact", "/contact", "GET", []postData{}, http.StatusOK},
 	{"search-availability", "/search-availability", "GET", []postData{}, http.StatusOK},
-	{"make-reservation", "/make-reservations", "GET", []postData{}, http.StatusOK},
 	{"reservation-summary", "/reservation-summary", "GET", []postData{}, http.StatusOK},
 	{"search-availability", "/search-availability", "POST", []postData{
+		{key: "start_date", value: futureDate(7)},
+		{key: "end_date", value: futureDate(10)},
+	}, http.StatusOK},
+	{"search-availability-invalid", "/search-availability", "POST", []postData{
 		{key: "startDate", value: "2021-01-01"},
 		{key: "endDate", value: "2021-01-10"},
 	}, http.StatusOK},
 	{"search-availability-json", "/search-availability-json", "POST", []postData{
+		{key: "check_in_date", value: futureDate(7)},
+		{key: "check_out_date", value: futureDate(10)},
+		{key: "room_id", value: "1"},
+	}, http.StatusOK},
+	{"search-availability-json-invalid", "/search-availability-json", "POST", []postData{
 		{key: "startDate", value: "2021-01-01"},
 		{key: "endDate", value: "2021-01-10"},
-	}, http.StatusOK},
-	{"make-reservations", "/make-reservations", "POST", []postData{
-		{key: "firstName", value: "John"},
-		{key: "lastName", value: "Smith"},
-		{key: "email", value: "smith@test.com"},
-		{key: "phone", value: "767-432-4312"},
-	}, http.StatusOK},
+	}, http.StatusBadRequest},
+	{"availability-calendar-json", "/availability-calendar-json", "GET", []postData{}, http.StatusOK},
+	{"availability-calendar-json-invalid", "/availability-calendar-json?days=1000", "GET", []postData{}, http.StatusBadRequest},
 }
 
 func TestHandlers(t *testing.T) {
@@ -74,3 +94,2150 @@ func TestHandlers(t *testing.T) {
 		}
 	}
 }
+
+// futureDate returns the date the given number of days from today in the layout used by the search forms
+func futureDate(days int) string {
+	return time.Now().AddDate(0, 0, days).Format(i18n.DateLayout(i18n.DefaultLocale))
+}
+
+func TestRouteHandler_PostAvailability(t *testing.T) {
+	routes := getRoutes()
+	tests := []struct {
+		name    string
+		start   string
+		end     string
+		expBody string
+	}{
+		{"valid", futureDate(7), futureDate(10), "Choose a Room"},
+		{"missing", "", "", "This field is required"},
+		{"malformed", "2021-07-13", futureDate(10), "Invalid date"},
+		{"in the past", time.Now().AddDate(0, 0, -3).Format(i18n.DateLayout(i18n.DefaultLocale)), futureDate(10), "must not be in the past"},
+		{"end before start", futureDate(10), futureDate(7), "must be after the start date"},
+		{"zero nights", futureDate(7), futureDate(7), "must be after the start date"},
+		{"too long", futureDate(1), futureDate(maxStayNights + 2), "cannot be longer than"},
+	}
+
+	for _, e := range tests {
+		values := url.Values{}
+		values.Add("start_date", e.start)
+		values.Add("end_date", e.end)
+		req := httptest.NewRequest("POST", "/search-availability", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("for %s, expected %d but got %d", e.name, http.StatusOK, rr.Code)
+		}
+		if !strings.Contains(rr.Body.String(), e.expBody) {
+			t.Errorf("for %s, expected response to contain %q", e.name, e.expBody)
+		}
+	}
+}
+
+func TestRouteHandler_PostAvailability_Localized(t *testing.T) {
+	routes := getRoutes()
+	start := time.Now().AddDate(0, 0, 7)
+	values := url.Values{}
+	values.Add("start_date", start.Format(i18n.DateLayout("es")))
+	values.Add("end_date", start.Format(i18n.DateLayout("es")))
+	req := httptest.NewRequest("POST", "/search-availability", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(i18n.WithLocale(req.Context(), "es"))
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, i18n.T("es", "forms.date_order")) {
+		t.Error("expected the validation message in Spanish")
+	}
+	if !strings.Contains(body, i18n.T("es", "search.title")) {
+		t.Error("expected the page to be rendered in Spanish")
+	}
+}
+
+func TestRouteHandler_SetLanguage(t *testing.T) {
+	routes := getRoutes()
+	req := httptest.NewRequest("GET", "/set-language/fr", nil)
+	req.Header.Set("Referer", "http://example.com/es/generals-quarters?x=1")
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/generals-quarters?x=1" {
+		t.Errorf("expected a redirect back to the page without the locale prefix, but got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+	if c := rr.Result().Cookies(); len(c) == 0 || c[0].Name != i18n.CookieName || c[0].Value != "fr" {
+		t.Error("expected the language cookie to be set to fr")
+	}
+
+	req = httptest.NewRequest("GET", "/set-language/xx", nil)
+	rr = httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected %d for an unsupported locale but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestRouteHandler_AvailabilityJSON(t *testing.T) {
+	routes := getRoutes()
+	tests := []struct {
+		name      string
+		start     string
+		end       string
+		roomID    string
+		expStatus int
+		expOK     bool
+	}{
+		{"available", futureDate(7), futureDate(10), "1", http.StatusOK, true},
+		{"not available", futureDate(7), futureDate(10), "2", http.StatusOK, false},
+		{"malformed date", "07-13-2021", futureDate(10), "1", http.StatusBadRequest, false},
+		{"end before start", futureDate(10), futureDate(7), "1", http.StatusBadRequest, false},
+		{"invalid room", futureDate(7), futureDate(10), "abc", http.StatusBadRequest, false},
+	}
+
+	for _, e := range tests {
+		values := url.Values{}
+		values.Add("check_in_date", e.start)
+		values.Add("check_out_date", e.end)
+		values.Add("room_id", e.roomID)
+		req := httptest.NewRequest("POST", "/search-availability-json", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+		var resp jsonResponse
+		if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+			t.Errorf("for %s, failed to parse json: %v", e.name, err)
+			continue
+		}
+		if resp.OK != e.expOK {
+			t.Errorf("for %s, expected ok to be %t but got %t", e.name, e.expOK, resp.OK)
+		}
+		if !resp.OK && resp.Message == "" {
+			t.Errorf("for %s, expected a message explaining why", e.name)
+		}
+	}
+}
+
+func TestRouteHandler_BookRoom(t *testing.T) {
+	routes := getRoutes()
+	tests := []struct {
+		name      string
+		url       string
+		expStatus int
+	}{
+		{"valid", fmt.Sprintf("/book-room?id=1&start=%s&end=%s", futureDate(7), futureDate(10)), http.StatusSeeOther},
+		{"invalid id", fmt.Sprintf("/book-room?id=x&start=%s&end=%s", futureDate(7), futureDate(10)), http.StatusBadRequest},
+		{"invalid dates", "/book-room?id=1&start=13/45/2021&end=", http.StatusOK},
+		{"room taken", fmt.Sprintf("/book-room?id=2&start=%s&end=%s", futureDate(7), futureDate(10)), http.StatusSeeOther},
+	}
+
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", e.url, nil)
+		rr := httptest.NewRecorder()
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+	}
+}
+
+func TestRouteHandler_Reservation(t *testing.T) {
+	getRoutes()
+	reservation := models.Reservation{
+		RoomID:       1,
+		CheckInDate:  time.Now().AddDate(0, 0, 7),
+		CheckOutDate: time.Now().AddDate(0, 0, 10),
+	}
+
+	req := httptest.NewRequest("GET", "/make-reservations", nil)
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", reservation)
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.Reservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("Reservation handler returned wrong response code: got %d, wanted %d", rr.Code, http.StatusOK)
+	}
+	if !strings.Contains(rr.Body.String(), "City tax") {
+		t.Error("Reservation handler should itemise the taxes and fees of the stay")
+	}
+
+	// reservation is not in the session
+	req = httptest.NewRequest("GET", "/make-reservations", nil)
+	req = req.WithContext(getCtx(req))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.Reservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("Reservation handler returned wrong response code: got %d, wanted %d", rr.Code, http.StatusInternalServerError)
+	}
+}
+
+func TestRouteHandler_PostReservation(t *testing.T) {
+	getRoutes()
+	reservation := models.Reservation{
+		RoomID:       1,
+		CheckInDate:  time.Now().AddDate(0, 0, 7),
+		CheckOutDate: time.Now().AddDate(0, 0, 10),
+	}
+	appConfig.MailChan = make(chan *models.MailData, 10)
+
+	values := url.Values{}
+	values.Add("first_name", "John")
+	values.Add("last_name", "Smith")
+	values.Add("email", "smith@test.com")
+	values.Add("phone", "767-432-4312")
+	req := httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", reservation)
+	rr := httptest.NewRecorder()
+	before := time.Now()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("PostReservation handler returned wrong response code: got %d, wanted %d", rr.Code, http.StatusSeeOther)
+	}
+	// the reservation is saved with the time it was booked, which the reports and exports are built on
+	saved, _ := session.Get(req.Context(), "reservation").(models.Reservation)
+	if saved.CreatedAt.Before(before) || saved.CreatedAt.After(time.Now()) || !saved.UpdatedAt.Equal(saved.CreatedAt) {
+		t.Errorf("expected the reservation to be saved with the time it was booked, got %s and %s", saved.CreatedAt,
+			saved.UpdatedAt)
+	}
+
+	// taxes and fees are worked out for the number of guests
+	values.Set("guests", "2")
+	req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	priced := reservation
+	priced.Room.Price = 10000
+	session.Put(req.Context(), "reservation", priced)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	saved, _ = session.Get(req.Context(), "reservation").(models.Reservation)
+	if rr.Code != http.StatusSeeOther || saved.RoomAmount != 30000 || saved.TotalAmount != 31200 || len(saved.Taxes) != 1 {
+		t.Errorf("expected 3 nights at 100.00 plus a city tax of 12.00, got %d with %d + %+v = %d", rr.Code,
+			saved.RoomAmount, saved.Taxes, saved.TotalAmount)
+	}
+
+	// a promo code takes its discount off the room charge
+	values.Set("promo_code", "summer10")
+	req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", priced)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	saved, _ = session.Get(req.Context(), "reservation").(models.Reservation)
+	if rr.Code != http.StatusSeeOther || saved.PromoCode != "SUMMER10" || saved.Discount != 3000 || saved.TotalAmount != 28200 {
+		t.Errorf("expected 10%% off 3 nights at 100.00, got %d with code %q, discount %d and total %d", rr.Code,
+			saved.PromoCode, saved.Discount, saved.TotalAmount)
+	}
+
+	// promo codes which cannot be used re-render the form with the reason
+	for code, expError := range map[string]string{
+		"NOPE": "This promo code does not exist",
+		"FULL": "This promo code has been used the maximum number of times",
+	} {
+		values.Set("promo_code", code)
+		req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		session.Put(req.Context(), "reservation", priced)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), expError) {
+			t.Errorf("for promo code %s, expected the form with %q but got %d", code, expError, rr.Code)
+		}
+	}
+	values.Del("promo_code")
+
+	// extras are priced for the nights and guests of the stay
+	values.Set("extra_1", "1")
+	values.Set("extra_2", "1")
+	req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", priced)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	saved, _ = session.Get(req.Context(), "reservation").(models.Reservation)
+	if rr.Code != http.StatusSeeOther || len(saved.Extras) != 2 || saved.TotalAmount != 31200+2400+4500 {
+		t.Errorf("expected breakfast for 2 guests and 3 nights of parking, got %d with %+v and total %d", rr.Code,
+			saved.Extras, saved.TotalAmount)
+	}
+
+	// only one parking space is left for the stay
+	values.Set("extra_2", "2")
+	req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", priced)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "between 0 and 1") {
+		t.Errorf("expected the form to limit parking to the space left, got %d", rr.Code)
+	}
+	values.Del("extra_1")
+	values.Del("extra_2")
+
+	// too many guests re-renders the form
+	values.Set("guests", "20")
+	req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", reservation)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Errorf("PostReservation handler should re-render the form for too many guests, got %d", rr.Code)
+	}
+	values.Del("guests")
+
+	// invalid phone number re-renders the form
+	values.Set("phone", "call me maybe")
+	req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", reservation)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Invalid phone number") {
+		t.Errorf("PostReservation handler should re-render the form for an invalid phone number, got %d", rr.Code)
+	}
+
+	// reservation is not in the session
+	req = httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("PostReservation handler returned wrong response code: got %d, wanted %d", rr.Code, http.StatusInternalServerError)
+	}
+}
+
+func getCtx(req *http.Request) context.Context {
+	ctx, err := session.Load(req.Context(), req.Header.Get("X-Session"))
+	if err != nil {
+		log.Println(err)
+	}
+	return ctx
+}
+
+func TestRouteHandler_SetCurrency(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/set-currency/eur", nil)
+	req = req.WithContext(getCtx(req))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("code", "eur")
+	req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.SetCurrency).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if got := session.GetString(req.Context(), "currency"); got != "EUR" {
+		t.Errorf("expected EUR in the session but got %q", got)
+	}
+
+	// no exchange rate for pounds
+	rctx.URLParams = chi.RouteParams{}
+	rctx.URLParams.Add("code", "GBP")
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.SetCurrency).ServeHTTP(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Errorf("expected %d but got %d", http.StatusNotFound, rr.Code)
+	}
+}
+
+func TestRouteHandler_AvailabilityCalendarJSON_Currency(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/availability-calendar-json?room_id=1&days=1", nil)
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "currency", "EUR")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AvailabilityCalendarJSON).ServeHTTP(rr, req)
+
+	var resp calendarResponse
+	if err := json.Unmarshal(rr.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Currency != "EUR" || len(resp.Rooms) != 1 {
+		t.Fatalf("expected one room priced in EUR, but got %+v", resp)
+	}
+	night := resp.Rooms[0].Nights[0]
+	if night.Price != 10000 || night.DisplayPrice != "€90.00" {
+		t.Errorf("expected a base price of 10000 shown as €90.00, but got %d and %q", night.Price, night.DisplayPrice)
+	}
+}
+
+func TestRouteHandler_AdminImportExchangeRates(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		csv       string
+		expStatus int
+	}{
+		{"valid", "currency,rate\nEUR,0.9\nGBP,0.8\n", http.StatusSeeOther},
+		{"base currency", "USD,1\n", http.StatusOK},
+		{"invalid rate", "EUR,zero\n", http.StatusOK},
+	}
+	for _, e := range tests {
+		body := new(bytes.Buffer)
+		mw := multipart.NewWriter(body)
+		fw, _ := mw.CreateFormFile("rates_file", "rates.csv")
+		fw.Write([]byte(e.csv))
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/admin/exchange-rates/import", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminImportExchangeRates).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+	}
+}
+
+func TestRouteHandler_ReservationPayment(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name        string
+		reservation models.Reservation
+		expLocation string
+	}{
+		{"deposit", models.Reservation{ID: 1, TotalAmount: 30000, Room: models.Room{Price: 10000}}, payments.FakeCheckoutPath},
+		{"no deposit", models.Reservation{ID: 1}, "/reservation-summary"},
+		{"checkout started", models.Reservation{ID: 7, TotalAmount: 30000, Room: models.Room{Price: 10000}},
+			payments.FakeCheckoutPath + "fake_7"},
+		{"checkout not started", models.Reservation{ID: 8, TotalAmount: 30000, Room: models.Room{Price: 10000}},
+			payments.FakeCheckoutPath},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/reservation-payment", nil)
+		req = req.WithContext(getCtx(req))
+		session.Put(req.Context(), "reservation", e.reservation)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.ReservationPayment).ServeHTTP(rr, req)
+
+		// a new checkout has a reference made up by the gateway, while a checkout started is gone back to as it is
+		location := rr.Header().Get("Location")
+		matches := location == e.expLocation
+		if e.expLocation == payments.FakeCheckoutPath {
+			matches = strings.HasPrefix(location, e.expLocation)
+		}
+		if rr.Code != http.StatusSeeOther || !matches {
+			t.Errorf("for %s, expected a redirect to %s but got %d %s", e.name, e.expLocation, rr.Code, location)
+		}
+	}
+
+	// the checkout page of the fake gateway shows the deposit
+	auth, err := appConfig.Payments.Authorize(context.Background(), payments.AuthorizeRequest{Amount: 9000, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for reference, expStatus := range map[string]int{auth.Reference: http.StatusOK, "fake_unknown": http.StatusNotFound} {
+		req := httptest.NewRequest("GET", payments.FakeCheckoutPath+reference, nil)
+		req = req.WithContext(getCtx(req))
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("reference", reference)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.FakeCheckout).ServeHTTP(rr, req)
+
+		if rr.Code != expStatus {
+			t.Errorf("for checkout %s, expected %d but got %d", reference, expStatus, rr.Code)
+		}
+		if expStatus == http.StatusOK && !strings.Contains(rr.Body.String(), "$90.00") {
+			t.Error("expected the checkout page to show the amount")
+		}
+	}
+}
+
+func TestRouteHandler_ReservationPaymentReturn(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name        string
+		payment     string
+		expStatus   int
+		expLocation string
+	}{
+		{"authorized", "1", http.StatusSeeOther, "/reservation-summary"},
+		{"failed", "2", http.StatusOK, ""},
+		{"unknown", "3", http.StatusNotFound, ""},
+		{"invalid", "abc", http.StatusBadRequest, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/reservation-payment/return?payment="+e.payment, nil)
+		req = req.WithContext(getCtx(req))
+		session.Put(req.Context(), "reservation", models.Reservation{ID: 1})
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.ReservationPaymentReturn).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+		if e.expLocation != "" && rr.Header().Get("Location") != e.expLocation {
+			t.Errorf("for %s, expected a redirect to %s but got %s", e.name, e.expLocation, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRouteHandler_PaymentWebhook(t *testing.T) {
+	getRoutes()
+	body, signature, err := payments.NewFakeProvider(webhookSecret).Sign(payments.Event{
+		Type: payments.EventAuthorized, Reference: "fake_1", Amount: 3000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an event for a checkout no payment has the reference of is acknowledged, so the gateway stops sending it
+	unknown, unknownSignature, err := payments.NewFakeProvider(webhookSecret).Sign(payments.Event{
+		Type: payments.EventAuthorized, Reference: "fake_unknown", Amount: 3000,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		body      []byte
+		signature string
+		expStatus int
+	}{
+		{"signed", body, signature, http.StatusNoContent},
+		{"forged", body, strings.Repeat("0", len(signature)), http.StatusBadRequest},
+		{"unsigned", body, "", http.StatusBadRequest},
+		{"unknown payment", unknown, unknownSignature, http.StatusNoContent},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(e.body))
+		req.Header.Set(payments.SignatureHeader, e.signature)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.PaymentWebhook).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+	}
+}
+
+// withURLParams adds chi URL parameters, given as name and value pairs, to the request
+func withURLParams(req *http.Request, params ...string) *http.Request {
+	rctx := chi.NewRouteContext()
+	for i := 0; i+1 < len(params); i += 2 {
+		rctx.URLParams.Add(params[i], params[i+1])
+	}
+	return req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+}
+
+func TestRouteHandler_AdminPostFolioItem(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"extra", url.Values{"kind": {"extra"}, "description": {"Breakfast"}, "quantity": {"2"}, "unit_amount": {"15.00"}},
+			http.StatusSeeOther, ""},
+		{"discount", url.Values{"kind": {"adjustment"}, "description": {"Discount"}, "quantity": {"1"}, "unit_amount": {"-10"}},
+			http.StatusSeeOther, ""},
+		{"negative extra", url.Values{"kind": {"extra"}, "description": {"Breakfast"}, "quantity": {"1"}, "unit_amount": {"-10"}},
+			http.StatusOK, "Only adjustments can be negative"},
+		{"invalid amount", url.Values{"kind": {"tax"}, "description": {"City tax"}, "quantity": {"1"}, "unit_amount": {"2,50"}},
+			http.StatusOK, "Enter an amount such as 12.50"},
+		{"room charge", url.Values{"kind": {"room"}, "description": {"Room"}, "quantity": {"1"}, "unit_amount": {"100"}},
+			http.StatusOK, "Choose an extra, a tax or an adjustment"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/reservations/all/1/folio", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req.WithContext(getCtx(req)), "src", "all", "id", "1")
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostFolioItem).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+		if e.expError != "" && !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected the error %q on the page", e.name, e.expError)
+		}
+		if e.expStatus == http.StatusSeeOther && rr.Header().Get("Location") != "/admin/reservations/all/1/show" {
+			t.Errorf("for %s, expected a redirect to the reservation but got %s", e.name, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRouteHandler_AdminCreateInvoice(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("POST", "/admin/reservations/new/1/invoices", nil)
+	req = withURLParams(req.WithContext(getCtx(req)), "src", "new", "id", "1")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminCreateInvoice).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/reservations/new/1/show" {
+		t.Errorf("expected a redirect to the reservation but got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+	if got := session.GetString(req.Context(), "flash"); got != "Invoice INV-000001 created" {
+		t.Errorf("unexpected flash message %q", got)
+	}
+}
+
+func TestRouteHandler_AdminInvoicePDF(t *testing.T) {
+	getRoutes()
+	for id, expStatus := range map[string]int{"1": http.StatusOK, "2": http.StatusNotFound} {
+		req := httptest.NewRequest("GET", "/admin/invoices/"+id+"/pdf", nil)
+		req = withURLParams(req.WithContext(getCtx(req)), "id", id)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminInvoicePDF).ServeHTTP(rr, req)
+
+		if rr.Code != expStatus {
+			t.Errorf("for invoice %s, expected %d but got %d", id, expStatus, rr.Code)
+		}
+		if expStatus == http.StatusOK && (rr.Header().Get("Content-Type") != "application/pdf" ||
+			!strings.Contains(rr.Header().Get("Content-Disposition"), "INV-000001.pdf")) {
+			t.Errorf("expected a PDF download but got %v", rr.Header())
+		}
+	}
+}
+
+func TestRouteHandler_AdminEmailInvoice(t *testing.T) {
+	getRoutes()
+	appConfig.MailChan = make(chan *models.MailData, 1)
+	req := httptest.NewRequest("POST", "/admin/invoices/1/email", strings.NewReader("src=cal"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = withURLParams(req.WithContext(getCtx(req)), "id", "1")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminEmailInvoice).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/reservations/cal/1/show" {
+		t.Errorf("expected a redirect to the reservation but got %d %s", rr.Code, rr.Header().Get("Location"))
+	}
+	msg := <-appConfig.MailChan
+	if len(msg.Attachments) != 1 || msg.Attachments[0].Name != "INV-000001.pdf" {
+		t.Errorf("expected the invoice attached to the email, got %+v", msg.Attachments)
+	}
+}
+
+func TestRouteHandler_AdminPostTaxRule(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"percent", url.Values{"name": {"VAT"}, "kind": {"percent"}, "amount": {"7.5"},
+			"start_date": {"2021-01-01"}, "end_date": {"2021-12-31"}}, http.StatusSeeOther, ""},
+		{"per person per night", url.Values{"name": {"City tax"}, "kind": {"per_person_night"}, "amount": {"2.50"},
+			"room_id": {"1"}, "start_date": {"2021-01-01"}, "end_date": {"2021-12-31"}}, http.StatusSeeOther, ""},
+		{"invalid percentage", url.Values{"name": {"VAT"}, "kind": {"percent"}, "amount": {"120"},
+			"start_date": {"2021-01-01"}, "end_date": {"2021-12-31"}}, http.StatusOK, "Enter a percentage such as 7.5"},
+		{"invalid amount", url.Values{"name": {"Cleaning"}, "kind": {"per_stay"}, "amount": {"-5"},
+			"start_date": {"2021-01-01"}, "end_date": {"2021-12-31"}}, http.StatusOK, "Enter an amount such as 2.50"},
+		{"unknown kind", url.Values{"name": {"Pet fee"}, "kind": {"per_pet"}, "amount": {"5"},
+			"start_date": {"2021-01-01"}, "end_date": {"2021-12-31"}}, http.StatusOK, "Choose how the rule is charged"},
+		{"dates reversed", url.Values{"name": {"VAT"}, "kind": {"percent"}, "amount": {"10"},
+			"start_date": {"2021-12-31"}, "end_date": {"2021-01-01"}}, http.StatusOK, "End date must not be before the start date"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/tax-rules", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostTaxRule).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+		if e.expError != "" && !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected the error %q on the page", e.name, e.expError)
+		}
+	}
+}
+
+func TestRouteHandler_AdminPromoCodes(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/admin/promo-codes", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminPromoCodes).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, "SUMMER10") || !strings.Contains(body, "2 / 10") ||
+		!strings.Contains(body, "$564.00") {
+		t.Errorf("expected the usage of SUMMER10 on the page, got %d", rr.Code)
+	}
+}
+
+func TestRouteHandler_AdminPostPromoCode(t *testing.T) {
+	getRoutes()
+	valid := func(change func(v url.Values)) url.Values {
+		v := url.Values{"code": {"autumn-25"}, "kind": {"fixed"}, "amount": {"25.00"},
+			"valid_from": {"2021-09-01"}, "valid_to": {"2021-11-30"}, "room_id": {"1", "2"}}
+		change(v)
+		return v
+	}
+	tests := []struct {
+		name      string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"fixed", valid(func(v url.Values) {}), http.StatusSeeOther, ""},
+		{"percent with stay window", valid(func(v url.Values) {
+			v.Set("kind", "percent")
+			v.Set("amount", "15")
+			v.Set("stay_start", "2021-10-01")
+			v.Set("stay_end", "2021-10-31")
+			v.Set("min_nights", "2")
+			v.Set("max_uses", "100")
+		}), http.StatusSeeOther, ""},
+		{"existing code", valid(func(v url.Values) { v.Set("code", "Summer10") }), http.StatusOK,
+			"There is already a promo code SUMMER10"},
+		{"invalid code", valid(func(v url.Values) { v.Set("code", "10% off") }), http.StatusOK,
+			"Use 3 to 40 letters, digits, dashes or underscores"},
+		{"invalid amount", valid(func(v url.Values) { v.Set("amount", "0") }), http.StatusOK, "Enter an amount such as 25.00"},
+		{"invalid percentage", valid(func(v url.Values) {
+			v.Set("kind", "percent")
+			v.Set("amount", "150")
+		}), http.StatusOK, "Enter a percentage such as 10"},
+		{"stay window reversed", valid(func(v url.Values) {
+			v.Set("stay_start", "2021-10-31")
+			v.Set("stay_end", "2021-10-01")
+		}), http.StatusOK, "End date must not be before the start date"},
+		{"invalid max uses", valid(func(v url.Values) { v.Set("max_uses", "-1") }), http.StatusOK,
+			"This field must be a number of uses"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/promo-codes", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostPromoCode).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+		if e.expError != "" && !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected the error %q on the page", e.name, e.expError)
+		}
+	}
+}
+
+func TestRouteHandler_AdminExtras(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/admin/extras", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminExtras).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if rr.Code != http.StatusOK || !strings.Contains(body, "Parking") || !strings.Contains(body, "/admin/extras/3/on") {
+		t.Errorf("expected the extras on the page, got %d", rr.Code)
+	}
+}
+
+func TestRouteHandler_AdminPostExtra(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"per night with inventory", url.Values{"name": {"Parking"}, "unit": {"per_night"}, "price": {"15.00"},
+			"inventory": {"3"}}, http.StatusSeeOther, ""},
+		{"per guest", url.Values{"name": {"Breakfast"}, "unit": {"per_guest"}, "price": {"12"}}, http.StatusSeeOther, ""},
+		{"unknown unit", url.Values{"name": {"Spa"}, "unit": {"per_hour"}, "price": {"30"}}, http.StatusOK,
+			"Choose how the extra is priced"},
+		{"invalid price", url.Values{"name": {"Spa"}, "unit": {"per_stay"}, "price": {"free"}}, http.StatusOK,
+			"Enter an amount such as 12.50"},
+		{"invalid inventory", url.Values{"name": {"Parking"}, "unit": {"per_night"}, "price": {"15.00"},
+			"inventory": {"some"}}, http.StatusOK, "This field must be a number of items"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/extras", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostExtra).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+		if e.expError != "" && !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected the error %q on the page", e.name, e.expError)
+		}
+	}
+}
+
+func TestRouteHandler_Waitlist(t *testing.T) {
+	getRoutes()
+	appConfig.MailChan = make(chan *models.MailData, 10)
+
+	// a search without rooms sends the guest to the waitlist
+	layout := i18n.DateLayout(i18n.DefaultLocale)
+	start := time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC)
+	values := url.Values{"start_date": {start.Format(layout)}, "end_date": {start.AddDate(0, 0, 2).Format(layout)}}
+	req := httptest.NewRequest("POST", "/search-availability", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostAvailability).ServeHTTP(rr, req)
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/waitlist" {
+		t.Fatalf("expected a redirect to the waitlist, got %d to %q", rr.Code, rr.Header().Get("Location"))
+	}
+	entry, ok := session.Get(req.Context(), "waitlist").(models.WaitlistEntry)
+	if !ok || !entry.StartDate.Equal(start) {
+		t.Fatalf("expected the dates searched in the session, got %+v", entry)
+	}
+
+	req = httptest.NewRequest("GET", "/waitlist", nil)
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "waitlist", entry)
+	rr = httptest.NewRecorder()
+	http.HandlerFunc(Handler.Waitlist).ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "Join Waitlist") {
+		t.Errorf("expected the waitlist form, got %d", rr.Code)
+	}
+
+	// joining needs the guest details
+	for _, e := range []struct {
+		name      string
+		values    url.Values
+		expStatus int
+	}{
+		{"valid", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"}, "room_id": {"1"}},
+			http.StatusSeeOther},
+		{"missing email", url.Values{"first_name": {"John"}, "last_name": {"Smith"}}, http.StatusOK},
+	} {
+		req = httptest.NewRequest("POST", "/waitlist", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		session.Put(req.Context(), "waitlist", entry)
+		rr = httptest.NewRecorder()
+		http.HandlerFunc(Handler.PostWaitlist).ServeHTTP(rr, req)
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+	}
+}
+
+func TestRouteHandler_WaitlistBook(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		token       string
+		expLocation string
+	}{
+		{"valid", "/make-reservations"},
+		{"expired", "/search-availability"},
+		{"unknown", "/search-availability"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/waitlist/book/"+e.token, nil)
+		req = withURLParams(req.WithContext(getCtx(req)), "token", e.token)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.WaitlistBook).ServeHTTP(rr, req)
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != e.expLocation {
+			t.Errorf("for %s, expected a redirect to %s but got %d to %q", e.token, e.expLocation, rr.Code,
+				rr.Header().Get("Location"))
+		}
+		if e.token == "valid" {
+			res, ok := session.Get(req.Context(), "reservation").(models.Reservation)
+			if !ok || res.RoomID != 1 || res.Email != "john@smith.com" {
+				t.Errorf("expected the reservation of the guest in the session, got %+v", res)
+			}
+			// the offer is accepted only once the guest books
+			if token := session.GetString(req.Context(), "waitlist_token"); token != "valid" {
+				t.Errorf("expected the offer followed in the session, got %q", token)
+			}
+		}
+	}
+}
+
+func TestRouteHandler_PostReservation_WaitlistOffer(t *testing.T) {
+	getRoutes()
+	appConfig.MailChan = make(chan *models.MailData, 10)
+	values := url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "email": {"john@smith.com"},
+		"phone": {"767-432-4312"}}
+	req := httptest.NewRequest("POST", "/make-reservations", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "reservation", models.Reservation{RoomID: 1,
+		CheckInDate:  time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+		CheckOutDate: time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC)})
+	session.Put(req.Context(), "waitlist_token", "valid")
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.PostReservation).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if session.Exists(req.Context(), "waitlist_token") {
+		t.Error("expected the waitlist offer to be accepted with the booking")
+	}
+}
+
+func TestRouteHandler_ExpireWaitlistOffers(t *testing.T) {
+	getRoutes()
+	appConfig.MailChan = make(chan *models.MailData, 10)
+	err := Handler.ExpireWaitlistOffers(time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(appConfig.MailChan) != 1 {
+		t.Fatalf("expected the room to be offered to the next guest, got %d emails", len(appConfig.MailChan))
+	}
+	msg := <-appConfig.MailChan
+	if msg.To != "john@smith.com" || !strings.Contains(msg.Content, "http://localhost/waitlist/book/") {
+		t.Errorf("expected a booking link for john@smith.com, got %+v", msg)
+	}
+}
+
+func TestRouteHandler_ChooseRoom_Hold(t *testing.T) {
+	getRoutes()
+	reservation := models.Reservation{
+		CheckInDate:  time.Now().AddDate(0, 0, 7),
+		CheckOutDate: time.Now().AddDate(0, 0, 10),
+	}
+	tests := []struct {
+		roomID      string
+		expLocation string
+		expHold     bool
+	}{
+		{"1", "/make-reservations", true},
+		{"2", "/search-availability", false},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/choose-room/"+e.roomID, nil)
+		req = withURLParams(req.WithContext(getCtx(req)), "id", e.roomID)
+		session.Put(req.Context(), "reservation", reservation)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.ChooseRoom).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != e.expLocation {
+			t.Errorf("for room %s, expected a redirect to %s but got %d to %q", e.roomID, e.expLocation, rr.Code,
+				rr.Header().Get("Location"))
+		}
+		hold, ok := session.Get(req.Context(), "hold").(models.RoomRestriction)
+		if ok != e.expHold {
+			t.Errorf("for room %s, expected a hold in the session to be %v", e.roomID, e.expHold)
+		}
+		if ok && (hold.RestrictionID != models.RestrictionHold || !hold.ExpiresAt.After(time.Now())) {
+			t.Errorf("for room %s, expected a hold expiring in the future but got %+v", e.roomID, hold)
+		}
+	}
+}
+
+func TestRouteHandler_ReleaseHold(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/make-reservations/cancel", nil)
+	req = req.WithContext(getCtx(req))
+	session.Put(req.Context(), "hold", models.RoomRestriction{ID: 1, RoomID: 1})
+	session.Put(req.Context(), "reservation", models.Reservation{RoomID: 1})
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.ReleaseHold).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther || session.Exists(req.Context(), "hold") || session.Exists(req.Context(), "reservation") {
+		t.Errorf("expected the hold and reservation to be dropped, got %d", rr.Code)
+	}
+}
+
+func TestRouteHandler_AdminReservationsCalender_Drag(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/admin/reservations-calender?y=2050&m=7", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminReservationsCalender).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected the calendar, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `data-reservation="1"`) ||
+		!strings.Contains(rr.Body.String(), `data-start="2050-07-10"`) {
+		t.Error("expected the reservation to be draggable with its stay")
+	}
+}
+
+func TestRouteHandler_AdminMoveReservation(t *testing.T) {
+	getRoutes()
+	appConfig.MailChan = make(chan *models.MailData, 10)
+	tests := []struct {
+		name      string
+		id        string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"other room", "1", url.Values{"room_id": {"3"}, "start_date": {"2050-07-10"}, "end_date": {"2050-07-12"}},
+			http.StatusOK, ""},
+		{"other dates", "1", url.Values{"room_id": {"1"}, "start_date": {"2050-07-14"}, "end_date": {"2050-07-16"}},
+			http.StatusOK, ""},
+		{"room unavailable", "1", url.Values{"room_id": {"2"}, "start_date": {"2050-07-10"}, "end_date": {"2050-07-12"}},
+			http.StatusConflict, ""},
+		{"end before start", "1", url.Values{"room_id": {"1"}, "start_date": {"2050-07-12"}, "end_date": {"2050-07-10"}},
+			http.StatusBadRequest, "end_date"},
+		{"missing room", "1", url.Values{"start_date": {"2050-07-10"}, "end_date": {"2050-07-12"}},
+			http.StatusBadRequest, "room_id"},
+		{"unknown reservation", "9", url.Values{"room_id": {"1"}, "start_date": {"2050-07-10"}, "end_date": {"2050-07-12"}},
+			http.StatusNotFound, ""},
+		{"stay too short", "1", url.Values{"room_id": {"4"}, "start_date": {"2050-07-10"}, "end_date": {"2050-07-12"}},
+			http.StatusBadRequest, "start_date"},
+		{"long enough stay", "1", url.Values{"room_id": {"4"}, "start_date": {"2050-07-10"}, "end_date": {"2050-07-13"}},
+			http.StatusOK, ""},
+		{"extra sold out", "1", url.Values{"room_id": {"1"}, "start_date": {"2050-08-10"}, "end_date": {"2050-08-12"}},
+			http.StatusBadRequest, "extras"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/reservations/"+e.id+"/move", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req.WithContext(getCtx(req)), "id", e.id)
+		session.Put(req.Context(), "user_id", 1)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminMoveReservation).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+		var resp jsonResponse
+		err := json.Unmarshal(rr.Body.Bytes(), &resp)
+		if err != nil {
+			t.Errorf("for %s, failed to parse json: %v", e.name, err)
+		}
+		if resp.OK != (e.expStatus == http.StatusOK) {
+			t.Errorf("for %s, expected ok to be %v", e.name, e.expStatus == http.StatusOK)
+		}
+		if e.expError != "" && resp.Errors[e.expError] == "" {
+			t.Errorf("for %s, expected an error for %s but got %v", e.name, e.expError, resp.Errors)
+		}
+	}
+}
+
+func TestRouteHandler_AdminReservationsCalender_RangedBlock(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/admin/reservations-calender?y=2050&m=7", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminReservationsCalender).ServeHTTP(rr, req)
+
+	body := rr.Body.String()
+	if !strings.Contains(body, "name='remove_block_1_07/20/2050'") {
+		t.Error("expected the first day of the block to be removable")
+	}
+	if strings.Contains(body, "remove_block_1_07/21/2050") || strings.Contains(body, "add_block_1_07/21/2050") {
+		t.Error("expected the other days of the block not to have a checkbox")
+	}
+	if !strings.Contains(body, "add_block_1_07/25/2050") {
+		t.Error("expected the room to be free on the day the block ends")
+	}
+	if strings.Count(body, `name="shown_block" value="2"`) != 1 {
+		t.Error("expected the block to be listed once on the form")
+	}
+}
+
+func TestRouteHandler_AdminPostBlocks(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name     string
+		values   url.Values
+		expFlash bool
+	}{
+		{"two rooms", url.Values{"room_id": {"1", "3"}, "start_date": {"2050-08-01"}, "end_date": {"2050-08-15"},
+			"reason": {"Renovation"}, "notes": {"New bathrooms"}}, true},
+		{"room with reservations", url.Values{"room_id": {"1", "2"}, "start_date": {"2050-08-01"},
+			"end_date": {"2050-08-15"}, "reason": {"Renovation"}}, false},
+		{"no rooms", url.Values{"start_date": {"2050-08-01"}, "end_date": {"2050-08-15"}, "reason": {"Renovation"}},
+			false},
+		{"end before start", url.Values{"room_id": {"1"}, "start_date": {"2050-08-15"}, "end_date": {"2050-08-01"},
+			"reason": {"Renovation"}}, false},
+		{"no reason", url.Values{"room_id": {"1"}, "start_date": {"2050-08-01"}, "end_date": {"2050-08-15"}}, false},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/blocks", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostBlocks).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther {
+			t.Errorf("for %s, expected %d but got %d", e.name, http.StatusSeeOther, rr.Code)
+		}
+		if session.Exists(req.Context(), "flash") != e.expFlash || session.Exists(req.Context(), "error") == e.expFlash {
+			t.Errorf("for %s, expected the rooms to be blocked to be %v", e.name, e.expFlash)
+		}
+	}
+}
+
+func TestRouteHandler_AdminPostReservationsCalender(t *testing.T) {
+	getRoutes()
+	appConfig.MailChan = make(chan *models.MailData, 10)
+	tests := []struct {
+		name       string
+		values     url.Values
+		propertyID int
+		expFlash   bool
+	}{
+		{"block removed and day added", url.Values{"y": {"2050"}, "m": {"7"}, "version": {"1"}, "shown_block": {"2"},
+			"add_block_1_07/28/2050": {"1"}}, 0, true},
+		{"block kept", url.Values{"y": {"2050"}, "m": {"7"}, "version": {"1"}, "shown_block": {"2"},
+			"remove_block_1_07/20/2050": {"2"}}, 0, true},
+		{"changed by someone else", url.Values{"y": {"2050"}, "m": {"7"}, "version": {"0"}, "shown_block": {"2"}}, 0,
+			false},
+		{"day booked", url.Values{"y": {"2050"}, "m": {"7"}, "version": {"1"}, "add_block_2_07/20/2050": {"1"}}, 0,
+			false},
+		{"calendar of a property", url.Values{"y": {"2050"}, "m": {"7"}, "version": {"2"}, "shown_block": {"2"}}, 2,
+			true},
+		{"changed at the property", url.Values{"y": {"2050"}, "m": {"7"}, "version": {"1"}, "shown_block": {"2"}}, 2,
+			false},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/reservations-calender", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		session.Put(req.Context(), "property_id", e.propertyID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostReservationsCalender).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/reservations-calender?y=2050&m=7" {
+			t.Errorf("for %s, expected a redirect to the calendar but got %d to %q", e.name, rr.Code,
+				rr.Header().Get("Location"))
+		}
+		if session.Exists(req.Context(), "flash") != e.expFlash || session.Exists(req.Context(), "error") == e.expFlash {
+			t.Errorf("for %s, expected the calendar to be saved to be %v", e.name, e.expFlash)
+		}
+	}
+}
+
+func TestRouteHandler_AdminDashBoard(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name     string
+		query    string
+		expError bool
+	}{
+		{"default range", "", false},
+		{"chosen range", "?from=2050-07-01&to=2050-07-31", false},
+		{"end before start", "?from=2050-07-31&to=2050-07-01", true},
+		{"too long", "?from=2050-01-01&to=2055-01-01", true},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/dashboard"+e.query, nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminDashBoard).ServeHTTP(rr, req)
+
+		body := rr.Body.String()
+		if rr.Code != http.StatusOK {
+			t.Errorf("for %s, expected %d but got %d", e.name, http.StatusOK, rr.Code)
+		}
+		if !strings.Contains(body, "<svg") || !strings.Contains(body, "48.39%") || !strings.Contains(body, "20%") {
+			t.Errorf("for %s, expected the charts with occupancy and cancellation rates", e.name)
+		}
+		if strings.Contains(body, "is-invalid") != e.expError {
+			t.Errorf("for %s, expected an invalid range to be %v", e.name, e.expError)
+		}
+	}
+}
+
+func TestRouteHandler_AdminExportReservations(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name       string
+		query      string
+		expStatus  int
+		expType    string
+		expRows    int
+		expContent string
+	}{
+		{"all as csv", "", http.StatusOK, "text/csv; charset=utf-8", 2, "John,Smith,john@smith.com"},
+		{"new", "?status=new&format=csv", http.StatusOK, "text/csv; charset=utf-8", 1, "2050-07-10,2050-07-12,2,2,200.00"},
+		{"room", "?room_id=2", http.StatusOK, "text/csv; charset=utf-8", 1, "Jane"},
+		{"dates", "?from=2050-07-15&to=2050-07-31", http.StatusOK, "text/csv; charset=utf-8", 1, "processed"},
+		{"booked", "?room_id=1", http.StatusOK, "text/csv; charset=utf-8", 1, "new,2050-06-01 09:30"},
+		{"excel", "?format=xlsx", http.StatusOK, export.FormatXLSX.ContentType(), 0, ""},
+		{"unknown format", "?format=pdf", http.StatusBadRequest, "", 0, ""},
+		{"unknown status", "?status=cancelled", http.StatusBadRequest, "", 0, ""},
+		{"invalid date", "?from=07/10/2050", http.StatusBadRequest, "", 0, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/reservations-export"+e.query, nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminExportReservations).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expType == "" {
+			continue
+		}
+		if rr.Header().Get("Content-Type") != e.expType {
+			t.Errorf("for %s, expected %s but got %s", e.name, e.expType, rr.Header().Get("Content-Type"))
+		}
+		if e.expType == export.FormatXLSX.ContentType() {
+			if !strings.HasPrefix(rr.Body.String(), "PK") {
+				t.Errorf("for %s, expected a zipped workbook", e.name)
+			}
+			continue
+		}
+		lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+		if len(lines)-1 != e.expRows {
+			t.Errorf("for %s, expected %d reservations but got %d", e.name, e.expRows, len(lines)-1)
+		}
+		if !strings.Contains(rr.Body.String(), e.expContent) {
+			t.Errorf("for %s, expected %q in the export, got %s", e.name, e.expContent, rr.Body.String())
+		}
+	}
+}
+
+func TestRouteHandler_AdminImportReservations(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/admin/reservations-import", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminImportReservations).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected %d but got %d", http.StatusOK, rr.Code)
+	}
+}
+
+func TestRouteHandler_AdminPostImportReservations(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name       string
+		csv        string
+		dryRun     bool
+		expStatus  int
+		expFlash   string
+		expWarning bool
+	}{
+		{"valid", "First Name,Last Name,Email,Room,Check-in,Check-out\nJohn,Smith,john@here.com,General's Quarters,2050-08-01,2050-08-03\n",
+			false, http.StatusSeeOther, "1 of 1 reservations imported", false},
+		{"dry run", "First Name,Last Name,Email,Room,Check-in,Check-out\nJohn,Smith,john@here.com,General's Quarters,2050-08-01,2050-08-03\nJane,Smith,jane@here.com,Major's Suite,2050-08-01,2050-08-03\n",
+			true, http.StatusSeeOther, "Dry run of 2 rows: 1 can be imported, 1 failed", true},
+		{"missing columns", "First Name,Last Name\nJohn,Smith\n", false, http.StatusOK, "", false},
+		{"no file", "", false, http.StatusOK, "", false},
+	}
+	for _, e := range tests {
+		body := new(bytes.Buffer)
+		mw := multipart.NewWriter(body)
+		if e.csv != "" {
+			fw, _ := mw.CreateFormFile("reservations_file", "reservations.csv")
+			fw.Write([]byte(e.csv))
+		}
+		if e.dryRun {
+			mw.WriteField("dry_run", "1")
+		}
+		mw.Close()
+
+		req := httptest.NewRequest("POST", "/admin/reservations-import", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostImportReservations).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if flash := session.PopString(req.Context(), "flash"); flash != e.expFlash {
+			t.Errorf("for %s, expected flash %q but got %q", e.name, e.expFlash, flash)
+		}
+		if warning := session.Exists(req.Context(), "warning"); warning != e.expWarning {
+			t.Errorf("for %s, expected a warning to be %v", e.name, e.expWarning)
+		}
+	}
+}
+
+func TestRouteHandler_AdminImportErrors(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		id        string
+		expStatus int
+	}{
+		{"found", "1", http.StatusOK},
+		{"not found", "2", http.StatusNotFound},
+		{"rows no longer kept", "3", http.StatusSeeOther},
+		{"invalid id", "one", http.StatusBadRequest},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/imports/"+e.id+"/errors", nil)
+		req = withURLParams(req, "id", e.id)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminImportErrors).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus == http.StatusOK && !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/csv") {
+			t.Errorf("for %s, expected a CSV file but got %s", e.name, rr.Header().Get("Content-Type"))
+		}
+	}
+}
+
+func TestRouteHandler_AdminAllReservations(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name       string
+		query      string
+		expStatus  int
+		expContent []string
+		expMissing []string
+	}{
+		{"all", "", http.StatusOK, []string{"John", "Jane", "Showing 1 to 2 of 2 reservations"}, nil},
+		{"search", "?q=jane", http.StatusOK, []string{"Jane"}, []string{"John"}},
+		{"room", "?room_id=1", http.StatusOK, []string{"John"}, []string{"Jane"}},
+		{"page", "?per_page=10&page=2", http.StatusOK, []string{"No reservations found"}, nil},
+		{"sorted", "?sort=name&order=asc", http.StatusOK,
+			[]string{"order=desc&amp;sort=name", "Name ▲"}, nil},
+		{"invalid room", "?room_id=one", http.StatusOK, []string{"No reservations found"}, nil},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/reservations-all"+e.query, nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminAllReservations).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		for _, s := range e.expContent {
+			if !strings.Contains(rr.Body.String(), s) {
+				t.Errorf("for %s, expected %q in the page", e.name, s)
+			}
+		}
+		for _, s := range e.expMissing {
+			if strings.Contains(rr.Body.String(), s) {
+				t.Errorf("for %s, did not expect %q in the page", e.name, s)
+			}
+		}
+	}
+}
+
+func TestRouteHandler_AdminNewReservations(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/admin/reservations-new?status=processed", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminNewReservations).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected %d but got %d", http.StatusOK, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "John") || strings.Contains(rr.Body.String(), "Jane") {
+		t.Error("expected only the new reservation to be listed")
+	}
+	if !strings.Contains(rr.Body.String(), "status=new") {
+		t.Error("expected the export links to export new reservations")
+	}
+}
+
+func TestRouteHandler_AdminGuests(t *testing.T) {
+	getRoutes()
+	req := httptest.NewRequest("GET", "/admin/guests?q=smith", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminGuests).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected %d but got %d", http.StatusOK, rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), "jsmith@work.com") {
+		t.Error("expected the guests to be listed")
+	}
+}
+
+func TestRouteHandler_AdminShowGuest(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		id        string
+		expStatus int
+	}{
+		{"found", "1", http.StatusOK},
+		{"not found", "3", http.StatusNotFound},
+		{"invalid id", "one", http.StatusBadRequest},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/guests/"+e.id, nil)
+		req = withURLParams(req, "id", e.id)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminShowGuest).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus == http.StatusOK {
+			for _, s := range []string{"Likes the quiet room", "jsmith@work.com", "Major&#39;s Suite"} {
+				if !strings.Contains(rr.Body.String(), s) {
+					t.Errorf("for %s, expected %q in the page", e.name, s)
+				}
+			}
+		}
+	}
+}
+
+func TestRouteHandler_AdminPostGuest(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"valid", url.Values{"first_name": {"John"}, "last_name": {"Smith"}, "tag": {"VIP"},
+			"other_tags": {"vip, Birthday in May,, "}}, http.StatusSeeOther, ""},
+		{"missing name", url.Values{"first_name": {"John"}}, http.StatusOK, "This field is required"},
+		{"too many tags", url.Values{"first_name": {"John"}, "last_name": {"Smith"},
+			"other_tags": {"a,b,c,d,e,f,g,h,i,j,k,l,m,n,o,p,q,r,s,t,u"}}, http.StatusOK, "A guest can have up to 20 tags"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/guests/1", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req, "id", "1")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostGuest).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expError != "" && !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected %q in the page", e.name, e.expError)
+		}
+	}
+}
+
+func TestRouteHandler_AdminMergeGuest(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name     string
+		mergeID  string
+		expFlash bool
+	}{
+		{"duplicate", "2", true},
+		{"same guest", "1", false},
+		{"unknown guest", "5", false},
+		{"missing guest", "", false},
+	}
+	for _, e := range tests {
+		values := url.Values{"merge_id": {e.mergeID}}
+		req := httptest.NewRequest("POST", "/admin/guests/1/merge", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req, "id", "1")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminMergeGuest).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/guests/1" {
+			t.Errorf("for %s, expected a redirect to the guest but got %d %s", e.name, rr.Code, rr.Header().Get("Location"))
+		}
+		if flash := session.Exists(req.Context(), "flash"); flash != e.expFlash {
+			t.Errorf("for %s, expected the merge to succeed to be %v", e.name, e.expFlash)
+		}
+		if e.expFlash == session.Exists(req.Context(), "error") {
+			t.Errorf("for %s, expected an error to be %v", e.name, !e.expFlash)
+		}
+	}
+}
+
+func TestParseGuestTags(t *testing.T) {
+	tags := parseGuestTags([]string{"vip", " Birthday   in May ", "", "VIP", "do not RENT"})
+	expected := []string{"VIP", "Birthday in May", "Do not rent"}
+	if strings.Join(tags, "|") != strings.Join(expected, "|") {
+		t.Errorf("expected %v but got %v", expected, tags)
+	}
+}
+
+func TestRouteHandler_AdminPrivacy(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name     string
+		email    string
+		expected string
+	}{
+		{"no email", "", "Find everything kept about a guest"},
+		{"guest", "john@smith.com", "Download ZIP"},
+		{"invalid email", "john", "Invalid email address"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/privacy?"+url.Values{"email": {e.email}}.Encode(), nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPrivacy).ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusOK {
+			t.Errorf("for %s, expected %d but got %d", e.name, http.StatusOK, rr.Code)
+			continue
+		}
+		if !strings.Contains(rr.Body.String(), e.expected) {
+			t.Errorf("for %s, expected %q in the page", e.name, e.expected)
+		}
+	}
+}
+
+func TestRouteHandler_AdminExportGuestData(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name        string
+		query       string
+		expStatus   int
+		contentType string
+	}{
+		{"zip", "email=john@smith.com", http.StatusOK, "application/zip"},
+		{"json", "email=john@smith.com&format=json", http.StatusOK, "application/json"},
+		{"invalid email", "email=john", http.StatusBadRequest, ""},
+		{"invalid format", "email=john@smith.com&format=xml", http.StatusBadRequest, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/privacy/export?"+e.query, nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminExportGuestData).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.contentType != "" && rr.Header().Get("Content-Type") != e.contentType {
+			t.Errorf("for %s, expected %s but got %s", e.name, e.contentType, rr.Header().Get("Content-Type"))
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/admin/privacy/export?email=john@smith.com&format=json", nil)
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminExportGuestData).ServeHTTP(rr, req)
+	if !strings.Contains(rr.Body.String(), `"number": "INV-000001"`) {
+		t.Errorf("expected the invoices in the export but got %s", rr.Body.String())
+	}
+}
+
+func TestRouteHandler_AdminEraseGuestData(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name     string
+		values   url.Values
+		expFlash bool
+		expError string
+	}{
+		{"confirmed", url.Values{"email": {"john@smith.com"}, "confirm_email": {"John@Smith.com "}}, true, ""},
+		{"not confirmed", url.Values{"email": {"john@smith.com"}}, false, "This field is required"},
+		{"different email", url.Values{"email": {"john@smith.com"}, "confirm_email": {"jane@doe.com"}}, false,
+			"Type the email address again to confirm"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/privacy/erase", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminEraseGuestData).ServeHTTP(rr, req)
+
+		if e.expFlash {
+			if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/admin/privacy?email=john%40smith.com" {
+				t.Errorf("for %s, expected a redirect but got %d %s", e.name, rr.Code, rr.Header().Get("Location"))
+			}
+			if flash := session.PopString(req.Context(), "flash"); !strings.Contains(flash, "1 reservation(s)") {
+				t.Errorf("for %s, unexpected flash %q", e.name, flash)
+			}
+			continue
+		}
+		if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected %q in the page but got %d", e.name, e.expError, rr.Code)
+		}
+	}
+}
+
+func TestRouteHandler_AnonymiseOldStays(t *testing.T) {
+	getRoutes()
+	defer func() { appConfig.RetentionMonths = 0 }()
+	for _, months := range []int{0, 24} {
+		appConfig.RetentionMonths = months
+		if err := Handler.AnonymiseOldStays(time.Now()); err != nil {
+			t.Errorf("with a retention of %d months, expected no error but got %v", months, err)
+		}
+	}
+}
+
+func TestRouteHandler_AdminFrontDesk(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		query     string
+		expStatus int
+	}{
+		{"today", "", http.StatusOK},
+		{"other day", "?date=2050-07-10", http.StatusOK},
+		{"invalid date", "?date=tomorrow", http.StatusOK},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/reservations-today"+e.query, nil)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminFrontDesk).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		body := rr.Body.String()
+		arrivals := strings.Index(body, "Arrivals")
+		departures := strings.Index(body, "Departures")
+		inHouse := strings.Index(body, "In House")
+		john, jim, jane := strings.Index(body, "John Smith"), strings.Index(body, "Jim Brown"), strings.Index(body, "Jane Doe")
+		if !(arrivals < john && john < departures && departures < jim && jim < inHouse && inHouse < jane) {
+			t.Errorf("for %s, expected John arriving, Jim leaving and Jane in house", e.name)
+		}
+	}
+}
+
+func TestRouteHandler_AdminCheckIn(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		id        string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"valid", "1", url.Values{"id_verification": {"Passport 1234"}, "key_number": {"101"},
+			"checked_in_at": {"2050-07-10T11:30"}, "fee": {"20.00"}}, http.StatusSeeOther, ""},
+		{"other room", "1", url.Values{"id_verification": {"Passport 1234"}, "room_id": {"3"}}, http.StatusSeeOther, ""},
+		{"unavailable room", "1", url.Values{"id_verification": {"Passport 1234"}, "room_id": {"2"}}, http.StatusOK,
+			"The room is not available for the stay"},
+		{"missing ID", "1", url.Values{"key_number": {"101"}}, http.StatusOK, "This field is required"},
+		{"invalid time", "1", url.Values{"id_verification": {"Passport"}, "checked_in_at": {"noon"}}, http.StatusOK,
+			"Invalid date and time"},
+		{"invalid fee", "1", url.Values{"id_verification": {"Passport"}, "fee": {"twenty"}}, http.StatusOK,
+			"Enter an amount such as 12.50"},
+		{"checked in", "4", url.Values{"id_verification": {"Passport"}}, http.StatusSeeOther,
+			"The guest has already checked in"},
+	}
+	appConfig.MailChan = make(chan *models.MailData, 10)
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/reservations/today/"+e.id+"/check-in", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req, "src", "today", "id", e.id)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminCheckIn).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus == http.StatusSeeOther && rr.Header().Get("Location") != "/admin/reservations/today/"+e.id+"/show" {
+			t.Errorf("for %s, unexpected redirect to %s", e.name, rr.Header().Get("Location"))
+		}
+		if e.expStatus == http.StatusSeeOther {
+			if msg := session.PopString(req.Context(), "error"); msg != e.expError {
+				t.Errorf("for %s, expected the error %q but got %q", e.name, e.expError, msg)
+			}
+		} else if !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected %q in the page", e.name, e.expError)
+		}
+	}
+}
+
+func TestRouteHandler_AdminCheckOut(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		id        string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"valid", "4", url.Values{"checked_out_at": {"2050-07-12T13:00"}, "fee": {"15"}}, http.StatusSeeOther, ""},
+		{"before check-in", "4", url.Values{"checked_out_at": {"2050-07-09T10:00"}}, http.StatusOK,
+			"The guest cannot check out before they checked in"},
+		{"not checked in", "1", url.Values{}, http.StatusSeeOther, "The guest is not checked in"},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/reservations/all/"+e.id+"/check-out", strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req, "src", "all", "id", e.id)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminCheckOut).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus == http.StatusSeeOther {
+			if msg := session.PopString(req.Context(), "error"); msg != e.expError {
+				t.Errorf("for %s, expected the error %q but got %q", e.name, e.expError, msg)
+			}
+		} else if !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected %q in the page", e.name, e.expError)
+		}
+	}
+}
+
+func TestEarlyCheckInLateCheckOut(t *testing.T) {
+	res := models.Reservation{
+		CheckInDate:  time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+		CheckOutDate: time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC),
+		CheckedInAt:  time.Date(2050, time.July, 10, 11, 0, 0, 0, time.UTC),
+	}
+	if !earlyCheckIn(res) {
+		t.Error("expected checking in at 11:00 to be early")
+	}
+	if lateCheckOut(res, time.Date(2050, time.July, 12, 10, 0, 0, 0, time.UTC)) {
+		t.Error("expected a guest in house at 10:00 on their departure day not to be late")
+	}
+	if !lateCheckOut(res, time.Date(2050, time.July, 12, 12, 0, 0, 0, time.UTC)) {
+		t.Error("expected a guest in house at 12:00 on their departure day to be late")
+	}
+	res.CheckedOutAt = time.Date(2050, time.July, 12, 9, 0, 0, 0, time.UTC)
+	if lateCheckOut(res, time.Date(2050, time.July, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Error("expected checking out at 09:00 not to be late")
+	}
+}
+
+func TestParseFormTime(t *testing.T) {
+	form := forms.New(url.Values{"checked_in_at": {"2050-07-10T11:30"}})
+	at := parseFormTime(form, "checked_in_at", time.Time{})
+	if !at.Equal(time.Date(2050, time.July, 10, 11, 30, 0, 0, time.UTC)) || at.Location() != time.UTC {
+		t.Errorf("expected 2050-07-10 11:30 UTC but got %s", at)
+	}
+}
+
+func TestRouteHandler_AdminHousekeeping(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		query     string
+		userID    int
+		expStatus int
+		expTasks  bool
+	}{
+		{"today", "", 1, http.StatusOK, true},
+		{"other day", "?date=2050-07-10", 1, http.StatusOK, true},
+		{"my tasks", "?mine=1", 1, http.StatusOK, true},
+		{"nothing assigned", "?mine=1", 2, http.StatusOK, false},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/housekeeping"+e.query, nil)
+		req = req.WithContext(getCtx(req))
+		session.Put(req.Context(), "user_id", e.userID)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminHousekeeping).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		body := rr.Body.String()
+		if strings.Contains(body, "/admin/housekeeping/tasks/1") != e.expTasks {
+			t.Errorf("for %s, expected tasks shown to be %t", e.name, e.expTasks)
+		}
+		if !strings.Contains(body, "/admin/housekeeping/rooms/2") {
+			t.Errorf("for %s, expected the rooms to be shown", e.name)
+		}
+	}
+}
+
+func TestRouteHandler_AdminPostRoomHousekeeping(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name        string
+		id          string
+		values      url.Values
+		expStatus   int
+		expError    string
+		expLocation string
+	}{
+		{"clean", "1", url.Values{"status": {"clean"}}, http.StatusSeeOther, "", "/admin/housekeeping"},
+		{"out of order", "1", url.Values{"status": {"out_of_order"}, "until": {"2050-07-20"}, "date": {"2050-07-10"},
+			"mine": {"1"}}, http.StatusSeeOther, "", "/admin/housekeeping?date=2050-07-10&mine=1"},
+		{"missing until", "1", url.Values{"status": {"out_of_order"}}, http.StatusSeeOther, "This field is required",
+			"/admin/housekeeping"},
+		{"until in past", "1", url.Values{"status": {"out_of_order"}, "until": {"2000-01-01"}}, http.StatusSeeOther,
+			"The room must be out of order until a later date", "/admin/housekeeping"},
+		{"booked", "2", url.Values{"status": {"out_of_order"}, "until": {"2050-07-20"}}, http.StatusSeeOther,
+			"The room has reservations before that date, move them first", "/admin/housekeeping"},
+		{"invalid status", "1", url.Values{"status": {"sparkling"}}, http.StatusBadRequest, "", ""},
+		{"missing room", "9", url.Values{"status": {"clean"}}, http.StatusNotFound, "", ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/housekeeping/rooms/"+e.id, strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req, "id", e.id)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostRoomHousekeeping).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus != http.StatusSeeOther {
+			continue
+		}
+		if rr.Header().Get("Location") != e.expLocation {
+			t.Errorf("for %s, expected redirect to %s but got %s", e.name, e.expLocation, rr.Header().Get("Location"))
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != e.expError {
+			t.Errorf("for %s, expected the error %q but got %q", e.name, e.expError, msg)
+		}
+	}
+}
+
+func TestRouteHandler_AdminPostHousekeepingTask(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		id        string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"start", "1", url.Values{"status": {"in_progress"}, "assigned_to": {"1"}}, http.StatusSeeOther, ""},
+		{"done", "2", url.Values{"status": {"done"}, "notes": {"Extra towels left"}}, http.StatusSeeOther, ""},
+		{"invalid assignee", "1", url.Values{"status": {"open"}, "assigned_to": {"someone"}}, http.StatusSeeOther,
+			"Choose a member of staff"},
+		{"unknown assignee", "1", url.Values{"status": {"open"}, "assigned_to": {"7"}}, http.StatusSeeOther,
+			"Choose a member of staff"},
+		{"invalid status", "1", url.Values{"status": {"skipped"}}, http.StatusBadRequest, ""},
+		{"missing task", "9", url.Values{"status": {"done"}}, http.StatusNotFound, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/housekeeping/tasks/"+e.id, strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req, "id", e.id)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostHousekeepingTask).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus != http.StatusSeeOther {
+			continue
+		}
+		if msg := session.PopString(req.Context(), "error"); msg != e.expError {
+			t.Errorf("for %s, expected the error %q but got %q", e.name, e.expError, msg)
+		}
+	}
+}
+
+func TestRouteHandler_AdminGenerateHousekeepingTasks(t *testing.T) {
+	getRoutes()
+	values := url.Values{"date": {"2050-07-10"}}
+	req := httptest.NewRequest("POST", "/admin/housekeeping/generate", strings.NewReader(values.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req = req.WithContext(getCtx(req))
+	rr := httptest.NewRecorder()
+	http.HandlerFunc(Handler.AdminGenerateHousekeepingTasks).ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Fatalf("expected %d but got %d", http.StatusSeeOther, rr.Code)
+	}
+	if rr.Header().Get("Location") != "/admin/housekeeping?date=2050-07-10" {
+		t.Errorf("unexpected redirect to %s", rr.Header().Get("Location"))
+	}
+	if msg := session.PopString(req.Context(), "flash"); msg != "2 task(s) added" {
+		t.Errorf("expected the flash %q but got %q", "2 task(s) added", msg)
+	}
+}
+
+func TestRouteHandler_SetProperty(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		slug      string
+		expStatus int
+		expCookie string
+	}{
+		{"property", "harbour-view", http.StatusSeeOther, "property=harbour-view"},
+		{"all", "all", http.StatusSeeOther, "property=;"},
+		{"unknown", "nowhere", http.StatusNotFound, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/set-property/"+e.slug, nil)
+		req.Header.Set("Referer", "http://example.com/p/fort-smythe/search-availability")
+		req = withURLParams(req, "slug", e.slug)
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.SetProperty).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus != http.StatusSeeOther {
+			continue
+		}
+		if !strings.HasPrefix(rr.Header().Get("Set-Cookie"), e.expCookie) {
+			t.Errorf("for %s, expected the cookie %s but got %s", e.name, e.expCookie, rr.Header().Get("Set-Cookie"))
+		}
+		if rr.Header().Get("Location") != "/search-availability" {
+			t.Errorf("for %s, unexpected redirect to %s", e.name, rr.Header().Get("Location"))
+		}
+	}
+}
+
+func TestRouteHandler_AdminSetProperty(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name       string
+		staff      int
+		propertyID string
+		expStatus  int
+	}{
+		{"one property", 0, "2", http.StatusSeeOther},
+		{"every property", 0, "0", http.StatusSeeOther},
+		{"own property", 2, "2", http.StatusSeeOther},
+		{"other property", 2, "1", http.StatusForbidden},
+		{"every property of restricted user", 2, "0", http.StatusForbidden},
+		{"missing property", 0, "9", http.StatusBadRequest},
+		{"invalid property", 0, "x", http.StatusBadRequest},
+	}
+	for _, e := range tests {
+		values := url.Values{"property_id": {e.propertyID}}
+		req := httptest.NewRequest("POST", "/admin/property", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = req.WithContext(property.WithStaff(getCtx(req), e.staff))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminSetProperty).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus == http.StatusSeeOther && strconv.Itoa(session.GetInt(req.Context(), "property_id")) != e.propertyID {
+			t.Errorf("for %s, expected property %s in the session but got %d", e.name, e.propertyID,
+				session.GetInt(req.Context(), "property_id"))
+		}
+	}
+}
+
+func TestRouteHandler_OtherProperty(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		method    string
+		url       string
+		id        string
+		values    url.Values
+		handler   http.HandlerFunc
+		staff     int
+		expStatus int
+	}{
+		{"show reservation", "GET", "/admin/reservations/all/1/show", "", nil, Handler.AdminShowReservation, 2,
+			http.StatusForbidden},
+		{"show own reservation", "GET", "/admin/reservations/all/1/show", "", nil, Handler.AdminShowReservation, 1,
+			http.StatusOK},
+		{"invoice", "POST", "/admin/reservations/1/invoice", "1", nil, Handler.AdminCreateInvoice, 2,
+			http.StatusForbidden},
+		{"room status", "POST", "/admin/housekeeping/rooms/1", "1", url.Values{"status": {"clean"}},
+			Handler.AdminPostRoomHousekeeping, 2, http.StatusForbidden},
+		{"task", "POST", "/admin/housekeeping/tasks/1", "1", url.Values{"status": {"done"}},
+			Handler.AdminPostHousekeepingTask, 2, http.StatusNotFound},
+		{"properties", "GET", "/admin/properties", "", nil, Handler.AdminProperties, 2, http.StatusForbidden},
+		{"new property", "POST", "/admin/properties", "", url.Values{"name": {"Cliff House"}},
+			Handler.AdminPostProperty, 2, http.StatusForbidden},
+		{"privacy", "GET", "/admin/privacy", "", nil, Handler.AdminPrivacy, 2, http.StatusForbidden},
+		{"tax rules", "GET", "/admin/tax-rules", "", nil, Handler.AdminTaxRules, 2, http.StatusForbidden},
+		{"extras", "GET", "/admin/extras", "", nil, Handler.AdminExtras, 2, http.StatusForbidden},
+		{"room rule", "POST", "/admin/room-rules", "", url.Values{"room_id": {"1"}, "start_date": {"2050-07-01"},
+			"end_date": {"2050-07-31"}}, Handler.AdminPostRoomRule, 2, http.StatusForbidden},
+		{"move room", "POST", "/admin/rooms/3/property", "3", url.Values{"property_id": {"1"}},
+			Handler.AdminPostRoomProperty, 2, http.StatusForbidden},
+		{"guest", "GET", "/admin/guests/1", "1", nil, Handler.AdminShowGuest, 2, http.StatusForbidden},
+		{"own guest", "GET", "/admin/guests/1", "1", nil, Handler.AdminShowGuest, 1, http.StatusOK},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest(e.method, e.url, strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.RequestURI = e.url
+		req = req.WithContext(property.WithStaff(getCtx(req), e.staff))
+		if e.id != "" {
+			req = withURLParams(req, "id", e.id)
+		}
+		rr := httptest.NewRecorder()
+		e.handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+	}
+}
+
+func TestRouteHandler_AdminPostAssignProperty(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name       string
+		url        string
+		id         string
+		propertyID string
+		handler    http.HandlerFunc
+		expStatus  int
+	}{
+		{"move room", "/admin/rooms/3/property", "3", "1", Handler.AdminPostRoomProperty, http.StatusSeeOther},
+		{"room to every property", "/admin/rooms/3/property", "3", "0", Handler.AdminPostRoomProperty,
+			http.StatusBadRequest},
+		{"missing room", "/admin/rooms/9/property", "9", "1", Handler.AdminPostRoomProperty, http.StatusNotFound},
+		{"missing property", "/admin/rooms/3/property", "3", "9", Handler.AdminPostRoomProperty,
+			http.StatusBadRequest},
+		{"user at property", "/admin/staff/2/property", "2", "2", Handler.AdminPostStaffProperty, http.StatusSeeOther},
+		{"user at every property", "/admin/staff/2/property", "2", "0", Handler.AdminPostStaffProperty,
+			http.StatusSeeOther},
+		{"missing user", "/admin/staff/9/property", "9", "1", Handler.AdminPostStaffProperty, http.StatusNotFound},
+		{"invalid property", "/admin/staff/2/property", "2", "x", Handler.AdminPostStaffProperty,
+			http.StatusBadRequest},
+	}
+	for _, e := range tests {
+		values := url.Values{"property_id": {e.propertyID}}
+		req := httptest.NewRequest("POST", e.url, strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req = withURLParams(req.WithContext(getCtx(req)), "id", e.id)
+		rr := httptest.NewRecorder()
+		e.handler.ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+		}
+	}
+}
+
+func TestRouteHandler_AdminProperties(t *testing.T) {
+	getRoutes()
+	tests := []struct {
+		name      string
+		id        string
+		expStatus int
+		expBody   string
+	}{
+		{"list", "", http.StatusOK, `action="/admin/properties"`},
+		{"edit", "2", http.StatusOK, `value="harbourview.com"`},
+		{"missing", "9", http.StatusNotFound, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("GET", "/admin/properties/"+e.id, nil)
+		handler := Handler.AdminProperties
+		if e.id != "" {
+			req = withURLParams(req, "id", e.id)
+			handler = Handler.AdminShowProperty
+		}
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(handler).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if !strings.Contains(rr.Body.String(), e.expBody) {
+			t.Errorf("for %s, expected the page to contain %s", e.name, e.expBody)
+		}
+	}
+}
+
+func TestRouteHandler_AdminPostProperty(t *testing.T) {
+	getRoutes()
+	valid := func() url.Values {
+		return url.Values{
+			"slug":           {"mountain-lodge"},
+			"name":           {"Mountain Lodge"},
+			"hostname":       {"MountainLodge.com"},
+			"email_from":     {"stay@mountainlodge.com"},
+			"brand_color":    {"#1a73e8"},
+			"deposit_policy": {"percent:20"},
+		}
+	}
+	with := func(field, value string) url.Values {
+		values := valid()
+		values.Set(field, value)
+		return values
+	}
+	tests := []struct {
+		name      string
+		id        string
+		values    url.Values
+		expStatus int
+		expError  string
+	}{
+		{"create", "", valid(), http.StatusSeeOther, ""},
+		{"update", "2", with("slug", "harbour-view"), http.StatusSeeOther, ""},
+		{"missing name", "", with("name", ""), http.StatusOK, "This field is required"},
+		{"invalid slug", "", with("slug", "Mountain Lodge"), http.StatusOK,
+			"Use lower case letters, digits and hyphens, such as harbour-view"},
+		{"reserved slug", "", with("slug", "all"), http.StatusOK,
+			"Use lower case letters, digits and hyphens, such as harbour-view"},
+		{"duplicate slug", "", with("slug", "fort-smythe"), http.StatusOK, "Another property has this slug"},
+		{"duplicate host", "1", with("hostname", "harbourview.com"), http.StatusOK,
+			"Another property is served on this host name"},
+		{"invalid host", "", with("hostname", "http://mountainlodge.com"), http.StatusOK,
+			"Enter a host name such as harbourview.com"},
+		{"invalid email", "", with("email_from", "stay"), http.StatusOK, "Invalid email address"},
+		{"invalid logo", "", with("logo_url", "javascript:alert(1)"), http.StatusOK,
+			"Enter the address of an image, starting with https:// or /"},
+		{"invalid color", "", with("brand_color", "blue"), http.StatusOK, "Enter a color such as #1a73e8"},
+		{"invalid deposit", "", with("deposit_policy", "half"), http.StatusOK, "Enter first-night, percent:N or none"},
+		{"missing property", "9", valid(), http.StatusNotFound, ""},
+	}
+	for _, e := range tests {
+		req := httptest.NewRequest("POST", "/admin/properties/"+e.id, strings.NewReader(e.values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if e.id != "" {
+			req = withURLParams(req, "id", e.id)
+		}
+		req = req.WithContext(getCtx(req))
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.AdminPostProperty).ServeHTTP(rr, req)
+
+		if rr.Code != e.expStatus {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expStatus, rr.Code)
+			continue
+		}
+		if e.expStatus == http.StatusSeeOther && rr.Header().Get("Location") != "/admin/properties" {
+			t.Errorf("for %s, unexpected redirect to %s", e.name, rr.Header().Get("Location"))
+		}
+		if e.expError != "" && !strings.Contains(rr.Body.String(), e.expError) {
+			t.Errorf("for %s, expected the error %q", e.name, e.expError)
+		}
+	}
+}
+
+func TestRouteHandler_PostAvailability_Property(t *testing.T) {
+	getRoutes()
+	values := url.Values{"start_date": {futureDate(7)}, "end_date": {futureDate(10)}}
+	for id, expRooms := range map[int]bool{0: true, 1: true, 2: false} {
+		req := httptest.NewRequest("POST", "/search-availability", strings.NewReader(values.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		ctx := getCtx(req)
+		if p, ok := appConfig.Properties.ByID(id); ok {
+			ctx = property.WithProperty(ctx, p)
+		}
+		req = req.WithContext(ctx)
+		rr := httptest.NewRecorder()
+		http.HandlerFunc(Handler.PostAvailability).ServeHTTP(rr, req)
+
+		if gotRooms := strings.Contains(rr.Body.String(), "/choose-room/1"); gotRooms != expRooms {
+			t.Errorf("for property %d, expected rooms found to be %t", id, expRooms)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"net/http"
+	"time"
+)
+
+// defaultHoldTTL is how long a room is held for a guest filling in the reservation form when no time is configured
+const defaultHoldTTL = 15 * time.Minute
+
+// holdRoom holds the room of the reservation for the guest, replacing the hold they had before, and keeps the hold in
+// the session. It returns repository.ErrRoomUnavailable if another guest has the room for the dates.
+func (rh *RouteHandler) holdRoom(r *http.Request, res models.Reservation) error {
+	ttl := rh.App.HoldTTL
+	if ttl <= 0 {
+		ttl = defaultHoldTTL
+	}
+	previous, _ := rh.App.Session.Get(r.Context(), "hold").(models.RoomRestriction)
+	hold := models.RoomRestriction{
+		StartDate: res.CheckInDate,
+		EndDate:   res.CheckOutDate,
+		RoomID:    res.RoomID,
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	err := rh.DB.HoldRoom(&hold, previous.ID)
+	if err != nil {
+		rh.App.Session.Remove(r.Context(), "hold")
+		return err
+	}
+	rh.App.Session.Put(r.Context(), "hold", hold)
+	return nil
+}
+
+// releaseHold releases the room held for the guest, if any
+func (rh *RouteHandler) releaseHold(r *http.Request) error {
+	hold, ok := rh.App.Session.Pop(r.Context(), "hold").(models.RoomRestriction)
+	if !ok {
+		return nil
+	}
+	return rh.DB.ReleaseHold(hold.ID)
+}
+
+// roomTaken sends the guest back to the search when the room they wanted has gone to another guest
+func (rh *RouteHandler) roomTaken(w http.ResponseWriter, r *http.Request) {
+	rh.App.Session.Put(r.Context(), "error", i18n.T(i18n.FromContext(r.Context()), "hold.taken"))
+	http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+}
+
+// ReleaseHold lets the guest give up the room held for them and search again
+func (rh *RouteHandler) ReleaseHold(w http.ResponseWriter, r *http.Request) {
+	err := rh.releaseHold(r)
+	if err != nil {
+		rh.App.ErrorLog.Println("failed to release hold", err)
+	}
+	rh.App.Session.Remove(r.Context(), "reservation")
+	http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+}
+
+// DeleteExpiredHolds deletes the holds which expired by now. Expired holds no longer make rooms unavailable, so this
+// only keeps the room restrictions tidy.
+func (rh *RouteHandler) DeleteExpiredHolds(now time.Time) error {
+	n, err := rh.DB.DeleteExpiredHolds(now)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		rh.App.InfoLog.Printf("released %d expired hold(s)\n", n)
+	}
+	return nil
+}
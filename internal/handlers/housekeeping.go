@@ -0,0 +1,288 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/property"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxTaskNotesLen is the longest note left on a housekeeping task
+const maxTaskNotesLen = 500
+
+// housekeepingStatuses are the statuses a room can be given, in the order they are shown
+var housekeepingStatuses = []string{
+	models.HousekeepingDirty,
+	models.HousekeepingCleaning,
+	models.HousekeepingClean,
+	models.HousekeepingInspected,
+	models.HousekeepingOutOfOrder,
+}
+
+// taskStatuses are the statuses a housekeeping task can be given, in the order they are shown
+var taskStatuses = []string{
+	models.TaskStatusOpen,
+	models.TaskStatusInProgress,
+	models.TaskStatusDone,
+}
+
+// AdminHousekeeping shows the housekeeping status of every room and the cleaning tasks of a day, today unless the
+// query has a date, only those assigned to the user if the query has mine=1
+func (rh *RouteHandler) AdminHousekeeping(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	day := models.Today(time.Now())
+	if date := parseFormDate(form, "date", htmlDateLayout); !date.IsZero() {
+		day = date
+	}
+	assignedTo := 0
+	if form.Get("mine") == "1" {
+		assignedTo = rh.App.Session.GetInt(r.Context(), "user_id")
+	}
+
+	propertyID := helpers.AdminPropertyID(r)
+	rooms, err := rh.DB.HousekeepingRooms(propertyID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	tasks, err := rh.DB.HousekeepingTasks(day, assignedTo, propertyID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	staff, err := rh.DB.AllStaff(propertyID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	data := make(map[string]interface{})
+	data["day"] = day
+	data["prev"] = day.AddDate(0, 0, -1).Format(htmlDateLayout)
+	data["next"] = day.AddDate(0, 0, 1).Format(htmlDateLayout)
+	data["rooms"] = rooms
+	data["tasks"] = tasks
+	data["staff"] = staff
+	data["room_statuses"] = housekeepingStatuses
+	data["task_statuses"] = taskStatuses
+	stringMap := make(map[string]string)
+	stringMap["mine"] = form.Get("mine")
+	render.Template(w, r, "admin-housekeeping.page.tmpl", &models.TemplateData{
+		Data:      data,
+		Form:      form,
+		StringMap: stringMap,
+	})
+}
+
+// AdminPostRoomHousekeeping changes the housekeeping status of a room. A room put out of order is blocked from today
+// until the date posted, and made available again when it is given another status.
+func (rh *RouteHandler) AdminPostRoomHousekeeping(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	roomID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	status := form.Get("status")
+	if !validStatus(housekeepingStatuses, status) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	ok, err := rh.managesRooms(r, roomID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !ok {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	now := time.Now()
+	var until time.Time
+	if status == models.HousekeepingOutOfOrder {
+		form.Required("until")
+		until = parseFormDate(form, "until", htmlDateLayout)
+		if !until.IsZero() && !until.After(models.Today(now)) {
+			form.Errors.Add("until", "The room must be out of order until a later date")
+		}
+	}
+	if !form.Valid() {
+		rh.App.Session.Put(r.Context(), "error", formErrorMessage(form, "until"))
+		http.Redirect(w, r, housekeepingURL(form), http.StatusSeeOther)
+		return
+	}
+
+	err = rh.DB.SetRoomHousekeepingStatus(roomID, status, until, now)
+	if errors.Is(err, repository.ErrRoomNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		rh.App.Session.Put(r.Context(), "error", "The room has reservations before that date, move them first")
+		http.Redirect(w, r, housekeepingURL(form), http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Room status changed")
+	http.Redirect(w, r, housekeepingURL(form), http.StatusSeeOther)
+}
+
+// AdminPostHousekeepingTask changes the status, assignee and notes of a housekeeping task
+func (rh *RouteHandler) AdminPostHousekeepingTask(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	taskID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	form := forms.New(r.PostForm)
+	task := models.HousekeepingTask{
+		ID:     taskID,
+		Status: form.Get("status"),
+		Notes:  strings.TrimSpace(form.Get("notes")),
+	}
+	if !validStatus(taskStatuses, task.Status) {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	if form.Has("assigned_to") {
+		task.AssignedTo, err = strconv.Atoi(form.Get("assigned_to"))
+		if err != nil || task.AssignedTo < 0 {
+			form.Errors.Add("assigned_to", "Choose a member of staff")
+		}
+	}
+	if task.AssignedTo > 0 {
+		staff, err := rh.DB.AllStaff(helpers.AdminPropertyID(r))
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		if !isStaff(staff, task.AssignedTo) {
+			form.Errors.Add("assigned_to", "Choose a member of staff")
+		}
+	}
+	form.MaxLength("notes", maxTaskNotesLen)
+	if !form.Valid() {
+		rh.App.Session.Put(r.Context(), "error", formErrorMessage(form, "assigned_to", "notes"))
+		http.Redirect(w, r, housekeepingURL(form), http.StatusSeeOther)
+		return
+	}
+
+	err = rh.DB.UpdateHousekeepingTask(&task, property.StaffFromContext(r.Context()))
+	if errors.Is(err, repository.ErrTaskNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Task updated")
+	http.Redirect(w, r, housekeepingURL(form), http.StatusSeeOther)
+}
+
+// AdminGenerateHousekeepingTasks makes the housekeeping tasks of the day posted for its departures and stayovers
+func (rh *RouteHandler) AdminGenerateHousekeepingTasks(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	form := forms.New(r.PostForm)
+	day := models.Today(time.Now())
+	if date := parseFormDate(form, "date", htmlDateLayout); !date.IsZero() {
+		day = date
+	}
+	if !form.Valid() {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	n, err := rh.DB.GenerateHousekeepingTasks(day, time.Now())
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%d task(s) added", n))
+	http.Redirect(w, r, housekeepingURL(form), http.StatusSeeOther)
+}
+
+// GenerateHousekeepingTasks makes the housekeeping tasks of today for its departures and stayovers, keeping the
+// tasks already made
+func (rh *RouteHandler) GenerateHousekeepingTasks(now time.Time) error {
+	n, err := rh.DB.GenerateHousekeepingTasks(models.Today(now), now)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		rh.App.InfoLog.Printf("added %d housekeeping task(s)\n", n)
+	}
+	return nil
+}
+
+// housekeepingURL returns the housekeeping page of the date and filter of a form
+func housekeepingURL(form *forms.Form) string {
+	query := url.Values{}
+	if form.Has("date") {
+		query.Set("date", form.Get("date"))
+	}
+	if form.Get("mine") == "1" {
+		query.Set("mine", "1")
+	}
+	if len(query) == 0 {
+		return "/admin/housekeeping"
+	}
+	return "/admin/housekeeping?" + query.Encode()
+}
+
+// formErrorMessage returns the first error of the fields of a form
+func formErrorMessage(form *forms.Form, fields ...string) string {
+	for _, field := range fields {
+		if msg := form.Errors.Get(field); msg != "" {
+			return msg
+		}
+	}
+	return ""
+}
+
+// isStaff returns true if the user is one of the staff
+func isStaff(staff []models.User, userID int) bool {
+	for _, u := range staff {
+		if u.ID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// validStatus returns true if status is one of statuses
+func validStatus(statuses []string, status string) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/importer"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"net/http"
+	"strconv"
+)
+
+const (
+	maxImportFileSize = 20 << 20
+	recentImportRuns  = 20
+)
+
+// AdminImportReservations shows the reservation import form and the recent imports
+func (rh *RouteHandler) AdminImportReservations(w http.ResponseWriter, r *http.Request) {
+	rh.renderImportReservations(w, r, forms.New(nil))
+}
+
+// AdminPostImportReservations imports the reservations in an uploaded CSV file, or only checks them in a dry run, and
+// records the import with its error file
+func (rh *RouteHandler) AdminPostImportReservations(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportFileSize)
+	form := forms.New(nil)
+	if err := r.ParseMultipartForm(maxImportFileSize); err != nil {
+		form.Errors.Add("reservations_file", "The file must be a CSV file smaller than 20MB")
+		rh.renderImportReservations(w, r, form)
+		return
+	}
+	form = forms.New(r.PostForm)
+	file, header, err := r.FormFile("reservations_file")
+	if err != nil {
+		form.Errors.Add("reservations_file", "Choose a CSV file to import")
+		rh.renderImportReservations(w, r, form)
+		return
+	}
+	defer file.Close()
+
+	run := models.ImportRun{
+		UserID:   rh.App.Session.GetInt(r.Context(), "user_id"),
+		FileName: header.Filename,
+		DryRun:   form.Has("dry_run"),
+	}
+	var errs bytes.Buffer
+	result, err := importer.Import(file, rh.DB, rh.App.Currency.Base(), helpers.AdminPropertyID(r), run.DryRun, &errs)
+	if err != nil {
+		form.Errors.Add("reservations_file", fmt.Sprintf("The file could not be imported: %v", err))
+		rh.renderImportReservations(w, r, form)
+		return
+	}
+	run.Rows, run.Imported, run.Failed = result.Rows, result.Imported, result.Failed
+	if result.Failed > 0 {
+		run.Errors = errs.Bytes()
+	}
+	if err = rh.DB.CreateImportRun(&run); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	if run.DryRun {
+		rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("Dry run of %d rows: %d can be imported, %d failed",
+			run.Rows, run.Imported, run.Failed))
+	} else {
+		rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("%d of %d reservations imported", run.Imported, run.Rows))
+	}
+	if run.Failed > 0 {
+		rh.App.Session.Put(r.Context(), "warning", fmt.Sprintf("%d rows failed, download the error file to fix them",
+			run.Failed))
+	}
+	http.Redirect(w, r, "/admin/reservations-import", http.StatusSeeOther)
+}
+
+// AdminImportErrors sends the rows of an import which failed as a CSV file, with the line and reason for each
+func (rh *RouteHandler) AdminImportErrors(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	run, err := rh.DB.GetImportRunByID(id)
+	if errors.Is(err, repository.ErrImportRunNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if len(run.Errors) == 0 {
+		rh.App.Session.Put(r.Context(), "error", "The failed rows of this import are no longer kept")
+		http.Redirect(w, r, "/admin/reservations-import", http.StatusSeeOther)
+		return
+	}
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="import-%d-errors.csv"`, run.ID))
+	w.Write(run.Errors)
+}
+
+func (rh *RouteHandler) renderImportReservations(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	runs, err := rh.DB.RecentImportRuns(recentImportRuns)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["runs"] = runs
+	render.Template(w, r, "admin-reservations-import.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
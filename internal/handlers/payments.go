@@ -0,0 +1,295 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/payments"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"net/http"
+	"strconv"
+)
+
+// ReservationPayment takes the deposit of the reservation in the session by sending the guest to the checkout of the
+// payment gateway. Reservations without a deposit go straight to the summary.
+func (rh *RouteHandler) ReservationPayment(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
+	res, ok := rh.App.Session.Get(r.Context(), "reservation").(models.Reservation)
+	if !ok {
+		rh.App.Session.Put(r.Context(), "error", i18n.T(locale, "summary.no_reservation"))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+
+	deposit := rh.depositPolicy(res.Room.PropertyID).Amount(res.TotalAmount, res.Room.Price)
+	if deposit <= 0 || rh.App.Payments == nil {
+		http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+		return
+	}
+
+	// reloading the page goes back to the checkout of the deposit already started, whose reference the gateway
+	// reports the outcome with
+	resPayments, err := rh.DB.PaymentsForReservation(res.ID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	payment, ok := pendingPayment(resPayments, rh.App.Payments.Name())
+	if ok && payment.Reference != "" && payment.CheckoutURL != "" {
+		http.Redirect(w, r, payment.CheckoutURL, http.StatusSeeOther)
+		return
+	}
+	if !ok {
+		payment.ReservationID = res.ID
+		payment.Provider = rh.App.Payments.Name()
+		payment.Amount = deposit
+		payment.Currency = rh.App.Currency.Base()
+		payment.Status = string(payments.StatusPending)
+		err = rh.DB.CreatePayment(&payment)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+
+	auth, err := rh.App.Payments.Authorize(r.Context(), payments.AuthorizeRequest{
+		Amount:      payment.Amount,
+		Currency:    payment.Currency,
+		Description: i18n.T(locale, "payment.description", res.Room.RoomName, res.CheckInDate, res.CheckOutDate),
+		Email:       res.Email,
+		ReturnURL:   fmt.Sprintf("/reservation-payment/return?payment=%d", payment.ID),
+	})
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	payment.Reference = auth.Reference
+	payment.CheckoutURL = auth.CheckoutURL
+	err = rh.DB.UpdatePayment(&payment)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	http.Redirect(w, r, auth.CheckoutURL, http.StatusSeeOther)
+}
+
+// pendingPayment returns the last payment of a reservation with the payment gateway still waiting for the guest
+func pendingPayment(resPayments []models.Payment, provider string) (models.Payment, bool) {
+	for i := len(resPayments) - 1; i >= 0; i-- {
+		p := resPayments[i]
+		if p.Provider == provider && payments.Status(p.Status) == payments.StatusPending {
+			return p, true
+		}
+	}
+	return models.Payment{}, false
+}
+
+// ReservationPaymentReturn is where the payment gateway sends the guest back after the checkout
+func (rh *RouteHandler) ReservationPaymentReturn(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
+	res, ok := rh.App.Session.Get(r.Context(), "reservation").(models.Reservation)
+	if !ok {
+		rh.App.Session.Put(r.Context(), "error", i18n.T(locale, "summary.no_reservation"))
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	id, err := strconv.Atoi(r.URL.Query().Get("payment"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	payment, err := rh.DB.GetPaymentByID(id)
+	if err != nil || payment.ReservationID != res.ID {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+
+	switch payments.Status(payment.Status) {
+	case payments.StatusFailed:
+		data := make(map[string]interface{})
+		data["reservation"] = res
+		data["payment"] = payment
+		render.Template(w, r, "payment-failed.page.tmpl", &models.TemplateData{
+			Data: data,
+		})
+		return
+	case payments.StatusPending:
+		// the gateway has not told us the outcome yet, it will arrive with the webhook
+		rh.App.Session.Put(r.Context(), "warning", i18n.T(locale, "payment.pending"))
+	default:
+		rh.App.Session.Put(r.Context(), "flash", i18n.T(locale, "payment.received"))
+	}
+	http.Redirect(w, r, "/reservation-summary", http.StatusSeeOther)
+}
+
+// PaymentWebhook receives the payment events sent by the payment gateway
+func (rh *RouteHandler) PaymentWebhook(w http.ResponseWriter, r *http.Request) {
+	if rh.App.Payments == nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	event, err := rh.App.Payments.VerifyWebhook(r)
+	if err != nil {
+		rh.App.ErrorLog.Println("rejected payment webhook:", err)
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	err = rh.applyPaymentEvent(event)
+	if err != nil {
+		// the gateway retries webhooks which are not acknowledged
+		helpers.ServerError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// applyPaymentEvent records the outcome reported by the payment gateway, ignoring events which would move the
+// payment backwards
+func (rh *RouteHandler) applyPaymentEvent(event payments.Event) error {
+	payment, err := rh.DB.GetPaymentByReference(event.Reference)
+	if errors.Is(err, repository.ErrPaymentNotFound) {
+		// acknowledged so the gateway does not retry an event no payment here will ever match
+		rh.App.InfoLog.Printf("ignoring %s event for unknown payment %s\n", event.Type, event.Reference)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	status := event.Status()
+	if !payments.CanTransition(payments.Status(payment.Status), status) {
+		rh.App.InfoLog.Printf("ignoring %s event for payment %s which is %s\n", event.Type, payment.Reference, payment.Status)
+		return nil
+	}
+	switch status {
+	case payments.StatusCaptured:
+		payment.Captured = event.Amount
+	case payments.StatusRefunded:
+		payment.Refunded = event.Amount
+	}
+	payment.Status = string(status)
+	return rh.DB.UpdatePayment(&payment)
+}
+
+// FakeCheckout shows the checkout page of the fake payment gateway
+func (rh *RouteHandler) FakeCheckout(w http.ResponseWriter, r *http.Request) {
+	fake, ok := rh.App.Payments.(*payments.FakeProvider)
+	if !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	checkout, err := fake.Checkout(chi.URLParam(r, "reference"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	data := make(map[string]interface{})
+	data["checkout"] = checkout
+	render.Template(w, r, "fake-checkout.page.tmpl", &models.TemplateData{
+		Data: data,
+	})
+}
+
+// PostFakeCheckout approves or declines a checkout of the fake payment gateway, then sends the guest back
+func (rh *RouteHandler) PostFakeCheckout(w http.ResponseWriter, r *http.Request) {
+	fake, ok := rh.App.Payments.(*payments.FakeProvider)
+	if !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	reference := chi.URLParam(r, "reference")
+	checkout, err := fake.Checkout(reference)
+	if err != nil {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	event, err := fake.Complete(reference, r.Form.Get("outcome") == "approve")
+	if err != nil {
+		helpers.ClientError(w, http.StatusConflict)
+		return
+	}
+	err = rh.applyPaymentEvent(event)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	http.Redirect(w, r, checkout.ReturnURL, http.StatusSeeOther)
+}
+
+// AdminCapturePayment captures the whole of an authorized payment
+func (rh *RouteHandler) AdminCapturePayment(w http.ResponseWriter, r *http.Request) {
+	rh.adminPaymentAction(w, r, payments.StatusAuthorized, func(p *models.Payment) error {
+		err := rh.App.Payments.Capture(r.Context(), p.Reference, p.Amount)
+		p.Captured = p.Amount
+		p.Status = string(payments.StatusCaptured)
+		return err
+	}, "Payment captured")
+}
+
+// AdminRefundPayment refunds what is left of a captured payment
+func (rh *RouteHandler) AdminRefundPayment(w http.ResponseWriter, r *http.Request) {
+	rh.adminPaymentAction(w, r, payments.StatusCaptured, func(p *models.Payment) error {
+		err := rh.App.Payments.Refund(r.Context(), p.Reference, p.Captured-p.Refunded)
+		p.Refunded = p.Captured
+		p.Status = string(payments.StatusRefunded)
+		return err
+	}, "Payment refunded")
+}
+
+// adminPaymentAction runs an action of the payment gateway on a payment in the expected status, saves the payment
+// and goes back to the reservation it belongs to
+func (rh *RouteHandler) adminPaymentAction(w http.ResponseWriter, r *http.Request, expected payments.Status,
+	action func(p *models.Payment) error, flash string) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	payment, err := rh.DB.GetPaymentByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if _, err = rh.managedReservation(r, payment.ReservationID); err != nil {
+		staffError(w, err)
+		return
+	}
+
+	back := adminReservationURL(r.Form.Get("src"), payment.ReservationID)
+
+	switch {
+	case rh.App.Payments == nil || rh.App.Payments.Name() != payment.Provider:
+		err = fmt.Errorf("payment %d was taken with %s which is not configured", payment.ID, payment.Provider)
+	case payments.Status(payment.Status) != expected:
+		err = errors.New("payment is " + payment.Status)
+	default:
+		err = action(&payment)
+	}
+	if err != nil {
+		rh.App.ErrorLog.Println(err)
+		rh.App.Session.Put(r.Context(), "error", err.Error())
+		http.Redirect(w, r, back, http.StatusSeeOther)
+		return
+	}
+
+	err = rh.DB.UpdatePayment(&payment)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", flash)
+	http.Redirect(w, r, back, http.StatusSeeOther)
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/privacy"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// AdminPrivacy shows a summary of everything kept about the guest with the email address of the query, with the
+// actions to export or erase it. As a guest's data spans every property, only staff of every property may use the
+// privacy tools
+func (rh *RouteHandler) AdminPrivacy(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	form := forms.New(r.URL.Query())
+	if form.Has("email") {
+		form.IsEmail("email")
+	}
+	rh.renderPrivacy(w, r, form)
+}
+
+// AdminExportGuestData downloads everything kept about the guest with the email address of the query, as a zip
+// file holding data.json and their invoices, or as data.json alone
+func (rh *RouteHandler) AdminExportGuestData(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	form := forms.New(r.URL.Query())
+	form.Required("email")
+	form.IsEmail("email")
+	format := form.Get("format")
+	if format == "" {
+		format = "zip"
+	}
+	if !form.Valid() || (format != "zip" && format != "json") {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+
+	data, err := rh.DB.GuestDataByEmail(strings.TrimSpace(form.Get("email")))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	now := time.Now()
+	name := fmt.Sprintf("guest-data-%s", now.Format("20060102"))
+	base := rh.App.Currency.Base()
+	if format == "json" {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, name))
+		err = privacy.WriteJSON(w, data, base, now)
+	} else {
+		w.Header().Set("Content-Type", "application/zip")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.zip"`, name))
+		err = privacy.WriteBundle(w, data, base, now)
+	}
+	if err != nil {
+		// the response has started, so the download is left unfinished for the browser to report
+		rh.App.ErrorLog.Println("failed to export guest data", err)
+	}
+}
+
+// AdminEraseGuestData anonymises the reservations of the guest with an email address and deletes the rest of their
+// data, once the address is typed again to confirm
+func (rh *RouteHandler) AdminEraseGuestData(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	form := forms.New(r.PostForm)
+	form.Required("email", "confirm_email")
+	form.IsEmail("email")
+	email := strings.TrimSpace(form.Get("email"))
+	if form.Has("confirm_email") && !strings.EqualFold(email, strings.TrimSpace(form.Get("confirm_email"))) {
+		form.Errors.Add("confirm_email", "Type the email address again to confirm")
+	}
+	if !form.Valid() {
+		rh.renderPrivacy(w, r, form)
+		return
+	}
+
+	n, err := rh.DB.EraseGuestData(email, time.Now())
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.InfoLog.Printf("erased the data of %s, anonymising %d reservation(s)\n", email, n)
+	rh.App.Session.Put(r.Context(), "flash", fmt.Sprintf("The data of %s was erased and %d reservation(s) anonymised",
+		email, n))
+	http.Redirect(w, r, "/admin/privacy?"+url.Values{"email": {email}}.Encode(), http.StatusSeeOther)
+}
+
+// AnonymiseOldStays anonymises the stays checked out longer ago than the retention period, doing nothing if stays
+// are kept
+func (rh *RouteHandler) AnonymiseOldStays(now time.Time) error {
+	if rh.App.RetentionMonths <= 0 {
+		return nil
+	}
+	cutoff := now.AddDate(0, -rh.App.RetentionMonths, 0).Truncate(24 * time.Hour)
+	n, err := rh.DB.AnonymiseStaysBefore(cutoff, now)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		rh.App.InfoLog.Printf("anonymised %d stay(s) checked out before %s\n", n, cutoff.Format(htmlDateLayout))
+	}
+	return nil
+}
+
+func (rh *RouteHandler) renderPrivacy(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	data := make(map[string]interface{})
+	data["retention_months"] = rh.App.RetentionMonths
+	if email := strings.TrimSpace(form.Get("email")); email != "" && form.Errors.Get("email") == "" {
+		guestData, err := rh.DB.GuestDataByEmail(email)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		data["guest_data"] = guestData
+		data["export_url"] = "/admin/privacy/export?" + url.Values{"email": {email}}.Encode()
+	}
+	render.Template(w, r, "admin-privacy.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
@@ -0,0 +1,186 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/promo"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"github.com/sunil206b/smart_booking/internal/rules"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// promoCodePattern is what a promo code may look like once normalised
+var promoCodePattern = regexp.MustCompile(`^[A-Z0-9_-]{3,40}$`)
+
+// AdminPromoCodes shows the promo codes with how much they have been used
+func (rh *RouteHandler) AdminPromoCodes(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	rh.renderPromoCodes(w, r, forms.New(nil))
+}
+
+// AdminPostPromoCode creates a new promo code
+func (rh *RouteHandler) AdminPostPromoCode(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("code", "kind", "amount", "valid_from", "valid_to")
+	form.MaxLength("description", 255)
+
+	p := models.PromoCode{
+		Code:        promo.Normalize(form.Get("code")),
+		Description: form.Get("description"),
+		Kind:        form.Get("kind"),
+	}
+	if form.Has("code") && !promoCodePattern.MatchString(p.Code) {
+		form.Errors.Add("code", "Use 3 to 40 letters, digits, dashes or underscores")
+	}
+	if form.Has("kind") && !promo.ValidKind(p.Kind) {
+		form.Errors.Add("kind", "Choose a percentage or a fixed discount")
+	}
+	if form.Has("amount") {
+		if promo.Kind(p.Kind) == promo.KindPercent {
+			p.Amount, err = parseBasisPoints(form.Get("amount"))
+			if err != nil || p.Amount == 0 {
+				form.Errors.Add("amount", "Enter a percentage such as 10")
+			}
+		} else {
+			p.Amount, err = currency.ParseAmount(form.Get("amount"), rh.App.Currency.Base())
+			if err != nil || p.Amount <= 0 {
+				form.Errors.Add("amount", "Enter an amount such as 25.00")
+			}
+		}
+	}
+	p.ValidFrom = parseFormDate(form, "valid_from", htmlDateLayout)
+	p.ValidTo = parseFormDate(form, "valid_to", htmlDateLayout)
+	if !p.ValidFrom.IsZero() && p.ValidTo.Before(p.ValidFrom) {
+		form.Errors.Add("valid_to", "End date must not be before the start date")
+	}
+	p.StayStart = parseFormDate(form, "stay_start", htmlDateLayout)
+	p.StayEnd = parseFormDate(form, "stay_end", htmlDateLayout)
+	if !p.StayStart.IsZero() && !p.StayEnd.IsZero() && p.StayEnd.Before(p.StayStart) {
+		form.Errors.Add("stay_end", "End date must not be before the start date")
+	}
+	p.MinNights = parseFormDays(form, "min_nights")
+	p.MaxUses = parseFormCount(form, "max_uses", "This field must be a number of uses")
+	for _, v := range r.PostForm["room_id"] {
+		id, err := strconv.Atoi(v)
+		if err == nil {
+			p.RoomIDs = append(p.RoomIDs, id)
+		}
+	}
+
+	if !form.Valid() {
+		rh.renderPromoCodes(w, r, form)
+		return
+	}
+
+	_, err = rh.DB.GetPromoCodeByCode(p.Code)
+	if err == nil {
+		form.Errors.Add("code", "There is already a promo code "+p.Code)
+		rh.renderPromoCodes(w, r, form)
+		return
+	}
+	if !errors.Is(err, repository.ErrPromoCodeNotFound) {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	err = rh.DB.CreatePromoCode(&p)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Promo code "+p.Code+" saved")
+	http.Redirect(w, r, "/admin/promo-codes", http.StatusSeeOther)
+}
+
+// AdminDeletePromoCode deletes a promo code
+func (rh *RouteHandler) AdminDeletePromoCode(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	err := rh.DB.DeletePromoCode(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Promo code deleted")
+	http.Redirect(w, r, "/admin/promo-codes", http.StatusSeeOther)
+}
+
+func (rh *RouteHandler) renderPromoCodes(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	stats, err := rh.DB.AllPromoCodeStats()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rooms, err := rh.DB.AllRooms(helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	roomNames := make(map[int]string)
+	for _, room := range rooms {
+		roomNames[room.ID] = room.RoomName
+	}
+	data := make(map[string]interface{})
+	data["stats"] = stats
+	data["rooms"] = rooms
+	data["room_names"] = roomNames
+	data["kinds"] = promo.Kinds
+	render.Template(w, r, "admin-promo-codes.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// applyPromoCode checks the promo code entered on the reservation form, adding the reason to the form if it cannot
+// be used, and otherwise records it and its discount on the reservation. Only a failure to look up the code is
+// returned as an error.
+func (rh *RouteHandler) applyPromoCode(form *forms.Form, res *models.Reservation) error {
+	res.PromoCodeID = 0
+	res.PromoCode = ""
+	res.Discount = 0
+	if !form.Has("promo_code") {
+		return nil
+	}
+
+	code, err := rh.DB.GetPromoCodeByCode(promo.Normalize(form.Get("promo_code")))
+	if errors.Is(err, repository.ErrPromoCodeNotFound) {
+		form.AddError("promo_code", "promo.invalid")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if reason := promo.Check(code, res.RoomID, res.CheckInDate, res.CheckOutDate, time.Now()); !reason.IsZero() {
+		form.AddError("promo_code", reason.Key, reason.Args...)
+		return nil
+	}
+
+	res.PromoCodeID = code.ID
+	res.PromoCode = code.Code
+	res.Discount = promo.Discount(code, rules.Nights(res.CheckInDate, res.CheckOutDate)*res.Room.Price)
+	return nil
+}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/payments"
+	"github.com/sunil206b/smart_booking/internal/property"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultMailAddress sends the emails of properties without an address of their own, and receives their booking
+// notifications
+const defaultMailAddress = "me@here.com"
+
+// allPropertiesSlug is the slug chosen to search and show every property on the public site
+const allPropertiesSlug = "all"
+
+// errOtherProperty is returned for the reservations and rooms of a property the signed-in user does not work at
+var errOtherProperty = errors.New("the user does not work at the property")
+
+// LoadProperties reads the properties from the database into the property directory
+func (rh *RouteHandler) LoadProperties() error {
+	properties, err := rh.DB.AllProperties()
+	if err != nil {
+		return err
+	}
+	rh.App.Properties.Set(properties)
+	return nil
+}
+
+// SetProperty remembers the property chosen by the visitor, or that they want to see every property, and sends them
+// back to the page they came from
+func (rh *RouteHandler) SetProperty(w http.ResponseWriter, r *http.Request) {
+	slug := chi.URLParam(r, "slug")
+	if slug == allPropertiesSlug {
+		slug = ""
+	} else if _, ok := rh.App.Properties.BySlug(slug); !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	http.SetCookie(w, property.Cookie(slug, rh.App.InProduction))
+	redirectBack(w, r)
+}
+
+// StaffProperty finds the property the signed-in user works at, keeping the admin tool to it if they work at one
+func (rh *RouteHandler) StaffProperty(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, err := rh.DB.GetUserByID(rh.App.Session.GetInt(r.Context(), "user_id"))
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(property.WithStaff(r.Context(), user.PropertyID)))
+	})
+}
+
+// AdminSetProperty scopes the admin tool to the property posted, or to every property if property_id is 0. Users
+// working at one property can only choose their own.
+func (rh *RouteHandler) AdminSetProperty(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	id, err := strconv.Atoi(r.PostForm.Get("property_id"))
+	if err != nil || id < 0 {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	if _, ok := rh.App.Properties.ByID(id); id != 0 && !ok {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	if !manages(r, id) || (id == 0 && !unrestricted(r)) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "property_id", id)
+	redirectBack(w, r)
+}
+
+// AdminProperties shows the properties run from the site, with a form to add one
+func (rh *RouteHandler) AdminProperties(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	rh.renderProperties(w, r, forms.New(nil), models.Property{})
+}
+
+// AdminShowProperty shows the properties run from the site, with a form to change one of them
+func (rh *RouteHandler) AdminShowProperty(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	p, ok := rh.App.Properties.ByID(id)
+	if !ok {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	form := forms.New(url.Values{
+		"slug":            {p.Slug},
+		"name":            {p.Name},
+		"hostname":        {p.Hostname},
+		"email_from":      {p.EmailFrom},
+		"email_from_name": {p.EmailFromName},
+		"logo_url":        {p.LogoURL},
+		"brand_color":     {p.BrandColor},
+		"deposit_policy":  {p.DepositPolicy},
+	})
+	rh.renderProperties(w, r, form, p)
+}
+
+// AdminPostProperty adds a property, or changes the property in the URL
+func (rh *RouteHandler) AdminPostProperty(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	var p models.Property
+	if idParam := chi.URLParam(r, "id"); idParam != "" {
+		id, _ := strconv.Atoi(idParam)
+		var ok bool
+		if p, ok = rh.App.Properties.ByID(id); !ok {
+			helpers.ClientError(w, http.StatusNotFound)
+			return
+		}
+	}
+
+	form := forms.New(r.PostForm)
+	rh.parseProperty(form, &p)
+	if !form.Valid() {
+		rh.renderProperties(w, r, form, p)
+		return
+	}
+
+	if p.ID == 0 {
+		err = rh.DB.CreateProperty(&p)
+	} else {
+		err = rh.DB.UpdateProperty(&p)
+	}
+	if errors.Is(err, repository.ErrPropertyNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if err = rh.LoadProperties(); err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Property "+p.Name+" saved")
+	http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+}
+
+// AdminPostRoomProperty moves a room to another property, with its reservations and rules
+func (rh *RouteHandler) AdminPostRoomProperty(w http.ResponseWriter, r *http.Request) {
+	p, ok := rh.assignedProperty(w, r)
+	if !ok {
+		return
+	}
+	if p.ID == 0 {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return
+	}
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	err := rh.DB.SetRoomProperty(id, p.ID)
+	if errors.Is(err, repository.ErrRoomNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Room moved to "+p.Name)
+	http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+}
+
+// AdminPostStaffProperty ties a user to the property they work at, or lets them work at every property
+func (rh *RouteHandler) AdminPostStaffProperty(w http.ResponseWriter, r *http.Request) {
+	p, ok := rh.assignedProperty(w, r)
+	if !ok {
+		return
+	}
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	err := rh.DB.SetUserProperty(id, p.ID)
+	if errors.Is(err, repository.ErrUserNotFound) {
+		helpers.ClientError(w, http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if p.ID == 0 {
+		p.Name = "every property"
+	}
+	rh.App.Session.Put(r.Context(), "flash", "The user now works at "+p.Name)
+	http.Redirect(w, r, "/admin/properties", http.StatusSeeOther)
+}
+
+// assignedProperty returns the property posted to assign a room or user to, which has no id for every property,
+// responding with an error if the user may not assign them or there is no such property
+func (rh *RouteHandler) assignedProperty(w http.ResponseWriter, r *http.Request) (models.Property, bool) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return models.Property{}, false
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return models.Property{}, false
+	}
+	id, err := strconv.Atoi(r.PostForm.Get("property_id"))
+	if err != nil || id < 0 {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return models.Property{}, false
+	}
+	p, ok := rh.App.Properties.ByID(id)
+	if id != 0 && !ok {
+		helpers.ClientError(w, http.StatusBadRequest)
+		return models.Property{}, false
+	}
+	return p, true
+}
+
+// parseProperty reads the details of a property from a form, adding an error for each one that is not valid
+func (rh *RouteHandler) parseProperty(form *forms.Form, p *models.Property) {
+	form.Required("slug", "name")
+	form.MaxLength("slug", 50)
+	form.MaxLength("name", 255)
+	form.MaxLength("hostname", 255)
+	form.MaxLength("email_from", 255)
+	form.MaxLength("email_from_name", 255)
+	form.MaxLength("logo_url", 500)
+
+	p.Slug = strings.TrimSpace(form.Get("slug"))
+	p.Name = strings.TrimSpace(form.Get("name"))
+	p.Hostname = strings.ToLower(strings.TrimSpace(form.Get("hostname")))
+	p.EmailFrom = strings.TrimSpace(form.Get("email_from"))
+	p.EmailFromName = strings.TrimSpace(form.Get("email_from_name"))
+	p.LogoURL = strings.TrimSpace(form.Get("logo_url"))
+	p.BrandColor = strings.TrimSpace(form.Get("brand_color"))
+	p.DepositPolicy = strings.TrimSpace(form.Get("deposit_policy"))
+
+	if p.Slug != "" && (!property.ValidSlug(p.Slug) || p.Slug == allPropertiesSlug) {
+		form.Errors.Add("slug", "Use lower case letters, digits and hyphens, such as harbour-view")
+	}
+	for _, other := range rh.App.Properties.All() {
+		if other.ID == p.ID {
+			continue
+		}
+		if other.Slug == p.Slug {
+			form.Errors.Add("slug", "Another property has this slug")
+		}
+		if p.Hostname != "" && strings.EqualFold(other.Hostname, p.Hostname) {
+			form.Errors.Add("hostname", "Another property is served on this host name")
+		}
+	}
+	if p.Hostname != "" && strings.ContainsAny(p.Hostname, "/: ") {
+		form.Errors.Add("hostname", "Enter a host name such as harbourview.com")
+	}
+	if p.EmailFrom != "" {
+		form.IsEmail("email_from")
+	}
+	if p.LogoURL != "" && !strings.HasPrefix(p.LogoURL, "/") && !strings.HasPrefix(p.LogoURL, "https://") &&
+		!strings.HasPrefix(p.LogoURL, "http://") {
+		form.Errors.Add("logo_url", "Enter the address of an image, starting with https:// or /")
+	}
+	if p.BrandColor != "" && !property.ValidColor(p.BrandColor) {
+		form.Errors.Add("brand_color", "Enter a color such as #1a73e8")
+	}
+	if p.DepositPolicy != "" {
+		if _, err := payments.ParseDepositPolicy(p.DepositPolicy); err != nil {
+			form.Errors.Add("deposit_policy", "Enter first-night, percent:N or none")
+		}
+	}
+}
+
+func (rh *RouteHandler) renderProperties(w http.ResponseWriter, r *http.Request, form *forms.Form, p models.Property) {
+	rooms, err := rh.DB.AllRooms(0)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	staff, err := rh.DB.AllStaff(0)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["properties"] = rh.App.Properties.All()
+	data["property"] = p
+	data["rooms"] = rooms
+	data["staff"] = staff
+	render.Template(w, r, "admin-properties.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// unrestricted returns true if the signed-in user works at every property
+func unrestricted(r *http.Request) bool {
+	return property.StaffFromContext(r.Context()) == 0
+}
+
+// manages returns true if the signed-in user works at the property, as users working at every property do
+func manages(r *http.Request, propertyID int) bool {
+	return unrestricted(r) || property.StaffFromContext(r.Context()) == propertyID
+}
+
+// managesRooms returns true if the signed-in user works at the properties of all the rooms
+func (rh *RouteHandler) managesRooms(r *http.Request, roomIDs ...int) (bool, error) {
+	if unrestricted(r) {
+		return true, nil
+	}
+	rooms, err := rh.DB.AllRooms(property.StaffFromContext(r.Context()))
+	if err != nil {
+		return false, err
+	}
+	own := make(map[int]bool)
+	for _, room := range rooms {
+		own[room.ID] = true
+	}
+	for _, id := range roomIDs {
+		if !own[id] {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// managedReservation returns the reservation with the id, or errOtherProperty if the signed-in user does not work at
+// its property
+func (rh *RouteHandler) managedReservation(r *http.Request, id int) (models.Reservation, error) {
+	res, err := rh.DB.GetReservationByID(id)
+	if err == nil && !manages(r, res.Room.PropertyID) {
+		return models.Reservation{}, errOtherProperty
+	}
+	return res, err
+}
+
+// staffError responds with 403 Forbidden to a user reading or changing the data of a property they do not work at, and
+// with a server error to any other error
+func staffError(w http.ResponseWriter, err error) {
+	if errors.Is(err, errOtherProperty) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	helpers.ServerError(w, err)
+}
+
+// propertyByID returns the property with the id, or an empty property if there is none
+func (rh *RouteHandler) propertyByID(id int) models.Property {
+	p, _ := rh.App.Properties.ByID(id)
+	return p
+}
+
+// mailSender returns the from address of the emails sent for a property
+func (rh *RouteHandler) mailSender(propertyID int) string {
+	return property.Sender(rh.propertyByID(propertyID), defaultMailAddress)
+}
+
+// mailInbox returns the address receiving the booking notifications of a property
+func (rh *RouteHandler) mailInbox(propertyID int) string {
+	if p := rh.propertyByID(propertyID); p.EmailFrom != "" {
+		return p.EmailFrom
+	}
+	return defaultMailAddress
+}
+
+// hotelName returns the name of a property printed on its invoices, or the name of the hotel if it has none
+func (rh *RouteHandler) hotelName(propertyID int) string {
+	if p := rh.propertyByID(propertyID); p.Name != "" {
+		return p.Name
+	}
+	return rh.App.HotelName
+}
+
+// depositPolicy returns the deposit taken when booking a room of a property, the default policy unless the property
+// has its own
+func (rh *RouteHandler) depositPolicy(propertyID int) payments.DepositPolicy {
+	p := rh.propertyByID(propertyID)
+	if p.DepositPolicy == "" {
+		return rh.App.Deposit
+	}
+	policy, err := payments.ParseDepositPolicy(p.DepositPolicy)
+	if err != nil {
+		rh.App.ErrorLog.Printf("invalid deposit policy of property %d: %v\n", p.ID, err)
+		return rh.App.Deposit
+	}
+	return policy
+}
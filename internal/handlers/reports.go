@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/reports"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxReportYears is the longest date range the dashboard reports on
+const maxReportYears = 3
+
+// AdminDashBoard shows the occupancy, revenue, booking and cancellation reports for a date range, the last twelve
+// months by default
+func (rh *RouteHandler) AdminDashBoard(w http.ResponseWriter, r *http.Request) {
+	form := forms.New(r.URL.Query())
+	start, end := reportRange(form, time.Now())
+	propertyID := helpers.AdminPropertyID(r)
+
+	occupancy, err := rh.DB.OccupancyByMonth(start, end, propertyID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	leadTimes, err := rh.DB.LeadTimeDistribution(start, end, propertyID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	stayLengths, err := rh.DB.StayLengthDistribution(start, end, propertyID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	cancellations, err := rh.DB.CancellationsByMonth(start, end, propertyID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	base := rh.App.Currency.Base()
+	locale := i18n.FromContext(r.Context())
+	totals := reports.MonthTotals(occupancy)
+	var occupancyBars, revparBars []reports.Bar
+	for _, t := range totals {
+		label := t.Month.Format("Jan 06")
+		occupancyBars = append(occupancyBars, reports.Bar{Label: label, Value: t.Rate, Text: render.Percent(t.Rate)})
+		revparBars = append(revparBars, reports.Bar{Label: label, Value: t.RevPAR,
+			Text: currency.Format(t.RevPAR, base, locale)})
+	}
+	cancelled := reports.ForCancellations(cancellations)
+	var cancelledBars []reports.Bar
+	for _, c := range cancelled {
+		cancelledBars = append(cancelledBars, reports.Bar{Label: c.Month.Format("Jan 06"), Value: c.Cancelled,
+			Text: strconv.Itoa(c.Cancelled)})
+	}
+
+	data := make(map[string]interface{})
+	data["occupancy"] = reports.ForOccupancy(occupancy)
+	data["totals"] = totals
+	data["lead_times"] = leadTimes
+	data["stay_lengths"] = stayLengths
+	data["cancellations"] = cancelled
+	data["occupancy_chart"] = reports.BarChart("Occupancy", occupancyBars)
+	data["revpar_chart"] = reports.BarChart("RevPAR", revparBars)
+	data["lead_time_chart"] = reports.BarChart("Booking lead time", bucketBars(leadTimes))
+	data["stay_length_chart"] = reports.BarChart("Length of stay", bucketBars(stayLengths))
+	data["cancellation_chart"] = reports.BarChart("Cancellations", cancelledBars)
+
+	stringMap := make(map[string]string)
+	stringMap["from"] = start.Format(htmlDateLayout)
+	stringMap["to"] = end.AddDate(0, 0, -1).Format(htmlDateLayout)
+	render.Template(w, r, "admin-dashboard.page.tmpl", &models.TemplateData{
+		StringMap: stringMap,
+		Data:      data,
+		Form:      form,
+	})
+}
+
+// reportRange returns the range of dates chosen for the reports, from the first date up to the day after the last.
+// The last twelve months up to the end of this month are reported on when no dates or invalid dates are chosen.
+func reportRange(form *forms.Form, now time.Time) (time.Time, time.Time) {
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	start := thisMonth.AddDate(0, -11, 0)
+	end := thisMonth.AddDate(0, 1, 0)
+
+	from := parseFormDate(form, "from", htmlDateLayout)
+	to := parseFormDate(form, "to", htmlDateLayout)
+	if from.IsZero() || to.IsZero() {
+		return start, end
+	}
+	if to.Before(from) {
+		form.Errors.Add("to", "End date must not be before the start date")
+		return start, end
+	}
+	if to.After(from.AddDate(maxReportYears, 0, 0)) {
+		form.Errors.Add("to", "Choose a range of at most "+strconv.Itoa(maxReportYears)+" years")
+		return start, end
+	}
+	return from, to.AddDate(0, 0, 1)
+}
+
+// bucketBars returns a bar for each band of a distribution
+func bucketBars(buckets []models.ReportBucket) []reports.Bar {
+	var bars []reports.Bar
+	for _, b := range buckets {
+		bars = append(bars, reports.Bar{Label: b.Label, Value: b.Count, Text: strconv.Itoa(b.Count)})
+	}
+	return bars
+}
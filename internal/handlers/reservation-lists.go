@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+const defaultReservationsPerPage = 25
+
+// reservationsPerPage are the page sizes the reservation lists can be shown in
+var reservationsPerPage = []int{10, 25, 50, 100}
+
+// AdminNewReservations shows a page of the new reservations in the admin tool
+func (rh *RouteHandler) AdminNewReservations(w http.ResponseWriter, r *http.Request) {
+	rh.renderReservationList(w, r, "admin-new-reservations.page.tmpl", models.ReservationStatusNew)
+}
+
+// AdminAllReservations shows a page of all reservations in the admin tool
+func (rh *RouteHandler) AdminAllReservations(w http.ResponseWriter, r *http.Request) {
+	rh.renderReservationList(w, r, "admin-all-reservations.page.tmpl", "")
+}
+
+// renderReservationList shows the page of reservations picked by the query of the request, only showing reservations
+// with the status if it is given
+func (rh *RouteHandler) renderReservationList(w http.ResponseWriter, r *http.Request, tmpl, status string) {
+	form := forms.New(r.URL.Query())
+	list := reservationList{
+		path:  r.URL.Path,
+		query: r.URL.Query(),
+		Search: models.ReservationSearch{
+			ReservationFilter: parseReservationFilter(form),
+			Sort:              form.Get("sort"),
+			Desc:              form.Get("order") == "desc",
+			Page:              1,
+			PerPage:           defaultReservationsPerPage,
+		},
+	}
+	list.Search.PropertyID = helpers.AdminPropertyID(r)
+	if status != "" {
+		list.Search.Status = status
+	}
+	if _, ok := reservationSortNames[list.Search.Sort]; !ok {
+		list.Search.Sort = models.ReservationSortCheckIn
+		list.Search.Desc = form.Get("order") != "asc"
+	}
+	if page, err := strconv.Atoi(form.Get("page")); err == nil && page > 1 {
+		list.Search.Page = page
+	}
+	if perPage, err := strconv.Atoi(form.Get("per_page")); err == nil {
+		for _, n := range reservationsPerPage {
+			if n == perPage {
+				list.Search.PerPage = perPage
+			}
+		}
+	}
+
+	var rs []models.Reservation
+	if form.Valid() {
+		var err error
+		rs, list.Total, err = rh.DB.SearchReservations(list.Search)
+		if err != nil {
+			helpers.ServerError(w, err)
+			return
+		}
+	}
+	rooms, err := rh.DB.AllRooms(helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["reservations"] = rs
+	data["list"] = list
+	data["rooms"] = rooms
+	render.Template(w, r, tmpl, &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// parseReservationFilter reads the reservations filter of the admin reservation lists and exports, adding errors to
+// the form for values which are not valid
+func parseReservationFilter(form *forms.Form) models.ReservationFilter {
+	filter := models.ReservationFilter{
+		Start:  parseFormDate(form, "from", htmlDateLayout),
+		Status: form.Get("status"),
+		Name:   form.Get("name"),
+		Email:  form.Get("email"),
+		Search: form.Get("q"),
+	}
+	if to := parseFormDate(form, "to", htmlDateLayout); !to.IsZero() {
+		filter.End = to.AddDate(0, 0, 1)
+	}
+	if form.Has("room_id") {
+		var err error
+		filter.RoomID, err = strconv.Atoi(form.Get("room_id"))
+		if err != nil {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+	}
+	if filter.Status != "" && filter.Status != models.ReservationStatusNew &&
+		filter.Status != models.ReservationStatusProcessed {
+		form.Errors.Add("status", "Choose new or processed reservations")
+	}
+	return filter
+}
+
+// reservationSortNames are the columns of the reservation lists which can be sorted by
+var reservationSortNames = map[string]bool{
+	models.ReservationSortID:       true,
+	models.ReservationSortName:     true,
+	models.ReservationSortEmail:    true,
+	models.ReservationSortRoom:     true,
+	models.ReservationSortCheckIn:  true,
+	models.ReservationSortCheckOut: true,
+	models.ReservationSortBooked:   true,
+}
+
+// reservationList is a page of a reservation list, making the links to its other pages and orders, keeping the
+// filter of the page
+type reservationList struct {
+	path   string
+	query  url.Values
+	Search models.ReservationSearch
+	Total  int
+}
+
+// Pages returns the number of pages of the list
+func (l reservationList) Pages() int {
+	return (l.Total + l.Search.PerPage - 1) / l.Search.PerPage
+}
+
+// From returns the position in the list of the first reservation of the page
+func (l reservationList) From() int {
+	if l.Total == 0 {
+		return 0
+	}
+	return (l.Search.Page-1)*l.Search.PerPage + 1
+}
+
+// To returns the position in the list of the last reservation of the page
+func (l reservationList) To() int {
+	to := l.Search.Page * l.Search.PerPage
+	if to > l.Total {
+		return l.Total
+	}
+	return to
+}
+
+// PageNumbers returns the numbers of the pages linked from the page, the two either side of it
+func (l reservationList) PageNumbers() []int {
+	var pages []int
+	for p := l.Search.Page - 2; p <= l.Search.Page+2; p++ {
+		if p >= 1 && p <= l.Pages() {
+			pages = append(pages, p)
+		}
+	}
+	return pages
+}
+
+// PerPageOptions returns the page sizes the list can be shown in
+func (l reservationList) PerPageOptions() []int {
+	return reservationsPerPage
+}
+
+// PageURL returns the link to a page of the list
+func (l reservationList) PageURL(page int) string {
+	return l.url(map[string]string{"page": strconv.Itoa(page)})
+}
+
+// PerPageURL returns the link to the first page of the list with a page size
+func (l reservationList) PerPageURL(perPage int) string {
+	return l.url(map[string]string{"per_page": strconv.Itoa(perPage), "page": ""})
+}
+
+// SortURL returns the link to the first page of the list sorted by a column, reversing the order if the list is
+// already sorted by it
+func (l reservationList) SortURL(column string) string {
+	order := "asc"
+	if column == l.Search.Sort && !l.Search.Desc {
+		order = "desc"
+	}
+	return l.url(map[string]string{"sort": column, "order": order, "page": ""})
+}
+
+// SortMark returns an arrow showing the order of the list if it is sorted by the column
+func (l reservationList) SortMark(column string) string {
+	switch {
+	case column != l.Search.Sort:
+		return ""
+	case l.Search.Desc:
+		return "▼"
+	}
+	return "▲"
+}
+
+// ExportURL returns the link exporting every reservation of the list in a format
+func (l reservationList) ExportURL(format string) string {
+	q := url.Values{}
+	for _, key := range []string{"from", "to", "room_id", "name", "email", "q"} {
+		if v := l.query.Get(key); v != "" {
+			q.Set(key, v)
+		}
+	}
+	if l.Search.Status != "" {
+		q.Set("status", l.Search.Status)
+	}
+	q.Set("format", format)
+	return "/admin/reservations-export?" + q.Encode()
+}
+
+// url returns the link to the list with the query changed, removing the keys set to an empty string
+func (l reservationList) url(changes map[string]string) string {
+	q := url.Values{}
+	for key, values := range l.query {
+		q[key] = values
+	}
+	for key, v := range changes {
+		if v == "" {
+			q.Del(key)
+		} else {
+			q.Set(key, v)
+		}
+	}
+	if len(q) == 0 {
+		return l.path
+	}
+	return l.path + "?" + q.Encode()
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"github.com/sunil206b/smart_booking/internal/rules"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// AdminMoveReservation moves a reservation dragged to another room or other dates on the admin calendar, answering
+// with JSON. The price of the reservation is kept, and the room and nights it leaves are offered to the waitlist.
+// The rules of the room and the extras of the reservation are checked for the new stay as they are for a booking.
+func (rh *RouteHandler) AdminMoveReservation(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, jsonResponse{Message: "Reservation not found"})
+		return
+	}
+	err = r.ParseForm()
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, jsonResponse{Message: "Invalid request"})
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_id", "start_date", "end_date")
+	move := models.ReservationMove{
+		ReservationID: id,
+		UserID:        rh.App.Session.GetInt(r.Context(), "user_id"),
+	}
+	if form.Has("room_id") {
+		move.ToRoomID, err = strconv.Atoi(form.Get("room_id"))
+		if err != nil || move.ToRoomID < 1 {
+			form.Errors.Add("room_id", "Choose a room")
+		}
+	}
+	move.ToStart = parseFormDate(form, "start_date", htmlDateLayout)
+	move.ToEnd = parseFormDate(form, "end_date", htmlDateLayout)
+	if !move.ToStart.IsZero() && !move.ToEnd.After(move.ToStart) {
+		form.Errors.Add("end_date", "End date must be after the start date")
+	}
+	if !form.Valid() {
+		resp := jsonResponse{
+			Message: "Invalid move",
+			Errors:  make(map[string]string),
+			RoomID:  form.Get("room_id"),
+		}
+		for _, field := range []string{"end_date", "start_date", "room_id"} {
+			if msg := form.Errors.Get(field); msg != "" {
+				resp.Message = msg
+				resp.Errors[field] = msg
+			}
+		}
+		writeJSON(w, http.StatusBadRequest, resp)
+		return
+	}
+
+	if !unrestricted(r) {
+		_, err = rh.managedReservation(r, id)
+		ok := err == nil
+		if ok {
+			ok, err = rh.managesRooms(r, move.ToRoomID)
+		}
+		if !ok {
+			writeJSON(w, http.StatusForbidden, jsonResponse{Message: "The reservation is at another property"})
+			return
+		}
+	}
+
+	roomRules, err := rh.DB.GetRulesForRoomByDate(move.ToRoomID, move.ToStart, move.ToEnd)
+	if err != nil {
+		rh.App.ErrorLog.Println(err)
+		writeJSON(w, http.StatusInternalServerError, jsonResponse{Message: "The reservation could not be moved"})
+		return
+	}
+	if reason := rules.Check(roomRules, move.ToStart, move.ToEnd, time.Now()); !reason.IsZero() {
+		writeMoveError(w, form, "start_date", i18n.TMessage(i18n.FromContext(r.Context()), reason))
+		return
+	}
+
+	err = rh.DB.MoveReservation(&move)
+	if errors.Is(err, repository.ErrReservationNotFound) {
+		writeJSON(w, http.StatusNotFound, jsonResponse{Message: "Reservation not found"})
+		return
+	}
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		writeJSON(w, http.StatusConflict, jsonResponse{Message: "The room is not available for those dates"})
+		return
+	}
+	if errors.Is(err, repository.ErrExtraSoldOut) {
+		writeMoveError(w, form, "extras", "An extra of the reservation is sold out for those dates")
+		return
+	}
+	if err != nil {
+		rh.App.ErrorLog.Println(err)
+		writeJSON(w, http.StatusInternalServerError, jsonResponse{Message: "The reservation could not be moved"})
+		return
+	}
+
+	err = rh.offerFreedRoom(move.FromRoomID, move.FromStart, move.FromEnd)
+	if err != nil {
+		rh.App.ErrorLog.Println("failed to offer the room a reservation moved from to the waitlist", err)
+	}
+	writeJSON(w, http.StatusOK, jsonResponse{
+		OK:        true,
+		Message:   "Reservation moved",
+		RoomID:    strconv.Itoa(move.ToRoomID),
+		StartDate: move.ToStart.Format(htmlDateLayout),
+		EndDate:   move.ToEnd.Format(htmlDateLayout),
+	})
+}
+
+// writeMoveError answers a move refused for the stay it asks for with the message as the error of the field
+func writeMoveError(w http.ResponseWriter, form *forms.Form, field, msg string) {
+	writeJSON(w, http.StatusBadRequest, jsonResponse{
+		Message: msg,
+		Errors:  map[string]string{field: msg},
+		RoomID:  form.Get("room_id"),
+	})
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/property"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const htmlDateLayout = "2006-01-02"
+
+// AdminRoomRules shows the stay rules of all rooms in the admin tool
+func (rh *RouteHandler) AdminRoomRules(w http.ResponseWriter, r *http.Request) {
+	rh.renderRoomRules(w, r, forms.New(nil))
+}
+
+// AdminPostRoomRule creates a new stay rule for a room
+func (rh *RouteHandler) AdminPostRoomRule(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("room_id", "start_date", "end_date")
+
+	rule := models.RoomRule{
+		ClosedToArrival:   form.Has("closed_to_arrival"),
+		ClosedToDeparture: form.Has("closed_to_departure"),
+	}
+	rule.RoomID, _ = strconv.Atoi(form.Get("room_id"))
+	rule.StartDate = parseFormDate(form, "start_date", htmlDateLayout)
+	rule.EndDate = parseFormDate(form, "end_date", htmlDateLayout)
+	if !rule.StartDate.IsZero() && rule.EndDate.Before(rule.StartDate) {
+		form.Errors.Add("end_date", "End date must not be before the start date")
+	}
+	rule.MinStay = parseFormDays(form, "min_stay")
+	rule.MaxStay = parseFormDays(form, "max_stay")
+	rule.MinAdvanceDays = parseFormDays(form, "min_advance_days")
+	rule.MaxAdvanceDays = parseFormDays(form, "max_advance_days")
+	if rule.MaxStay > 0 && rule.MaxStay < rule.MinStay {
+		form.Errors.Add("max_stay", "Maximum stay must not be less than the minimum stay")
+	}
+
+	if !form.Valid() {
+		rh.renderRoomRules(w, r, form)
+		return
+	}
+	ok, err := rh.managesRooms(r, rule.RoomID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !ok {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+
+	err = rh.DB.CreateRoomRule(&rule)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Rule saved")
+	http.Redirect(w, r, "/admin/room-rules", http.StatusSeeOther)
+}
+
+// AdminDeleteRoomRule deletes a stay rule
+func (rh *RouteHandler) AdminDeleteRoomRule(w http.ResponseWriter, r *http.Request) {
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	err := rh.DB.DeleteRoomRuleByID(id, property.StaffFromContext(r.Context()))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Rule deleted")
+	http.Redirect(w, r, "/admin/room-rules", http.StatusSeeOther)
+}
+
+func (rh *RouteHandler) renderRoomRules(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	roomRules, err := rh.DB.AllRoomRules()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rooms, err := rh.DB.AllRooms(helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["rules"] = roomRules
+	data["rooms"] = rooms
+	render.Template(w, r, "admin-room-rules.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// parseFormDate parses a date field of the form, adding an error to the form if it is not a valid date
+func parseFormDate(form *forms.Form, field, layout string) time.Time {
+	if !form.Has(field) {
+		return time.Time{}
+	}
+	t, err := time.Parse(layout, form.Get(field))
+	if err != nil {
+		form.Errors.Add(field, "Invalid date")
+		return time.Time{}
+	}
+	return t
+}
+
+// parseFormDays parses an optional number of days or nights, adding an error to the form if it is not a positive number
+func parseFormDays(form *forms.Form, field string) int {
+	return parseFormCount(form, field, "This field must be a number of days")
+}
+
+// parseFormCount parses an optional whole number that is 0 or more, adding the message as an error to the form if it is
+// not one
+func parseFormCount(form *forms.Form, field, message string) int {
+	if !form.Has(field) {
+		return 0
+	}
+	n, err := strconv.Atoi(form.Get(field))
+	if err != nil || n < 0 {
+		form.Errors.Add(field, message)
+		return 0
+	}
+	return n
+}
@@ -9,8 +9,12 @@ import (
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/justinas/nosurf"
 	"github.com/sunil206b/smart_booking/internal/config"
+	"github.com/sunil206b/smart_booking/internal/currency"
 	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/payments"
+	"github.com/sunil206b/smart_booking/internal/property"
 	"github.com/sunil206b/smart_booking/internal/render"
 	"html/template"
 	"log"
@@ -23,13 +27,27 @@ import (
 var appConfig config.AppConfig
 var session *scs.SessionManager
 var templatesPath = "../../templates"
-var functions = template.FuncMap{}
+var webhookSecret = "test-secret"
+var functions = template.FuncMap{
+	"humanDate":        render.HumanDate,
+	"formatDate":       render.FormatDate,
+	"iterate":          render.Iterate,
+	"add":              render.Add,
+	"t":                i18n.T,
+	"tm":               i18n.TMessage,
+	"localDate":        i18n.FormatDate,
+	"datePickerFormat": i18n.DatePickerFormat,
+	"money":            currency.Format,
+	"percent":          render.Percent,
+}
 var infoLog *log.Logger
 var errorLog *log.Logger
 
 func getRoutes() http.Handler {
 	//what am I going to put in the session
 	gob.Register(models.Reservation{})
+	gob.Register(models.WaitlistEntry{})
+	gob.Register(models.RoomRestriction{})
 
 	//Change this to true when in the production
 	appConfig.InProduction = false
@@ -47,6 +65,11 @@ func getRoutes() http.Handler {
 
 	appConfig.Session = session
 
+	err := i18n.LoadCatalogs("../../translations")
+	if err != nil {
+		log.Fatalf("Error while loading translations: %v\n", err)
+	}
+
 	tc, err := CreateTestTemplateCache()
 	if err != nil {
 		log.Fatalf("Error while creating template cache: %v\n", err)
@@ -55,8 +78,25 @@ func getRoutes() http.Handler {
 	appConfig.UseCache = true
 	render.NewRenderer(&appConfig)
 
-	rhHandler := NewRouteHandler(&appConfig)
+	appConfig.Currency, err = currency.NewConverter(currency.DefaultBase)
+	if err != nil {
+		log.Fatalf("Error while creating currency converter: %v\n", err)
+	}
+
+	appConfig.Payments = payments.NewFakeProvider(webhookSecret)
+	appConfig.Deposit = payments.DepositPolicy{Percent: 30}
+	appConfig.HotelName = "Fort Smythe"
+	appConfig.SiteURL = "http://localhost"
+	appConfig.Properties = property.NewDirectory()
+
+	rhHandler := NewTestRouteHandler(&appConfig)
 	NewHandler(rhHandler)
+	if err = rhHandler.LoadExchangeRates(); err != nil {
+		log.Fatalf("Error while loading exchange rates: %v\n", err)
+	}
+	if err = rhHandler.LoadProperties(); err != nil {
+		log.Fatalf("Error while loading properties: %v\n", err)
+	}
 	helpers.NewHelpers(&appConfig)
 
 	router := chi.NewRouter()
@@ -67,6 +107,9 @@ func getRoutes() http.Handler {
 	router.Get("/", Handler.Home)
 	router.Get("/about", Handler.About)
 	router.Get("/contact", Handler.Contact)
+	router.Get("/set-language/{locale}", Handler.SetLanguage)
+	router.Get("/set-currency/{code}", Handler.SetCurrency)
+	router.Get("/set-property/{slug}", Handler.SetProperty)
 
 	router.Get("/generals-quarters", Handler.Generals)
 	router.Get("/majors-suite", Handler.Majors)
@@ -74,19 +117,32 @@ func getRoutes() http.Handler {
 	router.Get("/search-availability", Handler.Availability)
 	router.Post("/search-availability", Handler.PostAvailability)
 	router.Post("/search-availability-json", Handler.AvailabilityJSON)
+	router.Get("/availability-calendar-json", Handler.AvailabilityCalendarJSON)
+	router.Get("/choose-room/{id}", Handler.ChooseRoom)
+	router.Get("/book-room", Handler.BookRoom)
+	router.Get("/waitlist", Handler.Waitlist)
+	router.Post("/waitlist", Handler.PostWaitlist)
+	router.Get("/waitlist/book/{token}", Handler.WaitlistBook)
 
 	router.Get("/make-reservations", Handler.Reservation)
 	router.Post("/make-reservations", Handler.PostReservation)
+	router.Get("/make-reservations/cancel", Handler.ReleaseHold)
 	router.Get("/reservation-summary", Handler.ReservationSummary)
+	router.Get("/reservation-payment", Handler.ReservationPayment)
+	router.Get("/reservation-payment/return", Handler.ReservationPaymentReturn)
+	router.Post("/payments/webhook", Handler.PaymentWebhook)
+	router.Get("/payments/fake/checkout/{reference}", Handler.FakeCheckout)
+	router.Post("/payments/fake/checkout/{reference}", Handler.PostFakeCheckout)
 
 	fileServer := http.FileServer(http.Dir("./static/"))
 	router.Handle("/static/*", http.StripPrefix("/static", fileServer))
 	return router
 }
 
-// NoSurf adds CSRF protection to all POST requests
+// NoSurf adds CSRF protection to all POST requests except the payment gateway webhooks, which are signed instead
 func NoSurf(next http.Handler) http.Handler {
 	csrfHandler := nosurf.New(next)
+	csrfHandler.ExemptPath("/payments/webhook")
 
 	csrfHandler.SetBaseCookie(http.Cookie{
 		HttpOnly: true,
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/pricing"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"html"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AdminTaxRules shows the tax and fee rules in the admin tool
+func (rh *RouteHandler) AdminTaxRules(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	rh.renderTaxRules(w, r, forms.New(nil))
+}
+
+// AdminPostTaxRule creates a new tax or fee rule. Percentages are entered as such, and stored in basis points.
+func (rh *RouteHandler) AdminPostTaxRule(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := forms.New(r.PostForm)
+	form.Required("name", "kind", "amount", "start_date", "end_date")
+	form.MaxLength("name", 255)
+	if form.Has("kind") && !pricing.ValidKind(form.Get("kind")) {
+		form.Errors.Add("kind", "Choose how the rule is charged")
+	}
+
+	rule := models.TaxRule{
+		Name: form.Get("name"),
+		Kind: form.Get("kind"),
+	}
+	rule.RoomID, _ = strconv.Atoi(form.Get("room_id"))
+	rule.StartDate = parseFormDate(form, "start_date", htmlDateLayout)
+	rule.EndDate = parseFormDate(form, "end_date", htmlDateLayout)
+	if !rule.StartDate.IsZero() && rule.EndDate.Before(rule.StartDate) {
+		form.Errors.Add("end_date", "End date must not be before the start date")
+	}
+	if form.Has("amount") {
+		if pricing.Kind(rule.Kind) == pricing.KindPercent {
+			rule.Amount, err = parseBasisPoints(form.Get("amount"))
+			if err != nil {
+				form.Errors.Add("amount", "Enter a percentage such as 7.5")
+			}
+		} else {
+			rule.Amount, err = currency.ParseAmount(form.Get("amount"), rh.App.Currency.Base())
+			if err != nil || rule.Amount < 0 {
+				form.Errors.Add("amount", "Enter an amount such as 2.50")
+			}
+		}
+	}
+
+	if !form.Valid() {
+		rh.renderTaxRules(w, r, form)
+		return
+	}
+
+	err = rh.DB.CreateTaxRule(&rule)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Tax rule saved")
+	http.Redirect(w, r, "/admin/tax-rules", http.StatusSeeOther)
+}
+
+// AdminDeleteTaxRule deletes a tax or fee rule
+func (rh *RouteHandler) AdminDeleteTaxRule(w http.ResponseWriter, r *http.Request) {
+	if !unrestricted(r) {
+		helpers.ClientError(w, http.StatusForbidden)
+		return
+	}
+	id, _ := strconv.Atoi(chi.URLParam(r, "id"))
+	err := rh.DB.DeleteTaxRuleByID(id)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rh.App.Session.Put(r.Context(), "flash", "Tax rule deleted")
+	http.Redirect(w, r, "/admin/tax-rules", http.StatusSeeOther)
+}
+
+func (rh *RouteHandler) renderTaxRules(w http.ResponseWriter, r *http.Request, form *forms.Form) {
+	taxRules, err := rh.DB.AllTaxRules()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	rooms, err := rh.DB.AllRooms(helpers.AdminPropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	data := make(map[string]interface{})
+	data["rules"] = taxRules
+	data["rooms"] = rooms
+	data["kinds"] = pricing.Kinds
+	render.Template(w, r, "admin-tax-rules.page.tmpl", &models.TemplateData{
+		Data: data,
+		Form: form,
+	})
+}
+
+// parseBasisPoints parses a percentage with up to two decimals, such as 7.5, into basis points
+func parseBasisPoints(s string) (int, error) {
+	f, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	if err != nil {
+		return 0, err
+	}
+	if f < 0 || f > 100 {
+		return 0, strconv.ErrRange
+	}
+	return int(math.Round(f * 100)), nil
+}
+
+// quoteStay prices a reservation with its discount and the tax and fee rules of its room
+func (rh *RouteHandler) quoteStay(res models.Reservation) (pricing.Quote, error) {
+	taxRules, err := rh.DB.GetTaxRulesForRoomByDate(res.RoomID, res.CheckInDate, res.CheckOutDate)
+	if err != nil {
+		return pricing.Quote{}, err
+	}
+	return pricing.Calculate(res.Room.Price, res.CheckInDate, res.CheckOutDate, res.Guests, res.Discount, taxRules), nil
+}
+
+// priceBreakdown lists the room charge, the taxes and fees, the extras and the total of a reservation for an email,
+// or returns an empty string for reservations without a price
+func (rh *RouteHandler) priceBreakdown(locale string, res models.Reservation) string {
+	if res.TotalAmount == 0 {
+		return ""
+	}
+	base := rh.App.Currency.Base()
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: %s<br>\n", i18n.T(locale, "summary.room_charge"), currency.Format(res.RoomAmount, base, locale))
+	if res.Discount > 0 {
+		fmt.Fprintf(&b, "%s: -%s<br>\n", i18n.T(locale, "promo.discount", html.EscapeString(res.PromoCode)),
+			currency.Format(res.Discount, base, locale))
+	}
+	for _, tax := range res.Taxes {
+		fmt.Fprintf(&b, "%s: %s<br>\n", html.EscapeString(tax.Name), currency.Format(tax.Amount, base, locale))
+	}
+	for _, extra := range res.Extras {
+		fmt.Fprintf(&b, "%s &times; %d: %s<br>\n", html.EscapeString(extra.Name), extra.Quantity,
+			currency.Format(extra.Amount, base, locale))
+	}
+	fmt.Fprintf(&b, "<strong>%s: %s</strong>", i18n.T(locale, "summary.total"), currency.Format(res.TotalAmount, base, locale))
+	return b.String()
+}
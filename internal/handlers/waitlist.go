@@ -0,0 +1,272 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/go-chi/chi/v5"
+	"github.com/sunil206b/smart_booking/internal/forms"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/render"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"github.com/sunil206b/smart_booking/internal/waitlist"
+	"html"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Waitlist shows the form to join the waitlist for the dates searched without finding a room
+func (rh *RouteHandler) Waitlist(w http.ResponseWriter, r *http.Request) {
+	entry, ok := rh.App.Session.Get(r.Context(), "waitlist").(models.WaitlistEntry)
+	if !ok {
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	rh.renderWaitlist(w, r, entry, newForm(r, nil))
+}
+
+// PostWaitlist puts the guest on the waitlist for the dates in the session
+func (rh *RouteHandler) PostWaitlist(w http.ResponseWriter, r *http.Request) {
+	entry, ok := rh.App.Session.Get(r.Context(), "waitlist").(models.WaitlistEntry)
+	if !ok {
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	err := r.ParseForm()
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	form := newForm(r, r.PostForm)
+	validateGuestDetails(form)
+	entry.RoomID = 0
+	if form.Has("room_id") {
+		entry.RoomID, err = strconv.Atoi(form.Get("room_id"))
+		if err != nil {
+			helpers.ClientError(w, http.StatusBadRequest)
+			return
+		}
+	}
+	if !form.Valid() {
+		rh.renderWaitlist(w, r, entry, form)
+		return
+	}
+
+	entry.FirstName = form.Get("first_name")
+	entry.LastName = form.Get("last_name")
+	entry.Email = form.Get("email")
+	entry.Phone = form.Get("phone")
+	entry.Locale = form.Locale
+	entry.PropertyID = helpers.PropertyID(r)
+	entry.Status = string(waitlist.StatusWaiting)
+	err = rh.DB.CreateWaitlistEntry(&entry)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	rh.App.Session.Remove(r.Context(), "waitlist")
+	rh.App.Session.Put(r.Context(), "flash", i18n.T(form.Locale, "waitlist.joined"))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+func (rh *RouteHandler) renderWaitlist(w http.ResponseWriter, r *http.Request, entry models.WaitlistEntry,
+	form *forms.Form) {
+	rooms, err := rh.DB.AllRooms(helpers.PropertyID(r))
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	locale := i18n.FromContext(r.Context())
+	stringMap := make(map[string]string)
+	stringMap["start_date"] = i18n.FormatDate(locale, entry.StartDate)
+	stringMap["end_date"] = i18n.FormatDate(locale, entry.EndDate)
+	data := make(map[string]interface{})
+	data["entry"] = entry
+	data["rooms"] = rooms
+	render.Template(w, r, "waitlist.page.tmpl", &models.TemplateData{
+		Form:      form,
+		StringMap: stringMap,
+		Data:      data,
+	})
+}
+
+// WaitlistBook follows the booking link sent to a waitlisted guest, taking them to the reservation form for the room
+// offered to them as long as the link works and the room is still free
+func (rh *RouteHandler) WaitlistBook(w http.ResponseWriter, r *http.Request) {
+	locale := i18n.FromContext(r.Context())
+	entry, err := rh.DB.GetWaitlistEntryByToken(chi.URLParam(r, "token"))
+	if errors.Is(err, repository.ErrWaitlistEntryNotFound) {
+		rh.App.Session.Put(r.Context(), "error", i18n.T(locale, "waitlist.link_invalid"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if waitlist.Status(entry.Status) != waitlist.StatusOffered || waitlist.Expired(entry, time.Now()) {
+		rh.App.Session.Put(r.Context(), "error", i18n.T(locale, "waitlist.link_expired"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+
+	available, _, err := rh.DB.SearchAvailabilityByDatesByRoom(entry.StartDate, entry.EndDate, entry.OfferedRoomID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	if !available {
+		rh.App.Session.Put(r.Context(), "error", i18n.T(locale, "waitlist.taken"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	room, err := rh.DB.GetRoomByID(entry.OfferedRoomID)
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+
+	res := models.Reservation{
+		FirstName:    entry.FirstName,
+		LastName:     entry.LastName,
+		Email:        entry.Email,
+		Phone:        entry.Phone,
+		RoomID:       entry.OfferedRoomID,
+		CheckInDate:  entry.StartDate,
+		CheckOutDate: entry.EndDate,
+	}
+	res.Room.RoomName = room.RoomName
+	res.Room.PropertyID = room.PropertyID
+	res.Room.PropertyName = room.PropertyName
+	err = rh.holdRoom(r, res)
+	if errors.Is(err, repository.ErrRoomUnavailable) {
+		rh.App.Session.Put(r.Context(), "error", i18n.T(locale, "waitlist.taken"))
+		http.Redirect(w, r, "/search-availability", http.StatusSeeOther)
+		return
+	}
+	if err != nil {
+		helpers.ServerError(w, err)
+		return
+	}
+	// the offer is accepted once the guest books, so if they let the hold go the offer expires and the room is offered
+	// to the next guests waiting
+	rh.App.Session.Put(r.Context(), "waitlist_token", entry.Token)
+	rh.App.Session.Put(r.Context(), "reservation", res)
+	http.Redirect(w, r, "/make-reservations", http.StatusSeeOther)
+}
+
+// acceptWaitlistOffer marks the waitlist offer the guest followed accepted, as long as they booked the room and dates
+// offered to them
+func (rh *RouteHandler) acceptWaitlistOffer(r *http.Request, res models.Reservation) error {
+	token := rh.App.Session.PopString(r.Context(), "waitlist_token")
+	if token == "" {
+		return nil
+	}
+	entry, err := rh.DB.GetWaitlistEntryByToken(token)
+	if errors.Is(err, repository.ErrWaitlistEntryNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if waitlist.Status(entry.Status) != waitlist.StatusOffered || entry.OfferedRoomID != res.RoomID ||
+		!entry.StartDate.Equal(res.CheckInDate) || !entry.EndDate.Equal(res.CheckOutDate) {
+		return nil
+	}
+	return rh.DB.UpdateWaitlistEntryStatus(entry.ID, string(waitlist.StatusAccepted))
+}
+
+// ExpireWaitlistOffers expires the booking links which ran out by now, offering their rooms to the next guests waiting
+func (rh *RouteHandler) ExpireWaitlistOffers(now time.Time) error {
+	expired, err := rh.DB.ExpiredWaitlistOffers(now)
+	if err != nil {
+		return err
+	}
+	for _, e := range expired {
+		err = rh.DB.UpdateWaitlistEntryStatus(e.ID, string(waitlist.StatusExpired))
+		if err != nil {
+			return err
+		}
+		if e.OfferedRoomID == 0 {
+			continue
+		}
+		err = rh.offerFreedRoom(e.OfferedRoomID, e.StartDate, e.EndDate)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// offerFreedRoom emails a booking link to the waitlisted guests a room freed from start to end can be offered to,
+// in the order they joined the waitlist
+func (rh *RouteHandler) offerFreedRoom(roomID int, start, end time.Time) error {
+	entries, err := rh.DB.WaitingEntriesForRoom(roomID, start, end)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	offers, err := waitlist.Next(entries, roomID, start, end, func(e models.WaitlistEntry) (bool, error) {
+		available, _, err := rh.DB.SearchAvailabilityByDatesByRoom(e.StartDate, e.EndDate, roomID)
+		return available, err
+	})
+	if err != nil {
+		return err
+	}
+	if len(offers) == 0 {
+		return nil
+	}
+	room, err := rh.DB.GetRoomByID(roomID)
+	if err != nil {
+		return err
+	}
+
+	ttl := rh.App.WaitlistOfferTTL
+	if ttl <= 0 {
+		ttl = waitlist.DefaultOfferTTL
+	}
+	for _, e := range offers {
+		e.Token, err = waitlist.NewToken()
+		if err != nil {
+			return err
+		}
+		e.OfferedRoomID = roomID
+		e.OfferedAt = time.Now()
+		e.ExpiresAt = e.OfferedAt.Add(ttl)
+		err = rh.DB.OfferWaitlistEntry(&e)
+		if errors.Is(err, repository.ErrWaitlistEntryNotFound) {
+			// the guest was offered another room in the meantime
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		rh.sendWaitlistOffer(e, room)
+	}
+	return nil
+}
+
+// sendWaitlistOffer emails the booking link of an offer to the waitlisted guest
+func (rh *RouteHandler) sendWaitlistOffer(e models.WaitlistEntry, room models.Room) {
+	link := fmt.Sprintf("%s/waitlist/book/%s", rh.App.SiteURL, e.Token)
+	expires := fmt.Sprintf("%s %s", i18n.FormatDate(e.Locale, e.ExpiresAt), e.ExpiresAt.Format("15:04"))
+	htmlMsg := fmt.Sprintf(`
+		<strong>%s</strong><br>
+		%s<br>
+		<a href="%s">%s</a>
+`, i18n.T(e.Locale, "waitlist.offer.subject"),
+		i18n.T(e.Locale, "waitlist.offer.body", html.EscapeString(e.FirstName), html.EscapeString(room.RoomName),
+			e.StartDate, e.EndDate, expires),
+		html.EscapeString(link), i18n.T(e.Locale, "waitlist.offer.link"))
+	rh.App.MailChan <- &models.MailData{
+		To:       e.Email,
+		From:     rh.mailSender(room.PropertyID),
+		Subject:  i18n.T(e.Locale, "waitlist.offer.subject"),
+		Content:  htmlMsg,
+		Template: "basic.html",
+		Locale:   e.Locale,
+	}
+}
@@ -3,6 +3,7 @@ package helpers
 import (
 	"fmt"
 	"github.com/sunil206b/smart_booking/internal/config"
+	"github.com/sunil206b/smart_booking/internal/property"
 	"net/http"
 	"runtime/debug"
 )
@@ -31,3 +32,30 @@ func IsAuthenticated(r *http.Request) bool {
 	exists := appConfig.Session.Exists(r.Context(), "user_id")
 	return exists
 }
+
+// Currency returns the currency chosen by the visitor, or the base currency if they have not chosen one
+// or there is no longer an exchange rate for it
+func Currency(r *http.Request) string {
+	code := appConfig.Session.GetString(r.Context(), "currency")
+	if code != "" && appConfig.Currency.Supported(code) {
+		return code
+	}
+	return appConfig.Currency.Base()
+}
+
+// PropertyID returns the id of the property the public site is showing, or 0 if it is showing every property
+func PropertyID(r *http.Request) int {
+	if p, ok := property.FromContext(r.Context()); ok {
+		return p.ID
+	}
+	return 0
+}
+
+// AdminPropertyID returns the id of the property chosen in the admin tool, or 0 if every property is shown. Users
+// working at one property always see that property.
+func AdminPropertyID(r *http.Request) int {
+	if id := property.StaffFromContext(r.Context()); id != 0 {
+		return id
+	}
+	return appConfig.Session.GetInt(r.Context(), "property_id")
+}
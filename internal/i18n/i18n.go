@@ -0,0 +1,239 @@
+package i18n
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultLocale is used when the request does not ask for a supported locale, and for keys missing from a catalog
+const DefaultLocale = "en"
+
+const (
+	// CookieName is the name of the cookie holding the locale chosen by the visitor
+	CookieName = "lang"
+
+	dateLayoutKey       = "date.layout"
+	datePickerFormatKey = "date.picker_format"
+	defaultDateLayout   = "01/02/2006"
+	defaultPickerFormat = "mm/dd/yyyy"
+	cookieMaxAge        = 365 * 24 * 60 * 60
+)
+
+type contextKey string
+
+const localeKey contextKey = "locale"
+
+// Message is a translation key and the arguments it is formatted with
+type Message struct {
+	Key  string
+	Args []interface{}
+}
+
+// NewMessage creates a message for the key with the given arguments
+func NewMessage(key string, args ...interface{}) Message {
+	return Message{Key: key, Args: args}
+}
+
+// IsZero returns true if the message has no key
+func (m Message) IsZero() bool {
+	return m.Key == ""
+}
+
+var (
+	mu       sync.RWMutex
+	catalogs = map[string]map[string]string{}
+)
+
+// LoadCatalogs loads every <locale>.json file in the directory into the translation catalogs.
+// Each file holds a flat object of message keys to fmt formats.
+func LoadCatalogs(path string) error {
+	files, err := filepath.Glob(filepath.Join(path, "*.json"))
+	if err != nil {
+		return errors.New("Error while looking for translation catalogs " + err.Error())
+	}
+	loaded := make(map[string]map[string]string)
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return errors.New("Error while reading translation catalog " + err.Error())
+		}
+		catalog := make(map[string]string)
+		if err = json.Unmarshal(data, &catalog); err != nil {
+			return fmt.Errorf("Error while parsing translation catalog %s: %v", file, err)
+		}
+		loaded[strings.TrimSuffix(filepath.Base(file), ".json")] = catalog
+	}
+	if _, ok := loaded[DefaultLocale]; !ok {
+		return fmt.Errorf("missing %s.json translation catalog in %s", DefaultLocale, path)
+	}
+
+	mu.Lock()
+	catalogs = loaded
+	mu.Unlock()
+	return nil
+}
+
+// Locales returns the supported locales in alphabetical order
+func Locales() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	var locales []string
+	for locale := range catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Supported returns true if there is a catalog for the locale
+func Supported(locale string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	_, ok := catalogs[locale]
+	return ok
+}
+
+// T translates the key into the locale, falling back to the default locale and then to the key itself.
+// Arguments are applied to the message with fmt; time.Time arguments are formatted as dates of the locale.
+func T(locale, key string, args ...interface{}) string {
+	mu.RLock()
+	format, ok := catalogs[locale][key]
+	if !ok {
+		format, ok = catalogs[DefaultLocale][key]
+	}
+	mu.RUnlock()
+	if !ok {
+		format = key
+	}
+	if len(args) == 0 {
+		return format
+	}
+
+	localized := make([]interface{}, len(args))
+	for i, arg := range args {
+		if t, isTime := arg.(time.Time); isTime {
+			localized[i] = FormatDate(locale, t)
+		} else {
+			localized[i] = arg
+		}
+	}
+	return fmt.Sprintf(format, localized...)
+}
+
+// TMessage translates the message into the locale
+func TMessage(locale string, m Message) string {
+	return T(locale, m.Key, m.Args...)
+}
+
+// DateLayout returns the time layout dates are written in for the locale
+func DateLayout(locale string) string {
+	if layout := T(locale, dateLayoutKey); layout != dateLayoutKey {
+		return layout
+	}
+	return defaultDateLayout
+}
+
+// DatePickerFormat returns the format used by the date picker for the locale, matching DateLayout
+func DatePickerFormat(locale string) string {
+	if format := T(locale, datePickerFormatKey); format != datePickerFormatKey {
+		return format
+	}
+	return defaultPickerFormat
+}
+
+// FormatDate formats the date with the layout of the locale
+func FormatDate(locale string, t time.Time) string {
+	return t.Format(DateLayout(locale))
+}
+
+// Negotiate returns the supported locale that best matches an Accept-Language header,
+// or the default locale if none match
+func Negotiate(acceptLanguage string) string {
+	type candidate struct {
+		locale  string
+		quality float64
+	}
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		candidates = append(candidates, candidate{tag, quality})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].quality > candidates[j].quality
+	})
+
+	for _, c := range candidates {
+		if c.quality <= 0 {
+			continue
+		}
+		if Supported(c.locale) {
+			return c.locale
+		}
+		if base := strings.SplitN(c.locale, "-", 2)[0]; Supported(base) {
+			return base
+		}
+	}
+	return DefaultLocale
+}
+
+// SplitPath splits a supported locale prefix such as /es off the URL path, returning the locale and the rest of
+// the path. The locale is empty if the path has no locale prefix.
+func SplitPath(path string) (string, string) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)
+	if !Supported(parts[0]) {
+		return "", path
+	}
+	if len(parts) == 1 {
+		return parts[0], "/"
+	}
+	return parts[0], "/" + parts[1]
+}
+
+// Cookie returns the cookie remembering the locale chosen by the visitor
+func Cookie(locale string, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     CookieName,
+		Value:    locale,
+		Path:     "/",
+		MaxAge:   cookieMaxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// WithLocale returns a copy of the context holding the locale
+func WithLocale(ctx context.Context, locale string) context.Context {
+	return context.WithValue(ctx, localeKey, locale)
+}
+
+// FromContext returns the locale held by the context, or the default locale if there is none
+func FromContext(ctx context.Context) string {
+	if locale, ok := ctx.Value(localeKey).(string); ok && locale != "" {
+		return locale
+	}
+	return DefaultLocale
+}
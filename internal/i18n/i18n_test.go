@@ -0,0 +1,135 @@
+package i18n
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testCatalogs = map[string]string{
+	"en.json": `{"greeting": "Hello %s", "only_en": "English only", "date.layout": "01/02/2006"}`,
+	"es.json": `{"greeting": "Hola %s", "date.layout": "02/01/2006", "date.picker_format": "dd/mm/yyyy"}`,
+}
+
+func TestMain(m *testing.M) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		panic(err)
+	}
+	for name, content := range testCatalogs {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			panic(err)
+		}
+	}
+	if err = LoadCatalogs(dir); err != nil {
+		panic(err)
+	}
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestLoadCatalogs_MissingDefault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ioutil.WriteFile(filepath.Join(dir, "es.json"), []byte(`{}`), 0644)
+
+	if err = LoadCatalogs(dir); err == nil {
+		t.Error("expected an error when the default catalog is missing")
+	}
+	if !Supported("es") || !Supported(DefaultLocale) {
+		t.Error("a failed load should keep the catalogs already loaded")
+	}
+}
+
+func TestT(t *testing.T) {
+	var tests = []struct {
+		locale string
+		key    string
+		args   []interface{}
+		exp    string
+	}{
+		{"es", "greeting", []interface{}{"Ana"}, "Hola Ana"},
+		{"en", "greeting", []interface{}{"Ana"}, "Hello Ana"},
+		{"es", "only_en", nil, "English only"},
+		{"de", "greeting", []interface{}{"Ana"}, "Hello Ana"},
+		{"es", "missing.key", nil, "missing.key"},
+	}
+	for _, e := range tests {
+		if got := T(e.locale, e.key, e.args...); got != e.exp {
+			t.Errorf("T(%s, %s): expected %q but got %q", e.locale, e.key, e.exp, got)
+		}
+	}
+}
+
+func TestT_FormatsDates(t *testing.T) {
+	d := time.Date(2021, time.July, 13, 0, 0, 0, 0, time.UTC)
+	if got := TMessage("es", NewMessage("greeting", d)); got != "Hola 13/07/2021" {
+		t.Errorf("expected the date in the Spanish layout, but got %q", got)
+	}
+	if got := FormatDate("en", d); got != "07/13/2021" {
+		t.Errorf("expected the date in the English layout, but got %q", got)
+	}
+}
+
+func TestDatePickerFormat(t *testing.T) {
+	if got := DatePickerFormat("es"); got != "dd/mm/yyyy" {
+		t.Errorf("expected dd/mm/yyyy but got %q", got)
+	}
+	if got := DatePickerFormat("en"); got != defaultPickerFormat {
+		t.Errorf("expected the default picker format but got %q", got)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	var tests = []struct {
+		header string
+		exp    string
+	}{
+		{"", DefaultLocale},
+		{"es", "es"},
+		{"es-MX,es;q=0.9,en;q=0.8", "es"},
+		{"de-DE,de;q=0.9", DefaultLocale},
+		{"en;q=0.5, es;q=0.8", "es"},
+		{"es;q=0, en", "en"},
+	}
+	for _, e := range tests {
+		if got := Negotiate(e.header); got != e.exp {
+			t.Errorf("Negotiate(%q): expected %s but got %s", e.header, e.exp, got)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	var tests = []struct {
+		path      string
+		expLocale string
+		expPath   string
+	}{
+		{"/es/search-availability", "es", "/search-availability"},
+		{"/es", "es", "/"},
+		{"/search-availability", "", "/search-availability"},
+		{"/de/about", "", "/de/about"},
+	}
+	for _, e := range tests {
+		locale, path := SplitPath(e.path)
+		if locale != e.expLocale || path != e.expPath {
+			t.Errorf("SplitPath(%s): expected %q %q but got %q %q", e.path, e.expLocale, e.expPath, locale, path)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != DefaultLocale {
+		t.Errorf("expected the default locale but got %s", got)
+	}
+	if got := FromContext(WithLocale(context.Background(), "es")); got != "es" {
+		t.Errorf("expected es but got %s", got)
+	}
+}
@@ -0,0 +1,362 @@
+// Package importer imports reservations from CSV files, such as those exported from the admin tool or from an older
+// booking system, checking each row and the rooms it books before importing it
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/asaskevich/govalidator"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/export"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatchSize is the number of reservations imported in each transaction
+const BatchSize = 500
+
+// the columns read from a file, named like the columns of a reservations export so exports can be imported. Other
+// columns are ignored.
+const (
+	colFirstName  = "First Name"
+	colLastName   = "Last Name"
+	colEmail      = "Email"
+	colPhone      = "Phone"
+	colRoom       = "Room"
+	colRoomID     = "Room ID"
+	colCheckIn    = "Check-in"
+	colCheckOut   = "Check-out"
+	colGuests     = "Guests"
+	colRoomAmount = "Room Amount"
+	colDiscount   = "Discount"
+	colTotal      = "Total"
+	colCurrency   = "Currency"
+	colStatus     = "Status"
+	colBooked     = "Booked"
+)
+
+// conflictError is given for rows whose room is already taken for their dates
+const conflictError = "The room is not available for those dates"
+
+// Result counts the rows of a file, and how many were imported and failed
+type Result struct {
+	Rows     int
+	Imported int
+	Failed   int
+}
+
+// row is a reservation read from a line of a file
+type row struct {
+	line   int
+	record []string
+	res    models.Reservation
+}
+
+// stay is the nights a reservation in the file books
+type stay struct {
+	line       int
+	start, end time.Time
+}
+
+// importer imports the rows of a file in batches, keeping the stays booked so far to catch rows booking the same room
+// twice
+type importer struct {
+	db      repository.DatabaseRepo
+	base    string
+	dryRun  bool
+	columns map[string]int
+	rooms   map[string]int
+	shared  map[string]bool
+	roomIDs map[int]bool
+	stays   map[int][]stay
+	errs    *csv.Writer
+	batch   []row
+	result  Result
+}
+
+// Import imports the reservations in a CSV file with a header row, in the base currency, in batches of BatchSize.
+// Rows can only book the rooms of the property, or of every property for propertyID 0, in which case a room whose
+// name is used at several properties must be given by its id. The rows which fail are written to errs as CSV with
+// the line and reason added, so they can be fixed and imported again. Nothing is imported in a dry run, which reports
+// the rows which would fail.
+func Import(r io.Reader, db repository.DatabaseRepo, base string, propertyID int, dryRun bool, errs io.Writer) (Result,
+	error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err == io.EOF {
+		return Result{}, errors.New("the file is empty")
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	im := &importer{
+		db:      db,
+		base:    base,
+		dryRun:  dryRun,
+		columns: make(map[string]int),
+		rooms:   make(map[string]int),
+		shared:  make(map[string]bool),
+		roomIDs: make(map[int]bool),
+		stays:   make(map[int][]stay),
+		errs:    csv.NewWriter(errs),
+	}
+	for i, name := range header {
+		im.columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	var missing []string
+	for _, name := range []string{colFirstName, colLastName, colEmail, colCheckIn, colCheckOut} {
+		if !im.has(name) {
+			missing = append(missing, name)
+		}
+	}
+	if !im.has(colRoom) && !im.has(colRoomID) {
+		missing = append(missing, colRoom)
+	}
+	if len(missing) > 0 {
+		return Result{}, fmt.Errorf("the file has no %s column", strings.Join(missing, ", "))
+	}
+
+	rooms, err := db.AllRooms(propertyID)
+	if err != nil {
+		return Result{}, err
+	}
+	for _, room := range rooms {
+		name := strings.ToLower(room.RoomName)
+		if _, ok := im.rooms[name]; ok {
+			im.shared[name] = true
+		}
+		im.rooms[name] = room.ID
+		im.roomIDs[room.ID] = true
+	}
+	var columns []string
+	for _, name := range header {
+		columns = append(columns, export.EscapeFormula(name))
+	}
+	if err = im.errs.Write(append(columns, "Line", "Error")); err != nil {
+		return Result{}, err
+	}
+
+	line := 1
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		line++
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return im.result, err
+			}
+			im.result.Rows++
+			if err = im.fail(line, record, "The line is not valid CSV"); err != nil {
+				return im.result, err
+			}
+			continue
+		}
+		if blank(record) {
+			continue
+		}
+		im.result.Rows++
+		res, msg := im.parse(record)
+		if msg == "" {
+			msg = im.book(line, res)
+		}
+		if msg != "" {
+			if err = im.fail(line, record, msg); err != nil {
+				return im.result, err
+			}
+			continue
+		}
+		im.batch = append(im.batch, row{line: line, record: record, res: res})
+		if len(im.batch) == BatchSize {
+			if err = im.flush(); err != nil {
+				return im.result, err
+			}
+		}
+	}
+	if err = im.flush(); err != nil {
+		return im.result, err
+	}
+	im.errs.Flush()
+	return im.result, im.errs.Error()
+}
+
+// flush imports the batch of valid rows, failing those whose room is taken
+func (im *importer) flush() error {
+	if len(im.batch) == 0 {
+		return nil
+	}
+	rs := make([]models.Reservation, len(im.batch))
+	for i, r := range im.batch {
+		rs[i] = r.res
+	}
+	conflicts, err := im.db.ImportReservations(rs, im.dryRun)
+	if err != nil {
+		return err
+	}
+	for _, i := range conflicts {
+		if err = im.fail(im.batch[i].line, im.batch[i].record, conflictError); err != nil {
+			return err
+		}
+	}
+	im.result.Imported += len(im.batch) - len(conflicts)
+	im.batch = im.batch[:0]
+	return nil
+}
+
+// fail writes a row which failed to the error file, escaping the cells a spreadsheet would run as formulas
+func (im *importer) fail(line int, record []string, msg string) error {
+	im.result.Failed++
+	cells := make([]string, 0, len(record)+2)
+	for _, c := range record {
+		cells = append(cells, export.EscapeFormula(c))
+	}
+	return im.errs.Write(append(cells, strconv.Itoa(line), msg))
+}
+
+// book adds the stay of a reservation to the stays booked by the file, returning why it cannot be booked if an earlier
+// row books the same room for any of its nights
+func (im *importer) book(line int, res models.Reservation) string {
+	for _, s := range im.stays[res.RoomID] {
+		if res.CheckInDate.Before(s.end) && res.CheckOutDate.After(s.start) {
+			return fmt.Sprintf("The room is already booked for those dates on line %d", s.line)
+		}
+	}
+	im.stays[res.RoomID] = append(im.stays[res.RoomID], stay{line: line, start: res.CheckInDate, end: res.CheckOutDate})
+	return ""
+}
+
+// parse reads the reservation in a row, returning why the row is not valid if it is not
+func (im *importer) parse(record []string) (models.Reservation, string) {
+	get := func(name string) string {
+		i, ok := im.columns[strings.ToLower(name)]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(export.UnescapeFormula(record[i]))
+	}
+
+	res := models.Reservation{
+		FirstName: get(colFirstName),
+		LastName:  get(colLastName),
+		Email:     get(colEmail),
+		Phone:     get(colPhone),
+		Guests:    1,
+	}
+	if res.FirstName == "" || res.LastName == "" {
+		return res, "First and last name are required"
+	}
+	if !govalidator.IsEmail(res.Email) {
+		return res, "Invalid email address"
+	}
+
+	if id := get(colRoomID); id != "" {
+		roomID, err := strconv.Atoi(id)
+		if err != nil || !im.roomIDs[roomID] {
+			return res, fmt.Sprintf("Unknown room %s", id)
+		}
+		res.RoomID = roomID
+	} else {
+		name := strings.ToLower(get(colRoom))
+		roomID, ok := im.rooms[name]
+		if !ok {
+			return res, fmt.Sprintf("Unknown room %q", get(colRoom))
+		}
+		if im.shared[name] {
+			return res, fmt.Sprintf("Room %q is at several properties, give its %s instead", get(colRoom), colRoomID)
+		}
+		res.RoomID = roomID
+	}
+
+	var err error
+	res.CheckInDate, err = time.Parse("2006-01-02", get(colCheckIn))
+	if err != nil {
+		return res, "Check-in must be a date such as 2021-07-31"
+	}
+	res.CheckOutDate, err = time.Parse("2006-01-02", get(colCheckOut))
+	if err != nil {
+		return res, "Check-out must be a date such as 2021-07-31"
+	}
+	if !res.CheckOutDate.After(res.CheckInDate) {
+		return res, "Check-out must be after check-in"
+	}
+
+	if guests := get(colGuests); guests != "" {
+		res.Guests, err = strconv.Atoi(guests)
+		if err != nil || res.Guests < 1 {
+			return res, "Guests must be a number of at least 1"
+		}
+	}
+
+	if code := get(colCurrency); code != "" && !strings.EqualFold(code, im.base) {
+		return res, fmt.Sprintf("Amounts must be in %s", im.base)
+	}
+	amounts := []struct {
+		column string
+		amount *int
+	}{
+		{colRoomAmount, &res.RoomAmount},
+		{colDiscount, &res.Discount},
+		{colTotal, &res.TotalAmount},
+	}
+	for _, a := range amounts {
+		v := get(a.column)
+		if v == "" {
+			continue
+		}
+		*a.amount, err = currency.ParseAmount(v, im.base)
+		if err != nil || *a.amount < 0 {
+			return res, fmt.Sprintf("%s must be an amount such as 12.50", a.column)
+		}
+	}
+	if get(colTotal) == "" {
+		res.TotalAmount = res.RoomAmount - res.Discount
+	}
+	if get(colRoomAmount) == "" {
+		res.RoomAmount = res.TotalAmount + res.Discount
+	}
+
+	switch strings.ToLower(get(colStatus)) {
+	case "", models.ReservationStatusNew:
+	case models.ReservationStatusProcessed:
+		res.Processed = 1
+	default:
+		return res, "Status must be new or processed"
+	}
+
+	if booked := get(colBooked); booked != "" {
+		res.CreatedAt, err = time.Parse("2006-01-02 15:04", booked)
+		if err != nil {
+			res.CreatedAt, err = time.Parse("2006-01-02", booked)
+		}
+		if err != nil {
+			return res, "Booked must be a date such as 2021-07-31 or 2021-07-31 14:30"
+		}
+	}
+	return res, ""
+}
+
+// has returns true if the file has the column
+func (im *importer) has(name string) bool {
+	_, ok := im.columns[strings.ToLower(name)]
+	return ok
+}
+
+// blank returns true for rows without any values, such as blank lines at the end of a spreadsheet
+func blank(record []string) bool {
+	for _, v := range record {
+		if strings.TrimSpace(v) != "" {
+			return false
+		}
+	}
+	return true
+}
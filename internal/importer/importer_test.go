@@ -0,0 +1,125 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/csv"
+	"github.com/sunil206b/smart_booking/internal/config"
+	"github.com/sunil206b/smart_booking/internal/repository/dbrepo"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	file := `First Name,Last Name,Email,Room,Check-in,Check-out,Guests,Room Amount,Discount,Status
+John,Smith,john@here.com,General's Quarters,2050-08-01,2050-08-03,2,200.00,20.00,processed
+Jane,Smith,jane@here.com,Major's Suite,2050-08-01,2050-08-03,1,100.00,,
+Jim,Smith,not-an-email,General's Quarters,2050-08-05,2050-08-06,1,100.00,,
+Jill,Smith,jill@here.com,General's Quarters,2050-08-02,2050-08-04,1,100.00,,
+Jack,Smith,jack@here.com,Penthouse,2050-08-05,2050-08-06,1,100.00,,
+Joan,Smith,joan@here.com,general's quarters,2050-08-06,2050-08-05,1,100.00,,
+,,,,,,,,,
+Jude,Smith,jude@here.com,General's Quarters,2050-08-10,2050-08-12,0,100.00,,
+Jean,Smith,jean@here.com,General's Quarters,2050-08-10,2050-08-12,1,ten,,
+Joe,Smith,joe@here.com,General's Quarters,2050-08-10,2050-08-12,1,100.00,,cancelled
+=cmd,Smith,'=not-an-email,General's Quarters,2050-08-20,2050-08-22,1,100.00,,
+`
+	var errs bytes.Buffer
+	result, err := Import(strings.NewReader(file), dbrepo.NewTestingRepo(&config.AppConfig{}), "USD", 0, true, &errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Rows != 10 || result.Imported != 1 || result.Failed != 9 {
+		t.Errorf("expected 10 rows with 1 imported and 9 failed, but got %+v", result)
+	}
+
+	records, err := csv.NewReader(&errs).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 10 {
+		t.Fatalf("expected a header and 9 rows in the error file, but got %d", len(records))
+	}
+	if h := records[0]; h[len(h)-2] != "Line" || h[len(h)-1] != "Error" {
+		t.Errorf("expected Line and Error columns in the error file, but got %v", h)
+	}
+	var expected = []struct {
+		line string
+		err  string
+	}{
+		{"4", "Invalid email address"},
+		{"5", "The room is already booked for those dates on line 2"},
+		{"6", `Unknown room "Penthouse"`},
+		{"7", "Check-out must be after check-in"},
+		{"9", "Guests must be a number of at least 1"},
+		{"10", "Room Amount must be an amount such as 12.50"},
+		{"11", "Status must be new or processed"},
+		{"12", "Invalid email address"},
+		{"3", conflictError},
+	}
+	if r := records[8]; r[0] != "'=cmd" || r[2] != "'=not-an-email" {
+		t.Errorf("expected the formulas of the failed row to be escaped, but got %v", r)
+	}
+	for i, e := range expected {
+		r := records[i+1]
+		if r[len(r)-2] != e.line || r[len(r)-1] != e.err {
+			t.Errorf("expected line %s to fail with %q, but got line %s with %q", e.line, e.err, r[len(r)-2], r[len(r)-1])
+		}
+	}
+}
+
+func TestImport_MissingColumns(t *testing.T) {
+	file := "First Name,Last Name,Check-in,Check-out\n"
+	_, err := Import(strings.NewReader(file), dbrepo.NewTestingRepo(&config.AppConfig{}), "USD", 0, true, &bytes.Buffer{})
+	if err == nil || !strings.Contains(err.Error(), "Email, Room") {
+		t.Errorf("expected missing Email and Room columns to be reported, but got %v", err)
+	}
+
+	_, err = Import(strings.NewReader(""), dbrepo.NewTestingRepo(&config.AppConfig{}), "USD", 0, true, &bytes.Buffer{})
+	if err == nil {
+		t.Error("expected an error for an empty file")
+	}
+}
+
+func TestImport_Export(t *testing.T) {
+	file := `ID,First Name,Last Name,Email,Phone,Room,Check-in,Check-out,Nights,Guests,Room Amount,Discount,Total,Currency,Promo Code,Status,Booked
+1,John,Smith,john@here.com,555,General's Quarters,2050-08-01,2050-08-03,2,2,200.00,20.00,180.00,USD,SUMMER,new,2050-06-01 10:30
+2,Jane,Smith,jane@here.com,555,General's Quarters,2050-09-01,2050-09-03,2,2,200.00,0.00,200.00,EUR,,new,2050-06-01 10:30
+`
+	var errs bytes.Buffer
+	result, err := Import(strings.NewReader(file), dbrepo.NewTestingRepo(&config.AppConfig{}), "USD", 0, false, &errs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Imported != 1 || result.Failed != 1 {
+		t.Errorf("expected 1 imported and 1 failed, but got %+v", result)
+	}
+	if !strings.Contains(errs.String(), "Amounts must be in USD") {
+		t.Errorf("expected the other currency to be reported, but got %s", errs.String())
+	}
+}
+
+func TestImport_Property(t *testing.T) {
+	file := `First Name,Last Name,Email,Room,Check-in,Check-out
+John,Smith,john@here.com,Colonel's Room,2050-08-01,2050-08-03
+`
+	tests := []struct {
+		name       string
+		propertyID int
+		expFailed  int
+	}{
+		{"room of the property", 2, 0},
+		{"room of another property", 1, 1},
+		{"every property", 0, 0},
+	}
+	for _, e := range tests {
+		var errs bytes.Buffer
+		result, err := Import(strings.NewReader(file), dbrepo.NewTestingRepo(&config.AppConfig{}), "USD", e.propertyID,
+			true, &errs)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Failed != e.expFailed {
+			t.Errorf("for %s, expected %d failed but got %+v", e.name, e.expFailed, result)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package invoice
+
+import (
+	"errors"
+	"strconv"
+	"time"
+)
+
+const (
+	margin     = 50.0
+	bodySize   = 10.0
+	lineHeight = 16.0
+	// columns of the line table, measured from the left edge of the page
+	quantityRight = 380.0
+	unitRight     = 465.0
+	amountRight   = pageWidth - margin
+)
+
+// Invoice holds what is printed on an invoice. Amounts are already formatted in the currency of the invoice.
+type Invoice struct {
+	Number string
+	Date   time.Time
+	// Issuer is the name and address of the hotel, one line each
+	Issuer []string
+	// BillTo is the name and contact details of the guest, one line each
+	BillTo []string
+	// Reference describes the stay, such as the reservation number and dates
+	Reference string
+	Lines     []Line
+	Totals    []Total
+}
+
+// Line is a row of the invoice
+type Line struct {
+	Description string
+	Quantity    int
+	UnitAmount  string
+	Amount      string
+}
+
+// Total is a row below the lines, such as the balance due. The last total is printed in bold.
+type Total struct {
+	Label  string
+	Amount string
+}
+
+// PDF renders the invoice as a PDF document, continuing the lines on new pages as needed
+func (inv Invoice) PDF() ([]byte, error) {
+	if inv.Number == "" {
+		return nil, errors.New("invoice has no number")
+	}
+	doc := &pdfDocument{}
+	y := inv.header(doc)
+
+	y = inv.tableHeader(doc, y)
+	for _, l := range inv.Lines {
+		if y < margin+lineHeight*float64(len(inv.Totals)+2) {
+			doc.addPage()
+			y = inv.tableHeader(doc, pageHeight-margin)
+		}
+		doc.text(margin, y, bodySize, false, truncate(l.Description, quantityRight-margin-60, bodySize, false))
+		if l.Quantity != 0 {
+			doc.textRight(quantityRight, y, bodySize, false, strconv.Itoa(l.Quantity))
+		}
+		doc.textRight(unitRight, y, bodySize, false, l.UnitAmount)
+		doc.textRight(amountRight, y, bodySize, false, l.Amount)
+		y -= lineHeight
+	}
+
+	doc.line(unitRight-80, y+lineHeight/2, amountRight, y+lineHeight/2)
+	y -= lineHeight / 2
+	for i, t := range inv.Totals {
+		bold := i == len(inv.Totals)-1
+		doc.textRight(unitRight, y, bodySize, bold, t.Label)
+		doc.textRight(amountRight, y, bodySize, bold, t.Amount)
+		y -= lineHeight
+	}
+	return doc.bytes(), nil
+}
+
+// header draws the issuer, the invoice number and the guest, returning where the line table starts
+func (inv Invoice) header(doc *pdfDocument) float64 {
+	y := pageHeight - margin - 10
+	for i, l := range inv.Issuer {
+		size := bodySize
+		if i == 0 {
+			size = 16
+		}
+		doc.text(margin, y, size, i == 0, l)
+		y -= lineHeight + size - bodySize
+	}
+
+	top := pageHeight - margin - 10
+	doc.textRight(amountRight, top, 16, true, "INVOICE")
+	doc.textRight(amountRight, top-lineHeight-6, bodySize, false, "Number: "+inv.Number)
+	doc.textRight(amountRight, top-2*lineHeight-6, bodySize, false, "Date: "+inv.Date.Format("2006-01-02"))
+
+	y -= lineHeight
+	doc.text(margin, y, bodySize, true, "Bill to")
+	y -= lineHeight
+	for _, l := range inv.BillTo {
+		doc.text(margin, y, bodySize, false, l)
+		y -= lineHeight
+	}
+	if inv.Reference != "" {
+		y -= lineHeight / 2
+		doc.text(margin, y, bodySize, false, inv.Reference)
+		y -= lineHeight
+	}
+	return y - lineHeight
+}
+
+// tableHeader draws the column titles of the line table, returning where the first line goes
+func (inv Invoice) tableHeader(doc *pdfDocument, y float64) float64 {
+	doc.text(margin, y, bodySize, true, "Description")
+	doc.textRight(quantityRight, y, bodySize, true, "Qty")
+	doc.textRight(unitRight, y, bodySize, true, "Unit price")
+	doc.textRight(amountRight, y, bodySize, true, "Amount")
+	doc.line(margin, y-5, amountRight, y-5)
+	return y - lineHeight - 4
+}
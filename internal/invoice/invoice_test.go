@@ -0,0 +1,104 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func testInvoice(lines int) Invoice {
+	inv := Invoice{
+		Number:    "INV-000042",
+		Date:      time.Date(2026, 7, 4, 0, 0, 0, 0, time.UTC),
+		Issuer:    []string{"Fort Smythe", "1 Harbour Road"},
+		BillTo:    []string{"Zoë (Jo) Smith", "zoe@example.com"},
+		Reference: "Reservation 7",
+		Totals:    []Total{{"Charges", "€330.00"}, {"Balance due", "€240.00"}},
+	}
+	for i := 0; i < lines; i++ {
+		inv.Lines = append(inv.Lines, Line{fmt.Sprintf("Breakfast %d", i), 2, "€15.00", "€30.00"})
+	}
+	return inv
+}
+
+func TestInvoice_PDF(t *testing.T) {
+	pdf, err := testInvoice(3).PDF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatal("expected a PDF document")
+	}
+	for _, expected := range []string{"(Number: INV-000042)", "(Fort Smythe)", "/Count 1"} {
+		if !bytes.Contains(pdf, []byte(expected)) {
+			t.Errorf("expected the PDF to contain %q", expected)
+		}
+	}
+	if !bytes.Contains(pdf, []byte(`(Zo`+"\xeb"+` \(Jo\) Smith)`)) {
+		t.Error("expected the guest name encoded in WinAnsi with parentheses escaped")
+	}
+	if !bytes.Contains(pdf, []byte("(\x80330.00)")) {
+		t.Error("expected the euro sign encoded in WinAnsi")
+	}
+	checkXref(t, pdf)
+
+	if _, err = (Invoice{}).PDF(); err == nil {
+		t.Error("expected an error for an invoice without a number")
+	}
+}
+
+func TestInvoice_PDF_Pages(t *testing.T) {
+	pdf, err := testInvoice(100).PDF()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(pdf, []byte("/Count 3")) {
+		t.Error("expected 100 lines to continue over 3 pages")
+	}
+	if !bytes.Contains(pdf, []byte("(Breakfast 99)")) {
+		t.Error("expected the last line on the last page")
+	}
+	checkXref(t, pdf)
+}
+
+// checkXref checks that every entry of the cross-reference table points at the start of its object
+func checkXref(t *testing.T, pdf []byte) {
+	t.Helper()
+	start := regexp.MustCompile(`startxref\n(\d+)`).FindSubmatch(pdf)
+	if start == nil {
+		t.Fatal("missing startxref")
+	}
+	xref, _ := strconv.Atoi(string(start[1]))
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(pdf[xref:], -1)
+	if len(entries) == 0 {
+		t.Fatal("empty cross-reference table")
+	}
+	for i, e := range entries {
+		offset, _ := strconv.Atoi(string(e[1]))
+		if !bytes.HasPrefix(pdf[offset:], []byte(fmt.Sprintf("%d 0 obj", i+1))) {
+			t.Errorf("cross-reference entry %d does not point at its object", i+1)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	if got := truncate("Breakfast", 200, bodySize, false); got != "Breakfast" {
+		t.Errorf("expected the text to fit, but got %q", got)
+	}
+	got := truncate("A very long description of a spa treatment", 100, bodySize, false)
+	if textWidth(got, bodySize, false) > 100 || got[len(got)-len("…"):] != "…" {
+		t.Errorf("expected the text to be shortened to 100 points, but got %q", got)
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	if got := textWidth("0123456789", 10, false); got != 55.6 {
+		t.Errorf("expected digits to be 5.56 points wide at size 10, got %v", got)
+	}
+	if textWidth("Amount", 10, true) <= textWidth("Amount", 10, false) {
+		t.Error("expected bold text to be wider")
+	}
+}
@@ -1,6 +1,8 @@
 package models
 
 import (
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"strings"
 	"time"
 )
 
@@ -12,16 +14,38 @@ type User struct {
 	Email       string
 	Password    string
 	AccessLevel int
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// PropertyID is the property the user works at, 0 if they work at all of them
+	PropertyID int
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
 }
 
 //Room is the rooms model
 type Room struct {
-	ID        int
-	RoomName  string
-	CreatedAt time.Time
-	UpdatedAt time.Time
+	ID           int
+	RoomName     string
+	Price        int
+	PropertyID   int
+	PropertyName string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+//Property is the properties model, a hotel run from the site with the rooms and staff it owns
+type Property struct {
+	ID   int
+	Slug string
+	Name string
+	// Hostname is the host name the public site of the property is served on, if it has one of its own
+	Hostname      string
+	EmailFrom     string
+	EmailFromName string
+	LogoURL       string
+	BrandColor    string
+	// DepositPolicy is the deposit taken when booking a room of the property, the default policy if empty
+	DepositPolicy string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
 }
 
 //Restriction is the restriction model
@@ -32,6 +56,16 @@ type Restriction struct {
 	UpdatedAt       time.Time
 }
 
+// The kinds of room restriction, matching the ids of the restrictions table
+const (
+	// RestrictionReservation makes a room unavailable for a reservation
+	RestrictionReservation = 1
+	// RestrictionBlock is a night blocked by the owner
+	RestrictionBlock = 2
+	// RestrictionHold holds a room for a guest filling in the reservation form until it expires
+	RestrictionHold = 3
+)
+
 //Reservation is the reservations model
 type Reservation struct {
 	ID           int       `json:"id"`
@@ -45,9 +79,168 @@ type Reservation struct {
 	CreatedAt    time.Time `json:"created_at"`
 	UpdatedAt    time.Time `json:"updated_at"`
 	Processed    int       `json:"processed"`
+	Guests       int       `json:"guests"`
+	RoomAmount   int       `json:"room_amount"`
+	PromoCodeID  int       `json:"promo_code_id"`
+	Discount     int       `json:"discount"`
+	TotalAmount  int       `json:"total_amount"`
 	Room         Room      `json:"-"`
+	PromoCode    string    `json:"promo_code"`
+	GuestID      int       `json:"guest_id"`
+	// CheckedInAt and CheckedOutAt are when the guest actually arrived and left, zero until they do
+	CheckedInAt    time.Time `json:"checked_in_at"`
+	CheckedOutAt   time.Time `json:"checked_out_at"`
+	KeyNumber      string    `json:"key_number"`
+	IDVerification string    `json:"id_verification"`
+	// Taxes are the taxes and fees charged on the stay, included in TotalAmount
+	Taxes []ReservationTax `json:"-"`
+	// Extras are the extras booked with the stay, included in TotalAmount
+	Extras []ReservationExtra `json:"-"`
+}
+
+//InHouse returns true if the guest has checked in and not yet checked out
+func (r Reservation) InHouse() bool {
+	return !r.CheckedInAt.IsZero() && r.CheckedOutAt.IsZero()
+}
+
+//HousekeepingRoom is the housekeeping status of a room. A room out of order is blocked until OutOfOrderUntil.
+type HousekeepingRoom struct {
+	Room            Room
+	Status          string
+	UpdatedAt       time.Time
+	BlockID         int
+	OutOfOrderUntil time.Time
+}
+
+// housekeeping statuses of rooms
+const (
+	HousekeepingDirty      = "dirty"
+	HousekeepingCleaning   = "cleaning"
+	HousekeepingClean      = "clean"
+	HousekeepingInspected  = "inspected"
+	HousekeepingOutOfOrder = "out_of_order"
+)
+
+//Today returns the date of a time, at midnight UTC as dates are stored
+func Today(now time.Time) time.Time {
+	return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+//HousekeepingTask is the housekeeping_tasks model, a room to clean on a day for a departure or a guest staying over
+type HousekeepingTask struct {
+	ID            int
+	RoomID        int
+	ReservationID int
+	Kind          string
+	TaskDate      time.Time
+	Status        string
+	AssignedTo    int
+	AssigneeName  string
+	Notes         string
+	CompletedAt   time.Time
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+	Room          Room
+}
+
+// kinds and statuses of housekeeping tasks
+const (
+	TaskKindDeparture = "departure"
+	TaskKindStayover  = "stayover"
+
+	TaskStatusOpen       = "open"
+	TaskStatusInProgress = "in_progress"
+	TaskStatusDone       = "done"
+)
+
+//FrontDeskAction is a guest checking in or out at the front desk
+type FrontDeskAction struct {
+	ReservationID  int
+	At             time.Time
+	KeyNumber      string
+	IDVerification string
+	// Fee is added to the folio for an early check-in or a late check-out if it has an amount, which is negative to
+	// credit the guest
+	Fee FolioItem
+	// Move moves the reservation to another room in the same transaction if set
+	Move *ReservationMove
+}
+
+//Guest is the guests model, a guest reservations are linked to by their email address
+type Guest struct {
+	ID          int
+	FirstName   string
+	LastName    string
+	Email       string
+	Phone       string
+	Notes       string
+	Tags        []string
+	Preferences string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	// Stays is the number of reservations of the guest, and LastStay the latest check-in, in guest lists
+	Stays    int
+	LastStay time.Time
+}
+
+// tags guests are often given, with HasTag matching them whatever their case
+const (
+	GuestTagVIP        = "VIP"
+	GuestTagDoNotRent  = "Do not rent"
+	GuestTagReturning  = "Returning"
+	GuestTagAccessible = "Accessible room"
+)
+
+//HasTag returns true if the guest has the tag
+func (g Guest) HasTag(tag string) bool {
+	for _, t := range g.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
 }
 
+//ReservationFilter picks the reservations staying between Start and End, in the room with RoomID of the property
+//with PropertyID and with the status, whose guest name and email contain Name and Email, and whose guest matches every word of Search. Zero values match
+//every reservation.
+type ReservationFilter struct {
+	Start      time.Time
+	End        time.Time
+	RoomID     int
+	PropertyID int
+	Status     string
+	Name       string
+	Email      string
+	Search     string
+}
+
+// statuses reservations can be filtered by
+const (
+	ReservationStatusNew       = "new"
+	ReservationStatusProcessed = "processed"
+)
+
+//ReservationSearch is a page of the reservations matching a filter, sorted by one of the ReservationSort columns
+type ReservationSearch struct {
+	ReservationFilter
+	Sort    string
+	Desc    bool
+	Page    int
+	PerPage int
+}
+
+// columns reservations can be sorted by
+const (
+	ReservationSortID       = "id"
+	ReservationSortName     = "name"
+	ReservationSortEmail    = "email"
+	ReservationSortRoom     = "room"
+	ReservationSortCheckIn  = "check_in"
+	ReservationSortCheckOut = "check_out"
+	ReservationSortBooked   = "booked"
+)
+
 //RoomRestriction is the room_restrictions model
 type RoomRestriction struct {
 	ID            int
@@ -56,18 +249,321 @@ type RoomRestriction struct {
 	RoomID        int
 	ReservationID int
 	RestrictionID int
+	// ExpiresAt is when a hold stops making the room unavailable, and zero for other restrictions
+	ExpiresAt time.Time
+	// Reason and Notes say why an owner block was made
+	Reason      string
+	Notes       string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Room        Room
+	Reservation Reservation
+	Restriction Restriction
+}
+
+//RoomRule is the room_rules model
+type RoomRule struct {
+	ID                int
+	RoomID            int
+	StartDate         time.Time
+	EndDate           time.Time
+	MinStay           int
+	MaxStay           int
+	ClosedToArrival   bool
+	ClosedToDeparture bool
+	MinAdvanceDays    int
+	MaxAdvanceDays    int
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
+	Room              Room
+}
+
+//ExcludedRoom holds a room left out of an availability search and the reason why
+type ExcludedRoom struct {
+	Room   Room
+	Reason i18n.Message
+}
+
+//CalendarNight holds the availability, price and rule flags of a room for a single night
+type CalendarNight struct {
+	Date              time.Time
+	Booked            bool
+	Price             int
+	MinStay           int
+	MaxStay           int
+	ClosedToArrival   bool
+	ClosedToDeparture bool
+	MinAdvanceDays    int
+	MaxAdvanceDays    int
+}
+
+//RoomCalendar holds the nights of a room over a date range
+type RoomCalendar struct {
+	Room   Room
+	Nights []CalendarNight
+}
+
+//ExchangeRate is the number of units of a currency one unit of the base currency buys
+type ExchangeRate struct {
+	ID           int
+	CurrencyCode string
+	Rate         float64
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+//Payment is the payments model, holding an amount taken through a payment gateway for a reservation
+type Payment struct {
+	ID            int
+	ReservationID int
+	Provider      string
+	Reference     string
+	CheckoutURL   string
+	Amount        int
+	Currency      string
+	Status        string
+	Captured      int
+	Refunded      int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+//FolioItem is the folio_items model, holding a charge added to a reservation by staff
+type FolioItem struct {
+	ID            int
+	ReservationID int
+	Kind          string
+	Description   string
+	Quantity      int
+	UnitAmount    int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+//Invoice is the invoices model. PDF is only loaded for a single invoice.
+type Invoice struct {
+	ID            int
+	ReservationID int
+	Number        string
+	Total         int
+	Currency      string
+	PDF           []byte
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+//TaxRule is the tax_rules model. Amount is in basis points for percentage rules and in minor units of the base
+//currency otherwise. A RoomID of 0 applies the rule to every room.
+type TaxRule struct {
+	ID        int
+	Name      string
+	Kind      string
+	Amount    int
+	RoomID    int
+	StartDate time.Time
+	EndDate   time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	Room      Room
+}
+
+//ReservationTax is the reservation_taxes model, holding a tax or fee as it was charged when the reservation was made
+type ReservationTax struct {
+	ID            int
+	ReservationID int
+	TaxRuleID     int
+	Name          string
+	Kind          string
+	Amount        int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+//PromoCode is the promo_codes model. Amount is in basis points for percentage discounts and in minor units of the
+//base currency for fixed ones. Guests can book with the code from ValidFrom to ValidTo, for stays whose nights fall
+//between StayStart and StayEnd when they are set. RoomIDs limits the code to some rooms, and a MaxUses of 0 means
+//the code can be used any number of times.
+type PromoCode struct {
+	ID          int
+	Code        string
+	Description string
+	Kind        string
+	Amount      int
+	ValidFrom   time.Time
+	ValidTo     time.Time
+	StayStart   time.Time
+	StayEnd     time.Time
+	MinNights   int
+	MaxUses     int
+	Uses        int
+	RoomIDs     []int
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+//PromoCodeStats holds how much a promo code has been used
+type PromoCodeStats struct {
+	PromoCode    PromoCode
+	Reservations int
+	Discount     int
+	Revenue      int
+}
+
+//Extra is the extras model, holding something guests can book with a room such as breakfast or parking. Unit is how
+//Price is charged and Inventory how many can be booked for a night, or 0 for no limit.
+type Extra struct {
+	ID          int
+	Name        string
+	Description string
+	Unit        string
+	Price       int
+	Inventory   int
+	Active      bool
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+//ReservationExtra is the reservation_extras model, holding an extra as it was booked with a reservation
+type ReservationExtra struct {
+	ID            int
+	ReservationID int
+	ExtraID       int
+	Name          string
+	Unit          string
+	Quantity      int
+	UnitPrice     int
+	Amount        int
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+}
+
+//WaitlistEntry is the waitlist_entries model, holding a guest waiting for a room to free up for their dates. RoomID
+//is the room they asked for, or 0 for any room. Once a room is offered to them, OfferedRoomID, Token and ExpiresAt
+//hold the room and the booking link sent to them.
+type WaitlistEntry struct {
+	ID            int
+	FirstName     string
+	LastName      string
+	Email         string
+	Phone         string
+	Locale        string
+	PropertyID    int
+	RoomID        int
+	StartDate     time.Time
+	EndDate       time.Time
+	Status        string
+	OfferedRoomID int
+	Token         string
+	OfferedAt     time.Time
+	ExpiresAt     time.Time
 	CreatedAt     time.Time
 	UpdatedAt     time.Time
 	Room          Room
-	Reservation   Reservation
-	Restriction   Restriction
+}
+
+//ReservationMove is the reservation_moves model, recording a reservation moved to another room or other dates by a
+//member of staff
+type ReservationMove struct {
+	ID            int
+	ReservationID int
+	UserID        int
+	FromRoomID    int
+	ToRoomID      int
+	FromStart     time.Time
+	FromEnd       time.Time
+	ToStart       time.Time
+	ToEnd         time.Time
+	CreatedAt     time.Time
+	UserName      string
+	FromRoom      Room
+	ToRoom        Room
+}
+
+//ImportRun is the import_runs model, the outcome of importing a file of reservations, with the rows which failed and
+//why as a CSV file
+type ImportRun struct {
+	ID        int
+	UserID    int
+	FileName  string
+	DryRun    bool
+	Rows      int
+	Imported  int
+	Failed    int
+	Errors    []byte
+	CreatedAt time.Time
+}
+
+//OccupancyStats are the nights a room was available and sold in a month of a report, and the room revenue of the
+//nights sold
+type OccupancyStats struct {
+	RoomID          int
+	RoomName        string
+	Month           time.Time
+	NightsAvailable int
+	NightsSold      int
+	RoomRevenue     int
+}
+
+//ReportBucket is the number of reservations falling in one band of a distribution, such as stays of 2 nights
+type ReportBucket struct {
+	Label string
+	Count int
+}
+
+//CancellationStats are the reservations cancelled in a month of a report and the revenue lost with them, with the
+//reservations booked in the month
+type CancellationStats struct {
+	Month       time.Time
+	Booked      int
+	Cancelled   int
+	LostRevenue int
 }
 
 // MailData holds an email message
 type MailData struct {
-	To       string
-	From     string
-	Subject  string
-	Content  string
-	Template string
+	To          string
+	From        string
+	Subject     string
+	Content     string
+	Template    string
+	Locale      string
+	Attachments []MailAttachment
+}
+
+//SentEmail is the sent_emails model, a copy of an email sent by the site kept for data subject requests
+type SentEmail struct {
+	ID        int
+	Recipient string
+	Subject   string
+	Content   string
+	Template  string
+	Status    string
+	CreatedAt time.Time
+}
+
+// statuses of sent emails
+const (
+	EmailStatusSent   = "sent"
+	EmailStatusFailed = "failed"
+)
+
+//GuestData is everything kept about a guest, found by one of their email addresses, for data subject requests
+type GuestData struct {
+	Email           string
+	Emails          []string
+	Guests          []Guest
+	Reservations    []Reservation
+	FolioItems      []FolioItem
+	Payments        []Payment
+	Invoices        []Invoice
+	WaitlistEntries []WaitlistEntry
+	SentEmails      []SentEmail
+	ImportRuns      []ImportRun
+}
+
+// MailAttachment holds a file attached to an email message
+type MailAttachment struct {
+	Name        string
+	ContentType string
+	Data        []byte
 }
@@ -1,6 +1,9 @@
 package models
 
-import "github.com/sunil206b/smart_booking/internal/forms"
+import (
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/forms"
+)
 
 // TemplateData holds data sent from handlers to template
 type TemplateData struct {
@@ -14,4 +17,23 @@ type TemplateData struct {
 	Error           string
 	Form            *forms.Form
 	IsAuthenticated bool
+	Locale          string
+	Locales         []string
+	Currency        string
+	Currencies      []string
+	BaseCurrency    string
+	Converter       *currency.Converter
+	Property        Property
+	Properties      []Property
+	AdminPropertyID int
+	StaffPropertyID int
+}
+
+// Price formats an amount in minor units of the base currency in the currency chosen by the visitor,
+// or in the base currency if there is no exchange rate for it
+func (td *TemplateData) Price(amount int) string {
+	if td.Converter == nil {
+		return currency.Format(amount, currency.DefaultBase, td.Locale)
+	}
+	return td.Converter.Format(amount, td.Currency, td.Locale)
 }
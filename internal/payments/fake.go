@@ -0,0 +1,170 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// SignatureHeader holds the HMAC-SHA256 of a webhook body, hex encoded
+const SignatureHeader = "X-Payment-Signature"
+
+// FakeCheckoutPath is where the fake gateway's local checkout page is served, followed by the payment reference
+const FakeCheckoutPath = "/payments/fake/checkout/"
+
+// FakeCheckout is a checkout started on the fake gateway
+type FakeCheckout struct {
+	AuthorizeRequest
+	Reference string
+	Status    Status
+	Captured  int
+	Refunded  int
+}
+
+// FakeProvider is an in-memory gateway for development and tests. Guests complete its checkout on a local page
+// instead of a gateway's site, and its webhooks are signed with a shared secret.
+type FakeProvider struct {
+	secret    []byte
+	mu        sync.Mutex
+	checkouts map[string]*FakeCheckout
+}
+
+// NewFakeProvider creates a fake gateway signing its webhooks with the secret
+func NewFakeProvider(secret string) *FakeProvider {
+	return &FakeProvider{
+		secret:    []byte(secret),
+		checkouts: make(map[string]*FakeCheckout),
+	}
+}
+
+// Name identifies the fake gateway
+func (f *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Authorize starts a checkout on the local checkout page
+func (f *FakeProvider) Authorize(ctx context.Context, req AuthorizeRequest) (Authorization, error) {
+	if req.Amount <= 0 {
+		return Authorization{}, fmt.Errorf("cannot authorize an amount of %d", req.Amount)
+	}
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return Authorization{}, err
+	}
+	ref := "fake_" + hex.EncodeToString(b)
+
+	f.mu.Lock()
+	f.checkouts[ref] = &FakeCheckout{AuthorizeRequest: req, Reference: ref, Status: StatusPending}
+	f.mu.Unlock()
+	return Authorization{Reference: ref, CheckoutURL: FakeCheckoutPath + ref}, nil
+}
+
+// Checkout returns a copy of the checkout with the reference
+func (f *FakeProvider) Checkout(reference string) (FakeCheckout, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.checkouts[reference]
+	if !ok {
+		return FakeCheckout{}, ErrUnknownPayment
+	}
+	return *c, nil
+}
+
+// Complete finishes a pending checkout as if the guest had entered a card which was approved or declined,
+// returning the event the gateway reports
+func (f *FakeProvider) Complete(reference string, approved bool) (Event, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.checkouts[reference]
+	if !ok {
+		return Event{}, ErrUnknownPayment
+	}
+	if c.Status != StatusPending {
+		return Event{}, fmt.Errorf("payment %s is already %s", reference, c.Status)
+	}
+	event := Event{Type: EventFailed, Reference: reference, Amount: c.Amount}
+	if approved {
+		event.Type = EventAuthorized
+	}
+	c.Status = event.Status()
+	return event, nil
+}
+
+// Capture takes an authorized amount
+func (f *FakeProvider) Capture(ctx context.Context, reference string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.checkouts[reference]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if c.Status != StatusAuthorized {
+		return fmt.Errorf("cannot capture payment %s which is %s", reference, c.Status)
+	}
+	if amount <= 0 || amount > c.Amount {
+		return fmt.Errorf("cannot capture %d of an authorization for %d", amount, c.Amount)
+	}
+	c.Captured = amount
+	c.Status = StatusCaptured
+	return nil
+}
+
+// Refund gives back a captured amount
+func (f *FakeProvider) Refund(ctx context.Context, reference string, amount int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.checkouts[reference]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if c.Status != StatusCaptured {
+		return fmt.Errorf("cannot refund payment %s which is %s", reference, c.Status)
+	}
+	if amount <= 0 || amount > c.Captured-c.Refunded {
+		return fmt.Errorf("cannot refund %d of %d captured", amount, c.Captured-c.Refunded)
+	}
+	c.Refunded += amount
+	if c.Refunded == c.Captured {
+		c.Status = StatusRefunded
+	}
+	return nil
+}
+
+// Sign returns the webhook body for the event and its signature
+func (f *FakeProvider) Sign(event Event) ([]byte, string, error) {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, f.signature(body), nil
+}
+
+// VerifyWebhook checks the signature of a webhook request and returns its event
+func (f *FakeProvider) VerifyWebhook(r *http.Request) (Event, error) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(nil, r.Body, 1<<16))
+	if err != nil {
+		return Event{}, err
+	}
+	expected := f.signature(body)
+	if !hmac.Equal([]byte(expected), []byte(r.Header.Get(SignatureHeader))) {
+		return Event{}, ErrInvalidSignature
+	}
+	var event Event
+	if err = json.Unmarshal(body, &event); err != nil {
+		return Event{}, err
+	}
+	return event, nil
+}
+
+func (f *FakeProvider) signature(body []byte) string {
+	mac := hmac.New(sha256.New, f.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
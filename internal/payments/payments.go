@@ -0,0 +1,149 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Status is the state of a payment
+type Status string
+
+const (
+	StatusPending    Status = "pending"
+	StatusAuthorized Status = "authorized"
+	StatusCaptured   Status = "captured"
+	StatusRefunded   Status = "refunded"
+	StatusFailed     Status = "failed"
+)
+
+// EventType is the kind of change a gateway reports for a payment
+type EventType string
+
+const (
+	EventAuthorized EventType = "payment.authorized"
+	EventFailed     EventType = "payment.failed"
+	EventCaptured   EventType = "payment.captured"
+	EventRefunded   EventType = "payment.refunded"
+)
+
+// ErrUnknownPayment is returned when the gateway does not know the payment reference
+var ErrUnknownPayment = errors.New("unknown payment")
+
+// ErrInvalidSignature is returned when a webhook is not signed by the gateway
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// AuthorizeRequest asks the gateway to hold an amount on the guest's card
+type AuthorizeRequest struct {
+	Amount      int
+	Currency    string
+	Description string
+	Email       string
+	// ReturnURL is where the gateway sends the guest once they have finished the checkout
+	ReturnURL string
+}
+
+// Authorization is the gateway's answer to an AuthorizeRequest. The guest must be sent to CheckoutURL to
+// enter their card details; the outcome arrives later as an Event.
+type Authorization struct {
+	Reference   string
+	CheckoutURL string
+}
+
+// Event is a change to a payment reported by the gateway. Amount is the payment's running total for the kind of
+// event, i.e. the amount authorized, captured or refunded so far, so a webhook delivered twice changes nothing.
+type Event struct {
+	Type      EventType `json:"type"`
+	Reference string    `json:"reference"`
+	Amount    int       `json:"amount"`
+}
+
+// Status returns the status a payment has after the event
+func (e Event) Status() Status {
+	switch e.Type {
+	case EventAuthorized:
+		return StatusAuthorized
+	case EventCaptured:
+		return StatusCaptured
+	case EventRefunded:
+		return StatusRefunded
+	default:
+		return StatusFailed
+	}
+}
+
+// CanTransition reports whether a payment may move from one status to another. Payments only move forward, so a
+// late or replayed event cannot undo a capture or a refund.
+func CanTransition(from, to Status) bool {
+	if from == StatusFailed || to == StatusFailed {
+		return from == StatusPending || from == to
+	}
+	rank := map[Status]int{StatusPending: 0, StatusAuthorized: 1, StatusCaptured: 2, StatusRefunded: 3}
+	return rank[to] >= rank[from]
+}
+
+// PaymentProvider is a payment gateway. Amounts are in minor units of the currency.
+type PaymentProvider interface {
+	// Name identifies the gateway in the payments table
+	Name() string
+	// Authorize starts a checkout holding the amount on the guest's card
+	Authorize(ctx context.Context, req AuthorizeRequest) (Authorization, error)
+	// Capture takes an authorized amount, which may be less than the amount authorized
+	Capture(ctx context.Context, reference string, amount int) error
+	// Refund gives back a captured amount, which may be less than the amount captured
+	Refund(ctx context.Context, reference string, amount int) error
+	// VerifyWebhook checks that a webhook request was sent by the gateway and returns its event
+	VerifyWebhook(r *http.Request) (Event, error)
+}
+
+// DepositPolicy decides how much of a stay is paid when booking
+type DepositPolicy struct {
+	// FirstNight charges the price of the first night
+	FirstNight bool
+	// Percent charges a percentage of the total when FirstNight is false
+	Percent int
+}
+
+// ParseDepositPolicy parses a policy written as "first-night", "none", or "percent:N" with N from 0 to 100
+func ParseDepositPolicy(s string) (DepositPolicy, error) {
+	s = strings.TrimSpace(strings.ToLower(s))
+	switch {
+	case s == "first-night":
+		return DepositPolicy{FirstNight: true}, nil
+	case s == "none" || s == "":
+		return DepositPolicy{}, nil
+	case strings.HasPrefix(s, "percent:"):
+		percent, err := strconv.Atoi(strings.TrimPrefix(s, "percent:"))
+		if err != nil || percent < 0 || percent > 100 {
+			return DepositPolicy{}, fmt.Errorf("invalid deposit percentage in %q", s)
+		}
+		return DepositPolicy{Percent: percent}, nil
+	}
+	return DepositPolicy{}, fmt.Errorf("unknown deposit policy %q", s)
+}
+
+// Amount returns the deposit for a stay with the given total and nightly price, never more than the total
+func (p DepositPolicy) Amount(total, nightly int) int {
+	deposit := total * p.Percent / 100
+	if p.FirstNight {
+		deposit = nightly
+	}
+	if deposit > total {
+		return total
+	}
+	return deposit
+}
+
+// String returns the policy in the form accepted by ParseDepositPolicy
+func (p DepositPolicy) String() string {
+	if p.FirstNight {
+		return "first-night"
+	}
+	if p.Percent == 0 {
+		return "none"
+	}
+	return fmt.Sprintf("percent:%d", p.Percent)
+}
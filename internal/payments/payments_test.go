@@ -0,0 +1,148 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestParseDepositPolicy(t *testing.T) {
+	var tests = []struct {
+		policy string
+		exp    DepositPolicy
+		expErr bool
+	}{
+		{"first-night", DepositPolicy{FirstNight: true}, false},
+		{"percent:30", DepositPolicy{Percent: 30}, false},
+		{"none", DepositPolicy{}, false},
+		{"percent:101", DepositPolicy{}, true},
+		{"percent:abc", DepositPolicy{}, true},
+		{"everything", DepositPolicy{}, true},
+	}
+	for _, e := range tests {
+		got, err := ParseDepositPolicy(e.policy)
+		if e.expErr != (err != nil) {
+			t.Errorf("for %s, expected error %v but got %v", e.policy, e.expErr, err)
+		}
+		if got != e.exp {
+			t.Errorf("for %s, expected %+v but got %+v", e.policy, e.exp, got)
+		}
+		if !e.expErr && got.String() != e.policy {
+			t.Errorf("expected %s to round trip but got %s", e.policy, got.String())
+		}
+	}
+}
+
+func TestDepositPolicy_Amount(t *testing.T) {
+	if got := (DepositPolicy{Percent: 30}).Amount(30000, 10000); got != 9000 {
+		t.Errorf("expected 9000 but got %d", got)
+	}
+	if got := (DepositPolicy{FirstNight: true}).Amount(30000, 10000); got != 10000 {
+		t.Errorf("expected 10000 but got %d", got)
+	}
+	if got := (DepositPolicy{FirstNight: true}).Amount(5000, 10000); got != 5000 {
+		t.Errorf("deposit should not be more than the total, but got %d", got)
+	}
+	if got := (DepositPolicy{}).Amount(30000, 10000); got != 0 {
+		t.Errorf("expected no deposit but got %d", got)
+	}
+}
+
+func TestFakeProvider_Lifecycle(t *testing.T) {
+	ctx := context.Background()
+	f := NewFakeProvider("secret")
+	auth, err := f.Authorize(ctx, AuthorizeRequest{Amount: 9000, Currency: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(auth.CheckoutURL, FakeCheckoutPath) {
+		t.Errorf("expected a local checkout URL but got %s", auth.CheckoutURL)
+	}
+
+	if err = f.Capture(ctx, auth.Reference, 9000); err == nil {
+		t.Error("a pending payment should not be captured")
+	}
+	event, err := f.Complete(auth.Reference, true)
+	if err != nil || event.Status() != StatusAuthorized {
+		t.Fatalf("expected the payment to be authorized, got %v %v", event, err)
+	}
+	if _, err = f.Complete(auth.Reference, true); err == nil {
+		t.Error("a checkout should only complete once")
+	}
+	if err = f.Capture(ctx, auth.Reference, 10000); err == nil {
+		t.Error("should not capture more than authorized")
+	}
+	if err = f.Capture(ctx, auth.Reference, 9000); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Refund(ctx, auth.Reference, 4000); err != nil {
+		t.Fatal(err)
+	}
+	if err = f.Refund(ctx, auth.Reference, 6000); err == nil {
+		t.Error("should not refund more than captured")
+	}
+	if err = f.Refund(ctx, auth.Reference, 5000); err != nil {
+		t.Fatal(err)
+	}
+	c, _ := f.Checkout(auth.Reference)
+	if c.Status != StatusRefunded {
+		t.Errorf("expected the payment to be refunded but it is %s", c.Status)
+	}
+}
+
+func TestFakeProvider_Declined(t *testing.T) {
+	f := NewFakeProvider("secret")
+	auth, _ := f.Authorize(context.Background(), AuthorizeRequest{Amount: 100})
+	event, err := f.Complete(auth.Reference, false)
+	if err != nil || event.Status() != StatusFailed {
+		t.Errorf("expected a failed payment, got %v %v", event, err)
+	}
+	if _, err = f.Authorize(context.Background(), AuthorizeRequest{Amount: 0}); err == nil {
+		t.Error("should not authorize a zero amount")
+	}
+}
+
+func TestFakeProvider_VerifyWebhook(t *testing.T) {
+	f := NewFakeProvider("secret")
+	body, sig, err := f.Sign(Event{Type: EventCaptured, Reference: "fake_1", Amount: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, sig)
+	event, err := f.VerifyWebhook(req)
+	if err != nil || event.Reference != "fake_1" || event.Status() != StatusCaptured {
+		t.Errorf("expected a verified capture event, got %v %v", event, err)
+	}
+
+	req = httptest.NewRequest("POST", "/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(SignatureHeader, NewFakeProvider("other").signature(body))
+	if _, err = f.VerifyWebhook(req); err != ErrInvalidSignature {
+		t.Errorf("expected an invalid signature, got %v", err)
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	var tests = []struct {
+		from, to Status
+		exp      bool
+	}{
+		{StatusPending, StatusAuthorized, true},
+		{StatusPending, StatusFailed, true},
+		{StatusAuthorized, StatusCaptured, true},
+		{StatusCaptured, StatusCaptured, true},
+		{StatusCaptured, StatusRefunded, true},
+		{StatusCaptured, StatusAuthorized, false},
+		{StatusRefunded, StatusCaptured, false},
+		{StatusAuthorized, StatusFailed, false},
+		{StatusFailed, StatusAuthorized, false},
+	}
+	for _, e := range tests {
+		if got := CanTransition(e.from, e.to); got != e.exp {
+			t.Errorf("from %s to %s: expected %v but got %v", e.from, e.to, e.exp, got)
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package pricing
+
+import (
+	"github.com/sunil206b/smart_booking/internal/models"
+	"time"
+)
+
+// Kind is how a tax or fee rule is charged
+type Kind string
+
+const (
+	// KindPercent charges a percentage of the room price of the nights the rule covers. The amount of the rule is in
+	// basis points, so 750 is 7.5%.
+	KindPercent Kind = "percent"
+	// KindPerNight charges the amount of the rule for each night it covers
+	KindPerNight Kind = "per_night"
+	// KindPerPersonNight charges the amount of the rule for each guest and each night it covers
+	KindPerPersonNight Kind = "per_person_night"
+	// KindPerStay charges the amount of the rule once when it covers the arrival date
+	KindPerStay Kind = "per_stay"
+)
+
+// Kinds are the kinds of tax and fee rules, in the order they are offered to staff
+var Kinds = []Kind{KindPercent, KindPerNight, KindPerPersonNight, KindPerStay}
+
+// ValidKind reports whether kind is a kind of tax or fee rule
+func ValidKind(kind string) bool {
+	for _, k := range Kinds {
+		if string(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtraUnit is how an extra is priced
+type ExtraUnit string
+
+const (
+	// ExtraPerStay charges the price of the extra once for the stay
+	ExtraPerStay ExtraUnit = "per_stay"
+	// ExtraPerNight charges the price of the extra for each night of the stay
+	ExtraPerNight ExtraUnit = "per_night"
+	// ExtraPerGuest charges the price of the extra once for each guest
+	ExtraPerGuest ExtraUnit = "per_guest"
+)
+
+// ExtraUnits are the ways an extra can be priced, in the order they are offered to staff
+var ExtraUnits = []ExtraUnit{ExtraPerStay, ExtraPerNight, ExtraPerGuest}
+
+// ValidExtraUnit reports whether unit is a way an extra can be priced
+func ValidExtraUnit(unit string) bool {
+	for _, u := range ExtraUnits {
+		if string(u) == unit {
+			return true
+		}
+	}
+	return false
+}
+
+// Line is a tax or fee charged on a stay
+type Line struct {
+	RuleID int
+	Name   string
+	Kind   Kind
+	Amount int
+}
+
+// Quote is the price of a stay, in minor units of the base currency
+type Quote struct {
+	Nights     int
+	Guests     int
+	RoomCharge int
+	Discount   int
+	Lines      []Line
+	Extras     []models.ReservationExtra
+	Total      int
+}
+
+// Calculate prices a stay from start to end for a number of guests at a nightly room price, taking a discount off
+// the room charge and applying the tax and fee rules of the room. Each rule only charges for the nights within its
+// validity, both ends inclusive, so a tax which changes during a stay is split between the old and the new rate.
+// Percentages are charged on the discounted room price. Rules charging nothing are left out.
+func Calculate(nightly int, start, end time.Time, guests, discount int, taxRules []models.TaxRule) Quote {
+	start = truncate(start)
+	end = truncate(end)
+	if guests < 1 {
+		guests = 1
+	}
+	q := Quote{Guests: guests}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		q.Nights++
+	}
+	q.RoomCharge = q.Nights * nightly
+	q.Discount = discount
+	if q.Discount > q.RoomCharge {
+		q.Discount = q.RoomCharge
+	}
+	q.Total = q.RoomCharge - q.Discount
+
+	for _, rule := range taxRules {
+		nights := 0
+		for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+			if covers(rule, d) {
+				nights++
+			}
+		}
+		amount := 0
+		switch Kind(rule.Kind) {
+		case KindPercent:
+			base := nights * nightly
+			if q.Discount > 0 {
+				base = base * (q.RoomCharge - q.Discount) / q.RoomCharge
+			}
+			amount = (base*rule.Amount + 5000) / 10000
+		case KindPerNight:
+			amount = nights * rule.Amount
+		case KindPerPersonNight:
+			amount = nights * guests * rule.Amount
+		case KindPerStay:
+			if q.Nights > 0 && covers(rule, start) {
+				amount = rule.Amount
+			}
+		}
+		if amount == 0 {
+			continue
+		}
+		q.Lines = append(q.Lines, Line{RuleID: rule.ID, Name: rule.Name, Kind: Kind(rule.Kind), Amount: amount})
+		q.Total += amount
+	}
+	return q
+}
+
+// AddExtra adds a quantity of an extra to the quote, priced for the nights and guests of the stay
+func (q *Quote) AddExtra(extra models.Extra, quantity int) {
+	if quantity < 1 {
+		return
+	}
+	units := quantity
+	switch ExtraUnit(extra.Unit) {
+	case ExtraPerNight:
+		units *= q.Nights
+	case ExtraPerGuest:
+		units *= q.Guests
+	}
+	q.Extras = append(q.Extras, models.ReservationExtra{
+		ExtraID:   extra.ID,
+		Name:      extra.Name,
+		Unit:      extra.Unit,
+		Quantity:  quantity,
+		UnitPrice: extra.Price,
+		Amount:    units * extra.Price,
+	})
+	q.Total += units * extra.Price
+}
+
+// Taxes returns the tax and fee lines of the quote as they are kept with a reservation
+func (q Quote) Taxes() []models.ReservationTax {
+	var taxes []models.ReservationTax
+	for _, l := range q.Lines {
+		taxes = append(taxes, models.ReservationTax{
+			TaxRuleID: l.RuleID,
+			Name:      l.Name,
+			Kind:      string(l.Kind),
+			Amount:    l.Amount,
+		})
+	}
+	return taxes
+}
+
+// covers returns true if the day falls within the rule's validity, both ends inclusive
+func covers(rule models.TaxRule, day time.Time) bool {
+	return !day.Before(truncate(rule.StartDate)) && !day.After(truncate(rule.EndDate))
+}
+
+func truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
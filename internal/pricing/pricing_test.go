@@ -0,0 +1,152 @@
+package pricing
+
+import (
+	"github.com/sunil206b/smart_booking/internal/models"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func rule(kind Kind, amount int, start, end time.Time) models.TaxRule {
+	return models.TaxRule{ID: 1, Name: string(kind), Kind: string(kind), Amount: amount, StartDate: start, EndDate: end}
+}
+
+var calculateTests = []struct {
+	name      string
+	rule      models.TaxRule
+	expAmount int
+}{
+	{"percent of every night", rule(KindPercent, 750, date(2021, time.January, 1), date(2021, time.December, 31)), 2250},
+	{"percent rounds half up", rule(KindPercent, 1, date(2021, time.January, 1), date(2021, time.December, 31)), 3},
+	{"per night", rule(KindPerNight, 200, date(2021, time.January, 1), date(2021, time.December, 31)), 600},
+	{"per person per night", rule(KindPerPersonNight, 150, date(2021, time.January, 1), date(2021, time.December, 31)), 900},
+	{"per stay", rule(KindPerStay, 5000, date(2021, time.January, 1), date(2021, time.December, 31)), 5000},
+	{"per night from the second night", rule(KindPerNight, 200, date(2021, time.July, 11), date(2021, time.December, 31)), 400},
+	{"per night up to the departure date", rule(KindPerNight, 200, date(2021, time.January, 1), date(2021, time.July, 13)), 600},
+	{"per stay not covering the arrival", rule(KindPerStay, 5000, date(2021, time.July, 11), date(2021, time.December, 31)), 0},
+	{"rule after the stay", rule(KindPerNight, 200, date(2021, time.August, 1), date(2021, time.August, 31)), 0},
+	{"unknown kind", rule("per_pet", 200, date(2021, time.January, 1), date(2021, time.December, 31)), 0},
+}
+
+func TestCalculate(t *testing.T) {
+	for _, e := range calculateTests {
+		q := Calculate(10000, date(2021, time.July, 10), date(2021, time.July, 13), 2, 0, []models.TaxRule{e.rule})
+		if q.Nights != 3 || q.RoomCharge != 30000 {
+			t.Errorf("for %s, expected 3 nights costing 30000 but got %d costing %d", e.name, q.Nights, q.RoomCharge)
+		}
+		if e.expAmount == 0 {
+			if len(q.Lines) != 0 {
+				t.Errorf("for %s, expected no line but got %+v", e.name, q.Lines)
+			}
+			continue
+		}
+		if len(q.Lines) != 1 || q.Lines[0].Amount != e.expAmount {
+			t.Errorf("for %s, expected one line of %d but got %+v", e.name, e.expAmount, q.Lines)
+			continue
+		}
+		if q.Total != q.RoomCharge+e.expAmount {
+			t.Errorf("for %s, expected total of %d but got %d", e.name, q.RoomCharge+e.expAmount, q.Total)
+		}
+	}
+}
+
+func TestCalculate_RateChange(t *testing.T) {
+	taxRules := []models.TaxRule{
+		{ID: 1, Name: "VAT", Kind: string(KindPercent), Amount: 1000, StartDate: date(2021, time.January, 1), EndDate: date(2021, time.June, 30)},
+		{ID: 2, Name: "VAT", Kind: string(KindPercent), Amount: 2000, StartDate: date(2021, time.July, 1), EndDate: date(2021, time.December, 31)},
+	}
+	q := Calculate(10000, date(2021, time.June, 29), date(2021, time.July, 2), 1, 0, taxRules)
+	if len(q.Lines) != 2 {
+		t.Fatalf("expected a line for each rate but got %+v", q.Lines)
+	}
+	if q.Lines[0].Amount != 2000 || q.Lines[1].Amount != 2000 {
+		t.Errorf("expected 2 nights at 10%% and 1 night at 20%% but got %+v", q.Lines)
+	}
+	if q.Total != 34000 {
+		t.Errorf("expected total of 34000 but got %d", q.Total)
+	}
+}
+
+func TestCalculate_Guests(t *testing.T) {
+	q := Calculate(10000, date(2021, time.July, 10), date(2021, time.July, 11), 0, 0,
+		[]models.TaxRule{rule(KindPerPersonNight, 150, date(2021, time.January, 1), date(2021, time.December, 31))})
+	if q.Guests != 1 || q.Total != 10150 {
+		t.Errorf("expected a stay to have at least one guest but got %d guests and a total of %d", q.Guests, q.Total)
+	}
+}
+
+func TestCalculate_Discount(t *testing.T) {
+	taxRules := []models.TaxRule{
+		rule(KindPercent, 1000, date(2021, time.January, 1), date(2021, time.December, 31)),
+		rule(KindPerNight, 200, date(2021, time.January, 1), date(2021, time.December, 31)),
+	}
+	q := Calculate(10000, date(2021, time.July, 10), date(2021, time.July, 13), 1, 3000, taxRules)
+	if q.Discount != 3000 {
+		t.Errorf("expected a discount of 3000 but got %d", q.Discount)
+	}
+	if q.Lines[0].Amount != 2700 || q.Lines[1].Amount != 600 {
+		t.Errorf("expected 10%% of the discounted room charge and the per night fee in full but got %+v", q.Lines)
+	}
+	if q.Total != 30000-3000+2700+600 {
+		t.Errorf("expected total of 30300 but got %d", q.Total)
+	}
+
+	q = Calculate(10000, date(2021, time.July, 10), date(2021, time.July, 11), 1, 50000, nil)
+	if q.Discount != 10000 || q.Total != 0 {
+		t.Errorf("expected the discount to be limited to the room charge but got %d and a total of %d", q.Discount, q.Total)
+	}
+}
+
+func TestQuote_AddExtra(t *testing.T) {
+	q := Calculate(10000, date(2021, time.July, 10), date(2021, time.July, 13), 2, 0, nil)
+	q.AddExtra(models.Extra{ID: 1, Name: "Parking", Unit: string(ExtraPerNight), Price: 1500}, 1)
+	q.AddExtra(models.Extra{ID: 2, Name: "Breakfast", Unit: string(ExtraPerGuest), Price: 1200}, 1)
+	q.AddExtra(models.Extra{ID: 3, Name: "Late checkout", Unit: string(ExtraPerStay), Price: 2000}, 1)
+	q.AddExtra(models.Extra{ID: 4, Name: "Cot", Unit: string(ExtraPerStay), Price: 1000}, 0)
+
+	if len(q.Extras) != 3 {
+		t.Fatalf("expected the 3 extras booked but got %+v", q.Extras)
+	}
+	for i, exp := range []int{4500, 2400, 2000} {
+		if q.Extras[i].Amount != exp {
+			t.Errorf("expected %s to cost %d but got %d", q.Extras[i].Name, exp, q.Extras[i].Amount)
+		}
+	}
+	if q.Total != 30000+4500+2400+2000 {
+		t.Errorf("expected total of 38900 but got %d", q.Total)
+	}
+}
+
+func TestQuote_Taxes(t *testing.T) {
+	q := Quote{Lines: []Line{{RuleID: 3, Name: "City tax", Kind: KindPerPersonNight, Amount: 600}}}
+	taxes := q.Taxes()
+	if len(taxes) != 1 || taxes[0].TaxRuleID != 3 || taxes[0].Name != "City tax" ||
+		taxes[0].Kind != string(KindPerPersonNight) || taxes[0].Amount != 600 {
+		t.Errorf("expected the line as a reservation tax but got %+v", taxes)
+	}
+}
+
+func TestValidExtraUnit(t *testing.T) {
+	for _, u := range ExtraUnits {
+		if !ValidExtraUnit(string(u)) {
+			t.Errorf("expected %s to be valid", u)
+		}
+	}
+	if ValidExtraUnit("per_hour") {
+		t.Error("expected per_hour to be invalid")
+	}
+}
+
+func TestValidKind(t *testing.T) {
+	for _, k := range Kinds {
+		if !ValidKind(string(k)) {
+			t.Errorf("expected %s to be valid", k)
+		}
+	}
+	if ValidKind("per_pet") {
+		t.Error("expected per_pet to be invalid")
+	}
+}
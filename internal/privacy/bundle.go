@@ -0,0 +1,284 @@
+// Package privacy writes the data export sent to a guest asking for everything kept about them
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"io"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// dateLayout is how dates are written in the export
+const dateLayout = "2006-01-02"
+
+// unsafeFileChars are the characters replaced in the names of the files of a bundle
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// Export is the data.json file of a bundle. Amounts are decimals in their currency, reservation amounts being in the
+// base currency.
+type Export struct {
+	Email           string          `json:"email"`
+	OtherEmails     []string        `json:"other_emails"`
+	ExportedAt      time.Time       `json:"exported_at"`
+	Guests          []guest         `json:"guest_profiles"`
+	Reservations    []reservation   `json:"reservations"`
+	WaitlistEntries []waitlistEntry `json:"waitlist_entries"`
+	SentEmails      []sentEmail     `json:"emails"`
+	FailedImports   []failedImport  `json:"failed_imports"`
+}
+
+type guest struct {
+	FirstName   string    `json:"first_name"`
+	LastName    string    `json:"last_name"`
+	Email       string    `json:"email"`
+	Phone       string    `json:"phone"`
+	Notes       string    `json:"notes"`
+	Tags        []string  `json:"tags"`
+	Preferences string    `json:"preferences"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type reservation struct {
+	ID         int          `json:"id"`
+	FirstName  string       `json:"first_name"`
+	LastName   string       `json:"last_name"`
+	Email      string       `json:"email"`
+	Phone      string       `json:"phone"`
+	Room       string       `json:"room"`
+	CheckIn    string       `json:"check_in"`
+	CheckOut   string       `json:"check_out"`
+	Guests     int          `json:"guests"`
+	RoomAmount string       `json:"room_amount"`
+	Discount   string       `json:"discount"`
+	Total      string       `json:"total"`
+	Currency   string       `json:"currency"`
+	BookedAt   time.Time    `json:"booked_at"`
+	Charges    []charge     `json:"charges"`
+	Payments   []payment    `json:"payments"`
+	Invoices   []invoiceRef `json:"invoices"`
+}
+
+type charge struct {
+	Kind        string    `json:"kind"`
+	Description string    `json:"description"`
+	Quantity    int       `json:"quantity"`
+	UnitAmount  string    `json:"unit_amount"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type payment struct {
+	Amount    string    `json:"amount"`
+	Currency  string    `json:"currency"`
+	Status    string    `json:"status"`
+	Captured  string    `json:"captured"`
+	Refunded  string    `json:"refunded"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type invoiceRef struct {
+	Number   string    `json:"number"`
+	Total    string    `json:"total"`
+	Currency string    `json:"currency"`
+	File     string    `json:"file,omitempty"`
+	IssuedAt time.Time `json:"issued_at"`
+}
+
+type waitlistEntry struct {
+	FirstName string    `json:"first_name"`
+	LastName  string    `json:"last_name"`
+	Email     string    `json:"email"`
+	Phone     string    `json:"phone"`
+	Room      string    `json:"room"`
+	StartDate string    `json:"start_date"`
+	EndDate   string    `json:"end_date"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type sentEmail struct {
+	Recipient string    `json:"recipient"`
+	Subject   string    `json:"subject"`
+	Content   string    `json:"content"`
+	Status    string    `json:"status"`
+	SentAt    time.Time `json:"sent_at"`
+}
+
+// failedImport is the rows mentioning the guest in the file of the rows of an import which failed
+type failedImport struct {
+	File       string     `json:"file"`
+	ImportedAt time.Time  `json:"imported_at"`
+	Columns    []string   `json:"columns"`
+	Rows       [][]string `json:"rows"`
+}
+
+// NewExport makes the data.json file of the data of a guest, leaving out payment references and waitlist tokens
+func NewExport(data models.GuestData, base string, now time.Time) Export {
+	e := Export{
+		Email:           data.Email,
+		OtherEmails:     []string{},
+		ExportedAt:      now,
+		Guests:          []guest{},
+		Reservations:    []reservation{},
+		WaitlistEntries: []waitlistEntry{},
+		SentEmails:      []sentEmail{},
+		FailedImports:   []failedImport{},
+	}
+	for _, email := range data.Emails {
+		if email != data.Email {
+			e.OtherEmails = append(e.OtherEmails, email)
+		}
+	}
+	for _, g := range data.Guests {
+		tags := g.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		e.Guests = append(e.Guests, guest{FirstName: g.FirstName, LastName: g.LastName, Email: g.Email,
+			Phone: g.Phone, Notes: g.Notes, Tags: tags, Preferences: g.Preferences, CreatedAt: g.CreatedAt})
+	}
+
+	for _, rs := range data.Reservations {
+		r := reservation{
+			ID:         rs.ID,
+			FirstName:  rs.FirstName,
+			LastName:   rs.LastName,
+			Email:      rs.Email,
+			Phone:      rs.Phone,
+			Room:       rs.Room.RoomName,
+			CheckIn:    rs.CheckInDate.Format(dateLayout),
+			CheckOut:   rs.CheckOutDate.Format(dateLayout),
+			Guests:     rs.Guests,
+			RoomAmount: currency.Decimal(rs.RoomAmount, base),
+			Discount:   currency.Decimal(rs.Discount, base),
+			Total:      currency.Decimal(rs.TotalAmount, base),
+			Currency:   base,
+			BookedAt:   rs.CreatedAt,
+			Charges:    []charge{},
+			Payments:   []payment{},
+			Invoices:   []invoiceRef{},
+		}
+		for _, item := range data.FolioItems {
+			if item.ReservationID == rs.ID {
+				r.Charges = append(r.Charges, charge{Kind: item.Kind, Description: item.Description,
+					Quantity: item.Quantity, UnitAmount: currency.Decimal(item.UnitAmount, base),
+					CreatedAt: item.CreatedAt})
+			}
+		}
+		for _, p := range data.Payments {
+			if p.ReservationID == rs.ID {
+				r.Payments = append(r.Payments, payment{Amount: currency.Decimal(p.Amount, p.Currency),
+					Currency: p.Currency, Status: p.Status, Captured: currency.Decimal(p.Captured, p.Currency),
+					Refunded: currency.Decimal(p.Refunded, p.Currency), CreatedAt: p.CreatedAt})
+			}
+		}
+		for _, inv := range data.Invoices {
+			if inv.ReservationID == rs.ID {
+				ref := invoiceRef{Number: inv.Number, Total: currency.Decimal(inv.Total, inv.Currency),
+					Currency: inv.Currency, IssuedAt: inv.CreatedAt}
+				if len(inv.PDF) > 0 {
+					ref.File = invoiceFile(inv)
+				}
+				r.Invoices = append(r.Invoices, ref)
+			}
+		}
+		e.Reservations = append(e.Reservations, r)
+	}
+
+	for _, w := range data.WaitlistEntries {
+		e.WaitlistEntries = append(e.WaitlistEntries, waitlistEntry{FirstName: w.FirstName, LastName: w.LastName,
+			Email: w.Email, Phone: w.Phone, Room: w.Room.RoomName, StartDate: w.StartDate.Format(dateLayout),
+			EndDate: w.EndDate.Format(dateLayout), Status: w.Status, CreatedAt: w.CreatedAt})
+	}
+	for _, m := range data.SentEmails {
+		e.SentEmails = append(e.SentEmails, sentEmail{Recipient: m.Recipient, Subject: m.Subject, Content: m.Content,
+			Status: m.Status, SentAt: m.CreatedAt})
+	}
+	for _, run := range data.ImportRuns {
+		if f := failedRows(run, data.Emails); len(f.Rows) > 0 {
+			e.FailedImports = append(e.FailedImports, f)
+		}
+	}
+	return e
+}
+
+// failedRows returns the rows which failed in an import with a cell holding any of the email addresses
+func failedRows(run models.ImportRun, emails []string) failedImport {
+	f := failedImport{File: run.FileName, ImportedAt: run.CreatedAt}
+	r := csv.NewReader(bytes.NewReader(run.Errors))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, _ := r.ReadAll()
+	for i, record := range records {
+		if i == 0 {
+			f.Columns = record
+			continue
+		}
+		if mentions(record, emails) {
+			f.Rows = append(f.Rows, record)
+		}
+	}
+	return f
+}
+
+// mentions returns true if a cell of the record holds any of the email addresses
+func mentions(record []string, emails []string) bool {
+	for _, cell := range record {
+		cell = strings.ToLower(cell)
+		for _, email := range emails {
+			if strings.Contains(cell, email) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WriteJSON writes the data.json file of the data of a guest
+func WriteJSON(w io.Writer, data models.GuestData, base string, now time.Time) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(NewExport(data, base, now))
+}
+
+// WriteBundle writes a zip file holding the data.json file of the data of a guest and the PDF of each of their
+// invoices
+func WriteBundle(w io.Writer, data models.GuestData, base string, now time.Time) error {
+	zw := zip.NewWriter(w)
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: "data.json", Method: zip.Deflate, Modified: now})
+	if err != nil {
+		return err
+	}
+	if err = WriteJSON(f, data, base, now); err != nil {
+		return err
+	}
+	for _, inv := range data.Invoices {
+		if len(inv.PDF) == 0 {
+			continue
+		}
+		f, err = zw.CreateHeader(&zip.FileHeader{Name: invoiceFile(inv), Method: zip.Deflate, Modified: inv.CreatedAt})
+		if err != nil {
+			return err
+		}
+		if _, err = f.Write(inv.PDF); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// invoiceFile returns the name of the PDF of an invoice in a bundle
+func invoiceFile(inv models.Invoice) string {
+	name := unsafeFileChars.ReplaceAllString(inv.Number, "_")
+	if name == "" {
+		name = fmt.Sprintf("invoice-%d", inv.ID)
+	}
+	return path.Join("invoices", name+".pdf")
+}
@@ -0,0 +1,97 @@
+package privacy
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+var testData = models.GuestData{
+	Email:  "john@smith.com",
+	Emails: []string{"john@smith.com", "jsmith@work.com"},
+	Guests: []models.Guest{{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com"}},
+	Reservations: []models.Reservation{{ID: 7, FirstName: "John", LastName: "Smith", Email: "jsmith@work.com",
+		CheckInDate:  time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC),
+		CheckOutDate: time.Date(2026, 7, 3, 0, 0, 0, 0, time.UTC),
+		TotalAmount:  20050, Room: models.Room{RoomName: "Major's Suite"}}},
+	FolioItems: []models.FolioItem{{ReservationID: 7, Kind: "extra", Description: "Breakfast", Quantity: 2,
+		UnitAmount: 1500}},
+	Payments: []models.Payment{{ReservationID: 7, Reference: "fake_secret", Amount: 6000, Currency: "USD",
+		Status: "captured", Captured: 6000}},
+	Invoices: []models.Invoice{{ReservationID: 7, Number: "INV/000001", Total: 20050, Currency: "USD",
+		PDF: []byte("%PDF-1.4")}},
+	WaitlistEntries: []models.WaitlistEntry{{Email: "john@smith.com", Token: "waitlist_secret"}},
+	ImportRuns: []models.ImportRun{{FileName: "old.csv", Errors: []byte("First Name,Email,Line,Error\n" +
+		"John,JSmith@work.com,2,Invalid room\nJane,jane@smith.com,3,Invalid room\n")}},
+}
+
+func TestNewExport(t *testing.T) {
+	e := NewExport(testData, "USD", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	if len(e.OtherEmails) != 1 || e.OtherEmails[0] != "jsmith@work.com" {
+		t.Errorf("unexpected other emails %v", e.OtherEmails)
+	}
+	if len(e.Reservations) != 1 {
+		t.Fatalf("expected 1 reservation but got %d", len(e.Reservations))
+	}
+	r := e.Reservations[0]
+	if r.Total != "200.50" || r.CheckIn != "2026-07-01" || r.Room != "Major's Suite" {
+		t.Errorf("unexpected reservation %+v", r)
+	}
+	if len(r.Charges) != 1 || len(r.Payments) != 1 || r.Payments[0].Amount != "60.00" {
+		t.Errorf("unexpected charges %+v or payments %+v", r.Charges, r.Payments)
+	}
+	if len(r.Invoices) != 1 || r.Invoices[0].File != "invoices/INV_000001.pdf" {
+		t.Errorf("unexpected invoices %+v", r.Invoices)
+	}
+	if len(e.SentEmails) != 0 || e.SentEmails == nil {
+		t.Errorf("expected an empty list of emails but got %v", e.SentEmails)
+	}
+	if len(e.FailedImports) != 1 || len(e.FailedImports[0].Rows) != 1 || e.FailedImports[0].Rows[0][0] != "John" {
+		t.Errorf("expected the failed import row of the guest only but got %+v", e.FailedImports)
+	}
+
+	b, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, secret := range []string{"fake_secret", "waitlist_secret"} {
+		if bytes.Contains(b, []byte(secret)) {
+			t.Errorf("expected the export not to include %q", secret)
+		}
+	}
+}
+
+func TestWriteBundle(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteBundle(&buf, testData, "USD", time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[f.Name], _ = ioutil.ReadAll(rc)
+		rc.Close()
+	}
+	if len(files) != 2 {
+		t.Errorf("expected 2 files but got %d", len(files))
+	}
+	var e Export
+	if err = json.Unmarshal(files["data.json"], &e); err != nil || e.Email != "john@smith.com" {
+		t.Errorf("unexpected data.json %s: %v", files["data.json"], err)
+	}
+	if string(files["invoices/INV_000001.pdf"]) != "%PDF-1.4" {
+		t.Errorf("unexpected invoice file %q", files["invoices/INV_000001.pdf"])
+	}
+}
@@ -0,0 +1,98 @@
+package promo
+
+import (
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"strings"
+	"time"
+)
+
+// Kind is how a promo code discounts a stay
+type Kind string
+
+const (
+	// KindPercent takes a percentage off the room charge. The amount of the code is in basis points, so 1000 is 10%.
+	KindPercent Kind = "percent"
+	// KindFixed takes the amount of the code, in minor units of the base currency, off the room charge
+	KindFixed Kind = "fixed"
+)
+
+// Kinds are the kinds of promo code, in the order they are offered to staff
+var Kinds = []Kind{KindPercent, KindFixed}
+
+// ValidKind reports whether kind is a kind of promo code
+func ValidKind(kind string) bool {
+	for _, k := range Kinds {
+		if string(k) == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Normalize returns a code the way it is stored, so guests can type it in any case
+func Normalize(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// Check evaluates a booking made today for the stay from start to end in a room against the conditions of the
+// promo code, and returns the reason the code cannot be used, or an empty message if it can. Dates of the code are
+// inclusive, and a zero stay date leaves that end of the stay window open.
+func Check(code models.PromoCode, roomID int, start, end, today time.Time) i18n.Message {
+	start = truncate(start)
+	end = truncate(end)
+	today = truncate(today)
+
+	if today.Before(truncate(code.ValidFrom)) || today.After(truncate(code.ValidTo)) {
+		return i18n.NewMessage("promo.not_valid_today")
+	}
+	if (!code.StayStart.IsZero() && start.Before(truncate(code.StayStart))) ||
+		(!code.StayEnd.IsZero() && end.After(truncate(code.StayEnd).AddDate(0, 0, 1))) {
+		if code.StayStart.IsZero() {
+			return i18n.NewMessage("promo.stay_until", code.StayEnd)
+		}
+		if code.StayEnd.IsZero() {
+			return i18n.NewMessage("promo.stay_from", code.StayStart)
+		}
+		return i18n.NewMessage("promo.stay_dates", code.StayStart, code.StayEnd)
+	}
+	if nights := int(end.Sub(start).Hours() / 24); code.MinNights > 0 && nights < code.MinNights {
+		return i18n.NewMessage("promo.min_nights", code.MinNights)
+	}
+	if len(code.RoomIDs) > 0 {
+		found := false
+		for _, id := range code.RoomIDs {
+			if id == roomID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return i18n.NewMessage("promo.room")
+		}
+	}
+	if code.MaxUses > 0 && code.Uses >= code.MaxUses {
+		return i18n.NewMessage("promo.used_up")
+	}
+	return i18n.Message{}
+}
+
+// Discount returns the amount the promo code takes off a room charge, which is never more than the room charge
+func Discount(code models.PromoCode, roomCharge int) int {
+	discount := 0
+	switch Kind(code.Kind) {
+	case KindPercent:
+		discount = (roomCharge*code.Amount + 5000) / 10000
+	case KindFixed:
+		discount = code.Amount
+	}
+	if discount > roomCharge {
+		return roomCharge
+	}
+	return discount
+}
+
+func truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
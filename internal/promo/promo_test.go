@@ -0,0 +1,88 @@
+package promo
+
+import (
+	"github.com/sunil206b/smart_booking/internal/models"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+var today = date(2021, time.July, 1)
+
+func code() models.PromoCode {
+	return models.PromoCode{
+		Code:      "SUMMER10",
+		Kind:      string(KindPercent),
+		Amount:    1000,
+		ValidFrom: date(2021, time.June, 1),
+		ValidTo:   date(2021, time.July, 31),
+	}
+}
+
+var checkTests = []struct {
+	name      string
+	change    func(c *models.PromoCode)
+	expReason string
+}{
+	{"no conditions", func(c *models.PromoCode) {}, ""},
+	{"not valid yet", func(c *models.PromoCode) { c.ValidFrom = date(2021, time.July, 2) }, "promo.not_valid_today"},
+	{"expired", func(c *models.PromoCode) { c.ValidTo = date(2021, time.June, 30) }, "promo.not_valid_today"},
+	{"last day of validity", func(c *models.PromoCode) { c.ValidTo = today }, ""},
+	{"stay within window", func(c *models.PromoCode) {
+		c.StayStart = date(2021, time.August, 10)
+		c.StayEnd = date(2021, time.August, 12)
+	}, ""},
+	{"stay starts before window", func(c *models.PromoCode) {
+		c.StayStart = date(2021, time.August, 11)
+		c.StayEnd = date(2021, time.August, 31)
+	}, "promo.stay_dates"},
+	{"stay ends after window", func(c *models.PromoCode) { c.StayEnd = date(2021, time.August, 11) }, "promo.stay_until"},
+	{"stay starts before open ended window", func(c *models.PromoCode) { c.StayStart = date(2021, time.August, 11) }, "promo.stay_from"},
+	{"min nights met", func(c *models.PromoCode) { c.MinNights = 3 }, ""},
+	{"min nights not met", func(c *models.PromoCode) { c.MinNights = 4 }, "promo.min_nights"},
+	{"room included", func(c *models.PromoCode) { c.RoomIDs = []int{2, 1} }, ""},
+	{"room excluded", func(c *models.PromoCode) { c.RoomIDs = []int{2} }, "promo.room"},
+	{"uses left", func(c *models.PromoCode) { c.MaxUses, c.Uses = 10, 9 }, ""},
+	{"used up", func(c *models.PromoCode) { c.MaxUses, c.Uses = 10, 10 }, "promo.used_up"},
+}
+
+func TestCheck(t *testing.T) {
+	for _, e := range checkTests {
+		c := code()
+		e.change(&c)
+		reason := Check(c, 1, date(2021, time.August, 10), date(2021, time.August, 13), today)
+		if reason.Key != e.expReason {
+			t.Errorf("for %s, expected %q but got %q", e.name, e.expReason, reason.Key)
+		}
+	}
+}
+
+func TestDiscount(t *testing.T) {
+	tests := []struct {
+		name        string
+		kind        Kind
+		amount      int
+		expDiscount int
+	}{
+		{"percent", KindPercent, 1000, 3000},
+		{"percent rounds half up", KindPercent, 5, 15},
+		{"fixed", KindFixed, 2500, 2500},
+		{"fixed above the room charge", KindFixed, 50000, 30000},
+		{"unknown kind", "free_night", 1, 0},
+	}
+	for _, e := range tests {
+		c := models.PromoCode{Kind: string(e.kind), Amount: e.amount}
+		if got := Discount(c, 30000); got != e.expDiscount {
+			t.Errorf("for %s, expected %d but got %d", e.name, e.expDiscount, got)
+		}
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if got := Normalize(" summer10 "); got != "SUMMER10" {
+		t.Errorf("expected SUMMER10 but got %q", got)
+	}
+}
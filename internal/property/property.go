@@ -0,0 +1,176 @@
+// Package property keeps the hotels run from the site and picks the one a request is for
+package property
+
+import (
+	"context"
+	"fmt"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	// CookieName is the name of the cookie holding the slug of the property chosen by the visitor
+	CookieName = "property"
+	// pathPrefix starts the paths of the public site of a property, followed by its slug
+	pathPrefix   = "/p/"
+	cookieMaxAge = 365 * 24 * 60 * 60
+)
+
+type contextKey string
+
+const (
+	propertyKey contextKey = "property"
+	staffKey    contextKey = "staff"
+)
+
+var (
+	slugPattern  = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*$`)
+	colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+)
+
+// Directory holds the properties run from the site. It is safe for concurrent use.
+type Directory struct {
+	mu         sync.RWMutex
+	properties []models.Property
+}
+
+// NewDirectory creates a directory with no properties
+func NewDirectory() *Directory {
+	return &Directory{}
+}
+
+// Set replaces the properties of the directory
+func (d *Directory) Set(properties []models.Property) {
+	ps := make([]models.Property, len(properties))
+	copy(ps, properties)
+	d.mu.Lock()
+	d.properties = ps
+	d.mu.Unlock()
+}
+
+// All returns the properties of the directory
+func (d *Directory) All() []models.Property {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	ps := make([]models.Property, len(d.properties))
+	copy(ps, d.properties)
+	return ps
+}
+
+// ByID returns the property with the id
+func (d *Directory) ByID(id int) (models.Property, bool) {
+	return d.find(func(p models.Property) bool { return p.ID == id })
+}
+
+// BySlug returns the property with the slug
+func (d *Directory) BySlug(slug string) (models.Property, bool) {
+	return d.find(func(p models.Property) bool { return p.Slug == slug })
+}
+
+// ByHost returns the property served on the host of a request, ignoring its port
+func (d *Directory) ByHost(host string) (models.Property, bool) {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if host == "" {
+		return models.Property{}, false
+	}
+	return d.find(func(p models.Property) bool { return p.Hostname != "" && strings.EqualFold(p.Hostname, host) })
+}
+
+func (d *Directory) find(match func(p models.Property) bool) (models.Property, bool) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+	for _, p := range d.properties {
+		if match(p) {
+			return p, true
+		}
+	}
+	return models.Property{}, false
+}
+
+// SplitPath splits a path such as /p/fort-smythe/search-availability into the slug of a property and the rest of
+// the path, returning an empty slug and the path unchanged if it does not start with a property
+func SplitPath(path string) (string, string) {
+	if !strings.HasPrefix(path, pathPrefix) {
+		return "", path
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, pathPrefix), "/", 2)
+	if parts[0] == "" {
+		return "", path
+	}
+	if len(parts) == 1 {
+		return parts[0], "/"
+	}
+	return parts[0], "/" + parts[1]
+}
+
+// Path returns the path of the public site of the property with the slug
+func Path(slug, path string) string {
+	return pathPrefix + slug + path
+}
+
+// Cookie returns the cookie remembering the property chosen by the visitor, or removing it if slug is empty
+func Cookie(slug string, secure bool) *http.Cookie {
+	c := &http.Cookie{
+		Name:     CookieName,
+		Value:    slug,
+		Path:     "/",
+		MaxAge:   cookieMaxAge,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if slug == "" {
+		c.MaxAge = -1
+	}
+	return c
+}
+
+// WithProperty returns a copy of the context holding the property
+func WithProperty(ctx context.Context, p models.Property) context.Context {
+	return context.WithValue(ctx, propertyKey, p)
+}
+
+// FromContext returns the property held by the context, if there is one
+func FromContext(ctx context.Context) (models.Property, bool) {
+	p, ok := ctx.Value(propertyKey).(models.Property)
+	return p, ok
+}
+
+// WithStaff returns a copy of the context holding the id of the property the signed-in user works at, 0 if they work
+// at all of them
+func WithStaff(ctx context.Context, propertyID int) context.Context {
+	return context.WithValue(ctx, staffKey, propertyID)
+}
+
+// StaffFromContext returns the id of the property the signed-in user works at, or 0 if they work at all of them
+func StaffFromContext(ctx context.Context) int {
+	id, _ := ctx.Value(staffKey).(int)
+	return id
+}
+
+// ValidSlug returns true if the slug is lower case letters and digits, with single hyphens between them
+func ValidSlug(slug string) bool {
+	return slugPattern.MatchString(slug)
+}
+
+// ValidColor returns true if the color is written as #rrggbb
+func ValidColor(color string) bool {
+	return colorPattern.MatchString(color)
+}
+
+// Sender returns the from address of the emails of a property, or def if the property has no address
+func Sender(p models.Property, def string) string {
+	if p.EmailFrom == "" {
+		return def
+	}
+	if p.EmailFromName == "" {
+		return p.EmailFrom
+	}
+	return fmt.Sprintf("%s <%s>", p.EmailFromName, p.EmailFrom)
+}
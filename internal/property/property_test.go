@@ -0,0 +1,112 @@
+package property
+
+import (
+	"context"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"testing"
+)
+
+var testProperties = []models.Property{
+	{ID: 1, Slug: "fort-smythe", Name: "Fort Smythe", Hostname: "fortsmythe.com"},
+	{ID: 2, Slug: "harbour-view", Name: "Harbour View"},
+}
+
+func TestDirectory(t *testing.T) {
+	d := NewDirectory()
+	if _, ok := d.ByID(1); ok {
+		t.Error("expected an empty directory to have no properties")
+	}
+	d.Set(testProperties)
+	if len(d.All()) != 2 {
+		t.Fatalf("expected 2 properties but got %d", len(d.All()))
+	}
+	if p, ok := d.ByID(2); !ok || p.Slug != "harbour-view" {
+		t.Errorf("expected property 2 to be harbour-view but got %q", p.Slug)
+	}
+	if p, ok := d.BySlug("fort-smythe"); !ok || p.ID != 1 {
+		t.Errorf("expected fort-smythe to be property 1 but got %d", p.ID)
+	}
+	if _, ok := d.BySlug("missing"); ok {
+		t.Error("expected no property for an unknown slug")
+	}
+}
+
+func TestDirectory_ByHost(t *testing.T) {
+	d := NewDirectory()
+	d.Set(testProperties)
+	tests := []struct {
+		host  string
+		expID int
+	}{
+		{"fortsmythe.com", 1},
+		{"FortSmythe.com:8080", 1},
+		{"localhost", 0},
+		{"", 0},
+	}
+	for _, e := range tests {
+		p, _ := d.ByHost(e.host)
+		if p.ID != e.expID {
+			t.Errorf("for %q, expected property %d but got %d", e.host, e.expID, p.ID)
+		}
+	}
+}
+
+func TestSplitPath(t *testing.T) {
+	tests := []struct {
+		path    string
+		expSlug string
+		expPath string
+	}{
+		{"/p/harbour-view/search-availability", "harbour-view", "/search-availability"},
+		{"/p/harbour-view", "harbour-view", "/"},
+		{"/p/", "", "/p/"},
+		{"/search-availability", "", "/search-availability"},
+		{"/payments/webhook", "", "/payments/webhook"},
+	}
+	for _, e := range tests {
+		slug, path := SplitPath(e.path)
+		if slug != e.expSlug || path != e.expPath {
+			t.Errorf("for %s, expected %q and %q but got %q and %q", e.path, e.expSlug, e.expPath, slug, path)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("expected no property in an empty context")
+	}
+	p, ok := FromContext(WithProperty(context.Background(), testProperties[1]))
+	if !ok || p.ID != 2 {
+		t.Errorf("expected property 2 but got %d", p.ID)
+	}
+}
+
+func TestValidSlugAndColor(t *testing.T) {
+	for slug, exp := range map[string]bool{"harbour-view": true, "hv2": true, "Harbour": false, "-a": false,
+		"a--b": false, "": false} {
+		if ValidSlug(slug) != exp {
+			t.Errorf("expected ValidSlug(%q) to be %t", slug, exp)
+		}
+	}
+	for color, exp := range map[string]bool{"#1a2B3c": true, "#123": false, "123456": false} {
+		if ValidColor(color) != exp {
+			t.Errorf("expected ValidColor(%q) to be %t", color, exp)
+		}
+	}
+}
+
+func TestSender(t *testing.T) {
+	tests := []struct {
+		p   models.Property
+		exp string
+	}{
+		{models.Property{}, "me@here.com"},
+		{models.Property{EmailFrom: "stay@harbour.com"}, "stay@harbour.com"},
+		{models.Property{EmailFrom: "stay@harbour.com", EmailFromName: "Harbour View"}, "Harbour View <stay@harbour.com>"},
+	}
+	for _, e := range tests {
+		if got := Sender(e.p, "me@here.com"); got != e.exp {
+			t.Errorf("expected %q but got %q", e.exp, got)
+		}
+	}
+}
@@ -6,11 +6,16 @@ import (
 	"fmt"
 	"github.com/justinas/nosurf"
 	"github.com/sunil206b/smart_booking/internal/config"
+	"github.com/sunil206b/smart_booking/internal/currency"
+	"github.com/sunil206b/smart_booking/internal/helpers"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/property"
 	"html/template"
 	"log"
 	"net/http"
 	"path/filepath"
+	"strconv"
 	"time"
 )
 
@@ -19,10 +24,16 @@ const (
 )
 
 var functions = template.FuncMap{
-	"humanDate":  HumanDate,
-	"formatDate": FormatDate,
-	"iterate":    Iterate,
-	"add":        Add,
+	"humanDate":        HumanDate,
+	"formatDate":       FormatDate,
+	"iterate":          Iterate,
+	"add":              Add,
+	"t":                i18n.T,
+	"tm":               i18n.TMessage,
+	"localDate":        i18n.FormatDate,
+	"datePickerFormat": i18n.DatePickerFormat,
+	"money":            currency.Format,
+	"percent":          Percent,
 }
 
 var appConfig *config.AppConfig
@@ -97,6 +108,23 @@ func AddDefaultData(data *models.TemplateData, r *http.Request) {
 	if appConfig.Session.Exists(r.Context(), "user_id") {
 		data.IsAuthenticated = true
 	}
+	data.Locale = i18n.FromContext(r.Context())
+	data.Locales = i18n.Locales()
+	if appConfig.Currency != nil {
+		data.Converter = appConfig.Currency
+		data.BaseCurrency = appConfig.Currency.Base()
+		data.Currencies = appConfig.Currency.Codes()
+		data.Currency = helpers.Currency(r)
+	}
+	data.Property, _ = property.FromContext(r.Context())
+	if appConfig.Properties != nil {
+		data.Properties = appConfig.Properties.All()
+	}
+	data.StaffPropertyID = property.StaffFromContext(r.Context())
+	data.AdminPropertyID = data.StaffPropertyID
+	if data.AdminPropertyID == 0 {
+		data.AdminPropertyID = appConfig.Session.GetInt(r.Context(), "property_id")
+	}
 }
 
 //HumanDate returns date in human readable for mm/dd/yyyy
@@ -123,3 +151,8 @@ func Iterate(count int) []int {
 func Add(a, b int) int {
 	return a + b
 }
+
+// Percent formats an amount in basis points as a percentage, such as 7.5%
+func Percent(basisPoints int) string {
+	return strconv.FormatFloat(float64(basisPoints)/100, 'f', -1, 64) + "%"
+}
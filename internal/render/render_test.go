@@ -70,3 +70,11 @@ func TestCreateTemplateCache(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestPercent(t *testing.T) {
+	for bp, exp := range map[int]string{750: "7.5%", 2000: "20%", 5: "0.05%", 0: "0%"} {
+		if got := Percent(bp); got != exp {
+			t.Errorf("for %d basis points, expected %s but got %s", bp, exp, got)
+		}
+	}
+}
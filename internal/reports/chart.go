@@ -0,0 +1,60 @@
+package reports
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"strings"
+)
+
+// chart dimensions in pixels
+const (
+	chartHeight  = 180
+	chartBarGap  = 8
+	chartBarMax  = 60
+	chartLabelsY = 20
+)
+
+// Bar is a bar of a chart, with the text shown over it
+type Bar struct {
+	Label string
+	Value int
+	Text  string
+}
+
+// BarChart draws the bars as an SVG bar chart scaled to the largest value, labelling each bar below and showing its
+// text above it
+func BarChart(title string, bars []Bar) template.HTML {
+	max := 0
+	for _, b := range bars {
+		if b.Value > max {
+			max = b.Value
+		}
+	}
+	width := len(bars)*(chartBarMax+chartBarGap) + chartBarGap
+	height := chartHeight + 2*chartLabelsY
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 %d %d" width="100%%" height="%d" role="img">`,
+		width, height, height)
+	fmt.Fprintf(&svg, `<title>%s</title>`, html.EscapeString(title))
+	for i, b := range bars {
+		h := 0
+		if max > 0 && b.Value > 0 {
+			h = b.Value * chartHeight / max
+			if h == 0 {
+				h = 1
+			}
+		}
+		x := chartBarGap + i*(chartBarMax+chartBarGap)
+		y := chartLabelsY + chartHeight - h
+		fmt.Fprintf(&svg, `<rect x="%d" y="%d" width="%d" height="%d" fill="#4b49ac"><title>%s: %s</title></rect>`,
+			x, y, chartBarMax, h, html.EscapeString(b.Label), html.EscapeString(b.Text))
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" font-size="11" text-anchor="middle">%s</text>`,
+			x+chartBarMax/2, y-4, html.EscapeString(b.Text))
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" font-size="11" text-anchor="middle">%s</text>`,
+			x+chartBarMax/2, chartLabelsY+chartHeight+14, html.EscapeString(b.Label))
+	}
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
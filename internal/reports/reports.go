@@ -0,0 +1,86 @@
+// Package reports works out the occupancy and revenue figures shown on the admin dashboard from the totals summed up
+// by the database, and draws them as simple charts
+package reports
+
+import (
+	"github.com/sunil206b/smart_booking/internal/models"
+	"time"
+)
+
+// Occupancy is the occupancy of a room, or of all rooms when RoomID is 0, for a month, with its occupancy rate in
+// basis points and its average daily rate (ADR) and revenue per available room night (RevPAR) in cents
+type Occupancy struct {
+	models.OccupancyStats
+	Rate   int
+	ADR    int
+	RevPAR int
+}
+
+// Cancellations are the cancellations of a month with the share of the reservations booked in the month they make
+// up, in basis points
+type Cancellations struct {
+	models.CancellationStats
+	Rate int
+}
+
+// ForOccupancy works out the rates of each room and month
+func ForOccupancy(stats []models.OccupancyStats) []Occupancy {
+	rows := make([]Occupancy, 0, len(stats))
+	for _, s := range stats {
+		rows = append(rows, occupancy(s))
+	}
+	return rows
+}
+
+// MonthTotals adds up the rooms of each month, in the order the months first appear
+func MonthTotals(stats []models.OccupancyStats) []Occupancy {
+	var months []time.Time
+	totals := make(map[time.Time]*models.OccupancyStats)
+	for _, s := range stats {
+		t, ok := totals[s.Month]
+		if !ok {
+			t = &models.OccupancyStats{RoomName: "All rooms", Month: s.Month}
+			totals[s.Month] = t
+			months = append(months, s.Month)
+		}
+		t.NightsAvailable += s.NightsAvailable
+		t.NightsSold += s.NightsSold
+		t.RoomRevenue += s.RoomRevenue
+	}
+	rows := make([]Occupancy, 0, len(months))
+	for _, m := range months {
+		rows = append(rows, occupancy(*totals[m]))
+	}
+	return rows
+}
+
+// ForCancellations works out the cancellation rate of each month
+func ForCancellations(stats []models.CancellationStats) []Cancellations {
+	rows := make([]Cancellations, 0, len(stats))
+	for _, s := range stats {
+		rows = append(rows, Cancellations{CancellationStats: s, Rate: Ratio(s.Cancelled, s.Booked)})
+	}
+	return rows
+}
+
+func occupancy(s models.OccupancyStats) Occupancy {
+	return Occupancy{
+		OccupancyStats: s,
+		Rate:           Ratio(s.NightsSold, s.NightsAvailable),
+		ADR:            divide(s.RoomRevenue, s.NightsSold),
+		RevPAR:         divide(s.RoomRevenue, s.NightsAvailable),
+	}
+}
+
+// Ratio returns part as a share of whole in basis points, rounded to the nearest, or 0 if whole is 0
+func Ratio(part, whole int) int {
+	return divide(part*10000, whole)
+}
+
+// divide divides amount by n rounding to the nearest, returning 0 if n is 0
+func divide(amount, n int) int {
+	if n == 0 {
+		return 0
+	}
+	return (amount + n/2) / n
+}
@@ -0,0 +1,72 @@
+package reports
+
+import (
+	"github.com/sunil206b/smart_booking/internal/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	july   = time.Date(2050, time.July, 1, 0, 0, 0, 0, time.UTC)
+	august = time.Date(2050, time.August, 1, 0, 0, 0, 0, time.UTC)
+)
+
+var stats = []models.OccupancyStats{
+	{RoomID: 1, Month: july, NightsAvailable: 31, NightsSold: 15, RoomRevenue: 150000},
+	{RoomID: 2, Month: july, NightsAvailable: 26, NightsSold: 0},
+	{RoomID: 1, Month: august, NightsAvailable: 31, NightsSold: 31, RoomRevenue: 310000},
+	{RoomID: 2, Month: august, NightsAvailable: 31, NightsSold: 3, RoomRevenue: 45000},
+}
+
+func TestForOccupancy(t *testing.T) {
+	rows := ForOccupancy(stats)
+	if len(rows) != len(stats) {
+		t.Fatalf("expected %d rows, got %d", len(stats), len(rows))
+	}
+	first := rows[0]
+	if first.Rate != 4839 || first.ADR != 10000 || first.RevPAR != 4839 {
+		t.Errorf("expected 48.39%% occupancy, ADR 10000 and RevPAR 4839, got %d, %d and %d", first.Rate, first.ADR,
+			first.RevPAR)
+	}
+	if rows[1].Rate != 0 || rows[1].ADR != 0 {
+		t.Errorf("expected a room without nights sold to have no occupancy or ADR, got %+v", rows[1])
+	}
+}
+
+func TestMonthTotals(t *testing.T) {
+	rows := MonthTotals(stats)
+	if len(rows) != 2 || !rows[0].Month.Equal(july) || !rows[1].Month.Equal(august) {
+		t.Fatalf("expected July and August, got %+v", rows)
+	}
+	aug := rows[1]
+	if aug.NightsAvailable != 62 || aug.NightsSold != 34 || aug.RoomRevenue != 355000 {
+		t.Errorf("expected the rooms of August to be added up, got %+v", aug.OccupancyStats)
+	}
+	if aug.Rate != 5484 || aug.ADR != 10441 || aug.RevPAR != 5726 {
+		t.Errorf("expected rates worked out from the totals, got %d, %d and %d", aug.Rate, aug.ADR, aug.RevPAR)
+	}
+}
+
+func TestForCancellations(t *testing.T) {
+	rows := ForCancellations([]models.CancellationStats{{Month: july, Booked: 8, Cancelled: 2}, {Month: august}})
+	if rows[0].Rate != 2500 || rows[1].Rate != 0 {
+		t.Errorf("expected 25%% and 0%% cancelled, got %d and %d", rows[0].Rate, rows[1].Rate)
+	}
+}
+
+func TestBarChart(t *testing.T) {
+	svg := string(BarChart("Stays", []Bar{{"1 night", 2, "2"}, {"2 nights", 4, "4"}, {"<3>", 0, "0"}}))
+	if !strings.HasPrefix(svg, "<svg") || !strings.HasSuffix(svg, "</svg>") {
+		t.Fatalf("expected an svg element, got %s", svg)
+	}
+	if strings.Count(svg, "<rect") != 3 {
+		t.Errorf("expected a bar for each value")
+	}
+	if !strings.Contains(svg, `height="90"`) || !strings.Contains(svg, `height="180"`) {
+		t.Errorf("expected the bars to be scaled to the largest value, got %s", svg)
+	}
+	if strings.Contains(svg, "<3>") || !strings.Contains(svg, "&lt;3&gt;") {
+		t.Errorf("expected labels to be escaped")
+	}
+}
@@ -2,47 +2,82 @@ package dbrepo
 
 import (
 	"context"
+	"database/sql"
 	"errors"
 	"fmt"
+	"github.com/lib/pq"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"github.com/sunil206b/smart_booking/internal/rules"
 	"golang.org/x/crypto/bcrypt"
+	"sort"
+	"strings"
 	"time"
+	"unicode"
 )
 
 const (
-	InsertReservation = `insert into reservations(first_name, last_name, email, phone, check_in, check_out, created_at, updated_at, room_id) 
-						values($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+	InsertReservation = `insert into reservations(first_name, last_name, email, phone, check_in, check_out, created_at, updated_at, room_id,
+						guests, room_amount, promo_code_id, discount, total_amount, guest_id)
+						values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, nullif($12, 0), $13, $14, $15) RETURNING id`
+
+	// UpsertGuest returns the guest with the email, creating them if they are new and keeping their latest phone number
+	UpsertGuest = `insert into guests(first_name, last_name, email, phone, created_at, updated_at) values($1, $2, $3, $4, $5, $5)
+					on conflict ((lower(email))) do update
+					set phone = coalesce(nullif(excluded.phone, ''), guests.phone), updated_at = excluded.updated_at
+					RETURNING id`
+
+	// GuestByMergedEmail returns the guest another guest with the email was merged into, keeping their latest phone
+	// number
+	GuestByMergedEmail = `update guests g set phone = coalesce(nullif($2, ''), g.phone), updated_at = $3
+							from guest_emails e where e.guest_id = g.id and lower(e.email) = lower($1)
+							RETURNING g.id`
+
+	UsePromoCode = `update promo_codes set uses = uses + 1, updated_at = $2 where id = $1 and (max_uses = 0 or uses < max_uses)`
+
+	InsertReservationTax = `insert into reservation_taxes(reservation_id, tax_rule_id, name, kind, amount, created_at, updated_at)
+							values($1, nullif($2, 0), $3, $4, $5, $6, $7) RETURNING id`
 
 	InsertRoomRestriction = `insert into room_restrictions(start_date, end_date, created_at, updated_at, room_id, reservation_id, restriction_id)
 							values($1, $2, $3, $4, $5, $6, $7) RETURNING id`
 
-	SearchAvailableRoomByDate = `select count(id) from room_restrictions where room_id = $1 and $2 < end_date and $3 > start_date`
+	SearchAvailableRoomByDate = `select count(id) from room_restrictions where room_id = $1 and $2 < end_date and $3 > start_date
+								and (expires_at is null or expires_at > now())`
 
-	SearchAllAvailableRooms = `select r.id, r.room_name from rooms r
-								where r.id not in (select rr.room_id from room_restrictions rr
-								where $1 < rr.end_date and $2 > rr.start_date)`
+	SearchAllAvailableRooms = `select r.id, r.room_name, r.property_id, p.name, exists(select 1 from room_restrictions rr
+								where rr.room_id = r.id and $1 < rr.end_date and $2 > rr.start_date
+								and (rr.expires_at is null or rr.expires_at > now()))
+								from rooms r inner join properties p on p.id = r.property_id
+								where $3 = 0 or r.property_id = $3
+								order by p.name, r.id`
 
-	SearchRoomByID = `select id, room_name, created_at, updated_at from rooms where id = $1`
+	SearchRoomByID = `select r.id, r.room_name, r.price, r.property_id, p.name, r.created_at, r.updated_at from rooms r
+						inner join properties p on p.id = r.property_id where r.id = $1`
 
-	GetUserByID = `select id, first_name, last_name, email, password, access_level, created_at, 
+	GetUserByID = `select id, first_name, last_name, email, password, access_level, coalesce(property_id, 0), created_at, 
        				updated_at from users where id = $1`
 
 	UpdateUser = `update users set first_name = $1, last_name = $2, email = $3, access_level = $4, 
-                 	updated_at = $5 where id = $6`
+                 	updated_at = $5, property_id = nullif($7, 0) where id = $6`
 
 	GetUserByEmail = `select id, password from users where email = $1`
 
-	AllReservations = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.phone, rs.check_in, rs.check_out,
-						rs.created_at, rs.updated_at, rs.room_id, rs.processed, r.id, r.room_name from reservations rs
-						inner join rooms r on rs.room_id = r.id order by rs.check_in desc`
-
-	AllNewReservations = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.phone, rs.check_in, rs.check_out,
-						rs.created_at, rs.updated_at, rs.room_id, r.id, r.room_name from reservations rs
-						inner join rooms r on rs.room_id = r.id where rs.processed = 0 order by rs.check_in desc`
+	SearchReservations = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.phone, rs.check_in, rs.check_out,
+							rs.created_at, rs.updated_at, rs.room_id, rs.processed, rs.total_amount, r.room_name, count(*) over()
+							from reservations rs inner join rooms r on rs.room_id = r.id
+							where ` + reservationFilterWhere + `
+							order by %s limit $9 offset $10`
 
 	GetReservationByID = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.phone, rs.check_in, rs.check_out,
-							rs.created_at, rs.updated_at, rs.room_id, rs.processed, r.id, r.room_name from reservations rs
-							inner join rooms r on rs.room_id = r.id where rs.id = $1`
+							rs.created_at, rs.updated_at, rs.room_id, rs.processed, rs.guests, rs.room_amount, coalesce(rs.promo_code_id, 0), rs.discount,
+							coalesce(pc.code, ''), rs.total_amount, coalesce(rs.guest_id, 0), rs.checked_in_at, rs.checked_out_at,
+							rs.key_number, rs.id_verification, r.id, r.room_name, r.property_id from reservations rs
+							inner join rooms r on rs.room_id = r.id left join promo_codes pc on rs.promo_code_id = pc.id
+							where rs.id = $1`
+
+	TaxesForReservation = `select id, reservation_id, coalesce(tax_rule_id, 0), name, kind, amount, created_at, updated_at
+							from reservation_taxes where reservation_id = $1 order by id`
 
 	UpdateReservation = `update reservations set first_name = $1, last_name = $2, email = $3, phone = $4, updated_at = $5
 							where id = $6`
@@ -51,108 +86,777 @@ const (
 
 	DeleteReservation = `delete from reservations where id = $1`
 
+	InsertCancellation = `insert into cancellations(reservation_id, room_id, check_in, check_out, booked_at, cancelled_at, total_amount)
+							select id, room_id, check_in, check_out, created_at, $2, total_amount from reservations where id = $1`
+
 	UpdateProcessedReservation = `update reservations set processed = $1, updated_at = $2 where id = $3`
 
-	AllRooms = `select id, room_name, created_at, updated_at from rooms order by room_name`
+	AllRooms = `select id, room_name, price, property_id, created_at, updated_at from rooms
+					where $1 = 0 or property_id = $1 order by room_name`
 
-	GetRoomRestrictionsByDate = `select id, start_date, end_date, room_id, coalesce(reservation_id, 0), restriction_id from room_restrictions
-									where $1 < end_date and $2 >= start_date and room_id = $3`
+	GetRoomRestrictionsByDate = `select id, start_date, end_date, room_id, coalesce(reservation_id, 0), restriction_id, reason, notes
+									from room_restrictions where $1 < end_date and $2 >= start_date and room_id = $3 and expires_at is null`
 
 	CreateBlockForRoom = `insert into room_restrictions(start_date, end_date, created_at, updated_at, room_id, restriction_id)
  							values ($1, $2, $3,$4, $5, $6)`
-	DeleteBlockByID = `delete from room_restrictions where id = $1`
+	DeleteBlockByID = `delete from room_restrictions where id = $1 and restriction_id = $2
+						and ($3 = 0 or room_id in (select id from rooms where property_id = $3))
+						RETURNING room_id, start_date, end_date`
+
+	CalendarVersion = `select coalesce(md5(string_agg(concat_ws(':', id, room_id, start_date, end_date, updated_at), ','
+						order by id)), '') from room_restrictions where $1 < end_date and $2 >= start_date and expires_at is null
+						and ($3 = 0 or room_id in (select id from rooms where property_id = $3))`
+
+	LockCalendarMonth = `select pg_advisory_xact_lock($1)`
+
+	RestrictionDates = `select start_date, end_date from room_restrictions where id = $1`
+
+	InsertBlock = `insert into room_restrictions(start_date, end_date, created_at, updated_at, room_id, restriction_id, reason, notes)
+					values ($1, $2, $3, $3, $4, $5, $6, $7) RETURNING id`
+
+	SearchBookingsForBlock = `select count(id) from room_restrictions where room_id = $1 and $2 < end_date and $3 > start_date
+								and restriction_id <> $4 and (expires_at is null or expires_at > now())`
+
+	AllRoomRules = `select ru.id, ru.room_id, ru.start_date, ru.end_date, ru.min_stay, ru.max_stay, ru.closed_to_arrival,
+						ru.closed_to_departure, ru.min_advance_days, ru.max_advance_days, ru.created_at, ru.updated_at,
+						r.id, r.room_name from room_rules ru inner join rooms r on ru.room_id = r.id
+						order by r.room_name, ru.start_date`
+
+	GetRoomRulesByDate = `select id, room_id, start_date, end_date, min_stay, max_stay, closed_to_arrival, closed_to_departure,
+							min_advance_days, max_advance_days from room_rules where start_date <= $2 and end_date >= $1`
+
+	GetRoomRulesForRoomByDate = `select id, room_id, start_date, end_date, min_stay, max_stay, closed_to_arrival, closed_to_departure,
+									min_advance_days, max_advance_days from room_rules
+									where start_date <= $2 and end_date >= $1 and room_id = $3`
+
+	InsertRoomRule = `insert into room_rules(room_id, start_date, end_date, min_stay, max_stay, closed_to_arrival, closed_to_departure,
+						min_advance_days, max_advance_days, created_at, updated_at)
+						values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11) RETURNING id`
+
+	DeleteRoomRuleByID = `delete from room_rules where id = $1
+							and ($2 = 0 or room_id in (select id from rooms where property_id = $2))`
+
+	AvailabilityCalendar = `select r.id, r.room_name, r.price, d.night,
+							exists(select 1 from room_restrictions rr
+								where rr.room_id = r.id and rr.start_date <= d.night and rr.end_date > d.night
+								and (rr.expires_at is null or rr.expires_at > now())),
+							coalesce(max(ru.min_stay), 0), coalesce(min(nullif(ru.max_stay, 0)), 0),
+							coalesce(bool_or(ru.closed_to_arrival), false), coalesce(bool_or(ru.closed_to_departure), false),
+							coalesce(max(ru.min_advance_days), 0), coalesce(min(nullif(ru.max_advance_days, 0)), 0)
+							from rooms r
+							cross join (select generate_series($1::date, $2::date - 1, interval '1 day')::date as night) d
+							left join room_rules ru on ru.room_id = r.id and d.night between ru.start_date and ru.end_date
+							where $3 = 0 or r.id = $3
+							group by r.id, r.room_name, r.price, d.night
+							order by r.room_name, r.id, d.night`
+
+	AllExchangeRates = `select id, currency_code, rate, created_at, updated_at from exchange_rates order by currency_code`
+
+	UpsertExchangeRate = `insert into exchange_rates(currency_code, rate, created_at, updated_at) values($1, $2, $3, $4)
+							on conflict (currency_code) do update set rate = excluded.rate, updated_at = excluded.updated_at`
+
+	DeleteExchangeRate = `delete from exchange_rates where currency_code = $1`
+
+	InsertPayment = `insert into payments(reservation_id, provider, reference, checkout_url, amount, currency, status,
+						captured, refunded, created_at, updated_at) values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+						RETURNING id`
+
+	GetPaymentByID = `select id, reservation_id, provider, reference, checkout_url, amount, currency, status, captured,
+						refunded, created_at, updated_at from payments where id = $1`
+
+	GetPaymentByReference = `select id, reservation_id, provider, reference, checkout_url, amount, currency, status, captured,
+						refunded, created_at, updated_at from payments where reference = $1`
+
+	UpdatePayment = `update payments set reference = $1, checkout_url = $2, status = $3, captured = $4, refunded = $5,
+						updated_at = $6 where id = $7`
+
+	PaymentsForReservation = `select id, reservation_id, provider, reference, checkout_url, amount, currency, status, captured,
+						refunded, created_at, updated_at from payments where reservation_id = $1 order by created_at`
+
+	FolioItemsForReservation = `select id, reservation_id, kind, description, quantity, unit_amount, created_at, updated_at
+						from folio_items where reservation_id = $1 order by created_at, id`
+
+	InsertFolioItem = `insert into folio_items(reservation_id, kind, description, quantity, unit_amount, created_at, updated_at)
+						values($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	DeleteFolioItem = `delete from folio_items where id = $1 and reservation_id = $2`
+
+	InvoicesForReservation = `select id, reservation_id, number, total, currency, created_at, updated_at from invoices
+						where reservation_id = $1 order by created_at`
+
+	GetInvoiceByID = `select id, reservation_id, number, total, currency, pdf, created_at, updated_at from invoices where id = $1`
+
+	NextInvoiceNumber = `update invoice_numbers set last_number = last_number + 1 where id = 1 RETURNING last_number`
+
+	InsertInvoice = `insert into invoices(reservation_id, number, total, currency, pdf, created_at, updated_at)
+						values($1, $2, $3, $4, $5, $6, $7) RETURNING id`
+
+	AllTaxRules = `select t.id, t.name, t.kind, t.amount, coalesce(t.room_id, 0), t.start_date, t.end_date, t.created_at,
+						t.updated_at, coalesce(r.id, 0), coalesce(r.room_name, '') from tax_rules t
+						left join rooms r on t.room_id = r.id order by t.start_date, t.name`
+
+	GetTaxRulesForRoomByDate = `select id, name, kind, amount, coalesce(room_id, 0), start_date, end_date, created_at, updated_at
+								from tax_rules where start_date < $2 and end_date >= $1 and (room_id is null or room_id = $3)
+								order by start_date, id`
+
+	InsertTaxRule = `insert into tax_rules(name, kind, amount, room_id, start_date, end_date, created_at, updated_at)
+						values($1, $2, $3, nullif($4, 0), $5, $6, $7, $8) RETURNING id`
+
+	DeleteTaxRuleByID = `delete from tax_rules where id = $1`
+
+	GetPromoCodeByCode = `select p.id, p.code, p.description, p.kind, p.amount, p.valid_from, p.valid_to, p.stay_start, p.stay_end,
+							p.min_nights, p.max_uses, p.uses, p.created_at, p.updated_at,
+							array(select room_id from promo_code_rooms where promo_code_id = p.id order by room_id)
+							from promo_codes p where p.code = $1`
+
+	AllPromoCodeStats = `select p.id, p.code, p.description, p.kind, p.amount, p.valid_from, p.valid_to, p.stay_start, p.stay_end,
+							p.min_nights, p.max_uses, p.uses, p.created_at, p.updated_at,
+							array(select room_id from promo_code_rooms where promo_code_id = p.id order by room_id),
+							count(rs.id), coalesce(sum(rs.discount), 0), coalesce(sum(rs.total_amount), 0)
+							from promo_codes p left join reservations rs on rs.promo_code_id = p.id
+							group by p.id order by p.valid_to desc, p.code`
+
+	InsertPromoCode = `insert into promo_codes(code, description, kind, amount, valid_from, valid_to, stay_start, stay_end,
+						min_nights, max_uses, created_at, updated_at) values($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+						RETURNING id`
+
+	InsertPromoCodeRoom = `insert into promo_code_rooms(promo_code_id, room_id) values($1, $2)`
+
+	DeletePromoCode = `delete from promo_codes where id = $1`
+
+	AllExtras = `select id, name, description, unit, price, inventory, active, created_at, updated_at from extras
+					order by name`
+
+	InsertExtra = `insert into extras(name, description, unit, price, inventory, active, created_at, updated_at)
+					values($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	SetExtraActive = `update extras set active = $1, updated_at = $2 where id = $3`
+
+	ExtrasBookedByDate = `select t.extra_id, max(t.booked) from (
+							select re.extra_id, d.night, sum(re.quantity) as booked
+							from generate_series($1::date, $2::date - 1, '1 day') d(night)
+							inner join reservations rs on d.night >= rs.check_in and d.night < rs.check_out
+							inner join reservation_extras re on re.reservation_id = rs.id
+							where re.extra_id is not null and ($3 = 0 or re.extra_id = $3)
+							group by re.extra_id, d.night) t group by t.extra_id`
+
+	LockExtraInventory = `select inventory from extras where id = $1 for update`
+
+	LockReservationExtraInventory = `select e.id, e.inventory from extras e
+					where e.inventory > 0 and e.id in (select extra_id from reservation_extras where reservation_id = $1)
+					order by e.id for update of e`
+
+	InsertReservationExtra = `insert into reservation_extras(reservation_id, extra_id, name, unit, quantity, unit_price, amount,
+								created_at, updated_at) values($1, nullif($2, 0), $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+
+	ExtrasForReservation = `select id, reservation_id, coalesce(extra_id, 0), name, unit, quantity, unit_price, amount, created_at,
+								updated_at from reservation_extras where reservation_id = $1 order by id`
+
+	LockRoom = `select id from rooms where id = $1 for update`
+
+	ReleaseHold = `delete from room_restrictions where id = $1 and restriction_id = $2`
+
+	InsertHold = `insert into room_restrictions(start_date, end_date, created_at, updated_at, room_id, restriction_id, expires_at)
+					values($1, $2, $3, $3, $4, $5, $6) RETURNING id`
+
+	ConvertHold = `update room_restrictions set restriction_id = $4, reservation_id = $1, expires_at = null, updated_at = $2
+					where id = $3 and restriction_id = $5 and expires_at > $2`
+
+	DeleteExpiredHolds = `delete from room_restrictions where restriction_id = $2 and expires_at <= $1`
+
+	FilteredReservations = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.phone, rs.check_in, rs.check_out,
+							rs.created_at, rs.updated_at, rs.room_id, rs.processed, rs.guests, rs.room_amount, rs.discount,
+							rs.total_amount, coalesce(p.code, ''), r.room_name from reservations rs
+							inner join rooms r on rs.room_id = r.id
+							left join promo_codes p on p.id = rs.promo_code_id
+							where ` + reservationFilterWhere + `
+							order by rs.check_in desc, rs.id desc`
+
+	// reservationFilterWhere matches the reservations of a models.ReservationFilter, with the name and email as
+	// escaped like patterns and the search as a tsquery. The rooms of the reservations are joined as r.
+	reservationFilterWhere = `($1::date is null or rs.check_out > $1) and ($2::date is null or rs.check_in < $2)
+							and ($3 = 0 or rs.room_id = $3)
+							and ($4 = '' or ($4 = 'new' and rs.processed = 0) or ($4 = 'processed' and rs.processed = 1))
+							and ($5 = '' or rs.first_name || ' ' || rs.last_name ilike $5)
+							and ($6 = '' or rs.email ilike $6)
+							and ($7 = '' or rs.search @@ to_tsquery('simple', $7))
+							and ($8 = 0 or r.property_id = $8)`
+
+	InsertImportedReservation = `insert into reservations(first_name, last_name, email, phone, check_in, check_out, created_at,
+									updated_at, room_id, processed, guests, room_amount, discount, total_amount, guest_id)
+									values($1, $2, $3, $4, $5, $6, $7, $7, $8, $9, $10, $11, $12, $13, $14) RETURNING id`
+
+	InsertImportRun = `insert into import_runs(user_id, file_name, dry_run, row_count, imported, failed, errors, created_at)
+						values(nullif($1, 0), $2, $3, $4, $5, $6, $7, $8) RETURNING id`
+
+	importRunColumns = `id, coalesce(user_id, 0), file_name, dry_run, row_count, imported, failed, created_at`
+
+	GetImportRunByID = `select ` + importRunColumns + `, coalesce(errors, '') from import_runs where id = $1`
+
+	RecentImportRuns = `select ` + importRunColumns + ` from import_runs order by created_at desc, id desc limit $1`
+
+	OccupancyByMonth = `with months as (
+							select m::date as month, greatest(m::date, $1::date) as first_night,
+								least((m + interval '1 month')::date, $2::date) as end_night
+							from generate_series(date_trunc('month', $1::date), $2::date - 1, interval '1 month') m
+						), sold as (
+							select r.room_id, date_trunc('month', d)::date as month, count(*) as nights,
+								sum(r.room_amount::numeric / greatest(r.check_out - r.check_in, 1)) as revenue
+							from reservations r
+							cross join generate_series(greatest(r.check_in, $1::date), least(r.check_out, $2::date) - 1,
+								interval '1 day') d
+							where r.check_in < $2 and r.check_out > $1
+							group by r.room_id, date_trunc('month', d)
+						), blocked as (
+							select rr.room_id, date_trunc('month', d)::date as month, count(distinct d) as nights
+							from room_restrictions rr
+							cross join generate_series(greatest(rr.start_date, $1::date), least(rr.end_date, $2::date) - 1,
+								interval '1 day') d
+							where rr.restriction_id = $3 and rr.start_date < $2 and rr.end_date > $1
+							group by rr.room_id, date_trunc('month', d)
+						)
+						select rm.id, rm.room_name, m.month, (m.end_night - m.first_night) - coalesce(b.nights, 0),
+							coalesce(s.nights, 0), coalesce(round(s.revenue), 0)::integer
+						from rooms rm cross join months m
+						left join sold s on s.room_id = rm.id and s.month = m.month
+						left join blocked b on b.room_id = rm.id and b.month = m.month
+						where $4 = 0 or rm.property_id = $4
+						order by m.month, rm.id`
+
+	LeadTimeDistribution = `select b.label, count(r.id) from (values
+								(1, 'Same day', null::integer, 0), (2, '1-7 days', 1, 7), (3, '8-30 days', 8, 30),
+								(4, '31-90 days', 31, 90), (5, 'Over 90 days', 91, null::integer)
+							) as b(ord, label, lo, hi)
+							left join reservations r on r.check_in >= $1 and r.check_in < $2
+								and (b.lo is null or r.check_in - r.created_at::date >= b.lo)
+								and (b.hi is null or r.check_in - r.created_at::date <= b.hi)
+								and ($3 = 0 or r.room_id in (select id from rooms where property_id = $3))
+							group by b.ord, b.label order by b.ord`
+
+	StayLengthDistribution = `select b.label, count(r.id) from (values
+								(1, '1 night', 1, 1), (2, '2 nights', 2, 2), (3, '3 nights', 3, 3), (4, '4 nights', 4, 4),
+								(5, '5-6 nights', 5, 6), (6, '7-13 nights', 7, 13), (7, '14+ nights', 14, null::integer)
+							) as b(ord, label, lo, hi)
+							left join reservations r on r.check_in >= $1 and r.check_in < $2
+								and r.check_out - r.check_in >= b.lo
+								and (b.hi is null or r.check_out - r.check_in <= b.hi)
+								and ($3 = 0 or r.room_id in (select id from rooms where property_id = $3))
+							group by b.ord, b.label order by b.ord`
+
+	CancellationsByMonth = `select m::date,
+								(select count(*) from reservations r where r.created_at >= greatest(m, $1)
+									and r.created_at < least(m + interval '1 month', $2)
+									and ($3 = 0 or r.room_id in (select id from rooms where property_id = $3))) +
+								(select count(*) from cancellations c where c.booked_at >= greatest(m, $1)
+									and c.booked_at < least(m + interval '1 month', $2)
+									and ($3 = 0 or c.room_id in (select id from rooms where property_id = $3))),
+								c.cancelled, c.lost
+							from generate_series(date_trunc('month', $1::date), $2::date - 1, interval '1 month') m
+							cross join lateral (
+								select count(*) as cancelled, coalesce(sum(total_amount), 0) as lost from cancellations
+								where cancelled_at >= greatest(m, $1) and cancelled_at < least(m + interval '1 month', $2)
+								and ($3 = 0 or room_id in (select id from rooms where property_id = $3))
+							) c
+							order by m`
+
+	LockReservation = `select room_id, check_in, check_out from reservations where id = $1 for update`
+
+	SearchAvailableRoomByDateForMove = `select count(id) from room_restrictions where room_id = $1 and $2 < end_date and $3 > start_date
+										and (expires_at is null or expires_at > now()) and reservation_id is distinct from $4`
+
+	MoveReservation = `update reservations set room_id = $1, check_in = $2, check_out = $3, updated_at = $4 where id = $5`
+
+	MoveReservationRestriction = `update room_restrictions set room_id = $1, start_date = $2, end_date = $3, updated_at = $4
+									where reservation_id = $5`
+
+	InsertReservationMove = `insert into reservation_moves(reservation_id, user_id, from_room_id, to_room_id, from_start, from_end,
+								to_start, to_end, created_at) values($1, nullif($2, 0), $3, $4, $5, $6, $7, $8, $9) RETURNING id`
+
+	MovesForReservation = `select m.id, m.reservation_id, coalesce(m.user_id, 0), m.from_room_id, m.to_room_id, m.from_start,
+							m.from_end, m.to_start, m.to_end, m.created_at, coalesce(u.first_name || ' ' || u.last_name, ''),
+							fr.room_name, tr.room_name from reservation_moves m
+							left join users u on u.id = m.user_id
+							inner join rooms fr on fr.id = m.from_room_id
+							inner join rooms tr on tr.id = m.to_room_id
+							where m.reservation_id = $1 order by m.created_at desc, m.id desc`
+
+	waitlistColumns = `w.id, w.first_name, w.last_name, w.email, w.phone, w.locale, coalesce(w.property_id, 0),
+						coalesce(w.room_id, 0), w.start_date, w.end_date, w.status, coalesce(w.offered_room_id, 0), coalesce(w.token, ''), w.offered_at,
+						w.expires_at, w.created_at, w.updated_at, coalesce(r.room_name, '')`
+
+	InsertWaitlistEntry = `insert into waitlist_entries(first_name, last_name, email, phone, locale, room_id, start_date, end_date,
+							status, created_at, updated_at, property_id)
+							values($1, $2, $3, $4, $5, nullif($6, 0), $7, $8, $9, $10, $11, nullif($12, 0))
+							RETURNING id`
+
+	WaitingEntriesForRoom = `select ` + waitlistColumns + ` from waitlist_entries w
+								left join rooms r on r.id = w.room_id
+								where w.status = 'waiting' and (w.room_id = $1 or (w.room_id is null and (w.property_id is null
+									or w.property_id = (select property_id from rooms where id = $1))))
+								and w.start_date < $3 and w.end_date > $2
+								order by w.created_at, w.id`
+
+	OfferWaitlistEntry = `update waitlist_entries set status = 'offered', offered_room_id = $1, token = $2, offered_at = $3,
+							expires_at = $4, updated_at = $3 where id = $5 and status = 'waiting'`
+
+	GetWaitlistEntryByToken = `select ` + waitlistColumns + ` from waitlist_entries w
+								left join rooms r on r.id = w.offered_room_id
+								where w.token = $1`
+
+	UpdateWaitlistEntryStatus = `update waitlist_entries set status = $1, updated_at = $2 where id = $3`
+
+	ExpiredWaitlistOffers = `select ` + waitlistColumns + ` from waitlist_entries w
+								left join rooms r on r.id = w.offered_room_id
+								where w.status = 'offered' and w.expires_at <= $1
+								order by w.expires_at, w.id`
+
+	guestColumns = `g.id, g.first_name, g.last_name, g.email, g.phone, g.notes, g.tags, g.preferences, g.created_at,
+					g.updated_at`
+
+	// guestListColumns are the guestColumns with the number of stays and the latest check-in of the guest
+	guestListColumns = guestColumns + `, (select count(*) from reservations rs where rs.guest_id = g.id),
+						(select coalesce(max(rs.check_in), '0001-01-01') from reservations rs where rs.guest_id = g.id)`
+
+	SearchGuests = `select ` + guestListColumns + ` from guests g
+					where ($1 = '' or g.first_name || ' ' || g.last_name ilike $1 or g.email ilike $1 or g.phone ilike $1)
+					and ($3 = 0 or exists(select 1 from reservations rs inner join rooms r on r.id = rs.room_id
+						where rs.guest_id = g.id and r.property_id = $3))
+					order by lower(g.last_name), lower(g.first_name), g.id limit $2`
+
+	GetGuestByID = `select ` + guestColumns + ` from guests g where g.id = $1`
+
+	UpdateGuest = `update guests set first_name = $1, last_name = $2, phone = $3, notes = $4, tags = $5, preferences = $6,
+					updated_at = $7 where id = $8`
+
+	StaysForGuest = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.check_in, rs.check_out, rs.room_id, rs.processed,
+						rs.guests, rs.total_amount, rs.created_at, r.room_name from reservations rs
+						inner join rooms r on rs.room_id = r.id
+						where rs.guest_id = $1 and ($2 = 0 or r.property_id = $2) order by rs.check_in desc, rs.id desc`
+
+	// PossibleDuplicateGuests finds the other guests with the same name or phone number as a guest
+	PossibleDuplicateGuests = `select ` + guestListColumns + ` from guests g
+								where g.id <> $1 and ((lower(g.first_name) = lower($2) and lower(g.last_name) = lower($3))
+								or ($4 <> '' and g.phone = $4))
+								and ($5 = 0 or exists(select 1 from reservations rs inner join rooms r on r.id = rs.room_id
+									where rs.guest_id = g.id and r.property_id = $5))
+								order by g.id limit 20`
+
+	// MergeGuest adds the tags, notes and preferences of the guest merged into the guest kept, keeping the phone
+	// number of the guest kept if it has one
+	MergeGuest = `update guests k set tags = array(select distinct t from unnest(k.tags || m.tags) t order by t),
+					notes = concat_ws(E'\n\n', nullif(k.notes, ''), nullif(m.notes, '')),
+					preferences = concat_ws(E'\n', nullif(k.preferences, ''), nullif(m.preferences, '')),
+					phone = coalesce(nullif(k.phone, ''), m.phone), created_at = least(k.created_at, m.created_at),
+					updated_at = $3
+					from guests m where k.id = $1 and m.id = $2`
+
+	MoveGuestReservations = `update reservations set guest_id = $1 where guest_id = $2`
+
+	// MoveGuestEmails gives the guest kept the addresses of the guest merged and of the guests merged into them
+	MoveGuestEmails = `update guest_emails set guest_id = $1 where guest_id = $2`
+
+	InsertGuestEmail = `insert into guest_emails(guest_id, email, created_at) select $1, email, $3 from guests where id = $2
+						on conflict do nothing`
+
+	DeleteGuest = `delete from guests where id = $1`
+
+	InsertSentEmail = `insert into sent_emails(recipient, subject, content, template, status, created_at)
+						values($1, $2, $3, $4, $5, $6) RETURNING id`
+
+	// GuestDataEmails finds the addresses of the reservations of the guests with an email address, so the data of
+	// merged guests is found by any of their addresses
+	GuestDataEmails = `select distinct lower(rs.email) from reservations rs inner join guests g on rs.guest_id = g.id
+						where lower(g.email) = $1
+						or g.id in (select guest_id from guest_emails where lower(email) = $1)`
+
+	GuestDataGuests = `select ` + guestListColumns + ` from guests g where lower(g.email) = any($1) order by g.id`
+
+	GuestDataReservations = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.phone, rs.check_in, rs.check_out,
+							rs.created_at, rs.updated_at, rs.room_id, rs.processed, rs.guests, rs.room_amount, rs.discount,
+							rs.total_amount, coalesce(rs.guest_id, 0), r.room_name from reservations rs
+							inner join rooms r on rs.room_id = r.id
+							where lower(rs.email) = any($1) order by rs.check_in, rs.id`
+
+	GuestDataFolioItems = `select id, reservation_id, kind, description, quantity, unit_amount, created_at, updated_at
+							from folio_items where reservation_id = any($1) order by reservation_id, created_at, id`
+
+	GuestDataPayments = `select id, reservation_id, provider, reference, amount, currency, status, captured, refunded,
+							created_at, updated_at from payments where reservation_id = any($1) order by reservation_id, created_at`
+
+	GuestDataInvoices = `select id, reservation_id, number, total, currency, pdf, created_at, updated_at from invoices
+							where reservation_id = any($1) order by reservation_id, created_at`
+
+	GuestDataWaitlistEntries = `select ` + waitlistColumns + ` from waitlist_entries w
+								left join rooms r on r.id = w.offered_room_id
+								where lower(w.email) = any($1) order by w.created_at, w.id`
+
+	GuestDataSentEmails = `select id, recipient, subject, content, template, status, created_at from sent_emails
+							where lower(recipient) = any($1) order by created_at, id`
+
+	// AnonymiseReservations replaces the guest details of reservations with placeholders, keeping the dates, room
+	// and amounts the occupancy reports and accounts are made from
+	AnonymiseReservations = `update reservations set first_name = 'Erased', last_name = 'Guest',
+							email = 'erased-' || id || '@erased.invalid', phone = '', id_verification = '', guest_id = null,
+							anonymised_at = $2, updated_at = $2
+							where lower(email) = any($1)`
+
+	DeleteGuestsByEmail = `delete from guests where lower(email) = any($1)`
+
+	DeleteWaitlistEntriesByEmail = `delete from waitlist_entries where lower(email) = any($1)`
+
+	DeleteSentEmailsByRecipient = `delete from sent_emails where lower(recipient) = any($1)`
+
+	// GuestDataImportRuns finds the imports whose failed rows kept mention any of the email addresses
+	GuestDataImportRuns = `select ` + importRunColumns + `, errors from import_runs where errors is not null
+							and exists(select 1 from unnest($1::text[]) e
+								where position(e in lower(encode(errors, 'escape'))) > 0)
+							order by created_at, id`
+
+	// DeleteImportErrorsByEmail deletes the failed rows kept of the imports with a row for any of the email addresses
+	DeleteImportErrorsByEmail = `update import_runs set errors = null where errors is not null
+									and exists(select 1 from unnest($1::text[]) e
+										where position(e in lower(encode(errors, 'escape'))) > 0)`
+
+	AnonymiseStaysBefore = `update reservations set first_name = 'Erased', last_name = 'Guest',
+							email = 'erased-' || id || '@erased.invalid', phone = '', id_verification = '', guest_id = null,
+							anonymised_at = $2, updated_at = $2
+							where check_out < $1 and anonymised_at is null`
+
+	DeleteGuestsWithoutStays = `delete from guests g where not exists(select 1 from reservations rs where rs.guest_id = g.id)`
+
+	DeleteWaitlistEntriesBefore = `delete from waitlist_entries where end_date < $1`
+
+	DeleteSentEmailsBefore = `delete from sent_emails where created_at < $1`
+
+	DeleteImportErrorsBefore = `update import_runs set errors = null where errors is not null and created_at < $1`
+
+	HousekeepingRooms = `select r.id, r.room_name, r.housekeeping_status, coalesce(r.housekeeping_updated_at, r.updated_at),
+							coalesce(rr.id, 0), rr.end_date from rooms r
+							left join room_restrictions rr on rr.id = r.out_of_order_block_id
+							where $1 = 0 or r.property_id = $1
+							order by r.room_name`
+
+	UpdateRoomHousekeepingStatus = `update rooms set housekeeping_status = $2, housekeeping_updated_at = $3 where id = $1
+									RETURNING coalesce(out_of_order_block_id, 0)`
+
+	SetOutOfOrderBlock = `update rooms set out_of_order_block_id = nullif($2, 0) where id = $1`
+
+	OutOfOrderBlockEnd = `select rr.end_date from rooms r inner join room_restrictions rr on rr.id = r.out_of_order_block_id
+							where r.id = $1`
+
+	// EndOutOfOrderBlock makes the nights of an out of order block from a day on available again, and
+	// DeleteOutOfOrderBlock deletes it if it had not started by then
+	EndOutOfOrderBlock = `update room_restrictions set end_date = $2, updated_at = $3
+							where id = $1 and start_date < $2 and end_date > $2`
+
+	DeleteOutOfOrderBlock = `delete from room_restrictions where id = $1 and start_date >= $2`
+
+	HousekeepingTasks = `select t.id, t.room_id, coalesce(t.reservation_id, 0), t.kind, t.task_date, t.status,
+							coalesce(t.assigned_to, 0), coalesce(u.first_name || ' ' || u.last_name, ''), t.notes,
+							t.completed_at, t.created_at, t.updated_at, r.room_name from housekeeping_tasks t
+							inner join rooms r on r.id = t.room_id left join users u on u.id = t.assigned_to
+							where t.task_date = $1 and ($2 = 0 or t.assigned_to = $2)
+							and ($3 = 0 or r.property_id = $3)
+							order by r.room_name, t.kind, t.id`
+
+	// GenerateHousekeepingTasks makes the tasks of a day for the rooms guests leave on it and for the guests in house
+	// staying over, keeping the tasks already made
+	GenerateHousekeepingTasks = `insert into housekeeping_tasks(room_id, reservation_id, kind, task_date, status, created_at,
+									updated_at)
+									select rs.room_id, rs.id, case when rs.check_out = $1 then 'departure' else 'stayover' end,
+									$1, 'open', $2, $2 from reservations rs
+									where rs.check_in < $1 and rs.check_out >= $1 and rs.checked_out_at is null
+									and (rs.checked_in_at is not null or rs.check_out = $1)
+									on conflict (room_id, task_date, kind) do nothing`
+
+	UpdateHousekeepingTask = `update housekeeping_tasks set status = $2, assigned_to = nullif($3, 0), notes = $4,
+								completed_at = case when $2 = 'done' then coalesce(completed_at, $5) end, updated_at = $5
+								where id = $1 and ($6 = 0 or room_id in (select id from rooms where property_id = $6))
+								RETURNING room_id, kind`
+
+	// TaskRoomStatus moves the room of a task to the status of its cleaning, unless the room is out of order or was
+	// cleaned after the task
+	TaskRoomStatus = `update rooms set housekeeping_status = $2, housekeeping_updated_at = $3
+						where id = $1 and housekeeping_status in ('dirty', 'cleaning')`
+
+	// AllStaff finds the users working at a property, and those working at all of them
+	AllStaff = `select id, first_name, last_name, email, access_level, coalesce(property_id, 0) from users
+				where $1 = 0 or property_id is null or property_id = $1
+				order by first_name, last_name`
+
+	AllProperties = `select id, slug, name, hostname, email_from, email_from_name, logo_url, brand_color, deposit_policy,
+						created_at, updated_at from properties order by name`
+
+	InsertProperty = `insert into properties(slug, name, hostname, email_from, email_from_name, logo_url, brand_color,
+						deposit_policy, created_at, updated_at) values($1, $2, $3, $4, $5, $6, $7, $8, $9, $9) RETURNING id`
+
+	UpdateProperty = `update properties set slug = $2, name = $3, hostname = $4, email_from = $5, email_from_name = $6,
+						logo_url = $7, brand_color = $8, deposit_policy = $9, updated_at = $10 where id = $1`
+
+	SetRoomProperty = `update rooms set property_id = $2, updated_at = $3 where id = $1`
+
+	// SetUserProperty ties a user to a property, or lets them work at every property for property 0
+	SetUserProperty = `update users set property_id = nullif($2, 0), updated_at = $3 where id = $1`
+
+	CheckInReservation = `update reservations set checked_in_at = $2, key_number = $3, id_verification = $4, processed = 1,
+							updated_at = $5 where id = $1 and checked_in_at is null`
+
+	CheckOutReservation = `update reservations set checked_out_at = $2, updated_at = $3
+							where id = $1 and checked_in_at is not null and checked_out_at is null`
+
+	// MarkCheckedOutRoomDirty marks the room of a reservation checked out as dirty, unless it is out of order
+	MarkCheckedOutRoomDirty = `update rooms r set housekeeping_status = 'dirty', housekeeping_updated_at = $2
+								from reservations rs where rs.id = $1 and r.id = rs.room_id
+								and r.housekeeping_status <> 'out_of_order'`
+
+	// InsertDepartureTask makes the task of cleaning the room of a reservation checked out, on the day it left
+	InsertDepartureTask = `insert into housekeeping_tasks(room_id, reservation_id, kind, task_date, status, created_at, updated_at)
+							select room_id, id, 'departure', ($2::timestamp)::date, 'open', $2, $2 from reservations
+							where id = $1
+							on conflict (room_id, task_date, kind) do nothing`
+
+	// FrontDeskReservations finds the reservations arriving or leaving on a day and those of the guests in house
+	FrontDeskReservations = `select rs.id, rs.first_name, rs.last_name, rs.email, rs.phone, rs.check_in, rs.check_out,
+							rs.room_id, rs.processed, rs.guests, rs.total_amount, coalesce(rs.guest_id, 0), rs.checked_in_at,
+							rs.checked_out_at, rs.key_number, r.room_name from reservations rs
+							inner join rooms r on rs.room_id = r.id
+							where (rs.check_in = $1 or rs.check_out = $1
+							or (rs.checked_in_at is not null and rs.checked_out_at is null))
+							and ($2 = 0 or r.property_id = $2)
+							order by r.room_name, rs.check_in, rs.id`
 )
 
+// invoiceNumberFormat formats the sequential number of an invoice
+const invoiceNumberFormat = "INV-%06d"
+
+// bulkTimeout is how long exports and imports may take, longer than other queries as they work through many rows
+const bulkTimeout = 5 * time.Minute
+
+var notAvailableReason = i18n.NewMessage("rules.not_available")
+
 func (pg *postgresDBRepo) AllUsers() bool {
 	return false
 }
 
-//CreateReservation creates reservation record in the database
+//CreateReservation creates reservation record in the database, together with the taxes and fees charged on it and
+//the extras booked with it. A promo code is used up in the same transaction, returning repository.ErrPromoCodeUsedUp
+//if it has no uses left, and repository.ErrExtraSoldOut is returned if an extra is no longer available.
 func (pg *postgresDBRepo) CreateReservation(res *models.Reservation) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	stmt, err := pg.DB.Prepare(InsertReservation)
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateReservation() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	err = lockCalendar(ctx, tx, res.CheckInDate, res.CheckOutDate)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateReservation() method while locking calendar: %v\n", err))
+	}
+	stmt, err := tx.PrepareContext(ctx, InsertReservation)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error in CreateReservation() method while preparing create reservations query: %v\n", err))
 	}
 	defer stmt.Close()
 
+	if res.PromoCodeID != 0 {
+		result, err := tx.ExecContext(ctx, UsePromoCode, res.PromoCodeID, time.Now())
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in CreateReservation() method while using promo code: %v\n", err))
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			return repository.ErrPromoCodeUsedUp
+		}
+	}
+
+	err = findGuest(ctx, tx, res, time.Now())
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateReservation() method while finding guest: %v\n", err))
+	}
+
 	reservationID := 0
 	err = stmt.QueryRowContext(ctx, res.FirstName, res.LastName, res.Email, res.Phone, res.CheckInDate,
-		res.CheckOutDate, res.CreatedAt, res.UpdatedAt, res.RoomID).Scan(&reservationID)
+		res.CheckOutDate, res.CreatedAt, res.UpdatedAt, res.RoomID, res.Guests, res.RoomAmount, res.PromoCodeID,
+		res.Discount, res.TotalAmount, res.GuestID).Scan(&reservationID)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error in CreateReservation() method while creating reservations: %v\n", err))
 	}
+
+	taxStmt, err := tx.PrepareContext(ctx, InsertReservationTax)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateReservation() method while preparing create reservation tax query: %v\n", err))
+	}
+	defer taxStmt.Close()
+	for i := range res.Taxes {
+		tax := &res.Taxes[i]
+		tax.ReservationID = reservationID
+		tax.CreatedAt = time.Now()
+		tax.UpdatedAt = tax.CreatedAt
+		err = taxStmt.QueryRowContext(ctx, tax.ReservationID, tax.TaxRuleID, tax.Name, tax.Kind, tax.Amount,
+			tax.CreatedAt, tax.UpdatedAt).Scan(&tax.ID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in CreateReservation() method while creating reservation tax: %v\n", err))
+		}
+	}
+
+	err = pg.insertReservationExtras(ctx, tx, res, reservationID)
+	if err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in CreateReservation() method while committing transaction: %v\n", err))
+	}
 	res.ID = reservationID
 	return nil
 }
 
+//insertReservationExtras adds the extras of a reservation in its transaction. The row of each extra with an
+//inventory is locked while what is already booked is counted, so two guests cannot book the last one at once.
+func (pg *postgresDBRepo) insertReservationExtras(ctx context.Context, tx *sql.Tx, res *models.Reservation, reservationID int) error {
+	for i := range res.Extras {
+		extra := &res.Extras[i]
+		if extra.ExtraID != 0 {
+			var inventory int
+			err := tx.QueryRowContext(ctx, LockExtraInventory, extra.ExtraID).Scan(&inventory)
+			if err != nil {
+				return errors.New(fmt.Sprintf("error in CreateReservation() method while locking extra %d: %v\n", extra.ExtraID, err))
+			}
+			if inventory > 0 {
+				booked := 0
+				err = tx.QueryRowContext(ctx, ExtrasBookedByDate, res.CheckInDate, res.CheckOutDate, extra.ExtraID).Scan(new(int), &booked)
+				if err != nil && err != sql.ErrNoRows {
+					return errors.New(fmt.Sprintf("error in CreateReservation() method while counting extra %d: %v\n", extra.ExtraID, err))
+				}
+				if booked+extra.Quantity > inventory {
+					return repository.ErrExtraSoldOut
+				}
+			}
+		}
+
+		extra.ReservationID = reservationID
+		extra.CreatedAt = time.Now()
+		extra.UpdatedAt = extra.CreatedAt
+		err := tx.QueryRowContext(ctx, InsertReservationExtra, extra.ReservationID, extra.ExtraID, extra.Name, extra.Unit,
+			extra.Quantity, extra.UnitPrice, extra.Amount, extra.CreatedAt, extra.UpdatedAt).Scan(&extra.ID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in CreateReservation() method while creating reservation extra: %v\n", err))
+		}
+	}
+	return nil
+}
+
 // CreateRoomRestriction create room restrictions in the database
 func (pg *postgresDBRepo) CreateRoomRestriction(r *models.RoomRestriction) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	stmt, err := pg.DB.Prepare(InsertRoomRestriction)
+	tx, err := pg.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error in CreateRoomRestriction() method while preparing create room restrictions query: %v\n", err))
+		return errors.New(fmt.Sprintf("error in CreateRoomRestriction() method while starting transaction: %v\n", err))
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
+	err = lockCalendar(ctx, tx, r.StartDate, r.EndDate)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateRoomRestriction() method while locking calendar: %v\n", err))
+	}
 	restrictionID := 0
-	err = stmt.QueryRowContext(ctx, r.StartDate, r.EndDate, r.CreatedAt, r.UpdatedAt, r.RoomID, r.ReservationID, r.RestrictionID).Scan(&restrictionID)
+	err = tx.QueryRowContext(ctx, InsertRoomRestriction, r.StartDate, r.EndDate, r.CreatedAt, r.UpdatedAt, r.RoomID,
+		r.ReservationID, r.RestrictionID).Scan(&restrictionID)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error in CreateRoomRestriction() method while creating room restriction: %v\n", err))
 	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in CreateRoomRestriction() method while committing transaction: %v\n", err))
+	}
 	r.ID = restrictionID
 	return nil
 }
 
-// SearchAvailabilityByDatesByRoom returns true if room available, and false if room not available in the given roomID
-func (pg *postgresDBRepo) SearchAvailabilityByDatesByRoom(start, end time.Time, roomID int) (bool, error) {
+// SearchAvailabilityByDatesByRoom returns true if room available, and false with the reason if room not available in the given roomID
+func (pg *postgresDBRepo) SearchAvailabilityByDatesByRoom(start, end time.Time, roomID int) (bool, i18n.Message, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	stmt, err := pg.DB.Prepare(SearchAvailableRoomByDate)
 	if err != nil {
-		return false, errors.New(fmt.Sprintf("error in SearchAvailabilityByDatesByRoom() method while preparing search available rooms query: %v\n", err))
+		return false, i18n.Message{}, errors.New(fmt.Sprintf("error in SearchAvailabilityByDatesByRoom() method while preparing search available rooms query: %v\n", err))
 	}
 	defer stmt.Close()
 
 	numRows := 0
 	err = stmt.QueryRowContext(ctx, roomID, start, end).Scan(&numRows)
 	if err != nil {
-		return false, errors.New(fmt.Sprintf("error in SearchAvailabilityByDatesByRoom() method while executing search available rooms query: %v\n", err))
+		return false, i18n.Message{}, errors.New(fmt.Sprintf("error in SearchAvailabilityByDatesByRoom() method while executing search available rooms query: %v\n", err))
+	}
+	if numRows > 0 {
+		return false, notAvailableReason, nil
+	}
+
+	roomRules, err := pg.GetRulesForRoomByDate(roomID, start, end)
+	if err != nil {
+		return false, i18n.Message{}, err
+	}
+	if reason := rules.Check(roomRules, start, end, time.Now()); !reason.IsZero() {
+		return false, reason, nil
 	}
-	return numRows == 0, nil
+	return true, i18n.Message{}, nil
 }
 
-// SearchAllAvailableRooms returns all available rooms if any, with the given date range
-func (pg *postgresDBRepo) SearchAllAvailableRooms(start, end time.Time) ([]models.Room, error) {
+// SearchAllAvailableRooms returns all available rooms if any, with the given date range, and the rooms
+// excluded from the search with the reason why
+func (pg *postgresDBRepo) SearchAllAvailableRooms(start, end time.Time, propertyID int) ([]models.Room, []models.ExcludedRoom, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	stmt, err := pg.DB.Prepare(SearchAllAvailableRooms)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while preparing query to search all available rooms: %v\n", err))
+		return nil, nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while preparing query to search all available rooms: %v\n", err))
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx, start, end)
+	rows, err := stmt.QueryContext(ctx, start, end, propertyID)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while executing query to search all available rooms: %v\n", err))
+		return nil, nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while executing query to search all available rooms: %v\n", err))
 	}
 	defer rows.Close()
 	if err = rows.Err(); err != nil {
-		return nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while checking for errors in the query rows: %v\n", err))
+		return nil, nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while checking for errors in the query rows: %v\n", err))
 	}
 
-	var rooms []models.Room
+	var candidates []models.Room
+	var excluded []models.ExcludedRoom
 	for rows.Next() {
 		var room models.Room
-		err = rows.Scan(&room.ID, &room.RoomName)
+		var booked bool
+		err = rows.Scan(&room.ID, &room.RoomName, &room.PropertyID, &room.PropertyName, &booked)
 		if err != nil {
-			return nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while scanning results into rooms model: %v\n", err))
+			return nil, nil, errors.New(fmt.Sprintf("error in SearchAllAvailableRooms() method while scanning results into rooms model: %v\n", err))
+		}
+		if booked {
+			excluded = append(excluded, models.ExcludedRoom{Room: room, Reason: notAvailableReason})
+			continue
+		}
+		candidates = append(candidates, room)
+	}
+
+	roomRules, err := pg.queryRoomRules(ctx, "SearchAllAvailableRooms", GetRoomRulesByDate, start, end)
+	if err != nil {
+		return nil, nil, err
+	}
+	rulesByRoom := make(map[int][]models.RoomRule)
+	for _, rule := range roomRules {
+		rulesByRoom[rule.RoomID] = append(rulesByRoom[rule.RoomID], rule)
+	}
+
+	var rooms []models.Room
+	for _, room := range candidates {
+		if reason := rules.Check(rulesByRoom[room.ID], start, end, time.Now()); !reason.IsZero() {
+			excluded = append(excluded, models.ExcludedRoom{Room: room, Reason: reason})
+			continue
 		}
 		rooms = append(rooms, room)
 	}
-	return rooms, nil
+	return rooms, excluded, nil
 }
 
 func (pg *postgresDBRepo) GetRoomByID(id int) (models.Room, error) {
@@ -165,7 +869,8 @@ func (pg *postgresDBRepo) GetRoomByID(id int) (models.Room, error) {
 	}
 	defer stmt.Close()
 
-	err = stmt.QueryRowContext(ctx, id).Scan(&room.ID, &room.RoomName, &room.CreatedAt, &room.UpdatedAt)
+	err = stmt.QueryRowContext(ctx, id).Scan(&room.ID, &room.RoomName, &room.Price, &room.PropertyID, &room.PropertyName,
+		&room.CreatedAt, &room.UpdatedAt)
 	if err != nil {
 		return room, errors.New(fmt.Sprintf("error in GetRoomByID() method while executing the query: %v\n", err))
 	}
@@ -184,7 +889,7 @@ func (pg *postgresDBRepo) GetUserByID(id int) (models.User, error) {
 	defer stmt.Close()
 
 	err = stmt.QueryRowContext(ctx, id).Scan(&user.ID, &user.FirstName, &user.LastName,
-		&user.Email, &user.Password, &user.AccessLevel, &user.CreatedAt, &user.UpdatedAt)
+		&user.Email, &user.Password, &user.AccessLevel, &user.PropertyID, &user.CreatedAt, &user.UpdatedAt)
 	if err != nil {
 		return user, errors.New(fmt.Sprintf("error in GetUserByID() method while executing the query: %v\n", err))
 	}
@@ -200,7 +905,8 @@ func (pg *postgresDBRepo) UpdateUser(user *models.User) error {
 		return errors.New(fmt.Sprintf("error in UpdateUser() method while preparing query to update a user: %v\n", err))
 	}
 	defer stmt.Close()
-	_, err = stmt.ExecContext(ctx, user.FirstName, user.LastName, user.Email, user.AccessLevel, user.UpdatedAt, user.ID)
+	_, err = stmt.ExecContext(ctx, user.FirstName, user.LastName, user.Email, user.AccessLevel, user.UpdatedAt, user.ID,
+		user.PropertyID)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error in UpdateUser() method while executing the query: %v\n", err))
 	}
@@ -233,68 +939,100 @@ func (pg *postgresDBRepo) Authenticate(email, testPass string) (int, string, err
 	return id, hashedPass, nil
 }
 
-// AllReservations returns a slice of all reservations
-func (pg *postgresDBRepo) AllReservations() ([]models.Reservation, error) {
+//reservationSortColumns are the columns reservations are sorted by for each models.ReservationSort column
+var reservationSortColumns = map[string][]string{
+	models.ReservationSortID:       {"rs.id"},
+	models.ReservationSortName:     {"lower(rs.last_name)", "lower(rs.first_name)"},
+	models.ReservationSortEmail:    {"lower(rs.email)"},
+	models.ReservationSortRoom:     {"r.room_name", "rs.check_in"},
+	models.ReservationSortCheckIn:  {"rs.check_in"},
+	models.ReservationSortCheckOut: {"rs.check_out"},
+	models.ReservationSortBooked:   {"rs.created_at"},
+}
+
+// SearchReservations returns a page of the reservations matching the search and the number of reservations matching
+// it on every page
+func (pg *postgresDBRepo) SearchReservations(search models.ReservationSearch) ([]models.Reservation, int, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	stmt, err := pg.DB.Prepare(AllReservations)
+
+	columns, ok := reservationSortColumns[search.Sort]
+	if !ok {
+		columns = reservationSortColumns[models.ReservationSortCheckIn]
+	}
+	direction := " asc"
+	if search.Desc {
+		direction = " desc"
+	}
+	order := strings.Join(columns, direction+", ") + direction + ", rs.id" + direction
+	stmt, err := pg.DB.Prepare(fmt.Sprintf(SearchReservations, order))
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error in AllReservations() method while preparing query to get all reservations: %v\n", err))
+		return nil, 0, errors.New(fmt.Sprintf("error in SearchReservations() method while preparing query to search reservations: %v\n", err))
 	}
 	defer stmt.Close()
 
-	rows, err := stmt.QueryContext(ctx)
+	args := append(reservationFilterArgs(search.ReservationFilter), search.PerPage, (search.Page-1)*search.PerPage)
+	rows, err := stmt.QueryContext(ctx, args...)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error in AllReservations() method while executing query to get all reservations: %v\n", err))
+		return nil, 0, errors.New(fmt.Sprintf("error in SearchReservations() method while executing query to search reservations: %v\n", err))
 	}
 	defer rows.Close()
-	if err = rows.Err(); err != nil {
-		return nil, errors.New(fmt.Sprintf("error in AllReservations() method while scanning rows for reservations: %v\n", err))
-	}
+
 	var reservations []models.Reservation
+	total := 0
 	for rows.Next() {
 		var rs models.Reservation
-		err = rows.Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.Phone,
-			&rs.CheckInDate, &rs.CheckOutDate, &rs.CreatedAt, &rs.UpdatedAt, &rs.RoomID,
-			&rs.Processed, &rs.Room.ID, &rs.Room.RoomName)
+		err = rows.Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.Phone, &rs.CheckInDate, &rs.CheckOutDate,
+			&rs.CreatedAt, &rs.UpdatedAt, &rs.RoomID, &rs.Processed, &rs.TotalAmount, &rs.Room.RoomName, &total)
 		if err != nil {
-			return nil, errors.New(fmt.Sprintf("error in AllReservations() method while scanning each row for reservation: %v\n", err))
+			return nil, 0, errors.New(fmt.Sprintf("error in SearchReservations() method while scanning each row for reservation: %v\n", err))
 		}
+		rs.Room.ID = rs.RoomID
 		reservations = append(reservations, rs)
 	}
-	return reservations, nil
+	if err = rows.Err(); err != nil {
+		return nil, 0, errors.New(fmt.Sprintf("error in SearchReservations() method while scanning rows for reservations: %v\n", err))
+	}
+	return reservations, total, nil
 }
 
-// AllNewReservations returns a slice of all new reservations
-func (pg *postgresDBRepo) AllNewReservations() ([]models.Reservation, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
-	defer cancel()
-	stmt, err := pg.DB.Prepare(AllNewReservations)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error in AllReservations() method while preparing query to get all reservations: %v\n", err))
+//reservationFilterArgs returns the arguments of reservationFilterWhere for the filter
+func reservationFilterArgs(filter models.ReservationFilter) []interface{} {
+	var start, end sql.NullTime
+	if !filter.Start.IsZero() {
+		start = sql.NullTime{Time: filter.Start, Valid: true}
 	}
-	defer stmt.Close()
-
-	rows, err := stmt.QueryContext(ctx)
-	if err != nil {
-		return nil, errors.New(fmt.Sprintf("error in AllReservations() method while executing query to get all reservations: %v\n", err))
+	if !filter.End.IsZero() {
+		end = sql.NullTime{Time: filter.End, Valid: true}
 	}
-	defer rows.Close()
-	if err = rows.Err(); err != nil {
-		return nil, errors.New(fmt.Sprintf("error in AllReservations() method while scanning rows for reservations: %v\n", err))
+	return []interface{}{start, end, filter.RoomID, filter.Status, likePattern(filter.Name), likePattern(filter.Email),
+		searchQuery(filter.Search), filter.PropertyID}
+}
+
+//likePattern returns a like pattern matching values containing s, or an empty string for an empty s
+func likePattern(s string) string {
+	if s == "" {
+		return ""
 	}
-	var reservations []models.Reservation
-	for rows.Next() {
-		var rs models.Reservation
-		err = rows.Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.Phone,
-			&rs.CheckInDate, &rs.CheckOutDate, &rs.CreatedAt, &rs.UpdatedAt, &rs.RoomID,
-			&rs.Room.ID, &rs.Room.RoomName)
-		if err != nil {
-			return nil, errors.New(fmt.Sprintf("error in AllReservations() method while scanning each row for reservation: %v\n", err))
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s) + "%"
+}
+
+//searchQuery returns a tsquery matching guests with words starting with each word of s, dropping the characters
+//tsquery gives a meaning to
+func searchQuery(s string) string {
+	var terms []string
+	for _, word := range strings.Fields(s) {
+		word = strings.Map(func(r rune) rune {
+			if unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("@.-_", r) {
+				return unicode.ToLower(r)
+			}
+			return -1
+		}, word)
+		if word = strings.Trim(word, ".-_"); word != "" {
+			terms = append(terms, word+":*")
 		}
-		reservations = append(reservations, rs)
 	}
-	return reservations, nil
+	return strings.Join(terms, " & ")
 }
 
 // GetReservationByID returns one reservation by ID
@@ -302,6 +1040,7 @@ func (pg *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	var rs models.Reservation
+	var checkedInAt, checkedOutAt sql.NullTime
 	stmt, err := pg.DB.Prepare(GetReservationByID)
 	if err != nil {
 		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while preparing query to get a reservation: %v\n", err))
@@ -310,10 +1049,60 @@ func (pg *postgresDBRepo) GetReservationByID(id int) (models.Reservation, error)
 
 	err = stmt.QueryRowContext(ctx, id).Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.Phone,
 		&rs.CheckInDate, &rs.CheckOutDate, &rs.CreatedAt, &rs.UpdatedAt, &rs.RoomID,
-		&rs.Processed, &rs.Room.ID, &rs.Room.RoomName)
+		&rs.Processed, &rs.Guests, &rs.RoomAmount, &rs.PromoCodeID, &rs.Discount, &rs.PromoCode, &rs.TotalAmount,
+		&rs.GuestID, &checkedInAt, &checkedOutAt, &rs.KeyNumber, &rs.IDVerification, &rs.Room.ID, &rs.Room.RoomName,
+		&rs.Room.PropertyID)
 	if err != nil {
 		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while executing query to get a reservation: %v\n", err))
 	}
+	rs.CheckedInAt = checkedInAt.Time
+	rs.CheckedOutAt = checkedOutAt.Time
+
+	taxStmt, err := pg.DB.Prepare(TaxesForReservation)
+	if err != nil {
+		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while preparing query to get reservation taxes: %v\n", err))
+	}
+	defer taxStmt.Close()
+	rows, err := taxStmt.QueryContext(ctx, id)
+	if err != nil {
+		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while executing query to get reservation taxes: %v\n", err))
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var tax models.ReservationTax
+		err = rows.Scan(&tax.ID, &tax.ReservationID, &tax.TaxRuleID, &tax.Name, &tax.Kind, &tax.Amount,
+			&tax.CreatedAt, &tax.UpdatedAt)
+		if err != nil {
+			return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while scanning each row for reservation tax: %v\n", err))
+		}
+		rs.Taxes = append(rs.Taxes, tax)
+	}
+	if err = rows.Err(); err != nil {
+		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while scanning rows for reservation taxes: %v\n", err))
+	}
+
+	extraStmt, err := pg.DB.Prepare(ExtrasForReservation)
+	if err != nil {
+		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while preparing query to get reservation extras: %v\n", err))
+	}
+	defer extraStmt.Close()
+	extraRows, err := extraStmt.QueryContext(ctx, id)
+	if err != nil {
+		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while executing query to get reservation extras: %v\n", err))
+	}
+	defer extraRows.Close()
+	for extraRows.Next() {
+		var extra models.ReservationExtra
+		err = extraRows.Scan(&extra.ID, &extra.ReservationID, &extra.ExtraID, &extra.Name, &extra.Unit, &extra.Quantity,
+			&extra.UnitPrice, &extra.Amount, &extra.CreatedAt, &extra.UpdatedAt)
+		if err != nil {
+			return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while scanning each row for reservation extra: %v\n", err))
+		}
+		rs.Extras = append(rs.Extras, extra)
+	}
+	if err = extraRows.Err(); err != nil {
+		return rs, errors.New(fmt.Sprintf("error in GetReservationByID() method while scanning rows for reservation extras: %v\n", err))
+	}
 	return rs, nil
 }
 
@@ -333,28 +1122,43 @@ func (pg *postgresDBRepo) UpdateReservation(res *models.Reservation) error {
 	return nil
 }
 
-// DeleteReservation deletes reservation by id
+// DeleteReservation deletes reservation by id, keeping a record of the cancellation for reporting
 func (pg *postgresDBRepo) DeleteReservation(id int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	stmt1, err := pg.DB.Prepare(DeleteRoomRestriction)
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteReservation() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var start, end time.Time
+	err = tx.QueryRowContext(ctx, LockReservation, id).Scan(new(int), &start, &end)
+	if err == sql.ErrNoRows {
+		return nil
+	}
 	if err != nil {
-		return errors.New(fmt.Sprintf("error in DeleteReservation() method while preparing query to delete a room restriction: %v\n", err))
+		return errors.New(fmt.Sprintf("error in DeleteReservation() method while locking reservation %d: %v\n", id, err))
 	}
-	defer stmt1.Close()
-	_, err = stmt1.ExecContext(ctx, id)
+	err = lockCalendar(ctx, tx, start, end)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error in DeleteReservation() method while executing the delete room restriction: %v\n", err))
+		return errors.New(fmt.Sprintf("error in DeleteReservation() method while locking calendar: %v\n", err))
 	}
-	stmt2, err := pg.DB.Prepare(DeleteReservation)
+	_, err = tx.ExecContext(ctx, InsertCancellation, id, time.Now())
 	if err != nil {
-		return errors.New(fmt.Sprintf("error in DeleteReservation() method while preparing query to delete a reservation: %v\n", err))
+		return errors.New(fmt.Sprintf("error in DeleteReservation() method while recording the cancellation: %v\n", err))
 	}
-	defer stmt2.Close()
-	_, err = stmt2.ExecContext(ctx, id)
+	_, err = tx.ExecContext(ctx, DeleteRoomRestriction, id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteReservation() method while executing the delete room restriction: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteReservation, id)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error in DeleteReservation() method while executing the delete reservation: %v\n", err))
 	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteReservation() method while committing transaction: %v\n", err))
+	}
 	return nil
 }
 
@@ -374,8 +1178,8 @@ func (pg *postgresDBRepo) UpdateProcessedReservation(id, processed int) error {
 	return nil
 }
 
-// AllRooms returns all the rooms
-func (pg *postgresDBRepo) AllRooms() ([]models.Room, error) {
+// AllRooms returns all the rooms of a property, or of every property if propertyID is 0
+func (pg *postgresDBRepo) AllRooms(propertyID int) ([]models.Room, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	stmt, err := pg.DB.Prepare(AllRooms)
@@ -384,7 +1188,7 @@ func (pg *postgresDBRepo) AllRooms() ([]models.Room, error) {
 	}
 	defer stmt.Close()
 	var rooms []models.Room
-	rows, err := stmt.QueryContext(ctx)
+	rows, err := stmt.QueryContext(ctx, propertyID)
 	if err != nil {
 		return nil, errors.New(fmt.Sprintf("error in AllRooms() method while executing query to get all rooms: %v\n", err))
 	}
@@ -395,7 +1199,7 @@ func (pg *postgresDBRepo) AllRooms() ([]models.Room, error) {
 
 	for rows.Next() {
 		var room models.Room
-		err = rows.Scan(&room.ID, &room.RoomName, &room.CreatedAt, &room.UpdatedAt)
+		err = rows.Scan(&room.ID, &room.RoomName, &room.Price, &room.PropertyID, &room.CreatedAt, &room.UpdatedAt)
 		if err = rows.Err(); err != nil {
 			return nil, errors.New(fmt.Sprintf("error in AllRooms() method while scanning each row to get a rooms: %v\n", err))
 		}
@@ -425,7 +1229,8 @@ func (pg *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end ti
 	var restrictions []models.RoomRestriction
 	for rows.Next() {
 		var res models.RoomRestriction
-		err = rows.Scan(&res.ID, &res.StartDate, &res.EndDate, &res.RoomID, &res.ReservationID, &res.RestrictionID)
+		err = rows.Scan(&res.ID, &res.StartDate, &res.EndDate, &res.RoomID, &res.ReservationID, &res.RestrictionID,
+			&res.Reason, &res.Notes)
 		if err = rows.Err(); err != nil {
 			return nil, errors.New(fmt.Sprintf("error in GetRestrictionsForRoomByDate() method while scanning each row to get room restriction: %v\n", err))
 		}
@@ -438,30 +1243,2699 @@ func (pg *postgresDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end ti
 func (pg postgresDBRepo) CreateBlockForRoom(id int, startDate time.Time) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	stmt, err := pg.DB.Prepare(CreateBlockForRoom)
+	tx, err := pg.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error in CreateBlockForRoom() method while preparing query to create room restriction: %v\n", err))
+		return errors.New(fmt.Sprintf("error in CreateBlockForRoom() method while starting transaction: %v\n", err))
 	}
-	defer stmt.Close()
-	_, err = stmt.ExecContext(ctx, startDate, startDate.AddDate(0, 0, 1), time.Now(), time.Now(), id, 2)
+	defer tx.Rollback()
+
+	err = lockCalendar(ctx, tx, startDate)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateBlockForRoom() method while locking calendar: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, CreateBlockForRoom, startDate, startDate.AddDate(0, 0, 1), time.Now(), time.Now(), id,
+		models.RestrictionBlock)
 	if err != nil {
 		return errors.New(fmt.Sprintf("error in CreateBlockForRoom() method while executing query to create room restriction: %v\n", err))
 	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in CreateBlockForRoom() method while committing transaction: %v\n", err))
+	}
 	return nil
 }
 
-//DeleteBlockByID deletes a room restriction
-func (pg postgresDBRepo) DeleteBlockByID(id int) error {
+//DeleteBlockByID deletes an owner block, returning the room and dates it blocked. A block which no longer exists is
+//returned with no room.
+func (pg postgresDBRepo) DeleteBlockByID(id int) (models.RoomRestriction, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	stmt, err := pg.DB.Prepare(DeleteBlockByID)
+	block := models.RoomRestriction{ID: id, RestrictionID: models.RestrictionBlock}
+	tx, err := pg.DB.BeginTx(ctx, nil)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error in DeleteBlockByID() method while preparing query to delete room restriction: %v\n", err))
+		return block, errors.New(fmt.Sprintf("error in DeleteBlockByID() method while starting transaction: %v\n", err))
 	}
-	defer stmt.Close()
-	_, err = stmt.ExecContext(ctx, id)
+	defer tx.Rollback()
+
+	var start, end time.Time
+	err = tx.QueryRowContext(ctx, RestrictionDates, id).Scan(&start, &end)
+	if err == sql.ErrNoRows {
+		return block, nil
+	}
+	if err != nil {
+		return block, errors.New(fmt.Sprintf("error in DeleteBlockByID() method while getting block dates: %v\n", err))
+	}
+	err = lockCalendar(ctx, tx, start, end)
+	if err != nil {
+		return block, errors.New(fmt.Sprintf("error in DeleteBlockByID() method while locking calendar: %v\n", err))
+	}
+	err = tx.QueryRowContext(ctx, DeleteBlockByID, id, models.RestrictionBlock, 0).Scan(&block.RoomID, &block.StartDate,
+		&block.EndDate)
+	if err != nil && err != sql.ErrNoRows {
+		return block, errors.New(fmt.Sprintf("error in DeleteBlockByID() method while executing query to delete room restriction: %v\n", err))
+	}
+	if err = tx.Commit(); err != nil {
+		return block, errors.New(fmt.Sprintf("error in DeleteBlockByID() method while committing transaction: %v\n", err))
+	}
+	return block, nil
+}
+
+//CreateBlocks blocks rooms for owner use or maintenance, each block covering the nights from its start date up to its
+//end date. The blocks are made together, and none are made if any room has a reservation or live hold for its dates,
+//returning repository.ErrRoomUnavailable.
+func (pg *postgresDBRepo) CreateBlocks(blocks []models.RoomRestriction) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateBlocks() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var dates []time.Time
+	for _, b := range blocks {
+		dates = append(dates, b.StartDate, b.EndDate)
+	}
+	err = lockCalendar(ctx, tx, dates...)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateBlocks() method while locking calendar: %v\n", err))
+	}
+	// lock the rooms in order so blocks made together do not deadlock
+	var roomIDs []int
+	locked := make(map[int]bool)
+	for _, b := range blocks {
+		if !locked[b.RoomID] {
+			locked[b.RoomID] = true
+			roomIDs = append(roomIDs, b.RoomID)
+		}
+	}
+	sort.Ints(roomIDs)
+	for _, id := range roomIDs {
+		err = tx.QueryRowContext(ctx, LockRoom, id).Scan(new(int))
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in CreateBlocks() method while locking room %d: %v\n", id, err))
+		}
+	}
+
+	now := time.Now()
+	for i := range blocks {
+		b := &blocks[i]
+		numRows := 0
+		err = tx.QueryRowContext(ctx, SearchBookingsForBlock, b.RoomID, b.StartDate, b.EndDate,
+			models.RestrictionBlock).Scan(&numRows)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in CreateBlocks() method while checking room availability: %v\n", err))
+		}
+		if numRows > 0 {
+			return repository.ErrRoomUnavailable
+		}
+		b.RestrictionID = models.RestrictionBlock
+		b.CreatedAt = now
+		b.UpdatedAt = now
+		err = tx.QueryRowContext(ctx, InsertBlock, b.StartDate, b.EndDate, now, b.RoomID, b.RestrictionID, b.Reason,
+			b.Notes).Scan(&b.ID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in CreateBlocks() method while creating block: %v\n", err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in CreateBlocks() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+// AllRoomRules returns all the room rules
+func (pg *postgresDBRepo) AllRoomRules() ([]models.RoomRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AllRoomRules)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllRoomRules() method while preparing query to get all room rules: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllRoomRules() method while executing query to get all room rules: %v\n", err))
+	}
+	defer rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllRoomRules() method while scanning rows for room rules: %v\n", err))
+	}
+	var roomRules []models.RoomRule
+	for rows.Next() {
+		var rule models.RoomRule
+		err = rows.Scan(&rule.ID, &rule.RoomID, &rule.StartDate, &rule.EndDate, &rule.MinStay, &rule.MaxStay,
+			&rule.ClosedToArrival, &rule.ClosedToDeparture, &rule.MinAdvanceDays, &rule.MaxAdvanceDays,
+			&rule.CreatedAt, &rule.UpdatedAt, &rule.Room.ID, &rule.Room.RoomName)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AllRoomRules() method while scanning each row for room rule: %v\n", err))
+		}
+		roomRules = append(roomRules, rule)
+	}
+	return roomRules, nil
+}
+
+//GetRulesForRoomByDate returns all rules for a particular room which overlap the date range
+func (pg *postgresDBRepo) GetRulesForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return pg.queryRoomRules(ctx, "GetRulesForRoomByDate", GetRoomRulesForRoomByDate, start, end, roomID)
+}
+
+// queryRoomRules runs one of the room rules queries taking the date range as its first two arguments
+func (pg *postgresDBRepo) queryRoomRules(ctx context.Context, method, query string, args ...interface{}) ([]models.RoomRule, error) {
+	stmt, err := pg.DB.Prepare(query)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while preparing query to get room rules: %v\n", method, err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while executing query to get room rules: %v\n", method, err))
+	}
+	defer rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while scanning rows for room rules: %v\n", method, err))
+	}
+	var roomRules []models.RoomRule
+	for rows.Next() {
+		var rule models.RoomRule
+		err = rows.Scan(&rule.ID, &rule.RoomID, &rule.StartDate, &rule.EndDate, &rule.MinStay, &rule.MaxStay,
+			&rule.ClosedToArrival, &rule.ClosedToDeparture, &rule.MinAdvanceDays, &rule.MaxAdvanceDays)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in %s() method while scanning each row for room rule: %v\n", method, err))
+		}
+		roomRules = append(roomRules, rule)
+	}
+	return roomRules, nil
+}
+
+//CreateRoomRule inserts a new room rule
+func (pg *postgresDBRepo) CreateRoomRule(rule *models.RoomRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertRoomRule)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateRoomRule() method while preparing query to create room rule: %v\n", err))
+	}
+	defer stmt.Close()
+
+	ruleID := 0
+	err = stmt.QueryRowContext(ctx, rule.RoomID, rule.StartDate, rule.EndDate, rule.MinStay, rule.MaxStay,
+		rule.ClosedToArrival, rule.ClosedToDeparture, rule.MinAdvanceDays, rule.MaxAdvanceDays,
+		time.Now(), time.Now()).Scan(&ruleID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateRoomRule() method while executing query to create room rule: %v\n", err))
+	}
+	rule.ID = ruleID
+	return nil
+}
+
+//DeleteRoomRuleByID deletes a room rule, unless its room is not at the property. Rules at every property can be
+//deleted for propertyID 0.
+func (pg *postgresDBRepo) DeleteRoomRuleByID(id, propertyID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(DeleteRoomRuleByID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteRoomRuleByID() method while preparing query to delete room rule: %v\n", err))
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, id, propertyID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteRoomRuleByID() method while executing query to delete room rule: %v\n", err))
+	}
+	return nil
+}
+
+//AvailabilityCalendar returns every room, or only the given room if roomID is not 0, with the availability,
+//price and rule flags of each night from start up to but not including end
+func (pg *postgresDBRepo) AvailabilityCalendar(start, end time.Time, roomID int) ([]models.RoomCalendar, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AvailabilityCalendar)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AvailabilityCalendar() method while preparing query to get the availability calendar: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, start, end, roomID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AvailabilityCalendar() method while executing query to get the availability calendar: %v\n", err))
+	}
+	defer rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AvailabilityCalendar() method while scanning rows for the availability calendar: %v\n", err))
+	}
+
+	var calendars []models.RoomCalendar
+	for rows.Next() {
+		var room models.Room
+		var night models.CalendarNight
+		err = rows.Scan(&room.ID, &room.RoomName, &room.Price, &night.Date, &night.Booked, &night.MinStay, &night.MaxStay,
+			&night.ClosedToArrival, &night.ClosedToDeparture, &night.MinAdvanceDays, &night.MaxAdvanceDays)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AvailabilityCalendar() method while scanning each row for a night: %v\n", err))
+		}
+		night.Price = room.Price
+		if len(calendars) == 0 || calendars[len(calendars)-1].Room.ID != room.ID {
+			calendars = append(calendars, models.RoomCalendar{Room: room})
+		}
+		last := &calendars[len(calendars)-1]
+		last.Nights = append(last.Nights, night)
+	}
+	return calendars, nil
+}
+
+//AllExchangeRates returns the exchange rates from the base currency of the property
+func (pg *postgresDBRepo) AllExchangeRates() ([]models.ExchangeRate, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AllExchangeRates)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllExchangeRates() method while preparing query to get exchange rates: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllExchangeRates() method while executing query to get exchange rates: %v\n", err))
+	}
+	defer rows.Close()
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllExchangeRates() method while scanning rows for exchange rates: %v\n", err))
+	}
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		err = rows.Scan(&rate.ID, &rate.CurrencyCode, &rate.Rate, &rate.CreatedAt, &rate.UpdatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AllExchangeRates() method while scanning each row for exchange rate: %v\n", err))
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+//SaveExchangeRates inserts or updates the exchange rates in a single transaction, so an import is applied completely or not at all
+func (pg *postgresDBRepo) SaveExchangeRates(rates []models.ExchangeRate) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SaveExchangeRates() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, UpsertExchangeRate)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SaveExchangeRates() method while preparing query to save exchange rates: %v\n", err))
+	}
+	defer stmt.Close()
+
+	for _, rate := range rates {
+		_, err = stmt.ExecContext(ctx, rate.CurrencyCode, rate.Rate, time.Now(), time.Now())
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in SaveExchangeRates() method while saving exchange rate for %s: %v\n", rate.CurrencyCode, err))
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in SaveExchangeRates() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//DeleteExchangeRate deletes the exchange rate of a currency
+func (pg *postgresDBRepo) DeleteExchangeRate(code string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(DeleteExchangeRate)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteExchangeRate() method while preparing query to delete exchange rate: %v\n", err))
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, code)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteExchangeRate() method while executing query to delete exchange rate: %v\n", err))
+	}
+	return nil
+}
+
+//CreatePayment creates a payment for a reservation in the database
+func (pg *postgresDBRepo) CreatePayment(p *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertPayment)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreatePayment() method while preparing query to create payment: %v\n", err))
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, p.ReservationID, p.Provider, p.Reference, p.CheckoutURL, p.Amount, p.Currency,
+		p.Status, p.Captured, p.Refunded, time.Now(), time.Now()).Scan(&p.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreatePayment() method while creating payment: %v\n", err))
+	}
+	return nil
+}
+
+//GetPaymentByID returns one payment by id
+func (pg *postgresDBRepo) GetPaymentByID(id int) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return pg.queryPayment(ctx, "GetPaymentByID", GetPaymentByID, id)
+}
+
+//GetPaymentByReference returns the payment with the reference given by the payment gateway
+func (pg *postgresDBRepo) GetPaymentByReference(reference string) (models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	return pg.queryPayment(ctx, "GetPaymentByReference", GetPaymentByReference, reference)
+}
+
+func (pg *postgresDBRepo) queryPayment(ctx context.Context, method, query string, arg interface{}) (models.Payment, error) {
+	var p models.Payment
+	stmt, err := pg.DB.Prepare(query)
+	if err != nil {
+		return p, errors.New(fmt.Sprintf("error in %s() method while preparing query to get a payment: %v\n", method, err))
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, arg).Scan(&p.ID, &p.ReservationID, &p.Provider, &p.Reference, &p.CheckoutURL,
+		&p.Amount, &p.Currency, &p.Status, &p.Captured, &p.Refunded, &p.CreatedAt, &p.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return p, repository.ErrPaymentNotFound
+	}
+	if err != nil {
+		return p, errors.New(fmt.Sprintf("error in %s() method while executing query to get a payment: %v\n", method, err))
+	}
+	return p, nil
+}
+
+//UpdatePayment updates the reference, checkout, status and amounts captured and refunded of a payment
+func (pg *postgresDBRepo) UpdatePayment(p *models.Payment) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(UpdatePayment)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdatePayment() method while preparing query to update payment: %v\n", err))
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, p.Reference, p.CheckoutURL, p.Status, p.Captured, p.Refunded, time.Now(), p.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdatePayment() method while executing query to update payment: %v\n", err))
+	}
+	return nil
+}
+
+//PaymentsForReservation returns the payments of a reservation, oldest first
+func (pg *postgresDBRepo) PaymentsForReservation(reservationID int) ([]models.Payment, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(PaymentsForReservation)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in PaymentsForReservation() method while preparing query to get payments: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, reservationID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in PaymentsForReservation() method while executing query to get payments: %v\n", err))
+	}
+	defer rows.Close()
+
+	var payments []models.Payment
+	for rows.Next() {
+		var p models.Payment
+		err = rows.Scan(&p.ID, &p.ReservationID, &p.Provider, &p.Reference, &p.CheckoutURL, &p.Amount, &p.Currency,
+			&p.Status, &p.Captured, &p.Refunded, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in PaymentsForReservation() method while scanning each row for payment: %v\n", err))
+		}
+		payments = append(payments, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in PaymentsForReservation() method while scanning rows for payments: %v\n", err))
+	}
+	return payments, nil
+}
+
+//FolioItemsForReservation returns the charges added to a reservation by staff, oldest first
+func (pg *postgresDBRepo) FolioItemsForReservation(reservationID int) ([]models.FolioItem, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(FolioItemsForReservation)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in FolioItemsForReservation() method while preparing query to get folio items: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, reservationID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in FolioItemsForReservation() method while executing query to get folio items: %v\n", err))
+	}
+	defer rows.Close()
+
+	var items []models.FolioItem
+	for rows.Next() {
+		var item models.FolioItem
+		err = rows.Scan(&item.ID, &item.ReservationID, &item.Kind, &item.Description, &item.Quantity, &item.UnitAmount,
+			&item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in FolioItemsForReservation() method while scanning each row for folio item: %v\n", err))
+		}
+		items = append(items, item)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in FolioItemsForReservation() method while scanning rows for folio items: %v\n", err))
+	}
+	return items, nil
+}
+
+//CreateFolioItem adds a charge to the folio of a reservation
+func (pg *postgresDBRepo) CreateFolioItem(item *models.FolioItem) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertFolioItem)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateFolioItem() method while preparing query to create folio item: %v\n", err))
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, item.ReservationID, item.Kind, item.Description, item.Quantity, item.UnitAmount,
+		time.Now(), time.Now()).Scan(&item.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateFolioItem() method while creating folio item: %v\n", err))
+	}
+	return nil
+}
+
+//DeleteFolioItem deletes a charge from the folio of a reservation
+func (pg *postgresDBRepo) DeleteFolioItem(reservationID, id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(DeleteFolioItem)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteFolioItem() method while preparing query to delete folio item: %v\n", err))
+	}
+	defer stmt.Close()
+
+	_, err = stmt.ExecContext(ctx, id, reservationID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteFolioItem() method while executing query to delete folio item: %v\n", err))
+	}
+	return nil
+}
+
+//InvoicesForReservation returns the invoices of a reservation without their PDF, oldest first
+func (pg *postgresDBRepo) InvoicesForReservation(reservationID int) ([]models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InvoicesForReservation)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in InvoicesForReservation() method while preparing query to get invoices: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, reservationID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in InvoicesForReservation() method while executing query to get invoices: %v\n", err))
+	}
+	defer rows.Close()
+
+	var invoices []models.Invoice
+	for rows.Next() {
+		var inv models.Invoice
+		err = rows.Scan(&inv.ID, &inv.ReservationID, &inv.Number, &inv.Total, &inv.Currency, &inv.CreatedAt, &inv.UpdatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in InvoicesForReservation() method while scanning each row for invoice: %v\n", err))
+		}
+		invoices = append(invoices, inv)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in InvoicesForReservation() method while scanning rows for invoices: %v\n", err))
+	}
+	return invoices, nil
+}
+
+//GetInvoiceByID returns one invoice with its PDF
+func (pg *postgresDBRepo) GetInvoiceByID(id int) (models.Invoice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var inv models.Invoice
+	stmt, err := pg.DB.Prepare(GetInvoiceByID)
+	if err != nil {
+		return inv, errors.New(fmt.Sprintf("error in GetInvoiceByID() method while preparing query to get an invoice: %v\n", err))
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&inv.ID, &inv.ReservationID, &inv.Number, &inv.Total, &inv.Currency, &inv.PDF,
+		&inv.CreatedAt, &inv.UpdatedAt)
+	if err != nil {
+		return inv, errors.New(fmt.Sprintf("error in GetInvoiceByID() method while executing query to get an invoice: %v\n", err))
+	}
+	return inv, nil
+}
+
+//CreateInvoice issues the next invoice number and saves the invoice with the PDF rendered for that number. Both happen
+//in one transaction, so a failed invoice does not use up a number.
+func (pg *postgresDBRepo) CreateInvoice(inv *models.Invoice, renderPDF func(number string) ([]byte, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateInvoice() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var number int
+	err = tx.QueryRowContext(ctx, NextInvoiceNumber).Scan(&number)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateInvoice() method while issuing invoice number: %v\n", err))
+	}
+	inv.Number = fmt.Sprintf(invoiceNumberFormat, number)
+	inv.PDF, err = renderPDF(inv.Number)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateInvoice() method while rendering invoice %s: %v\n", inv.Number, err))
+	}
+
+	inv.CreatedAt = time.Now()
+	inv.UpdatedAt = inv.CreatedAt
+	err = tx.QueryRowContext(ctx, InsertInvoice, inv.ReservationID, inv.Number, inv.Total, inv.Currency, inv.PDF,
+		inv.CreatedAt, inv.UpdatedAt).Scan(&inv.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateInvoice() method while creating invoice: %v\n", err))
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in CreateInvoice() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//AllTaxRules returns every tax and fee rule with the room it is limited to, if any
+func (pg *postgresDBRepo) AllTaxRules() ([]models.TaxRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AllTaxRules)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllTaxRules() method while preparing query to get all tax rules: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllTaxRules() method while executing query to get all tax rules: %v\n", err))
+	}
+	defer rows.Close()
+
+	var taxRules []models.TaxRule
+	for rows.Next() {
+		var rule models.TaxRule
+		err = rows.Scan(&rule.ID, &rule.Name, &rule.Kind, &rule.Amount, &rule.RoomID, &rule.StartDate, &rule.EndDate,
+			&rule.CreatedAt, &rule.UpdatedAt, &rule.Room.ID, &rule.Room.RoomName)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AllTaxRules() method while scanning each row for tax rule: %v\n", err))
+		}
+		taxRules = append(taxRules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllTaxRules() method while scanning rows for tax rules: %v\n", err))
+	}
+	return taxRules, nil
+}
+
+//GetTaxRulesForRoomByDate returns the tax and fee rules of a room valid for any night from start up to but not
+//including end, including the rules which apply to every room
+func (pg *postgresDBRepo) GetTaxRulesForRoomByDate(roomID int, start, end time.Time) ([]models.TaxRule, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(GetTaxRulesForRoomByDate)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in GetTaxRulesForRoomByDate() method while preparing query to get tax rules: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, start, end, roomID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in GetTaxRulesForRoomByDate() method while executing query to get tax rules: %v\n", err))
+	}
+	defer rows.Close()
+
+	var taxRules []models.TaxRule
+	for rows.Next() {
+		var rule models.TaxRule
+		err = rows.Scan(&rule.ID, &rule.Name, &rule.Kind, &rule.Amount, &rule.RoomID, &rule.StartDate, &rule.EndDate,
+			&rule.CreatedAt, &rule.UpdatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in GetTaxRulesForRoomByDate() method while scanning each row for tax rule: %v\n", err))
+		}
+		taxRules = append(taxRules, rule)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in GetTaxRulesForRoomByDate() method while scanning rows for tax rules: %v\n", err))
+	}
+	return taxRules, nil
+}
+
+//CreateTaxRule creates a tax or fee rule
+func (pg *postgresDBRepo) CreateTaxRule(rule *models.TaxRule) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertTaxRule)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateTaxRule() method while preparing query to create tax rule: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = rule.CreatedAt
+	err = stmt.QueryRowContext(ctx, rule.Name, rule.Kind, rule.Amount, rule.RoomID, rule.StartDate, rule.EndDate,
+		rule.CreatedAt, rule.UpdatedAt).Scan(&rule.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateTaxRule() method while executing query to create tax rule: %v\n", err))
+	}
+	return nil
+}
+
+//DeleteTaxRuleByID deletes a tax or fee rule. Reservations keep the taxes they were charged.
+func (pg *postgresDBRepo) DeleteTaxRuleByID(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(DeleteTaxRuleByID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteTaxRuleByID() method while preparing query to delete tax rule: %v\n", err))
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeleteTaxRuleByID() method while executing query to delete tax rule: %v\n", err))
+	}
+	return nil
+}
+
+//GetPromoCodeByCode returns a promo code with the rooms it is limited to, or repository.ErrPromoCodeNotFound if there
+//is no such code
+func (pg *postgresDBRepo) GetPromoCodeByCode(code string) (models.PromoCode, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(GetPromoCodeByCode)
+	if err != nil {
+		return models.PromoCode{}, errors.New(fmt.Sprintf("error in GetPromoCodeByCode() method while preparing query to get a promo code: %v\n", err))
+	}
+	defer stmt.Close()
+
+	p, err := scanPromoCode(stmt.QueryRowContext(ctx, code))
+	if err == sql.ErrNoRows {
+		return p, repository.ErrPromoCodeNotFound
+	}
+	if err != nil {
+		return p, errors.New(fmt.Sprintf("error in GetPromoCodeByCode() method while executing query to get a promo code: %v\n", err))
+	}
+	return p, nil
+}
+
+//AllPromoCodeStats returns every promo code with the number of reservations made with it, the discount they were
+//given and what they were charged
+func (pg *postgresDBRepo) AllPromoCodeStats() ([]models.PromoCodeStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AllPromoCodeStats)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllPromoCodeStats() method while preparing query to get promo codes: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllPromoCodeStats() method while executing query to get promo codes: %v\n", err))
+	}
+	defer rows.Close()
+
+	var stats []models.PromoCodeStats
+	for rows.Next() {
+		var st models.PromoCodeStats
+		st.PromoCode, err = scanPromoCode(rows, &st.Reservations, &st.Discount, &st.Revenue)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AllPromoCodeStats() method while scanning each row for promo code: %v\n", err))
+		}
+		stats = append(stats, st)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllPromoCodeStats() method while scanning rows for promo codes: %v\n", err))
+	}
+	return stats, nil
+}
+
+//scanPromoCode scans the columns of a promo code followed by the ids of its rooms, then any extra columns
+func scanPromoCode(row interface{ Scan(...interface{}) error }, extra ...interface{}) (models.PromoCode, error) {
+	var p models.PromoCode
+	var stayStart, stayEnd sql.NullTime
+	var roomIDs []int64
+	dest := []interface{}{&p.ID, &p.Code, &p.Description, &p.Kind, &p.Amount, &p.ValidFrom, &p.ValidTo, &stayStart,
+		&stayEnd, &p.MinNights, &p.MaxUses, &p.Uses, &p.CreatedAt, &p.UpdatedAt, pq.Array(&roomIDs)}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return p, err
+	}
+	p.StayStart = stayStart.Time
+	p.StayEnd = stayEnd.Time
+	for _, id := range roomIDs {
+		p.RoomIDs = append(p.RoomIDs, int(id))
+	}
+	return p, nil
+}
+
+//CreatePromoCode creates a promo code and the rooms it is limited to
+func (pg *postgresDBRepo) CreatePromoCode(p *models.PromoCode) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreatePromoCode() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var stayStart, stayEnd sql.NullTime
+	if !p.StayStart.IsZero() {
+		stayStart = sql.NullTime{Time: p.StayStart, Valid: true}
+	}
+	if !p.StayEnd.IsZero() {
+		stayEnd = sql.NullTime{Time: p.StayEnd, Valid: true}
+	}
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	err = tx.QueryRowContext(ctx, InsertPromoCode, p.Code, p.Description, p.Kind, p.Amount, p.ValidFrom, p.ValidTo,
+		stayStart, stayEnd, p.MinNights, p.MaxUses, p.CreatedAt, p.UpdatedAt).Scan(&p.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreatePromoCode() method while creating promo code: %v\n", err))
+	}
+	for _, roomID := range p.RoomIDs {
+		_, err = tx.ExecContext(ctx, InsertPromoCodeRoom, p.ID, roomID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in CreatePromoCode() method while adding room to promo code: %v\n", err))
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in CreatePromoCode() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//DeletePromoCode deletes a promo code. Reservations made with it keep their discount.
+func (pg *postgresDBRepo) DeletePromoCode(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(DeletePromoCode)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeletePromoCode() method while preparing query to delete promo code: %v\n", err))
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in DeletePromoCode() method while executing query to delete promo code: %v\n", err))
+	}
+	return nil
+}
+
+//AllExtras returns every extra, including the ones no longer offered to guests
+func (pg *postgresDBRepo) AllExtras() ([]models.Extra, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AllExtras)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllExtras() method while preparing query to get all extras: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllExtras() method while executing query to get all extras: %v\n", err))
+	}
+	defer rows.Close()
+
+	var extras []models.Extra
+	for rows.Next() {
+		var e models.Extra
+		err = rows.Scan(&e.ID, &e.Name, &e.Description, &e.Unit, &e.Price, &e.Inventory, &e.Active, &e.CreatedAt, &e.UpdatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AllExtras() method while scanning each row for extra: %v\n", err))
+		}
+		extras = append(extras, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllExtras() method while scanning rows for extras: %v\n", err))
+	}
+	return extras, nil
+}
+
+//ExtrasBookedByDate returns, by extra id, the most booked on any night from start up to but not including end
+func (pg *postgresDBRepo) ExtrasBookedByDate(start, end time.Time) (map[int]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(ExtrasBookedByDate)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in ExtrasBookedByDate() method while preparing query to count extras: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, start, end, 0)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in ExtrasBookedByDate() method while executing query to count extras: %v\n", err))
+	}
+	defer rows.Close()
+
+	booked := make(map[int]int)
+	for rows.Next() {
+		var id, n int
+		err = rows.Scan(&id, &n)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in ExtrasBookedByDate() method while scanning each row for extra: %v\n", err))
+		}
+		booked[id] = n
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in ExtrasBookedByDate() method while scanning rows for extras: %v\n", err))
+	}
+	return booked, nil
+}
+
+//CreateExtra adds an extra to the catalogue
+func (pg *postgresDBRepo) CreateExtra(e *models.Extra) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertExtra)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateExtra() method while preparing query to create extra: %v\n", err))
+	}
+	defer stmt.Close()
+
+	e.CreatedAt = time.Now()
+	e.UpdatedAt = e.CreatedAt
+	err = stmt.QueryRowContext(ctx, e.Name, e.Description, e.Unit, e.Price, e.Inventory, e.Active,
+		e.CreatedAt, e.UpdatedAt).Scan(&e.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateExtra() method while executing query to create extra: %v\n", err))
+	}
+	return nil
+}
+
+//SetExtraActive offers an extra to guests or withdraws it. Reservations keep the extras they were booked with.
+func (pg *postgresDBRepo) SetExtraActive(id int, active bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(SetExtraActive)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetExtraActive() method while preparing query to update extra: %v\n", err))
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, active, time.Now(), id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetExtraActive() method while executing query to update extra: %v\n", err))
+	}
+	return nil
+}
+
+//scanWaitlistEntry scans the waitlist columns into an entry, with the name of the room joined to it
+func scanWaitlistEntry(row interface{ Scan(...interface{}) error }) (models.WaitlistEntry, error) {
+	var e models.WaitlistEntry
+	var offeredAt, expiresAt sql.NullTime
+	err := row.Scan(&e.ID, &e.FirstName, &e.LastName, &e.Email, &e.Phone, &e.Locale, &e.PropertyID, &e.RoomID,
+		&e.StartDate, &e.EndDate, &e.Status, &e.OfferedRoomID, &e.Token, &offeredAt, &expiresAt, &e.CreatedAt, &e.UpdatedAt,
+		&e.Room.RoomName)
+	e.OfferedAt = offeredAt.Time
+	e.ExpiresAt = expiresAt.Time
+	return e, err
+}
+
+//queryWaitlistEntries runs a query returning waitlist entries
+func (pg *postgresDBRepo) queryWaitlistEntries(method, query string, args ...interface{}) ([]models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(query)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while preparing query to get waitlist entries: %v\n", method, err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while executing query to get waitlist entries: %v\n", method, err))
+	}
+	defer rows.Close()
+
+	var entries []models.WaitlistEntry
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in %s() method while scanning each row for waitlist entry: %v\n", method, err))
+		}
+		entries = append(entries, e)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while scanning rows for waitlist entries: %v\n", method, err))
+	}
+	return entries, nil
+}
+
+//CreateWaitlistEntry puts a guest on the waitlist
+func (pg *postgresDBRepo) CreateWaitlistEntry(e *models.WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertWaitlistEntry)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateWaitlistEntry() method while preparing query to create waitlist entry: %v\n", err))
+	}
+	defer stmt.Close()
+
+	e.CreatedAt = time.Now()
+	e.UpdatedAt = e.CreatedAt
+	err = stmt.QueryRowContext(ctx, e.FirstName, e.LastName, e.Email, e.Phone, e.Locale, e.RoomID, e.StartDate, e.EndDate,
+		e.Status, e.CreatedAt, e.UpdatedAt, e.PropertyID).Scan(&e.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateWaitlistEntry() method while executing query to create waitlist entry: %v\n", err))
+	}
+	return nil
+}
+
+//WaitingEntriesForRoom returns the guests still waiting who would take the room for a stay sharing a night with
+//start to end, in the order they joined the waitlist. Guests waiting for any room only take the rooms of the
+//property they joined the waitlist at.
+func (pg *postgresDBRepo) WaitingEntriesForRoom(roomID int, start, end time.Time) ([]models.WaitlistEntry, error) {
+	return pg.queryWaitlistEntries("WaitingEntriesForRoom", WaitingEntriesForRoom, roomID, start, end)
+}
+
+//ExpiredWaitlistOffers returns the entries whose booking link expired at or before now without being used
+func (pg *postgresDBRepo) ExpiredWaitlistOffers(now time.Time) ([]models.WaitlistEntry, error) {
+	return pg.queryWaitlistEntries("ExpiredWaitlistOffers", ExpiredWaitlistOffers, now)
+}
+
+//OfferWaitlistEntry records the room and booking link offered to a waiting guest. It returns
+//repository.ErrWaitlistEntryNotFound if the guest is no longer waiting.
+func (pg *postgresDBRepo) OfferWaitlistEntry(e *models.WaitlistEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(OfferWaitlistEntry)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in OfferWaitlistEntry() method while preparing query to offer a room: %v\n", err))
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, e.OfferedRoomID, e.Token, e.OfferedAt, e.ExpiresAt, e.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in OfferWaitlistEntry() method while executing query to offer a room: %v\n", err))
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in OfferWaitlistEntry() method while counting rows updated: %v\n", err))
+	}
+	if n == 0 {
+		return repository.ErrWaitlistEntryNotFound
+	}
+	e.Status = "offered"
+	return nil
+}
+
+//GetWaitlistEntryByToken returns the entry a booking link was sent for, with the room offered, or
+//repository.ErrWaitlistEntryNotFound if there is none
+func (pg *postgresDBRepo) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(GetWaitlistEntryByToken)
+	if err != nil {
+		return models.WaitlistEntry{}, errors.New(fmt.Sprintf("error in GetWaitlistEntryByToken() method while preparing query to get waitlist entry: %v\n", err))
+	}
+	defer stmt.Close()
+
+	e, err := scanWaitlistEntry(stmt.QueryRowContext(ctx, token))
+	if err == sql.ErrNoRows {
+		return e, repository.ErrWaitlistEntryNotFound
+	}
+	if err != nil {
+		return e, errors.New(fmt.Sprintf("error in GetWaitlistEntryByToken() method while executing query to get waitlist entry: %v\n", err))
+	}
+	return e, nil
+}
+
+//UpdateWaitlistEntryStatus moves a waitlist entry to a new status
+func (pg *postgresDBRepo) UpdateWaitlistEntryStatus(id int, status string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(UpdateWaitlistEntryStatus)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateWaitlistEntryStatus() method while preparing query to update waitlist entry: %v\n", err))
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, status, time.Now(), id)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateWaitlistEntryStatus() method while executing query to update waitlist entry: %v\n", err))
+	}
+	return nil
+}
+
+//HoldRoom holds a room for a guest until hold.ExpiresAt, releasing the hold the guest had before if releaseID is not
+//0. The room is locked while it is checked, so two guests cannot hold it at once, and repository.ErrRoomUnavailable
+//is returned if another restriction makes it unavailable for the dates.
+func (pg *postgresDBRepo) HoldRoom(hold *models.RoomRestriction, releaseID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in HoldRoom() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	err = lockCalendar(ctx, tx, hold.StartDate, hold.EndDate)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in HoldRoom() method while locking calendar: %v\n", err))
+	}
+	err = tx.QueryRowContext(ctx, LockRoom, hold.RoomID).Scan(new(int))
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in HoldRoom() method while locking room %d: %v\n", hold.RoomID, err))
+	}
+	if releaseID != 0 {
+		_, err = tx.ExecContext(ctx, ReleaseHold, releaseID, models.RestrictionHold)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in HoldRoom() method while releasing hold %d: %v\n", releaseID, err))
+		}
+	}
+	numRows := 0
+	err = tx.QueryRowContext(ctx, SearchAvailableRoomByDate, hold.RoomID, hold.StartDate, hold.EndDate).Scan(&numRows)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in HoldRoom() method while checking room availability: %v\n", err))
+	}
+	if numRows > 0 {
+		// commit to keep the release of the old hold
+		if err = tx.Commit(); err != nil {
+			return errors.New(fmt.Sprintf("error in HoldRoom() method while committing transaction: %v\n", err))
+		}
+		return repository.ErrRoomUnavailable
+	}
+
+	hold.RestrictionID = models.RestrictionHold
+	hold.CreatedAt = time.Now()
+	hold.UpdatedAt = hold.CreatedAt
+	err = tx.QueryRowContext(ctx, InsertHold, hold.StartDate, hold.EndDate, hold.CreatedAt, hold.RoomID,
+		hold.RestrictionID, hold.ExpiresAt).Scan(&hold.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in HoldRoom() method while creating hold: %v\n", err))
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in HoldRoom() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//ReleaseHold releases a hold before it expires
+func (pg *postgresDBRepo) ReleaseHold(id int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(ReleaseHold)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in ReleaseHold() method while preparing query to release hold: %v\n", err))
+	}
+	defer stmt.Close()
+	_, err = stmt.ExecContext(ctx, id, models.RestrictionHold)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in ReleaseHold() method while executing query to release hold: %v\n", err))
+	}
+	return nil
+}
+
+//ConvertHold turns a hold into the restriction of the reservation made while it was held. It returns
+//repository.ErrHoldExpired if the hold has expired or was released.
+func (pg *postgresDBRepo) ConvertHold(id, reservationID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in ConvertHold() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var start, end time.Time
+	err = tx.QueryRowContext(ctx, RestrictionDates, id).Scan(&start, &end)
+	if err == sql.ErrNoRows {
+		return repository.ErrHoldExpired
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in ConvertHold() method while getting hold dates: %v\n", err))
+	}
+	err = lockCalendar(ctx, tx, start, end)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in ConvertHold() method while locking calendar: %v\n", err))
+	}
+	result, err := tx.ExecContext(ctx, ConvertHold, reservationID, time.Now(), id, models.RestrictionReservation,
+		models.RestrictionHold)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in ConvertHold() method while executing query to convert hold: %v\n", err))
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in ConvertHold() method while counting rows updated: %v\n", err))
+	}
+	if n == 0 {
+		return repository.ErrHoldExpired
+	}
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in ConvertHold() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//DeleteExpiredHolds deletes the holds which expired at or before now, returning how many there were
+func (pg *postgresDBRepo) DeleteExpiredHolds(now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(DeleteExpiredHolds)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in DeleteExpiredHolds() method while preparing query to delete holds: %v\n", err))
+	}
+	defer stmt.Close()
+	result, err := stmt.ExecContext(ctx, now, models.RestrictionHold)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in DeleteExpiredHolds() method while executing query to delete holds: %v\n", err))
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in DeleteExpiredHolds() method while counting rows deleted: %v\n", err))
+	}
+	return int(n), nil
+}
+
+//MoveReservation moves a reservation to the room and dates of the move, together with the restriction making the
+//room unavailable, and records who moved it. The reservation and the room it moves to are locked while availability
+//is checked, returning repository.ErrRoomUnavailable if the room is not free for the new dates, or
+//repository.ErrExtraSoldOut if an extra of the reservation is sold out on them. The room and dates it moved from are
+//filled into the move.
+func (pg *postgresDBRepo) MoveReservation(move *models.ReservationMove) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in MoveReservation() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	if err = moveReservation(ctx, tx, "MoveReservation", move); err != nil {
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in MoveReservation() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//moveReservation moves a reservation and its room restriction to another room or dates within a transaction,
+//recording the move, after locking the reservation and the room it moves to
+func moveReservation(ctx context.Context, tx *sql.Tx, method string, move *models.ReservationMove) error {
+	err := tx.QueryRowContext(ctx, LockReservation, move.ReservationID).Scan(&move.FromRoomID, &move.FromStart, &move.FromEnd)
+	if err == sql.ErrNoRows {
+		return repository.ErrReservationNotFound
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while locking reservation %d: %v\n", method, move.ReservationID, err))
+	}
+	err = lockCalendar(ctx, tx, move.FromStart, move.FromEnd, move.ToStart, move.ToEnd)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while locking calendar: %v\n", method, err))
+	}
+	err = tx.QueryRowContext(ctx, LockRoom, move.ToRoomID).Scan(new(int))
+	if err == sql.ErrNoRows {
+		return repository.ErrRoomUnavailable
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while locking room %d: %v\n", method, move.ToRoomID, err))
+	}
+
+	numRows := 0
+	err = tx.QueryRowContext(ctx, SearchAvailableRoomByDateForMove, move.ToRoomID, move.ToStart, move.ToEnd,
+		move.ReservationID).Scan(&numRows)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while checking room availability: %v\n", method, err))
+	}
+	if numRows > 0 {
+		return repository.ErrRoomUnavailable
+	}
+
+	move.CreatedAt = time.Now()
+	_, err = tx.ExecContext(ctx, MoveReservation, move.ToRoomID, move.ToStart, move.ToEnd, move.CreatedAt, move.ReservationID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while updating reservation: %v\n", method, err))
+	}
+	if !move.ToStart.Equal(move.FromStart) || !move.ToEnd.Equal(move.FromEnd) {
+		if err = checkMovedExtras(ctx, tx, method, move); err != nil {
+			return err
+		}
+	}
+	_, err = tx.ExecContext(ctx, MoveReservationRestriction, move.ToRoomID, move.ToStart, move.ToEnd, move.CreatedAt,
+		move.ReservationID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while updating room restriction: %v\n", method, err))
+	}
+	err = tx.QueryRowContext(ctx, InsertReservationMove, move.ReservationID, move.UserID, move.FromRoomID, move.ToRoomID,
+		move.FromStart, move.FromEnd, move.ToStart, move.ToEnd, move.CreatedAt).Scan(&move.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while recording move: %v\n", method, err))
+	}
+	return nil
+}
+
+//checkMovedExtras returns repository.ErrExtraSoldOut if an extra with an inventory booked with a moved reservation
+//is sold out on a night of its new dates. It runs once the reservation has its new dates, so what is booked counts
+//the reservation itself, and the rows of its extras are locked while they are counted.
+func checkMovedExtras(ctx context.Context, tx *sql.Tx, method string, move *models.ReservationMove) error {
+	rows, err := tx.QueryContext(ctx, LockReservationExtraInventory, move.ReservationID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while locking extras: %v\n", method, err))
+	}
+	inventory := make(map[int]int)
+	var extraIDs []int
+	for rows.Next() {
+		var extraID, left int
+		if err = rows.Scan(&extraID, &left); err != nil {
+			rows.Close()
+			return errors.New(fmt.Sprintf("error in %s() method while scanning extras: %v\n", method, err))
+		}
+		inventory[extraID] = left
+		extraIDs = append(extraIDs, extraID)
+	}
+	if err = rows.Err(); err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while reading extras: %v\n", method, err))
+	}
+	rows.Close()
+
+	for _, extraID := range extraIDs {
+		booked := 0
+		err = tx.QueryRowContext(ctx, ExtrasBookedByDate, move.ToStart, move.ToEnd, extraID).Scan(new(int), &booked)
+		if err != nil && err != sql.ErrNoRows {
+			return errors.New(fmt.Sprintf("error in %s() method while counting extra %d: %v\n", method, extraID, err))
+		}
+		if booked > inventory[extraID] {
+			return repository.ErrExtraSoldOut
+		}
+	}
+	return nil
+}
+
+//MovesForReservation returns the moves of a reservation, latest first
+func (pg *postgresDBRepo) MovesForReservation(reservationID int) ([]models.ReservationMove, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(MovesForReservation)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in MovesForReservation() method while preparing query to get moves: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, reservationID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in MovesForReservation() method while executing query to get moves: %v\n", err))
+	}
+	defer rows.Close()
+
+	var moves []models.ReservationMove
+	for rows.Next() {
+		var m models.ReservationMove
+		err = rows.Scan(&m.ID, &m.ReservationID, &m.UserID, &m.FromRoomID, &m.ToRoomID, &m.FromStart, &m.FromEnd,
+			&m.ToStart, &m.ToEnd, &m.CreatedAt, &m.UserName, &m.FromRoom.RoomName, &m.ToRoom.RoomName)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in MovesForReservation() method while scanning each row for move: %v\n", err))
+		}
+		moves = append(moves, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in MovesForReservation() method while scanning rows for moves: %v\n", err))
+	}
+	return moves, nil
+}
+
+//CalendarVersion returns a version of the rooms calendar of a property from start to end, or of every property if
+//propertyID is 0, which changes whenever a reservation or block on it is made, changed or removed
+func (pg *postgresDBRepo) CalendarVersion(start, end time.Time, propertyID int) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(CalendarVersion)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("error in CalendarVersion() method while preparing query to get calendar version: %v\n", err))
+	}
+	defer stmt.Close()
+
+	var version string
+	err = stmt.QueryRowContext(ctx, start, end, propertyID).Scan(&version)
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("error in CalendarVersion() method while executing query to get calendar version: %v\n", err))
+	}
+	return version, nil
+}
+
+//SaveCalendarBlocks adds and removes the owner blocks edited on the rooms calendar of a property from start to end, or
+//of every property if propertyID is 0, as long as the calendar is still at the version it was edited from. Saves and
+//other changes to the same month wait for each other, and repository.ErrCalendarChanged is returned without saving
+//anything if the calendar has changed. repository.ErrRoomUnavailable is returned without saving anything if a day
+//added has a reservation or live hold. Only the blocks of rooms at the property are removed, and the blocks removed
+//are returned.
+func (pg *postgresDBRepo) SaveCalendarBlocks(start, end time.Time, version string, add []models.RoomRestriction,
+	remove []int, propertyID int) ([]models.RoomRestriction, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	err = lockCalendar(ctx, tx, start, end)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while locking calendar: %v\n", err))
+	}
+	current := ""
+	err = tx.QueryRowContext(ctx, CalendarVersion, start, end, propertyID).Scan(&current)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while getting calendar version: %v\n", err))
+	}
+	if current != version {
+		return nil, repository.ErrCalendarChanged
+	}
+
+	var removed []models.RoomRestriction
+	for _, id := range remove {
+		block := models.RoomRestriction{ID: id, RestrictionID: models.RestrictionBlock}
+		err = tx.QueryRowContext(ctx, DeleteBlockByID, id, models.RestrictionBlock, propertyID).Scan(&block.RoomID,
+			&block.StartDate, &block.EndDate)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while deleting block %d: %v\n", id, err))
+		}
+		removed = append(removed, block)
+	}
+	// lock the rooms blocked in order, and block none of them if any has a reservation or live hold on a day added
+	var roomIDs []int
+	locked := make(map[int]bool)
+	for _, b := range add {
+		if !locked[b.RoomID] {
+			locked[b.RoomID] = true
+			roomIDs = append(roomIDs, b.RoomID)
+		}
+	}
+	sort.Ints(roomIDs)
+	for _, id := range roomIDs {
+		err = tx.QueryRowContext(ctx, LockRoom, id).Scan(new(int))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while locking room %d: %v\n", id, err))
+		}
+	}
+	for _, b := range add {
+		numRows := 0
+		err = tx.QueryRowContext(ctx, SearchBookingsForBlock, b.RoomID, b.StartDate, b.EndDate,
+			models.RestrictionBlock).Scan(&numRows)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while checking room availability: %v\n", err))
+		}
+		if numRows > 0 {
+			return nil, repository.ErrRoomUnavailable
+		}
+	}
+	now := time.Now()
+	for i := range add {
+		b := &add[i]
+		b.RestrictionID = models.RestrictionBlock
+		b.CreatedAt = now
+		b.UpdatedAt = now
+		err = tx.QueryRowContext(ctx, InsertBlock, b.StartDate, b.EndDate, now, b.RoomID, b.RestrictionID, b.Reason,
+			b.Notes).Scan(&b.ID)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while creating block: %v\n", err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in SaveCalendarBlocks() method while committing transaction: %v\n", err))
+	}
+	return removed, nil
+}
+
+//lockCalendar locks the months of the calendar from the earliest to the latest of the dates, in order, until the end
+//of the transaction, skipping dates which are not set. Every change to the reservations and blocks of the calendar
+//takes these locks before locking any room, so a save of the calendar blocks does not have the version it checked
+//changed before it commits.
+func lockCalendar(ctx context.Context, tx *sql.Tx, dates ...time.Time) error {
+	var first, last time.Time
+	for _, d := range dates {
+		if d.IsZero() {
+			continue
+		}
+		if first.IsZero() || d.Before(first) {
+			first = d
+		}
+		if last.IsZero() || d.After(last) {
+			last = d
+		}
+	}
+	if first.IsZero() {
+		return nil
+	}
+	for m := time.Date(first.Year(), first.Month(), 1, 0, 0, 0, 0, time.UTC); !m.After(last); m = m.AddDate(0, 1, 0) {
+		_, err := tx.ExecContext(ctx, LockCalendarMonth, m.Year()*100+int(m.Month()))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//OccupancyByMonth returns the nights each room was available and sold in each month from start up to end, and the
+//room revenue of the nights sold. Nights blocked are not available, and the room amount of a reservation is spread
+//evenly over its nights. Only the rooms of the property are counted, unless propertyID is 0.
+func (pg *postgresDBRepo) OccupancyByMonth(start, end time.Time, propertyID int) ([]models.OccupancyStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(OccupancyByMonth)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in OccupancyByMonth() method while preparing query to get occupancy: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, start, end, models.RestrictionBlock, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in OccupancyByMonth() method while executing query to get occupancy: %v\n", err))
+	}
+	defer rows.Close()
+
+	var stats []models.OccupancyStats
+	for rows.Next() {
+		var o models.OccupancyStats
+		err = rows.Scan(&o.RoomID, &o.RoomName, &o.Month, &o.NightsAvailable, &o.NightsSold, &o.RoomRevenue)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in OccupancyByMonth() method while scanning each row for occupancy: %v\n", err))
+		}
+		stats = append(stats, o)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in OccupancyByMonth() method while scanning rows for occupancy: %v\n", err))
+	}
+	return stats, nil
+}
+
+//LeadTimeDistribution returns how far ahead the reservations arriving from start up to end were booked, at the
+//property or at every property for propertyID 0
+func (pg *postgresDBRepo) LeadTimeDistribution(start, end time.Time, propertyID int) ([]models.ReportBucket, error) {
+	return pg.reportBuckets("LeadTimeDistribution", LeadTimeDistribution, start, end, propertyID)
+}
+
+//StayLengthDistribution returns how many nights the reservations arriving from start up to end stay, at the property
+//or at every property for propertyID 0
+func (pg *postgresDBRepo) StayLengthDistribution(start, end time.Time, propertyID int) ([]models.ReportBucket, error) {
+	return pg.reportBuckets("StayLengthDistribution", StayLengthDistribution, start, end, propertyID)
+}
+
+//reportBuckets runs a query counting the reservations in each band of a distribution
+func (pg *postgresDBRepo) reportBuckets(method, query string, start, end time.Time, propertyID int) ([]models.ReportBucket, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(query)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while preparing query to get distribution: %v\n", method, err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, start, end, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while executing query to get distribution: %v\n", method, err))
+	}
+	defer rows.Close()
+
+	var buckets []models.ReportBucket
+	for rows.Next() {
+		var b models.ReportBucket
+		err = rows.Scan(&b.Label, &b.Count)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in %s() method while scanning each row for distribution: %v\n", method, err))
+		}
+		buckets = append(buckets, b)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while scanning rows for distribution: %v\n", method, err))
+	}
+	return buckets, nil
+}
+
+//CancellationsByMonth returns the reservations booked and cancelled in each month from start up to end, and the
+//revenue lost with the cancellations, at the property or at every property for propertyID 0
+func (pg *postgresDBRepo) CancellationsByMonth(start, end time.Time, propertyID int) ([]models.CancellationStats, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(CancellationsByMonth)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in CancellationsByMonth() method while preparing query to get cancellations: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, start, end, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in CancellationsByMonth() method while executing query to get cancellations: %v\n", err))
+	}
+	defer rows.Close()
+
+	var stats []models.CancellationStats
+	for rows.Next() {
+		var c models.CancellationStats
+		err = rows.Scan(&c.Month, &c.Booked, &c.Cancelled, &c.LostRevenue)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in CancellationsByMonth() method while scanning each row for cancellations: %v\n", err))
+		}
+		stats = append(stats, c)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in CancellationsByMonth() method while scanning rows for cancellations: %v\n", err))
+	}
+	return stats, nil
+}
+
+//EachReservation calls fn with each reservation matching the filter, latest arrival first, as it is read from the
+//database, so that any number of reservations can be exported. It stops at the first error returned by fn.
+func (pg *postgresDBRepo) EachReservation(filter models.ReservationFilter, fn func(res models.Reservation) error) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(FilteredReservations)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in EachReservation() method while preparing query to get reservations: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, reservationFilterArgs(filter)...)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in EachReservation() method while executing query to get reservations: %v\n", err))
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var rs models.Reservation
+		err = rows.Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.Phone, &rs.CheckInDate, &rs.CheckOutDate,
+			&rs.CreatedAt, &rs.UpdatedAt, &rs.RoomID, &rs.Processed, &rs.Guests, &rs.RoomAmount, &rs.Discount,
+			&rs.TotalAmount, &rs.PromoCode, &rs.Room.RoomName)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in EachReservation() method while scanning each row for reservation: %v\n", err))
+		}
+		rs.Room.ID = rs.RoomID
+		if err = fn(rs); err != nil {
+			return err
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return errors.New(fmt.Sprintf("error in EachReservation() method while scanning rows for reservations: %v\n", err))
+	}
+	return nil
+}
+
+//ImportReservations creates the reservations imported from a file together with the restrictions making their rooms
+//unavailable, returning the indexes of the reservations whose room is not available for their dates, which are not
+//created. The rooms are locked while the reservations are checked and created, so later reservations are checked
+//against earlier ones. Nothing is created in a dry run.
+func (pg *postgresDBRepo) ImportReservations(rs []models.Reservation, dryRun bool) ([]int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	var dates []time.Time
+	for _, res := range rs {
+		dates = append(dates, res.CheckInDate, res.CheckOutDate)
+	}
+	err = lockCalendar(ctx, tx, dates...)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while locking calendar: %v\n", err))
+	}
+	// lock the rooms in order so imports running together do not deadlock
+	var roomIDs []int
+	locked := make(map[int]bool)
+	for _, res := range rs {
+		if !locked[res.RoomID] {
+			locked[res.RoomID] = true
+			roomIDs = append(roomIDs, res.RoomID)
+		}
+	}
+	sort.Ints(roomIDs)
+	for _, id := range roomIDs {
+		err = tx.QueryRowContext(ctx, LockRoom, id).Scan(new(int))
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while locking room %d: %v\n", id, err))
+		}
+	}
+
+	var conflicts []int
+	now := time.Now()
+	for i := range rs {
+		res := &rs[i]
+		numRows := 0
+		err = tx.QueryRowContext(ctx, SearchAvailableRoomByDate, res.RoomID, res.CheckInDate, res.CheckOutDate).Scan(&numRows)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while checking room availability: %v\n", err))
+		}
+		if numRows > 0 {
+			conflicts = append(conflicts, i)
+			continue
+		}
+		if res.CreatedAt.IsZero() {
+			res.CreatedAt = now
+		}
+		err = findGuest(ctx, tx, res, now)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while finding guest: %v\n", err))
+		}
+		err = tx.QueryRowContext(ctx, InsertImportedReservation, res.FirstName, res.LastName, res.Email, res.Phone,
+			res.CheckInDate, res.CheckOutDate, res.CreatedAt, res.RoomID, res.Processed, res.Guests, res.RoomAmount,
+			res.Discount, res.TotalAmount, res.GuestID).Scan(&res.ID)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while creating reservation: %v\n", err))
+		}
+		_, err = tx.ExecContext(ctx, InsertRoomRestriction, res.CheckInDate, res.CheckOutDate, now, now, res.RoomID,
+			res.ID, models.RestrictionReservation)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while creating room restriction: %v\n", err))
+		}
+	}
+
+	if dryRun {
+		return conflicts, nil
+	}
+	if err = tx.Commit(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in ImportReservations() method while committing transaction: %v\n", err))
+	}
+	return conflicts, nil
+}
+
+//CreateImportRun records the outcome of an import
+func (pg *postgresDBRepo) CreateImportRun(run *models.ImportRun) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertImportRun)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateImportRun() method while preparing query to create import run: %v\n", err))
+	}
+	defer stmt.Close()
+
+	run.CreatedAt = time.Now()
+	err = stmt.QueryRowContext(ctx, run.UserID, run.FileName, run.DryRun, run.Rows, run.Imported, run.Failed, run.Errors,
+		run.CreatedAt).Scan(&run.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateImportRun() method while executing query to create import run: %v\n", err))
+	}
+	return nil
+}
+
+//GetImportRunByID returns an import with the file of rows which failed, or repository.ErrImportRunNotFound
+func (pg *postgresDBRepo) GetImportRunByID(id int) (models.ImportRun, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var run models.ImportRun
+	stmt, err := pg.DB.Prepare(GetImportRunByID)
+	if err != nil {
+		return run, errors.New(fmt.Sprintf("error in GetImportRunByID() method while preparing query to get import run: %v\n", err))
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&run.ID, &run.UserID, &run.FileName, &run.DryRun, &run.Rows, &run.Imported,
+		&run.Failed, &run.CreatedAt, &run.Errors)
+	if err == sql.ErrNoRows {
+		return run, repository.ErrImportRunNotFound
+	}
+	if err != nil {
+		return run, errors.New(fmt.Sprintf("error in GetImportRunByID() method while executing query to get import run: %v\n", err))
+	}
+	return run, nil
+}
+
+//RecentImportRuns returns the latest imports, without their files of rows which failed
+func (pg *postgresDBRepo) RecentImportRuns(limit int) ([]models.ImportRun, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(RecentImportRuns)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in RecentImportRuns() method while preparing query to get import runs: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, limit)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in RecentImportRuns() method while executing query to get import runs: %v\n", err))
+	}
+	defer rows.Close()
+
+	var runs []models.ImportRun
+	for rows.Next() {
+		var run models.ImportRun
+		err = rows.Scan(&run.ID, &run.UserID, &run.FileName, &run.DryRun, &run.Rows, &run.Imported, &run.Failed,
+			&run.CreatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in RecentImportRuns() method while scanning each row for import run: %v\n", err))
+		}
+		runs = append(runs, run)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in RecentImportRuns() method while scanning rows for import runs: %v\n", err))
+	}
+	return runs, nil
+}
+
+//SearchGuests returns the guests whose name, email or phone number contains the query, with every guest for an empty
+//query, up to the limit. Unless propertyID is 0, only the guests who stayed at the property are returned.
+func (pg *postgresDBRepo) SearchGuests(query string, propertyID, limit int) ([]models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(SearchGuests)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in SearchGuests() method while preparing query to search guests: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, likePattern(query), limit, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in SearchGuests() method while executing query to search guests: %v\n", err))
+	}
+	return scanGuestList(rows, "SearchGuests")
+}
+
+//GetGuestByID returns a guest, or repository.ErrGuestNotFound
+func (pg *postgresDBRepo) GetGuestByID(id int) (models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var g models.Guest
+	stmt, err := pg.DB.Prepare(GetGuestByID)
+	if err != nil {
+		return g, errors.New(fmt.Sprintf("error in GetGuestByID() method while preparing query to get guest: %v\n", err))
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, id).Scan(&g.ID, &g.FirstName, &g.LastName, &g.Email, &g.Phone, &g.Notes,
+		pq.Array(&g.Tags), &g.Preferences, &g.CreatedAt, &g.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return g, repository.ErrGuestNotFound
+	}
+	if err != nil {
+		return g, errors.New(fmt.Sprintf("error in GetGuestByID() method while executing query to get guest: %v\n", err))
+	}
+	return g, nil
+}
+
+//UpdateGuest updates the profile of a guest, or returns repository.ErrGuestNotFound. The email address reservations
+//are linked by is not changed.
+func (pg *postgresDBRepo) UpdateGuest(guest *models.Guest) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(UpdateGuest)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateGuest() method while preparing query to update guest: %v\n", err))
+	}
+	defer stmt.Close()
+
+	if guest.Tags == nil {
+		guest.Tags = []string{}
+	}
+	guest.UpdatedAt = time.Now()
+	result, err := stmt.ExecContext(ctx, guest.FirstName, guest.LastName, guest.Phone, guest.Notes, pq.Array(guest.Tags),
+		guest.Preferences, guest.UpdatedAt, guest.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateGuest() method while executing query to update guest: %v\n", err))
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return repository.ErrGuestNotFound
+	}
+	return nil
+}
+
+//StaysForGuest returns the reservations of a guest at the property, or at every property for propertyID 0, latest
+//arrival first
+func (pg *postgresDBRepo) StaysForGuest(guestID, propertyID int) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(StaysForGuest)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in StaysForGuest() method while preparing query to get stays: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, guestID, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in StaysForGuest() method while executing query to get stays: %v\n", err))
+	}
+	defer rows.Close()
+
+	var stays []models.Reservation
+	for rows.Next() {
+		var rs models.Reservation
+		err = rows.Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.CheckInDate, &rs.CheckOutDate, &rs.RoomID,
+			&rs.Processed, &rs.Guests, &rs.TotalAmount, &rs.CreatedAt, &rs.Room.RoomName)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in StaysForGuest() method while scanning each row for stay: %v\n", err))
+		}
+		rs.GuestID = guestID
+		rs.Room.ID = rs.RoomID
+		stays = append(stays, rs)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in StaysForGuest() method while scanning rows for stays: %v\n", err))
+	}
+	return stays, nil
+}
+
+//PossibleDuplicateGuests returns the other guests with the same name or phone number as the guest, who may be the
+//same person booking with another email address. Unless propertyID is 0, only the guests who stayed at the property
+//are returned.
+func (pg *postgresDBRepo) PossibleDuplicateGuests(guest models.Guest, propertyID int) ([]models.Guest, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(PossibleDuplicateGuests)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in PossibleDuplicateGuests() method while preparing query to find guests: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, guest.ID, guest.FirstName, guest.LastName, guest.Phone, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in PossibleDuplicateGuests() method while executing query to find guests: %v\n", err))
+	}
+	return scanGuestList(rows, "PossibleDuplicateGuests")
+}
+
+//MergeGuests merges a duplicate guest into the guest kept, moving their reservations to the guest kept and adding
+//their tags, notes and preferences to it before deleting them. Their email address is kept as another address of the
+//guest kept, so their next reservations are linked to it. repository.ErrGuestNotFound is returned if either
+//guest does not exist.
+func (pg *postgresDBRepo) MergeGuests(keepID, mergeID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in MergeGuests() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, MergeGuest, keepID, mergeID, time.Now())
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in MergeGuests() method while merging guest profiles: %v\n", err))
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return repository.ErrGuestNotFound
+	}
+	_, err = tx.ExecContext(ctx, MoveGuestReservations, keepID, mergeID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in MergeGuests() method while moving reservations: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, MoveGuestEmails, keepID, mergeID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in MergeGuests() method while moving email addresses: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, InsertGuestEmail, keepID, mergeID, time.Now())
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in MergeGuests() method while keeping email address: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteGuest, mergeID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in MergeGuests() method while deleting merged guest: %v\n", err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in MergeGuests() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//findGuest links a reservation to the guest with its email address, or to the guest a guest with the address was
+//merged into, creating the guest if they are new
+func findGuest(ctx context.Context, tx *sql.Tx, res *models.Reservation, now time.Time) error {
+	err := tx.QueryRowContext(ctx, GuestByMergedEmail, res.Email, res.Phone, now).Scan(&res.GuestID)
+	if err != sql.ErrNoRows {
+		return err
+	}
+	return tx.QueryRowContext(ctx, UpsertGuest, res.FirstName, res.LastName, res.Email, res.Phone,
+		now).Scan(&res.GuestID)
+}
+
+//scanGuestList reads the guests of a query selecting guestListColumns, closing the rows
+func scanGuestList(rows *sql.Rows, method string) ([]models.Guest, error) {
+	defer rows.Close()
+	var guests []models.Guest
+	for rows.Next() {
+		var g models.Guest
+		err := rows.Scan(&g.ID, &g.FirstName, &g.LastName, &g.Email, &g.Phone, &g.Notes, pq.Array(&g.Tags),
+			&g.Preferences, &g.CreatedAt, &g.UpdatedAt, &g.Stays, &g.LastStay)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in %s() method while scanning each row for guest: %v\n", method, err))
+		}
+		guests = append(guests, g)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in %s() method while scanning rows for guests: %v\n", method, err))
+	}
+	return guests, nil
+}
+
+//CreateSentEmail keeps a copy of an email sent by the site
+func (pg *postgresDBRepo) CreateSentEmail(e *models.SentEmail) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertSentEmail)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateSentEmail() method while preparing query to insert sent email: %v\n", err))
+	}
+	defer stmt.Close()
+
+	err = stmt.QueryRowContext(ctx, e.Recipient, e.Subject, e.Content, e.Template, e.Status, e.CreatedAt).Scan(&e.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateSentEmail() method while executing query to insert sent email: %v\n", err))
+	}
+	return nil
+}
+
+//GuestDataByEmail returns everything kept about the guest with an email address, including the data found by the
+//other addresses of their reservations, read in one transaction so it is consistent
+func (pg *postgresDBRepo) GuestDataByEmail(email string) (models.GuestData, error) {
+	data := models.GuestData{Email: strings.ToLower(email), Emails: []string{strings.ToLower(email)}}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, GuestDataEmails, data.Email)
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get email addresses: %v\n", err))
+	}
+	for rows.Next() {
+		var e string
+		if err = rows.Scan(&e); err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for email address: %v\n", err))
+		}
+		if e != data.Email {
+			data.Emails = append(data.Emails, e)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for email addresses: %v\n", err))
+	}
+	emails := pq.Array(data.Emails)
+
+	rows, err = tx.QueryContext(ctx, GuestDataGuests, emails)
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get guests: %v\n", err))
+	}
+	data.Guests, err = scanGuestList(rows, "GuestDataByEmail")
+	if err != nil {
+		return data, err
+	}
+
+	rows, err = tx.QueryContext(ctx, GuestDataReservations, emails)
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get reservations: %v\n", err))
+	}
+	var ids []int64
+	for rows.Next() {
+		var rs models.Reservation
+		err = rows.Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.Phone, &rs.CheckInDate, &rs.CheckOutDate,
+			&rs.CreatedAt, &rs.UpdatedAt, &rs.RoomID, &rs.Processed, &rs.Guests, &rs.RoomAmount, &rs.Discount,
+			&rs.TotalAmount, &rs.GuestID, &rs.Room.RoomName)
+		if err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for reservation: %v\n", err))
+		}
+		rs.Room.ID = rs.RoomID
+		data.Reservations = append(data.Reservations, rs)
+		ids = append(ids, int64(rs.ID))
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for reservations: %v\n", err))
+	}
+
+	rows, err = tx.QueryContext(ctx, GuestDataFolioItems, pq.Array(ids))
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get folio items: %v\n", err))
+	}
+	for rows.Next() {
+		var item models.FolioItem
+		err = rows.Scan(&item.ID, &item.ReservationID, &item.Kind, &item.Description, &item.Quantity, &item.UnitAmount,
+			&item.CreatedAt, &item.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for folio item: %v\n", err))
+		}
+		data.FolioItems = append(data.FolioItems, item)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for folio items: %v\n", err))
+	}
+
+	rows, err = tx.QueryContext(ctx, GuestDataPayments, pq.Array(ids))
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get payments: %v\n", err))
+	}
+	for rows.Next() {
+		var p models.Payment
+		err = rows.Scan(&p.ID, &p.ReservationID, &p.Provider, &p.Reference, &p.Amount, &p.Currency, &p.Status,
+			&p.Captured, &p.Refunded, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for payment: %v\n", err))
+		}
+		data.Payments = append(data.Payments, p)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for payments: %v\n", err))
+	}
+
+	rows, err = tx.QueryContext(ctx, GuestDataInvoices, pq.Array(ids))
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get invoices: %v\n", err))
+	}
+	for rows.Next() {
+		var inv models.Invoice
+		err = rows.Scan(&inv.ID, &inv.ReservationID, &inv.Number, &inv.Total, &inv.Currency, &inv.PDF, &inv.CreatedAt,
+			&inv.UpdatedAt)
+		if err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for invoice: %v\n", err))
+		}
+		data.Invoices = append(data.Invoices, inv)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for invoices: %v\n", err))
+	}
+
+	rows, err = tx.QueryContext(ctx, GuestDataWaitlistEntries, emails)
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get waitlist entries: %v\n", err))
+	}
+	for rows.Next() {
+		e, err := scanWaitlistEntry(rows)
+		if err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for waitlist entry: %v\n", err))
+		}
+		data.WaitlistEntries = append(data.WaitlistEntries, e)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for waitlist entries: %v\n", err))
+	}
+
+	rows, err = tx.QueryContext(ctx, GuestDataSentEmails, emails)
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get sent emails: %v\n", err))
+	}
+	for rows.Next() {
+		var e models.SentEmail
+		err = rows.Scan(&e.ID, &e.Recipient, &e.Subject, &e.Content, &e.Template, &e.Status, &e.CreatedAt)
+		if err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for sent email: %v\n", err))
+		}
+		data.SentEmails = append(data.SentEmails, e)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for sent emails: %v\n", err))
+	}
+
+	rows, err = tx.QueryContext(ctx, GuestDataImportRuns, emails)
+	if err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while executing query to get imports: %v\n", err))
+	}
+	for rows.Next() {
+		var run models.ImportRun
+		err = rows.Scan(&run.ID, &run.UserID, &run.FileName, &run.DryRun, &run.Rows, &run.Imported, &run.Failed,
+			&run.CreatedAt, &run.Errors)
+		if err != nil {
+			rows.Close()
+			return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning each row for import: %v\n", err))
+		}
+		data.ImportRuns = append(data.ImportRuns, run)
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return data, errors.New(fmt.Sprintf("error in GuestDataByEmail() method while scanning rows for imports: %v\n", err))
+	}
+	return data, nil
+}
+
+//EraseGuestData anonymises the reservations of the guest with an email address, and of the other addresses of their
+//reservations, and deletes their guest profiles, waitlist entries, sent emails and the failed rows kept of the imports
+//mentioning them. Payments, folios and invoices are kept for the accounts. It returns the number of reservations anonymised.
+func (pg *postgresDBRepo) EraseGuestData(email string, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	email = strings.ToLower(email)
+	emails := []string{email}
+	rows, err := tx.QueryContext(ctx, GuestDataEmails, email)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while executing query to get email addresses: %v\n", err))
+	}
+	for rows.Next() {
+		var e string
+		if err = rows.Scan(&e); err != nil {
+			rows.Close()
+			return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while scanning each row for email address: %v\n", err))
+		}
+		if e != email {
+			emails = append(emails, e)
+		}
+	}
+	rows.Close()
+	if err = rows.Err(); err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while scanning rows for email addresses: %v\n", err))
+	}
+
+	result, err := tx.ExecContext(ctx, AnonymiseReservations, pq.Array(emails), now)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while anonymising reservations: %v\n", err))
+	}
+	n, _ := result.RowsAffected()
+	_, err = tx.ExecContext(ctx, DeleteGuestsByEmail, pq.Array(emails))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while deleting guests: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteWaitlistEntriesByEmail, pq.Array(emails))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while deleting waitlist entries: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteSentEmailsByRecipient, pq.Array(emails))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while deleting sent emails: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteImportErrorsByEmail, pq.Array(emails))
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while deleting failed import rows: %v\n", err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.New(fmt.Sprintf("error in EraseGuestData() method while committing transaction: %v\n", err))
+	}
+	return int(n), nil
+}
+
+//AnonymiseStaysBefore anonymises the reservations checked out before the cutoff, deleting the guests left without
+//reservations and the waitlist entries, sent emails and failed import rows older than the cutoff. It returns the
+//number of reservations anonymised.
+func (pg *postgresDBRepo) AnonymiseStaysBefore(cutoff, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), bulkTimeout)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in AnonymiseStaysBefore() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, AnonymiseStaysBefore, cutoff, now)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in AnonymiseStaysBefore() method while anonymising reservations: %v\n", err))
+	}
+	n, _ := result.RowsAffected()
+	_, err = tx.ExecContext(ctx, DeleteGuestsWithoutStays)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in AnonymiseStaysBefore() method while deleting guests: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteWaitlistEntriesBefore, cutoff)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in AnonymiseStaysBefore() method while deleting waitlist entries: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteSentEmailsBefore, cutoff)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in AnonymiseStaysBefore() method while deleting sent emails: %v\n", err))
+	}
+	_, err = tx.ExecContext(ctx, DeleteImportErrorsBefore, cutoff)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in AnonymiseStaysBefore() method while deleting failed import rows: %v\n", err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, errors.New(fmt.Sprintf("error in AnonymiseStaysBefore() method while committing transaction: %v\n", err))
+	}
+	return int(n), nil
+}
+
+//CheckInReservation records the guest of a reservation arriving, adding the fee of the check-in to the folio and
+//moving them to the room of action.Move if set
+func (pg *postgresDBRepo) CheckInReservation(action *models.FrontDeskAction) error {
+	return pg.frontDeskAction("CheckInReservation", action, repository.ErrAlreadyCheckedIn, nil, CheckInReservation,
+		action.ReservationID, action.At, action.KeyNumber, action.IDVerification, time.Now())
+}
+
+//CheckOutReservation records the guest of a reservation leaving, adding the fee of the check-out to the folio, marking
+//their room dirty and making the task of cleaning it
+func (pg *postgresDBRepo) CheckOutReservation(action *models.FrontDeskAction) error {
+	return pg.frontDeskAction("CheckOutReservation", action, repository.ErrNotInHouse,
+		[]string{MarkCheckedOutRoomDirty, InsertDepartureTask}, CheckOutReservation,
+		action.ReservationID, action.At, time.Now())
+}
+
+//frontDeskAction runs the update of a check-in or check-out, adds its fee to the folio and runs the then queries with
+//the reservation id and the time of the action, in one transaction. It returns errState if the reservation was not in
+//the state the update needs.
+func (pg *postgresDBRepo) frontDeskAction(method string, action *models.FrontDeskAction, errState error, then []string,
+	query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while starting transaction: %v\n", method, err))
+	}
+	defer tx.Rollback()
+
+	if action.Move != nil {
+		if err = moveReservation(ctx, tx, method, action.Move); err != nil {
+			return err
+		}
+	}
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while updating reservation: %v\n", method, err))
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return errState
+	}
+	if fee := &action.Fee; fee.UnitAmount != 0 {
+		err = tx.QueryRowContext(ctx, InsertFolioItem, action.ReservationID, fee.Kind, fee.Description, fee.Quantity,
+			fee.UnitAmount, time.Now(), time.Now()).Scan(&fee.ID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in %s() method while adding fee to folio: %v\n", method, err))
+		}
+	}
+	for _, q := range then {
+		_, err = tx.ExecContext(ctx, q, action.ReservationID, action.At)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in %s() method while updating housekeeping: %v\n", method, err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in %s() method while committing transaction: %v\n", method, err))
+	}
+	return nil
+}
+
+//FrontDeskReservations returns the reservations arriving or leaving on a day and those of the guests in house, by room,
+//at a property or at every property if propertyID is 0
+func (pg *postgresDBRepo) FrontDeskReservations(day time.Time, propertyID int) ([]models.Reservation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(FrontDeskReservations)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in FrontDeskReservations() method while preparing query to get reservations: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, day, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in FrontDeskReservations() method while executing query to get reservations: %v\n", err))
+	}
+	defer rows.Close()
+	var reservations []models.Reservation
+	for rows.Next() {
+		var rs models.Reservation
+		var checkedInAt, checkedOutAt sql.NullTime
+		err = rows.Scan(&rs.ID, &rs.FirstName, &rs.LastName, &rs.Email, &rs.Phone, &rs.CheckInDate, &rs.CheckOutDate,
+			&rs.RoomID, &rs.Processed, &rs.Guests, &rs.TotalAmount, &rs.GuestID, &checkedInAt, &checkedOutAt,
+			&rs.KeyNumber, &rs.Room.RoomName)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in FrontDeskReservations() method while scanning each row for reservation: %v\n", err))
+		}
+		rs.CheckedInAt = checkedInAt.Time
+		rs.CheckedOutAt = checkedOutAt.Time
+		rs.Room.ID = rs.RoomID
+		reservations = append(reservations, rs)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in FrontDeskReservations() method while scanning rows for reservations: %v\n", err))
+	}
+	return reservations, nil
+}
+
+//HousekeepingRooms returns the housekeeping status of the rooms of a property, or of every room if propertyID is 0,
+//by name
+func (pg *postgresDBRepo) HousekeepingRooms(propertyID int) ([]models.HousekeepingRoom, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(HousekeepingRooms)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in HousekeepingRooms() method while preparing query to get rooms: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in HousekeepingRooms() method while executing query to get rooms: %v\n", err))
+	}
+	defer rows.Close()
+	var rooms []models.HousekeepingRoom
+	for rows.Next() {
+		var room models.HousekeepingRoom
+		var updatedAt, until sql.NullTime
+		err = rows.Scan(&room.Room.ID, &room.Room.RoomName, &room.Status, &updatedAt, &room.BlockID, &until)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in HousekeepingRooms() method while scanning each row for room: %v\n", err))
+		}
+		room.UpdatedAt = updatedAt.Time
+		room.OutOfOrderUntil = until.Time
+		rooms = append(rooms, room)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in HousekeepingRooms() method while scanning rows for rooms: %v\n", err))
+	}
+	return rooms, nil
+}
+
+//SetRoomHousekeepingStatus changes the housekeeping status of a room. A room put out of order is blocked from today
+//until the date given, returning repository.ErrRoomUnavailable if it has reservations then, and the nights of its
+//block from today on are made available again when it is back in order.
+func (pg *postgresDBRepo) SetRoomHousekeepingStatus(roomID int, status string, until, now time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	// the months from today to the end of the block being ended or made are changed
+	today := models.Today(now)
+	blockEnd := today
+	err = tx.QueryRowContext(ctx, OutOfOrderBlockEnd, roomID).Scan(&blockEnd)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while getting out of order block: %v\n", err))
+	}
+	err = lockCalendar(ctx, tx, today, blockEnd, until)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while locking calendar: %v\n", err))
+	}
+	err = tx.QueryRowContext(ctx, LockRoom, roomID).Scan(new(int))
+	if err == sql.ErrNoRows {
+		return repository.ErrRoomNotFound
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while locking room %d: %v\n", roomID, err))
+	}
+	var blockID int
+	err = tx.QueryRowContext(ctx, UpdateRoomHousekeepingStatus, roomID, status, now).Scan(&blockID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while updating room: %v\n", err))
+	}
+
+	if blockID != 0 {
+		_, err = tx.ExecContext(ctx, EndOutOfOrderBlock, blockID, today, now)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while ending block: %v\n", err))
+		}
+		_, err = tx.ExecContext(ctx, DeleteOutOfOrderBlock, blockID, today)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while deleting block: %v\n", err))
+		}
+		blockID = 0
+	}
+	if status == models.HousekeepingOutOfOrder {
+		numRows := 0
+		err = tx.QueryRowContext(ctx, SearchBookingsForBlock, roomID, today, until, models.RestrictionBlock).Scan(&numRows)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while checking room availability: %v\n", err))
+		}
+		if numRows > 0 {
+			return repository.ErrRoomUnavailable
+		}
+		err = tx.QueryRowContext(ctx, InsertBlock, today, until, now, roomID, models.RestrictionBlock, "Out of order",
+			"").Scan(&blockID)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while creating block: %v\n", err))
+		}
+	}
+	_, err = tx.ExecContext(ctx, SetOutOfOrderBlock, roomID, blockID)
 	if err != nil {
-		return errors.New(fmt.Sprintf("error in DeleteBlockByID() method while executing query to delete room restriction: %v\n", err))
+		return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while updating room block: %v\n", err))
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in SetRoomHousekeepingStatus() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//HousekeepingTasks returns the housekeeping tasks of a day by room, only those assigned to a member of staff if
+//assignedTo is not 0 and those of a property if propertyID is not 0
+func (pg *postgresDBRepo) HousekeepingTasks(day time.Time, assignedTo, propertyID int) ([]models.HousekeepingTask, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(HousekeepingTasks)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in HousekeepingTasks() method while preparing query to get tasks: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, day, assignedTo, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in HousekeepingTasks() method while executing query to get tasks: %v\n", err))
+	}
+	defer rows.Close()
+	var tasks []models.HousekeepingTask
+	for rows.Next() {
+		var t models.HousekeepingTask
+		var completedAt sql.NullTime
+		err = rows.Scan(&t.ID, &t.RoomID, &t.ReservationID, &t.Kind, &t.TaskDate, &t.Status, &t.AssignedTo,
+			&t.AssigneeName, &t.Notes, &completedAt, &t.CreatedAt, &t.UpdatedAt, &t.Room.RoomName)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in HousekeepingTasks() method while scanning each row for task: %v\n", err))
+		}
+		t.CompletedAt = completedAt.Time
+		t.Room.ID = t.RoomID
+		tasks = append(tasks, t)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in HousekeepingTasks() method while scanning rows for tasks: %v\n", err))
+	}
+	return tasks, nil
+}
+
+//GenerateHousekeepingTasks makes the housekeeping tasks of a day for departures and stayovers, returning the number of
+//tasks made
+func (pg *postgresDBRepo) GenerateHousekeepingTasks(day, now time.Time) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(GenerateHousekeepingTasks)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in GenerateHousekeepingTasks() method while preparing query to make tasks: %v\n", err))
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, day, now)
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("error in GenerateHousekeepingTasks() method while executing query to make tasks: %v\n", err))
+	}
+	n, _ := result.RowsAffected()
+	return int(n), nil
+}
+
+//UpdateHousekeepingTask changes the status, assignee and notes of a housekeeping task, marking its room as being
+//cleaned when it is started and clean when it is done. Tasks of rooms at other properties than propertyID, unless it
+//is 0, are not found.
+func (pg *postgresDBRepo) UpdateHousekeepingTask(task *models.HousekeepingTask, propertyID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tx, err := pg.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateHousekeepingTask() method while starting transaction: %v\n", err))
+	}
+	defer tx.Rollback()
+
+	task.UpdatedAt = time.Now()
+	err = tx.QueryRowContext(ctx, UpdateHousekeepingTask, task.ID, task.Status, task.AssignedTo, task.Notes,
+		task.UpdatedAt, propertyID).Scan(&task.RoomID, &task.Kind)
+	if err == sql.ErrNoRows {
+		return repository.ErrTaskNotFound
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateHousekeepingTask() method while updating task: %v\n", err))
+	}
+	roomStatus := ""
+	switch task.Status {
+	case models.TaskStatusInProgress:
+		roomStatus = models.HousekeepingCleaning
+	case models.TaskStatusDone:
+		roomStatus = models.HousekeepingClean
+	}
+	if roomStatus != "" {
+		_, err = tx.ExecContext(ctx, TaskRoomStatus, task.RoomID, roomStatus, task.UpdatedAt)
+		if err != nil {
+			return errors.New(fmt.Sprintf("error in UpdateHousekeepingTask() method while updating room: %v\n", err))
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateHousekeepingTask() method while committing transaction: %v\n", err))
+	}
+	return nil
+}
+
+//AllStaff returns the users of the admin tool working at a property, or every user if propertyID is 0, by name
+func (pg *postgresDBRepo) AllStaff(propertyID int) ([]models.User, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AllStaff)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllStaff() method while preparing query to get users: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx, propertyID)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllStaff() method while executing query to get users: %v\n", err))
+	}
+	defer rows.Close()
+	var users []models.User
+	for rows.Next() {
+		var u models.User
+		var accessLevel sql.NullInt64
+		err = rows.Scan(&u.ID, &u.FirstName, &u.LastName, &u.Email, &accessLevel, &u.PropertyID)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AllStaff() method while scanning each row for user: %v\n", err))
+		}
+		u.AccessLevel = int(accessLevel.Int64)
+		users = append(users, u)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllStaff() method while scanning rows for users: %v\n", err))
+	}
+	return users, nil
+}
+
+//AllProperties returns the properties run from the site, by name
+func (pg *postgresDBRepo) AllProperties() ([]models.Property, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(AllProperties)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllProperties() method while preparing query to get properties: %v\n", err))
+	}
+	defer stmt.Close()
+
+	rows, err := stmt.QueryContext(ctx)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllProperties() method while executing query to get properties: %v\n", err))
+	}
+	defer rows.Close()
+	var properties []models.Property
+	for rows.Next() {
+		var p models.Property
+		err = rows.Scan(&p.ID, &p.Slug, &p.Name, &p.Hostname, &p.EmailFrom, &p.EmailFromName, &p.LogoURL, &p.BrandColor,
+			&p.DepositPolicy, &p.CreatedAt, &p.UpdatedAt)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("error in AllProperties() method while scanning each row for property: %v\n", err))
+		}
+		properties = append(properties, p)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, errors.New(fmt.Sprintf("error in AllProperties() method while scanning rows for properties: %v\n", err))
+	}
+	return properties, nil
+}
+
+//CreateProperty adds a property
+func (pg *postgresDBRepo) CreateProperty(p *models.Property) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(InsertProperty)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateProperty() method while preparing query to create property: %v\n", err))
+	}
+	defer stmt.Close()
+
+	p.CreatedAt = time.Now()
+	p.UpdatedAt = p.CreatedAt
+	err = stmt.QueryRowContext(ctx, p.Slug, p.Name, p.Hostname, p.EmailFrom, p.EmailFromName, p.LogoURL, p.BrandColor,
+		p.DepositPolicy, p.CreatedAt).Scan(&p.ID)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in CreateProperty() method while creating property: %v\n", err))
+	}
+	return nil
+}
+
+//UpdateProperty changes the details of a property, returning repository.ErrPropertyNotFound if there is none with its id
+func (pg *postgresDBRepo) UpdateProperty(p *models.Property) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(UpdateProperty)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateProperty() method while preparing query to update property: %v\n", err))
+	}
+	defer stmt.Close()
+
+	p.UpdatedAt = time.Now()
+	result, err := stmt.ExecContext(ctx, p.ID, p.Slug, p.Name, p.Hostname, p.EmailFrom, p.EmailFromName, p.LogoURL,
+		p.BrandColor, p.DepositPolicy, p.UpdatedAt)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in UpdateProperty() method while updating property: %v\n", err))
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return repository.ErrPropertyNotFound
+	}
+	return nil
+}
+
+//SetRoomProperty moves a room to a property, or returns repository.ErrRoomNotFound
+func (pg *postgresDBRepo) SetRoomProperty(roomID, propertyID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(SetRoomProperty)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetRoomProperty() method while preparing query to update room: %v\n", err))
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, roomID, propertyID, time.Now())
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetRoomProperty() method while updating room: %v\n", err))
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return repository.ErrRoomNotFound
+	}
+	return nil
+}
+
+//SetUserProperty ties a user to the property they work at, or lets them work at every property for propertyID 0.
+//repository.ErrUserNotFound is returned if there is no such user.
+func (pg *postgresDBRepo) SetUserProperty(userID, propertyID int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	stmt, err := pg.DB.Prepare(SetUserProperty)
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetUserProperty() method while preparing query to update user: %v\n", err))
+	}
+	defer stmt.Close()
+
+	result, err := stmt.ExecContext(ctx, userID, propertyID, time.Now())
+	if err != nil {
+		return errors.New(fmt.Sprintf("error in SetUserProperty() method while updating user: %v\n", err))
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return repository.ErrUserNotFound
 	}
 	return nil
 }
@@ -0,0 +1,886 @@
+package dbrepo
+
+import (
+	"database/sql"
+	"errors"
+	"github.com/sunil206b/smart_booking/internal/config"
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"github.com/sunil206b/smart_booking/internal/repository"
+	"strings"
+	"time"
+)
+
+type testDBRepo struct {
+	App *config.AppConfig
+	DB  *sql.DB
+}
+
+// NewTestingRepo will create the in memory repository used by the tests
+func NewTestingRepo(app *config.AppConfig) repository.DatabaseRepo {
+	return &testDBRepo{
+		App: app,
+	}
+}
+
+func (tr *testDBRepo) AllUsers() bool {
+	return true
+}
+
+//CreateReservation fails for room 2 and for a reservation without the time it was made, and succeeds for any other
+//room
+func (tr *testDBRepo) CreateReservation(res *models.Reservation) error {
+	if res.RoomID == 2 {
+		return errors.New("failed to create reservation")
+	}
+	if res.CreatedAt.IsZero() || res.UpdatedAt.IsZero() {
+		return errors.New("reservation has no created or updated time")
+	}
+	res.ID = 1
+	return nil
+}
+
+//CreateRoomRestriction fails for room 1000, and succeeds for any other room
+func (tr *testDBRepo) CreateRoomRestriction(r *models.RoomRestriction) error {
+	if r.RoomID == 1000 {
+		return errors.New("failed to create room restriction")
+	}
+	return nil
+}
+
+//SearchAvailabilityByDatesByRoom reports room 2 as booked, and every other room as available
+func (tr *testDBRepo) SearchAvailabilityByDatesByRoom(start, end time.Time, roomID int) (bool, i18n.Message, error) {
+	if roomID == 2 {
+		return false, notAvailableReason, nil
+	}
+	return true, i18n.Message{}, nil
+}
+
+//SearchAllAvailableRooms returns room 1 of Fort Smythe as available and room 2 as booked, or no rooms for stays from
+//2050 or at Harbour View
+func (tr *testDBRepo) SearchAllAvailableRooms(start, end time.Time, propertyID int) ([]models.Room, []models.ExcludedRoom, error) {
+	if propertyID == 2 {
+		return nil, nil, nil
+	}
+	rooms := []models.Room{
+		{ID: 1, RoomName: "General's Quarters", PropertyID: 1, PropertyName: "Fort Smythe"},
+	}
+	excluded := []models.ExcludedRoom{
+		{Room: models.Room{ID: 2, RoomName: "Major's Suite", PropertyID: 1, PropertyName: "Fort Smythe"},
+			Reason: notAvailableReason},
+	}
+	if start.Year() >= 2050 {
+		return nil, excluded, nil
+	}
+	return rooms, excluded, nil
+}
+
+//GetRoomByID returns a room for ids 1 and 2, and an error for any other id
+func (tr *testDBRepo) GetRoomByID(id int) (models.Room, error) {
+	if id < 1 || id > 2 {
+		return models.Room{}, errors.New("room not found")
+	}
+	return models.Room{ID: id, RoomName: "General's Quarters", Price: 10000, PropertyID: 1, PropertyName: "Fort Smythe"},
+		nil
+}
+
+//GetUserByID returns user 2 working at Harbour View, and other users working at every property
+func (tr *testDBRepo) GetUserByID(id int) (models.User, error) {
+	if id == 2 {
+		return models.User{ID: 2, FirstName: "Harbour", LastName: "Staff", PropertyID: 2}, nil
+	}
+	return models.User{}, nil
+}
+
+func (tr *testDBRepo) UpdateUser(user *models.User) error {
+	return nil
+}
+
+//Authenticate accepts only admin@admin.com
+func (tr *testDBRepo) Authenticate(email, testPass string) (int, string, error) {
+	if email != "admin@admin.com" {
+		return 0, "", errors.New("incorrect password")
+	}
+	return 1, "", nil
+}
+
+//GetReservationByID returns a reservation for id 1, and an error for any other id
+//GetReservationByID returns reservation 1 in room 1, and reservation 4 in room 2 whose guest checked in on July 10th
+//2050
+func (tr *testDBRepo) GetReservationByID(id int) (models.Reservation, error) {
+	switch id {
+	case 1:
+		return models.Reservation{ID: 1, RoomID: 1, GuestID: 1, Room: models.Room{ID: 1, PropertyID: 1}}, nil
+	case 4:
+		return models.Reservation{ID: 4, RoomID: 2, FirstName: "Jane", LastName: "Doe", KeyNumber: "204",
+			CheckInDate:  time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+			CheckOutDate: time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC),
+			CheckedInAt:  time.Date(2050, time.July, 10, 14, 0, 0, 0, time.UTC),
+			Room:         models.Room{ID: 2, RoomName: "Major's Suite", PropertyID: 1}}, nil
+	}
+	return models.Reservation{}, errors.New("reservation not found")
+}
+
+func (tr *testDBRepo) UpdateReservation(res *models.Reservation) error {
+	return nil
+}
+
+func (tr *testDBRepo) DeleteReservation(id int) error {
+	return nil
+}
+
+func (tr *testDBRepo) UpdateProcessedReservation(id, processed int) error {
+	return nil
+}
+
+//AllRooms returns General's Quarters and Major's Suite at Fort Smythe, and Colonel's Room at Harbour View
+func (tr *testDBRepo) AllRooms(propertyID int) ([]models.Room, error) {
+	all := []models.Room{
+		{ID: 1, RoomName: "General's Quarters", PropertyID: 1},
+		{ID: 2, RoomName: "Major's Suite", PropertyID: 1},
+		{ID: 3, RoomName: "Colonel's Room", PropertyID: 2},
+	}
+	if propertyID == 0 {
+		return all, nil
+	}
+	var rooms []models.Room
+	for _, room := range all {
+		if room.PropertyID == propertyID {
+			rooms = append(rooms, room)
+		}
+	}
+	return rooms, nil
+}
+
+//GetRestrictionsForRoomByDate returns reservation 1 in room 1 from July 10th to 12th 2050, and a renovation block of
+//room 1 from July 20th up to 25th 2050
+func (tr *testDBRepo) GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error) {
+	restrictions := []models.RoomRestriction{
+		{
+			ID:            1,
+			StartDate:     time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+			EndDate:       time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC),
+			RoomID:        1,
+			ReservationID: 1,
+			RestrictionID: models.RestrictionReservation,
+		},
+		{
+			ID:            2,
+			StartDate:     time.Date(2050, time.July, 20, 0, 0, 0, 0, time.UTC),
+			EndDate:       time.Date(2050, time.July, 25, 0, 0, 0, 0, time.UTC),
+			RoomID:        1,
+			RestrictionID: models.RestrictionBlock,
+			Reason:        "Renovation",
+		},
+	}
+	var found []models.RoomRestriction
+	for _, r := range restrictions {
+		if roomID == r.RoomID && start.Before(r.EndDate) && !end.Before(r.StartDate) {
+			found = append(found, r)
+		}
+	}
+	return found, nil
+}
+
+func (tr *testDBRepo) CreateBlockForRoom(id int, startDate time.Time) error {
+	return nil
+}
+
+func (tr *testDBRepo) DeleteBlockByID(id int) (models.RoomRestriction, error) {
+	return models.RoomRestriction{}, nil
+}
+
+//CreateBlocks fails with room 2, which is unavailable
+func (tr *testDBRepo) CreateBlocks(blocks []models.RoomRestriction) error {
+	for _, b := range blocks {
+		if b.RoomID == 2 {
+			return repository.ErrRoomUnavailable
+		}
+	}
+	return nil
+}
+
+func (tr *testDBRepo) AllRoomRules() ([]models.RoomRule, error) {
+	return []models.RoomRule{}, nil
+}
+
+//GetRulesForRoomByDate returns a minimum stay of 3 nights for room 4, and no rules for the other rooms
+func (tr *testDBRepo) GetRulesForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRule, error) {
+	if roomID == 4 {
+		return []models.RoomRule{{
+			RoomID:    4,
+			StartDate: time.Date(2050, time.January, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, time.December, 31, 0, 0, 0, 0, time.UTC),
+			MinStay:   3,
+		}}, nil
+	}
+	return []models.RoomRule{}, nil
+}
+
+func (tr *testDBRepo) CreateRoomRule(rule *models.RoomRule) error {
+	return nil
+}
+
+func (tr *testDBRepo) DeleteRoomRuleByID(id, propertyID int) error {
+	return nil
+}
+
+//AvailabilityCalendar returns room 1 free on every night of the range
+func (tr *testDBRepo) AvailabilityCalendar(start, end time.Time, roomID int) ([]models.RoomCalendar, error) {
+	calendar := models.RoomCalendar{Room: models.Room{ID: 1, RoomName: "General's Quarters", Price: 10000}}
+	for d := start; d.Before(end); d = d.AddDate(0, 0, 1) {
+		calendar.Nights = append(calendar.Nights, models.CalendarNight{Date: d, Price: 10000})
+	}
+	return []models.RoomCalendar{calendar}, nil
+}
+
+//AllExchangeRates returns a rate for euros
+func (tr *testDBRepo) AllExchangeRates() ([]models.ExchangeRate, error) {
+	return []models.ExchangeRate{{ID: 1, CurrencyCode: "EUR", Rate: 0.9}}, nil
+}
+
+func (tr *testDBRepo) SaveExchangeRates(rates []models.ExchangeRate) error {
+	return nil
+}
+
+func (tr *testDBRepo) DeleteExchangeRate(code string) error {
+	return nil
+}
+
+//CreatePayment gives the payment id 1, and fails for reservation 7 which already has a pending deposit
+func (tr *testDBRepo) CreatePayment(p *models.Payment) error {
+	if p.ReservationID == 7 {
+		return errors.New("reservation 7 already has a pending payment")
+	}
+	p.ID = 1
+	return nil
+}
+
+//GetPaymentByID returns an authorized deposit of reservation 1 for id 1, a failed one for id 2, and an error for
+//any other id
+func (tr *testDBRepo) GetPaymentByID(id int) (models.Payment, error) {
+	switch id {
+	case 1:
+		return models.Payment{ID: 1, ReservationID: 1, Provider: "fake", Reference: "fake_1", Amount: 3000,
+			Currency: "USD", Status: "authorized"}, nil
+	case 2:
+		return models.Payment{ID: 2, ReservationID: 1, Provider: "fake", Reference: "fake_2", Amount: 3000,
+			Currency: "USD", Status: "failed"}, nil
+	}
+	return models.Payment{}, repository.ErrPaymentNotFound
+}
+
+//GetPaymentByReference returns a pending deposit of reservation 1 for reference fake_1, and no payment for any other
+//reference
+func (tr *testDBRepo) GetPaymentByReference(reference string) (models.Payment, error) {
+	if reference != "fake_1" {
+		return models.Payment{}, repository.ErrPaymentNotFound
+	}
+	return models.Payment{ID: 1, ReservationID: 1, Provider: "fake", Reference: reference, Amount: 3000,
+		Currency: "USD", Status: "pending"}, nil
+}
+
+func (tr *testDBRepo) UpdatePayment(p *models.Payment) error {
+	return nil
+}
+
+//PaymentsForReservation returns a failed deposit and a pending one whose checkout has started for reservation 7,
+//and a pending deposit whose checkout could not be started for reservation 8
+func (tr *testDBRepo) PaymentsForReservation(reservationID int) ([]models.Payment, error) {
+	switch reservationID {
+	case 7:
+		return []models.Payment{
+			{ID: 6, ReservationID: 7, Provider: "fake", Reference: "fake_6", Amount: 3000, Currency: "USD",
+				Status: "failed"},
+			{ID: 7, ReservationID: 7, Provider: "fake", Reference: "fake_7", CheckoutURL: "/payments/fake/checkout/fake_7",
+				Amount: 3000, Currency: "USD", Status: "pending"},
+		}, nil
+	case 8:
+		return []models.Payment{{ID: 8, ReservationID: 8, Provider: "fake", Amount: 3000, Currency: "USD",
+			Status: "pending"}}, nil
+	}
+	return []models.Payment{}, nil
+}
+
+//FolioItemsForReservation returns a breakfast for reservation 1
+func (tr *testDBRepo) FolioItemsForReservation(reservationID int) ([]models.FolioItem, error) {
+	if reservationID != 1 {
+		return []models.FolioItem{}, nil
+	}
+	return []models.FolioItem{{ID: 1, ReservationID: 1, Kind: "extra", Description: "Breakfast", Quantity: 2, UnitAmount: 1500}}, nil
+}
+
+func (tr *testDBRepo) CreateFolioItem(item *models.FolioItem) error {
+	item.ID = 2
+	return nil
+}
+
+func (tr *testDBRepo) DeleteFolioItem(reservationID, id int) error {
+	return nil
+}
+
+func (tr *testDBRepo) InvoicesForReservation(reservationID int) ([]models.Invoice, error) {
+	return []models.Invoice{}, nil
+}
+
+//GetInvoiceByID returns invoice INV-000001 of reservation 1 for id 1, and an error for any other id
+func (tr *testDBRepo) GetInvoiceByID(id int) (models.Invoice, error) {
+	if id != 1 {
+		return models.Invoice{}, errors.New("invoice not found")
+	}
+	return models.Invoice{ID: 1, ReservationID: 1, Number: "INV-000001", Total: 3000, Currency: "USD",
+		PDF: []byte("%PDF-1.4\n%%EOF\n")}, nil
+}
+
+//CreateInvoice always issues number INV-000001
+func (tr *testDBRepo) CreateInvoice(inv *models.Invoice, renderPDF func(number string) ([]byte, error)) error {
+	inv.ID = 1
+	inv.Number = "INV-000001"
+	var err error
+	inv.PDF, err = renderPDF(inv.Number)
+	return err
+}
+
+func (tr *testDBRepo) AllTaxRules() ([]models.TaxRule, error) {
+	return []models.TaxRule{}, nil
+}
+
+//GetTaxRulesForRoomByDate returns a city tax of 2.00 per person per night valid on any date
+func (tr *testDBRepo) GetTaxRulesForRoomByDate(roomID int, start, end time.Time) ([]models.TaxRule, error) {
+	return []models.TaxRule{{ID: 1, Name: "City tax", Kind: "per_person_night", Amount: 200,
+		StartDate: start, EndDate: end}}, nil
+}
+
+func (tr *testDBRepo) CreateTaxRule(rule *models.TaxRule) error {
+	rule.ID = 1
+	return nil
+}
+
+func (tr *testDBRepo) DeleteTaxRuleByID(id int) error {
+	return nil
+}
+
+//GetPromoCodeByCode returns SUMMER10, good for 10% off any stay, and FULL, which has no uses left
+func (tr *testDBRepo) GetPromoCodeByCode(code string) (models.PromoCode, error) {
+	p := models.PromoCode{ID: 1, Code: code, Kind: "percent", Amount: 1000,
+		ValidFrom: time.Now().AddDate(-1, 0, 0), ValidTo: time.Now().AddDate(1, 0, 0)}
+	switch code {
+	case "SUMMER10":
+		return p, nil
+	case "FULL":
+		p.ID, p.MaxUses, p.Uses = 2, 5, 5
+		return p, nil
+	}
+	return models.PromoCode{}, repository.ErrPromoCodeNotFound
+}
+
+//AllPromoCodeStats returns SUMMER10, limited to room 1 and used for 2 reservations
+func (tr *testDBRepo) AllPromoCodeStats() ([]models.PromoCodeStats, error) {
+	p, _ := tr.GetPromoCodeByCode("SUMMER10")
+	p.RoomIDs = []int{1}
+	p.StayStart = p.ValidFrom
+	p.MinNights, p.MaxUses, p.Uses = 2, 10, 2
+	return []models.PromoCodeStats{{PromoCode: p, Reservations: 2, Discount: 6000, Revenue: 56400}}, nil
+}
+
+func (tr *testDBRepo) CreatePromoCode(p *models.PromoCode) error {
+	p.ID = 3
+	return nil
+}
+
+func (tr *testDBRepo) DeletePromoCode(id int) error {
+	return nil
+}
+
+//AllExtras returns breakfast, parking with 3 spaces and a withdrawn airport transfer
+func (tr *testDBRepo) AllExtras() ([]models.Extra, error) {
+	return []models.Extra{
+		{ID: 1, Name: "Breakfast", Unit: "per_guest", Price: 1200, Active: true},
+		{ID: 2, Name: "Parking", Unit: "per_night", Price: 1500, Inventory: 3, Active: true},
+		{ID: 3, Name: "Airport transfer", Unit: "per_stay", Price: 4000},
+	}, nil
+}
+
+//ExtrasBookedByDate returns 2 parking spaces booked
+func (tr *testDBRepo) ExtrasBookedByDate(start, end time.Time) (map[int]int, error) {
+	return map[int]int{2: 2}, nil
+}
+
+func (tr *testDBRepo) CreateExtra(e *models.Extra) error {
+	e.ID = 4
+	return nil
+}
+
+func (tr *testDBRepo) SetExtraActive(id int, active bool) error {
+	return nil
+}
+
+func (tr *testDBRepo) CreateWaitlistEntry(e *models.WaitlistEntry) error {
+	e.ID = 1
+	return nil
+}
+
+//WaitingEntriesForRoom returns a guest waiting for any room from July 10th to 12th 2050
+func (tr *testDBRepo) WaitingEntriesForRoom(roomID int, start, end time.Time) ([]models.WaitlistEntry, error) {
+	return []models.WaitlistEntry{
+		{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Locale: "en", Status: "waiting",
+			StartDate: time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC)},
+	}, nil
+}
+
+//ExpiredWaitlistOffers returns an offer of room 1 which has expired
+func (tr *testDBRepo) ExpiredWaitlistOffers(now time.Time) ([]models.WaitlistEntry, error) {
+	return []models.WaitlistEntry{
+		{ID: 2, Status: "offered", OfferedRoomID: 1, ExpiresAt: now.Add(-time.Minute),
+			StartDate: time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC)},
+	}, nil
+}
+
+func (tr *testDBRepo) OfferWaitlistEntry(e *models.WaitlistEntry) error {
+	e.Status = "offered"
+	return nil
+}
+
+//GetWaitlistEntryByToken returns a live offer of room 1 for "valid", an expired one for "expired", and no entry
+//for any other token
+func (tr *testDBRepo) GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error) {
+	e := models.WaitlistEntry{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Status: "offered",
+		OfferedRoomID: 1, Token: token, ExpiresAt: time.Now().Add(time.Hour),
+		StartDate: time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC)}
+	switch token {
+	case "valid":
+		return e, nil
+	case "expired":
+		e.ExpiresAt = time.Now().Add(-time.Hour)
+		return e, nil
+	}
+	return models.WaitlistEntry{}, repository.ErrWaitlistEntryNotFound
+}
+
+func (tr *testDBRepo) UpdateWaitlistEntryStatus(id int, status string) error {
+	return nil
+}
+
+//HoldRoom holds any room but room 2, which is unavailable
+func (tr *testDBRepo) HoldRoom(hold *models.RoomRestriction, releaseID int) error {
+	if hold.RoomID == 2 {
+		return repository.ErrRoomUnavailable
+	}
+	hold.ID = 1
+	hold.RestrictionID = models.RestrictionHold
+	return nil
+}
+
+func (tr *testDBRepo) ReleaseHold(id int) error {
+	return nil
+}
+
+//ConvertHold converts hold 1, the others having expired
+func (tr *testDBRepo) ConvertHold(id, reservationID int) error {
+	if id != 1 {
+		return repository.ErrHoldExpired
+	}
+	return nil
+}
+
+func (tr *testDBRepo) DeleteExpiredHolds(now time.Time) (int, error) {
+	return 0, nil
+}
+
+//MoveReservation moves reservation 1 from room 1 for July 10th to 12th 2050, to any room but room 2, which is
+//unavailable, and to any dates but those in August 2050, when an extra of the reservation is sold out
+func (tr *testDBRepo) MoveReservation(move *models.ReservationMove) error {
+	if move.ReservationID != 1 {
+		return repository.ErrReservationNotFound
+	}
+	if move.ToRoomID == 2 {
+		return repository.ErrRoomUnavailable
+	}
+	if move.ToStart.Year() == 2050 && move.ToStart.Month() == time.August {
+		return repository.ErrExtraSoldOut
+	}
+	move.ID = 1
+	move.FromRoomID = 1
+	move.FromStart = time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC)
+	move.FromEnd = time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC)
+	return nil
+}
+
+func (tr *testDBRepo) MovesForReservation(reservationID int) ([]models.ReservationMove, error) {
+	return nil, nil
+}
+
+//CalendarVersion returns the version "1" for every month, or "2" for the calendar of property 2
+func (tr *testDBRepo) CalendarVersion(start, end time.Time, propertyID int) (string, error) {
+	if propertyID == 2 {
+		return "2", nil
+	}
+	return "1", nil
+}
+
+//SaveCalendarBlocks saves calendars edited at the version CalendarVersion returns, removing blocks of room 1. Room 2
+//is booked, so no block can be added to it.
+func (tr *testDBRepo) SaveCalendarBlocks(start, end time.Time, version string, add []models.RoomRestriction,
+	remove []int, propertyID int) ([]models.RoomRestriction, error) {
+	if current, _ := tr.CalendarVersion(start, end, propertyID); version != current {
+		return nil, repository.ErrCalendarChanged
+	}
+	for _, b := range add {
+		if b.RoomID == 2 {
+			return nil, repository.ErrRoomUnavailable
+		}
+	}
+	var removed []models.RoomRestriction
+	for _, id := range remove {
+		removed = append(removed, models.RoomRestriction{ID: id, RoomID: 1, StartDate: start, EndDate: start.AddDate(0, 0, 1)})
+	}
+	return removed, nil
+}
+
+//OccupancyByMonth returns two rooms for July 2050, one of them half sold
+func (tr *testDBRepo) OccupancyByMonth(start, end time.Time, propertyID int) ([]models.OccupancyStats, error) {
+	month := time.Date(2050, time.July, 1, 0, 0, 0, 0, time.UTC)
+	return []models.OccupancyStats{
+		{RoomID: 1, RoomName: "General's Quarters", Month: month, NightsAvailable: 31, NightsSold: 15, RoomRevenue: 150000},
+		{RoomID: 2, RoomName: "Major's Suite", Month: month, NightsAvailable: 26, NightsSold: 0},
+	}, nil
+}
+
+func (tr *testDBRepo) LeadTimeDistribution(start, end time.Time, propertyID int) ([]models.ReportBucket, error) {
+	return []models.ReportBucket{{Label: "Same day", Count: 1}, {Label: "1-7 days", Count: 4}}, nil
+}
+
+func (tr *testDBRepo) StayLengthDistribution(start, end time.Time, propertyID int) ([]models.ReportBucket, error) {
+	return []models.ReportBucket{{Label: "1 night", Count: 2}, {Label: "2 nights", Count: 3}}, nil
+}
+
+func (tr *testDBRepo) CancellationsByMonth(start, end time.Time, propertyID int) ([]models.CancellationStats, error) {
+	month := time.Date(2050, time.July, 1, 0, 0, 0, 0, time.UTC)
+	return []models.CancellationStats{{Month: month, Booked: 10, Cancelled: 2, LostRevenue: 40000}}, nil
+}
+
+//EachReservation has a new reservation in room 1 booked on June 1st and a processed one in room 2 booked on July 2nd,
+//both staying in July 2050
+func (tr *testDBRepo) EachReservation(filter models.ReservationFilter, fn func(res models.Reservation) error) error {
+	all := []models.Reservation{
+		{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", RoomID: 1,
+			CheckInDate:  time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+			CheckOutDate: time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC),
+			CreatedAt:    time.Date(2050, time.June, 1, 9, 30, 0, 0, time.UTC),
+			Guests:       2, RoomAmount: 20000, TotalAmount: 22000, Room: models.Room{ID: 1, RoomName: "General's Quarters"}},
+		{ID: 2, FirstName: "Jane", LastName: "Doe", Email: "jane@doe.com", RoomID: 2, Processed: 1,
+			CheckInDate:  time.Date(2050, time.July, 20, 0, 0, 0, 0, time.UTC),
+			CheckOutDate: time.Date(2050, time.July, 21, 0, 0, 0, 0, time.UTC),
+			CreatedAt:    time.Date(2050, time.July, 2, 18, 5, 0, 0, time.UTC),
+			Guests:       1, RoomAmount: 10000, TotalAmount: 10000, Room: models.Room{ID: 2, RoomName: "Major's Suite"}},
+	}
+	for _, rs := range all {
+		if filter.RoomID != 0 && rs.RoomID != filter.RoomID {
+			continue
+		}
+		if (filter.Status == models.ReservationStatusNew && rs.Processed != 0) ||
+			(filter.Status == models.ReservationStatusProcessed && rs.Processed != 1) {
+			continue
+		}
+		if (!filter.Start.IsZero() && !rs.CheckOutDate.After(filter.Start)) ||
+			(!filter.End.IsZero() && !rs.CheckInDate.Before(filter.End)) {
+			continue
+		}
+		if err := fn(rs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//SearchReservations pages through the reservations of EachReservation whose guest name contains the name or search
+func (tr *testDBRepo) SearchReservations(search models.ReservationSearch) ([]models.Reservation, int, error) {
+	var found []models.Reservation
+	err := tr.EachReservation(search.ReservationFilter, func(rs models.Reservation) error {
+		name := strings.ToLower(rs.FirstName + " " + rs.LastName)
+		if strings.Contains(name, strings.ToLower(search.Name)) && strings.Contains(name, strings.ToLower(search.Search)) {
+			found = append(found, rs)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	start := (search.Page - 1) * search.PerPage
+	if start >= len(found) {
+		return nil, len(found), nil
+	}
+	end := start + search.PerPage
+	if end > len(found) {
+		end = len(found)
+	}
+	return found[start:end], len(found), nil
+}
+
+//ImportReservations reports reservations in room 2 as conflicting
+func (tr *testDBRepo) ImportReservations(rs []models.Reservation, dryRun bool) ([]int, error) {
+	var conflicts []int
+	for i, res := range rs {
+		if res.RoomID == 2 {
+			conflicts = append(conflicts, i)
+		}
+	}
+	return conflicts, nil
+}
+
+func (tr *testDBRepo) CreateImportRun(run *models.ImportRun) error {
+	run.ID = 1
+	run.CreatedAt = time.Now()
+	return nil
+}
+
+//GetImportRunByID returns import 1 with one row which failed
+//GetImportRunByID returns an import with a failed row for id 1, and one whose failed rows are no longer kept for id 3
+func (tr *testDBRepo) GetImportRunByID(id int) (models.ImportRun, error) {
+	if id == 3 {
+		return models.ImportRun{ID: 3, FileName: "old.csv", Rows: 1, Failed: 1, CreatedAt: time.Now()}, nil
+	}
+	if id != 1 {
+		return models.ImportRun{}, repository.ErrImportRunNotFound
+	}
+	return models.ImportRun{ID: 1, FileName: "reservations.csv", Rows: 2, Imported: 1, Failed: 1,
+		Errors: []byte("Line,Error\n3,The room is not available for those dates\n"), CreatedAt: time.Now()}, nil
+}
+
+func (tr *testDBRepo) RecentImportRuns(limit int) ([]models.ImportRun, error) {
+	return []models.ImportRun{{ID: 1, FileName: "reservations.csv", Rows: 2, Imported: 1, Failed: 1,
+		CreatedAt: time.Now()}}, nil
+}
+
+//SearchGuests returns guest 1 and their duplicate guest 2
+func (tr *testDBRepo) SearchGuests(query string, propertyID, limit int) ([]models.Guest, error) {
+	return []models.Guest{
+		{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Tags: []string{models.GuestTagVIP}, Stays: 2,
+			LastStay: time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC)},
+		{ID: 2, FirstName: "John", LastName: "Smith", Email: "jsmith@work.com", Stays: 1,
+			LastStay: time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)},
+	}, nil
+}
+
+//GetGuestByID returns a VIP guest for id 1 and a guest without tags for id 2
+func (tr *testDBRepo) GetGuestByID(id int) (models.Guest, error) {
+	switch id {
+	case 1:
+		return models.Guest{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com", Phone: "555-1234",
+			Notes: "Likes the quiet room", Tags: []string{models.GuestTagVIP}}, nil
+	case 2:
+		return models.Guest{ID: 2, FirstName: "John", LastName: "Smith", Email: "jsmith@work.com"}, nil
+	}
+	return models.Guest{}, repository.ErrGuestNotFound
+}
+
+func (tr *testDBRepo) UpdateGuest(guest *models.Guest) error {
+	if guest.ID != 1 && guest.ID != 2 {
+		return repository.ErrGuestNotFound
+	}
+	return nil
+}
+
+//StaysForGuest returns a stay in 2050 and one in 2020, both at property 1
+func (tr *testDBRepo) StaysForGuest(guestID, propertyID int) ([]models.Reservation, error) {
+	if propertyID != 0 && propertyID != 1 {
+		return nil, nil
+	}
+	return []models.Reservation{
+		{ID: 1, FirstName: "John", LastName: "Smith", RoomID: 1, GuestID: guestID,
+			CheckInDate:  time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+			CheckOutDate: time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC),
+			Room:         models.Room{ID: 1, RoomName: "General's Quarters", PropertyID: 1}},
+		{ID: 3, FirstName: "John", LastName: "Smith", RoomID: 2, GuestID: guestID, Processed: 1,
+			CheckInDate:  time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC),
+			CheckOutDate: time.Date(2020, time.March, 4, 0, 0, 0, 0, time.UTC),
+			Room:         models.Room{ID: 2, RoomName: "Major's Suite", PropertyID: 1}},
+	}, nil
+}
+
+//PossibleDuplicateGuests returns guest 2 as a duplicate of guest 1
+func (tr *testDBRepo) PossibleDuplicateGuests(guest models.Guest, propertyID int) ([]models.Guest, error) {
+	if guest.ID != 1 {
+		return nil, nil
+	}
+	return []models.Guest{{ID: 2, FirstName: "John", LastName: "Smith", Email: "jsmith@work.com", Stays: 1}}, nil
+}
+
+//MergeGuests merges guests 1 and 2
+func (tr *testDBRepo) MergeGuests(keepID, mergeID int) error {
+	if (keepID != 1 && keepID != 2) || (mergeID != 1 && mergeID != 2) {
+		return repository.ErrGuestNotFound
+	}
+	return nil
+}
+
+func (tr *testDBRepo) CreateSentEmail(e *models.SentEmail) error {
+	e.ID = 1
+	return nil
+}
+
+//GuestDataByEmail returns a reservation with a payment and an invoice for john@smith.com, and nothing for other
+//addresses
+func (tr *testDBRepo) GuestDataByEmail(email string) (models.GuestData, error) {
+	data := models.GuestData{Email: strings.ToLower(email), Emails: []string{strings.ToLower(email)}}
+	if data.Email != "john@smith.com" {
+		return data, nil
+	}
+	data.Guests = []models.Guest{{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com",
+		Tags: []string{models.GuestTagVIP}, Stays: 1}}
+	data.Reservations = []models.Reservation{{ID: 1, FirstName: "John", LastName: "Smith", Email: "john@smith.com",
+		RoomID: 1, GuestID: 1, TotalAmount: 20000, Room: models.Room{ID: 1, RoomName: "General's Quarters"},
+		CheckInDate:  time.Date(2050, time.July, 10, 0, 0, 0, 0, time.UTC),
+		CheckOutDate: time.Date(2050, time.July, 12, 0, 0, 0, 0, time.UTC)}}
+	data.Payments = []models.Payment{{ID: 1, ReservationID: 1, Provider: "fake", Amount: 6000, Currency: "USD",
+		Status: "captured", Captured: 6000}}
+	data.Invoices = []models.Invoice{{ID: 1, ReservationID: 1, Number: "INV-000001", Total: 20000, Currency: "USD",
+		PDF: []byte("%PDF-1.4")}}
+	data.SentEmails = []models.SentEmail{{ID: 1, Recipient: "john@smith.com", Subject: "Reservation confirmation",
+		Status: models.EmailStatusSent}}
+	return data, nil
+}
+
+//EraseGuestData anonymises one reservation for john@smith.com
+func (tr *testDBRepo) EraseGuestData(email string, now time.Time) (int, error) {
+	if strings.ToLower(email) == "john@smith.com" {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+func (tr *testDBRepo) AnonymiseStaysBefore(cutoff, now time.Time) (int, error) {
+	return 0, nil
+}
+
+//CheckInReservation checks in reservation 1, moving it as MoveReservation does
+func (tr *testDBRepo) CheckInReservation(action *models.FrontDeskAction) error {
+	if action.Move != nil {
+		if err := tr.MoveReservation(action.Move); err != nil {
+			return err
+		}
+	}
+	if action.ReservationID != 1 {
+		return repository.ErrAlreadyCheckedIn
+	}
+	return nil
+}
+
+func (tr *testDBRepo) CheckOutReservation(action *models.FrontDeskAction) error {
+	if action.ReservationID != 4 {
+		return repository.ErrNotInHouse
+	}
+	return nil
+}
+
+//FrontDeskReservations returns John Smith arriving on the day, Jane Doe in house, and Jim Brown leaving on the day
+func (tr *testDBRepo) FrontDeskReservations(day time.Time, propertyID int) ([]models.Reservation, error) {
+	return []models.Reservation{
+		{ID: 1, FirstName: "John", LastName: "Smith", RoomID: 1, CheckInDate: day, CheckOutDate: day.AddDate(0, 0, 2),
+			Room: models.Room{ID: 1, RoomName: "General's Quarters"}},
+		{ID: 4, FirstName: "Jane", LastName: "Doe", RoomID: 2, CheckInDate: day.AddDate(0, 0, -1),
+			CheckOutDate: day.AddDate(0, 0, 1), CheckedInAt: day.Add(-10 * time.Hour), KeyNumber: "204",
+			Room: models.Room{ID: 2, RoomName: "Major's Suite"}},
+		{ID: 5, FirstName: "Jim", LastName: "Brown", RoomID: 3, CheckInDate: day.AddDate(0, 0, -3), CheckOutDate: day,
+			CheckedInAt: day.Add(-58 * time.Hour), KeyNumber: "301", Room: models.Room{ID: 3, RoomName: "Colonel's Room"}},
+	}, nil
+}
+
+//HousekeepingRooms returns General's Quarters dirty and Major's Suite out of order
+func (tr *testDBRepo) HousekeepingRooms(propertyID int) ([]models.HousekeepingRoom, error) {
+	return []models.HousekeepingRoom{
+		{Room: models.Room{ID: 1, RoomName: "General's Quarters"}, Status: models.HousekeepingDirty},
+		{Room: models.Room{ID: 2, RoomName: "Major's Suite"}, Status: models.HousekeepingOutOfOrder, BlockID: 1,
+			OutOfOrderUntil: time.Date(2050, time.July, 20, 0, 0, 0, 0, time.UTC)},
+	}, nil
+}
+
+//SetRoomHousekeepingStatus fails for rooms other than 1 and 2, and room 2 cannot be put out of order
+func (tr *testDBRepo) SetRoomHousekeepingStatus(roomID int, status string, until, now time.Time) error {
+	if roomID != 1 && roomID != 2 {
+		return repository.ErrRoomNotFound
+	}
+	if roomID == 2 && status == models.HousekeepingOutOfOrder {
+		return repository.ErrRoomUnavailable
+	}
+	return nil
+}
+
+//HousekeepingTasks returns a departure task for room 1 assigned to user 1 and a stayover task for room 2
+func (tr *testDBRepo) HousekeepingTasks(day time.Time, assignedTo, propertyID int) ([]models.HousekeepingTask, error) {
+	tasks := []models.HousekeepingTask{
+		{ID: 1, RoomID: 1, ReservationID: 5, Kind: models.TaskKindDeparture, TaskDate: day,
+			Status: models.TaskStatusOpen, AssignedTo: 1, AssigneeName: "Admin User",
+			Room: models.Room{ID: 1, RoomName: "General's Quarters"}},
+		{ID: 2, RoomID: 2, ReservationID: 4, Kind: models.TaskKindStayover, TaskDate: day,
+			Status: models.TaskStatusOpen, Room: models.Room{ID: 2, RoomName: "Major's Suite"}},
+	}
+	if assignedTo == 0 {
+		return tasks, nil
+	}
+	var mine []models.HousekeepingTask
+	for _, t := range tasks {
+		if t.AssignedTo == assignedTo {
+			mine = append(mine, t)
+		}
+	}
+	return mine, nil
+}
+
+func (tr *testDBRepo) GenerateHousekeepingTasks(day, now time.Time) (int, error) {
+	return 2, nil
+}
+
+//UpdateHousekeepingTask fails for tasks other than 1 and 2, which are tasks of rooms at property 1
+func (tr *testDBRepo) UpdateHousekeepingTask(task *models.HousekeepingTask, propertyID int) error {
+	if (task.ID != 1 && task.ID != 2) || (propertyID != 0 && propertyID != 1) {
+		return repository.ErrTaskNotFound
+	}
+	return nil
+}
+
+//AllStaff returns the admin user 1
+func (tr *testDBRepo) AllStaff(propertyID int) ([]models.User, error) {
+	return []models.User{{ID: 1, FirstName: "Admin", LastName: "User", Email: "admin@admin.com", AccessLevel: 3}}, nil
+}
+
+//SetRoomProperty fails for rooms other than 1, 2 and 3
+func (tr *testDBRepo) SetRoomProperty(roomID, propertyID int) error {
+	if roomID < 1 || roomID > 3 {
+		return repository.ErrRoomNotFound
+	}
+	return nil
+}
+
+//SetUserProperty fails for users other than 1 and 2
+func (tr *testDBRepo) SetUserProperty(userID, propertyID int) error {
+	if userID != 1 && userID != 2 {
+		return repository.ErrUserNotFound
+	}
+	return nil
+}
+
+//AllProperties returns Fort Smythe, and Harbour View served on harbourview.com
+func (tr *testDBRepo) AllProperties() ([]models.Property, error) {
+	return []models.Property{
+		{ID: 1, Slug: "fort-smythe", Name: "Fort Smythe"},
+		{ID: 2, Slug: "harbour-view", Name: "Harbour View", Hostname: "harbourview.com", EmailFrom: "stay@harbourview.com",
+			EmailFromName: "Harbour View", DepositPolicy: "first-night"},
+	}, nil
+}
+
+func (tr *testDBRepo) CreateProperty(p *models.Property) error {
+	p.ID = 3
+	return nil
+}
+
+//UpdateProperty fails for properties other than 1 and 2
+func (tr *testDBRepo) UpdateProperty(p *models.Property) error {
+	if p.ID != 1 && p.ID != 2 {
+		return repository.ErrPropertyNotFound
+	}
+	return nil
+}
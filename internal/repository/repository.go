@@ -1,29 +1,148 @@
 package repository
 
 import (
+	"errors"
+	"github.com/sunil206b/smart_booking/internal/i18n"
 	"github.com/sunil206b/smart_booking/internal/models"
 	"time"
 )
 
+var (
+	// ErrPromoCodeNotFound is returned when there is no promo code with the code given by a guest
+	ErrPromoCodeNotFound = errors.New("promo code not found")
+	// ErrPromoCodeUsedUp is returned when a reservation is made with a promo code which has no uses left
+	ErrPromoCodeUsedUp = errors.New("promo code used up")
+	// ErrExtraSoldOut is returned when a reservation is made with more of an extra than is left for its nights
+	ErrExtraSoldOut = errors.New("extra sold out")
+	// ErrWaitlistEntryNotFound is returned when there is no waitlist entry for a booking link, or it is no longer
+	// waiting for a room
+	ErrWaitlistEntryNotFound = errors.New("waitlist entry not found")
+	// ErrRoomUnavailable is returned when a room cannot be held, blocked or moved to because it is not available for
+	// the dates
+	ErrRoomUnavailable = errors.New("room unavailable")
+	// ErrHoldExpired is returned when a hold has expired or been released
+	ErrHoldExpired = errors.New("hold expired")
+	// ErrCalendarChanged is returned when the rooms calendar is saved after someone else changed it
+	ErrCalendarChanged = errors.New("calendar changed")
+	// ErrImportRunNotFound is returned when there is no import with the id given
+	ErrImportRunNotFound = errors.New("import run not found")
+	// ErrReservationNotFound is returned when there is no reservation with the id given
+	ErrReservationNotFound = errors.New("reservation not found")
+	// ErrGuestNotFound is returned when there is no guest with the id given
+	ErrGuestNotFound = errors.New("guest not found")
+	// ErrAlreadyCheckedIn is returned when checking in a reservation whose guest has already checked in
+	ErrAlreadyCheckedIn = errors.New("reservation already checked in")
+	// ErrNotInHouse is returned when checking out a reservation whose guest has not checked in or has already left
+	ErrNotInHouse = errors.New("reservation not in house")
+	// ErrRoomNotFound is returned when there is no room with the id given
+	ErrRoomNotFound = errors.New("room not found")
+	// ErrTaskNotFound is returned when there is no housekeeping task with the id given
+	ErrTaskNotFound = errors.New("housekeeping task not found")
+	// ErrPropertyNotFound is returned when there is no property with the id given
+	ErrPropertyNotFound = errors.New("property not found")
+	// ErrUserNotFound is returned when there is no user with the id given
+	ErrUserNotFound = errors.New("user not found")
+	// ErrPaymentNotFound is returned when there is no payment with the id or gateway reference given
+	ErrPaymentNotFound = errors.New("payment not found")
+)
+
 type DatabaseRepo interface {
 	AllUsers() bool
 
 	CreateReservation(res *models.Reservation) error
 	CreateRoomRestriction(r *models.RoomRestriction) error
-	SearchAvailabilityByDatesByRoom(start, end time.Time, roomID int) (bool, error)
-	SearchAllAvailableRooms(start, end time.Time) ([]models.Room, error)
+	SearchAvailabilityByDatesByRoom(start, end time.Time, roomID int) (bool, i18n.Message, error)
+	SearchAllAvailableRooms(start, end time.Time, propertyID int) ([]models.Room, []models.ExcludedRoom, error)
 	GetRoomByID(id int) (models.Room, error)
 	GetUserByID(id int) (models.User, error)
 	UpdateUser(user *models.User) error
 	Authenticate(email, testPass string) (int, string, error)
-	AllReservations() ([]models.Reservation, error)
-	AllNewReservations() ([]models.Reservation, error)
+	SearchReservations(search models.ReservationSearch) ([]models.Reservation, int, error)
+	EachReservation(filter models.ReservationFilter, fn func(res models.Reservation) error) error
+	ImportReservations(rs []models.Reservation, dryRun bool) ([]int, error)
+	CreateImportRun(run *models.ImportRun) error
+	GetImportRunByID(id int) (models.ImportRun, error)
+	RecentImportRuns(limit int) ([]models.ImportRun, error)
 	GetReservationByID(id int) (models.Reservation, error)
 	UpdateReservation(res *models.Reservation) error
 	DeleteReservation(id int) error
 	UpdateProcessedReservation(id, processed int) error
-	AllRooms() ([]models.Room, error)
+	AllRooms(propertyID int) ([]models.Room, error)
 	GetRestrictionsForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRestriction, error)
 	CreateBlockForRoom(id int, startDate time.Time) error
-	DeleteBlockByID(id int) error
+	DeleteBlockByID(id int) (models.RoomRestriction, error)
+	CreateBlocks(blocks []models.RoomRestriction) error
+	CalendarVersion(start, end time.Time, propertyID int) (string, error)
+	OccupancyByMonth(start, end time.Time, propertyID int) ([]models.OccupancyStats, error)
+	LeadTimeDistribution(start, end time.Time, propertyID int) ([]models.ReportBucket, error)
+	StayLengthDistribution(start, end time.Time, propertyID int) ([]models.ReportBucket, error)
+	CancellationsByMonth(start, end time.Time, propertyID int) ([]models.CancellationStats, error)
+	SaveCalendarBlocks(start, end time.Time, version string, add []models.RoomRestriction, remove []int, propertyID int) ([]models.RoomRestriction, error)
+	AllRoomRules() ([]models.RoomRule, error)
+	GetRulesForRoomByDate(roomID int, start, end time.Time) ([]models.RoomRule, error)
+	CreateRoomRule(rule *models.RoomRule) error
+	DeleteRoomRuleByID(id, propertyID int) error
+	AvailabilityCalendar(start, end time.Time, roomID int) ([]models.RoomCalendar, error)
+	AllExchangeRates() ([]models.ExchangeRate, error)
+	SaveExchangeRates(rates []models.ExchangeRate) error
+	DeleteExchangeRate(code string) error
+	CreatePayment(p *models.Payment) error
+	GetPaymentByID(id int) (models.Payment, error)
+	GetPaymentByReference(reference string) (models.Payment, error)
+	UpdatePayment(p *models.Payment) error
+	PaymentsForReservation(reservationID int) ([]models.Payment, error)
+	FolioItemsForReservation(reservationID int) ([]models.FolioItem, error)
+	CreateFolioItem(item *models.FolioItem) error
+	DeleteFolioItem(reservationID, id int) error
+	InvoicesForReservation(reservationID int) ([]models.Invoice, error)
+	GetInvoiceByID(id int) (models.Invoice, error)
+	CreateInvoice(inv *models.Invoice, renderPDF func(number string) ([]byte, error)) error
+	AllTaxRules() ([]models.TaxRule, error)
+	GetTaxRulesForRoomByDate(roomID int, start, end time.Time) ([]models.TaxRule, error)
+	CreateTaxRule(rule *models.TaxRule) error
+	DeleteTaxRuleByID(id int) error
+	GetPromoCodeByCode(code string) (models.PromoCode, error)
+	AllPromoCodeStats() ([]models.PromoCodeStats, error)
+	CreatePromoCode(p *models.PromoCode) error
+	DeletePromoCode(id int) error
+	AllExtras() ([]models.Extra, error)
+	ExtrasBookedByDate(start, end time.Time) (map[int]int, error)
+	CreateExtra(e *models.Extra) error
+	SetExtraActive(id int, active bool) error
+	CreateWaitlistEntry(e *models.WaitlistEntry) error
+	WaitingEntriesForRoom(roomID int, start, end time.Time) ([]models.WaitlistEntry, error)
+	ExpiredWaitlistOffers(now time.Time) ([]models.WaitlistEntry, error)
+	OfferWaitlistEntry(e *models.WaitlistEntry) error
+	GetWaitlistEntryByToken(token string) (models.WaitlistEntry, error)
+	UpdateWaitlistEntryStatus(id int, status string) error
+	HoldRoom(hold *models.RoomRestriction, releaseID int) error
+	ReleaseHold(id int) error
+	ConvertHold(id, reservationID int) error
+	DeleteExpiredHolds(now time.Time) (int, error)
+	MoveReservation(move *models.ReservationMove) error
+	MovesForReservation(reservationID int) ([]models.ReservationMove, error)
+	SearchGuests(query string, propertyID, limit int) ([]models.Guest, error)
+	GetGuestByID(id int) (models.Guest, error)
+	UpdateGuest(guest *models.Guest) error
+	StaysForGuest(guestID, propertyID int) ([]models.Reservation, error)
+	PossibleDuplicateGuests(guest models.Guest, propertyID int) ([]models.Guest, error)
+	MergeGuests(keepID, mergeID int) error
+	CreateSentEmail(e *models.SentEmail) error
+	GuestDataByEmail(email string) (models.GuestData, error)
+	EraseGuestData(email string, now time.Time) (int, error)
+	AnonymiseStaysBefore(cutoff, now time.Time) (int, error)
+	CheckInReservation(action *models.FrontDeskAction) error
+	CheckOutReservation(action *models.FrontDeskAction) error
+	FrontDeskReservations(day time.Time, propertyID int) ([]models.Reservation, error)
+	HousekeepingRooms(propertyID int) ([]models.HousekeepingRoom, error)
+	SetRoomHousekeepingStatus(roomID int, status string, until, now time.Time) error
+	HousekeepingTasks(day time.Time, assignedTo, propertyID int) ([]models.HousekeepingTask, error)
+	GenerateHousekeepingTasks(day, now time.Time) (int, error)
+	UpdateHousekeepingTask(task *models.HousekeepingTask, propertyID int) error
+	AllStaff(propertyID int) ([]models.User, error)
+	AllProperties() ([]models.Property, error)
+	CreateProperty(p *models.Property) error
+	UpdateProperty(p *models.Property) error
+	SetRoomProperty(roomID, propertyID int) error
+	SetUserProperty(userID, propertyID int) error
 }
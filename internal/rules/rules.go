@@ -0,0 +1,72 @@
+package rules
+
+import (
+	"github.com/sunil206b/smart_booking/internal/i18n"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"time"
+)
+
+// Check evaluates the stay from start to end against the given room rules and returns the
+// reason the stay is not allowed, or an empty message if every rule is satisfied.
+// Length of stay, closed to arrival and advance booking rules apply to the arrival date,
+// closed to departure rules apply to the departure date.
+func Check(roomRules []models.RoomRule, start, end, today time.Time) i18n.Message {
+	start = truncate(start)
+	end = truncate(end)
+	today = truncate(today)
+
+	nights := Nights(start, end)
+	daysAhead := Nights(today, start)
+
+	for _, rule := range roomRules {
+		if covers(rule, start) {
+			if rule.ClosedToArrival {
+				return i18n.NewMessage("rules.closed_to_arrival", start)
+			}
+			if rule.MinStay > 0 && nights < rule.MinStay {
+				return i18n.NewMessage("rules.min_stay", rule.MinStay, start)
+			}
+			if rule.MaxStay > 0 && nights > rule.MaxStay {
+				return i18n.NewMessage("rules.max_stay", rule.MaxStay, start)
+			}
+			if rule.MinAdvanceDays > 0 && daysAhead < rule.MinAdvanceDays {
+				return i18n.NewMessage("rules.min_advance", rule.MinAdvanceDays)
+			}
+			if rule.MaxAdvanceDays > 0 && daysAhead > rule.MaxAdvanceDays {
+				return i18n.NewMessage("rules.max_advance", rule.MaxAdvanceDays)
+			}
+		}
+		if covers(rule, end) && rule.ClosedToDeparture {
+			return i18n.NewMessage("rules.closed_to_departure", end)
+		}
+	}
+	return i18n.Message{}
+}
+
+// ClosedToArrival returns true if guests cannot arrive on the night, either because a rule closes it to
+// arrivals or because it falls outside the advance booking window
+func ClosedToArrival(night models.CalendarNight, today time.Time) bool {
+	if night.ClosedToArrival {
+		return true
+	}
+	daysAhead := Nights(today, night.Date)
+	if night.MinAdvanceDays > 0 && daysAhead < night.MinAdvanceDays {
+		return true
+	}
+	return night.MaxAdvanceDays > 0 && daysAhead > night.MaxAdvanceDays
+}
+
+// Nights returns the number of nights between start and end
+func Nights(start, end time.Time) int {
+	return int(truncate(end).Sub(truncate(start)).Hours() / 24)
+}
+
+// covers returns true if the day falls within the rule's date range, both ends inclusive
+func covers(rule models.RoomRule, day time.Time) bool {
+	return !day.Before(truncate(rule.StartDate)) && !day.After(truncate(rule.EndDate))
+}
+
+func truncate(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,92 @@
+package rules
+
+import (
+	"github.com/sunil206b/smart_booking/internal/models"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+var today = date(2021, time.July, 1)
+
+var checkTests = []struct {
+	name      string
+	rule      models.RoomRule
+	start     time.Time
+	end       time.Time
+	expReason bool
+}{
+	{"no restriction", models.RoomRule{}, date(2021, time.July, 10), date(2021, time.July, 12), false},
+	{"min stay met", models.RoomRule{MinStay: 2}, date(2021, time.July, 10), date(2021, time.July, 12), false},
+	{"min stay not met", models.RoomRule{MinStay: 3}, date(2021, time.July, 10), date(2021, time.July, 12), true},
+	{"max stay exceeded", models.RoomRule{MaxStay: 1}, date(2021, time.July, 10), date(2021, time.July, 12), true},
+	{"closed to arrival", models.RoomRule{ClosedToArrival: true}, date(2021, time.July, 10), date(2021, time.July, 12), true},
+	{"closed to departure", models.RoomRule{ClosedToDeparture: true}, date(2021, time.July, 10), date(2021, time.July, 12), true},
+	{"too close to arrival", models.RoomRule{MinAdvanceDays: 14}, date(2021, time.July, 10), date(2021, time.July, 12), true},
+	{"too far in advance", models.RoomRule{MaxAdvanceDays: 5}, date(2021, time.July, 10), date(2021, time.July, 12), true},
+	{"within advance window", models.RoomRule{MinAdvanceDays: 5, MaxAdvanceDays: 30}, date(2021, time.July, 10), date(2021, time.July, 12), false},
+}
+
+func TestCheck(t *testing.T) {
+	for _, e := range checkTests {
+		rule := e.rule
+		rule.StartDate = date(2021, time.July, 10)
+		rule.EndDate = date(2021, time.July, 12)
+
+		reason := Check([]models.RoomRule{rule}, e.start, e.end, today)
+		if e.expReason && reason.IsZero() {
+			t.Errorf("for %s, expected a reason but got none", e.name)
+		}
+		if !e.expReason && !reason.IsZero() {
+			t.Errorf("for %s, expected no reason but got %q", e.name, reason.Key)
+		}
+	}
+}
+
+func TestCheck_OutsideRange(t *testing.T) {
+	rule := models.RoomRule{
+		StartDate:       date(2021, time.August, 1),
+		EndDate:         date(2021, time.August, 31),
+		ClosedToArrival: true,
+		MinStay:         7,
+	}
+	reason := Check([]models.RoomRule{rule}, date(2021, time.July, 10), date(2021, time.July, 12), today)
+	if !reason.IsZero() {
+		t.Errorf("rule outside of the stay should not apply, but got %q", reason.Key)
+	}
+
+	// departure inside the range only triggers closed to departure
+	rule.ClosedToDeparture = true
+	reason = Check([]models.RoomRule{rule}, date(2021, time.July, 30), date(2021, time.August, 1), today)
+	if reason.IsZero() {
+		t.Error("departure inside a closed to departure range should not be allowed")
+	}
+}
+
+func TestNights(t *testing.T) {
+	n := Nights(date(2021, time.July, 10), date(2021, time.July, 13))
+	if n != 3 {
+		t.Errorf("expected 3 nights, but got %d", n)
+	}
+}
+
+func TestClosedToArrival(t *testing.T) {
+	night := models.CalendarNight{Date: date(2021, time.July, 10)}
+	if ClosedToArrival(night, today) {
+		t.Error("night without rules should be open to arrival")
+	}
+
+	night.MinAdvanceDays = 10
+	if !ClosedToArrival(night, today) {
+		t.Error("night inside the minimum advance period should be closed to arrival")
+	}
+
+	night.MinAdvanceDays = 0
+	night.MaxAdvanceDays = 5
+	if !ClosedToArrival(night, today) {
+		t.Error("night beyond the maximum advance period should be closed to arrival")
+	}
+}
@@ -0,0 +1,81 @@
+package waitlist
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"time"
+)
+
+// Status is where a waitlist entry is in its life
+type Status string
+
+const (
+	// StatusWaiting is a guest waiting for a room to free up
+	StatusWaiting Status = "waiting"
+	// StatusOffered is a guest who has been sent a booking link which has not been used or expired yet
+	StatusOffered Status = "offered"
+	// StatusAccepted is a guest who booked the room offered to them with their booking link
+	StatusAccepted Status = "accepted"
+	// StatusExpired is a guest whose booking link expired before they used it
+	StatusExpired Status = "expired"
+)
+
+// DefaultOfferTTL is how long a booking link sent to a waitlisted guest works for
+const DefaultOfferTTL = 24 * time.Hour
+
+// NewToken returns a random token for the booking link sent to a waitlisted guest
+func NewToken() (string, error) {
+	b := make([]byte, 24)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Wants reports whether the guest of the entry would take the room
+func Wants(e models.WaitlistEntry, roomID int) bool {
+	return e.RoomID == 0 || e.RoomID == roomID
+}
+
+// Overlaps reports whether the stay of the entry shares a night with the stay from start to end
+func Overlaps(e models.WaitlistEntry, start, end time.Time) bool {
+	return e.StartDate.Before(end) && start.Before(e.EndDate)
+}
+
+// Expired reports whether the booking link sent for the entry no longer works
+func Expired(e models.WaitlistEntry, now time.Time) bool {
+	return Status(e.Status) == StatusOffered && !now.Before(e.ExpiresAt)
+}
+
+// Next returns the waiting entries the room freed from start to end should be offered to, taking them in the order
+// given. An entry is offered the room if it wants it, its stay shares a night with the nights freed, it does not share
+// a night with an entry offered the room before it, and available says the room is free for its whole stay.
+func Next(entries []models.WaitlistEntry, roomID int, start, end time.Time,
+	available func(e models.WaitlistEntry) (bool, error)) ([]models.WaitlistEntry, error) {
+	var offers []models.WaitlistEntry
+	for _, e := range entries {
+		if Status(e.Status) != StatusWaiting || !Wants(e, roomID) || !Overlaps(e, start, end) {
+			continue
+		}
+		taken := false
+		for _, o := range offers {
+			if Overlaps(e, o.StartDate, o.EndDate) {
+				taken = true
+				break
+			}
+		}
+		if taken {
+			continue
+		}
+		ok, err := available(e)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			offers = append(offers, e)
+		}
+	}
+	return offers, nil
+}
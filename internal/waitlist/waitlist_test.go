@@ -0,0 +1,80 @@
+package waitlist
+
+import (
+	"errors"
+	"github.com/sunil206b/smart_booking/internal/models"
+	"testing"
+	"time"
+)
+
+func date(y int, m time.Month, d int) time.Time {
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func entry(id, roomID int, start, end time.Time) models.WaitlistEntry {
+	return models.WaitlistEntry{ID: id, RoomID: roomID, StartDate: start, EndDate: end, Status: string(StatusWaiting)}
+}
+
+func TestNext(t *testing.T) {
+	entries := []models.WaitlistEntry{
+		entry(1, 2, date(2021, time.July, 10), date(2021, time.July, 12)),
+		entry(2, 0, date(2021, time.July, 10), date(2021, time.July, 12)),
+		entry(3, 1, date(2021, time.July, 11), date(2021, time.July, 13)),
+		entry(4, 1, date(2021, time.July, 12), date(2021, time.July, 14)),
+		entry(5, 1, date(2021, time.July, 20), date(2021, time.July, 22)),
+		entry(6, 1, date(2021, time.July, 14), date(2021, time.July, 16)),
+	}
+	entries[5].Status = string(StatusOffered)
+
+	// room 1 is free from the 10th to the 14th, except for the stay of entry 6 which is not
+	available := func(e models.WaitlistEntry) (bool, error) {
+		return !e.EndDate.After(date(2021, time.July, 14)), nil
+	}
+	offers, err := Next(entries, 1, date(2021, time.July, 10), date(2021, time.July, 14), available)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []int
+	for _, o := range offers {
+		ids = append(ids, o.ID)
+	}
+	if len(ids) != 2 || ids[0] != 2 || ids[1] != 4 {
+		t.Errorf("expected entries 2 and 4 to be offered the room, got %v", ids)
+	}
+}
+
+func TestNext_Error(t *testing.T) {
+	entries := []models.WaitlistEntry{entry(1, 0, date(2021, time.July, 10), date(2021, time.July, 12))}
+	_, err := Next(entries, 1, date(2021, time.July, 10), date(2021, time.July, 12), func(e models.WaitlistEntry) (bool, error) {
+		return false, errors.New("database is down")
+	})
+	if err == nil {
+		t.Error("expected the error checking availability to be returned")
+	}
+}
+
+func TestExpired(t *testing.T) {
+	now := date(2021, time.July, 1)
+	e := models.WaitlistEntry{Status: string(StatusOffered), ExpiresAt: now.Add(time.Hour)}
+	if Expired(e, now) {
+		t.Error("expected an offer expiring in an hour to still work")
+	}
+	if !Expired(e, now.Add(time.Hour)) {
+		t.Error("expected an offer to expire at its expiry time")
+	}
+	e.Status = string(StatusAccepted)
+	if Expired(e, now.Add(2*time.Hour)) {
+		t.Error("expected an accepted offer not to expire")
+	}
+}
+
+func TestNewToken(t *testing.T) {
+	a, err := NewToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := NewToken()
+	if len(a) != 48 || a == b {
+		t.Errorf("expected two different tokens of 48 characters, got %q and %q", a, b)
+	}
+}
@@ -18,6 +18,16 @@ function bookRoom(roomId) {
                 showOnFocus: true,
                 minDate: new Date(),
             })
+            fetch('/availability-calendar-json?room_id=' + roomId)
+                .then(response => response.json())
+                .then(data => {
+                    if (!data.ok || data.rooms.length === 0) {
+                        return;
+                    }
+                    rp.setOptions({
+                        datesDisabled: data.rooms[0].nights.filter(n => !n.available).map(n => n.date),
+                    })
+                })
         },
         didOpen: () => {
             document.getElementById('check_in_date').removeAttribute('disabled');
@@ -49,7 +59,7 @@ function bookRoom(roomId) {
                     })
                 } else {
                     attention.error({
-                        msg: "Not Available"
+                        msg: data.message || "Not Available"
                     })
                 }
             })